@@ -29,6 +29,12 @@ func main() {
 		panic(err)
 	}
 
+	// Start proxy
+	err = app.ProxyServer.Start(ctx)
+	if err != nil {
+		panic(err)
+	}
+
 	app.StartWorker(ctx)
 
 	log.Println("Application running. Press Ctrl+C to stop.")
@@ -13,6 +13,7 @@ const (
 	ErrorTypeAuth       ErrorType = "AUTHENTICATION"
 	ErrorTypeForbidden  ErrorType = "FORBIDDEN"
 	ErrorTypeBadRequest ErrorType = "BAD_REQUEST"
+	ErrorTypeConflict   ErrorType = "CONFLICT"
 )
 
 type AppError struct {
@@ -128,6 +129,8 @@ func getHTTPCode(errType ErrorType) int {
 		return http.StatusForbidden
 	case ErrorTypeBadRequest:
 		return http.StatusBadRequest
+	case ErrorTypeConflict:
+		return http.StatusConflict
 	default:
 		return http.StatusInternalServerError
 	}
@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"github.com/RajVerma97/golang-vercel/backend/internal/api/webhooks"
 	"github.com/RajVerma97/golang-vercel/backend/internal/helpers"
 	"github.com/RajVerma97/golang-vercel/backend/internal/services"
 )
@@ -9,6 +10,7 @@ type Handlers struct {
 	BuildHandler      *BuildHandler
 	DeploymentHandler *DeploymentHandler
 	WebhookHandler    *WebhookHandler
+	ProjectHandler    *ProjectHandler
 }
 
 func NewHandlers(services *services.Services) *Handlers {
@@ -18,11 +20,20 @@ func NewHandlers(services *services.Services) *Handlers {
 	deploymentHandler := NewDeploymentHandler(&DeploymentHandlerConfig{
 		services: services,
 	})
-	webhookHandler := NewWebhookHandler(services, helpers.GetEnv("GITHUB_WEBHOOK_SECRET", ""))
+	projectHandler := NewProjectHandler(&ProjectHandlerConfig{
+		services: services,
+	})
+	webhookHandler := NewWebhookHandler(services, webhooks.NewProviders(map[string]string{
+		webhooks.ProviderGitHub:    helpers.GetEnv("GITHUB_WEBHOOK_SECRET", ""),
+		webhooks.ProviderGitLab:    helpers.GetEnv("GITLAB_WEBHOOK_TOKEN", ""),
+		webhooks.ProviderGitea:     helpers.GetEnv("GITEA_WEBHOOK_SECRET", ""),
+		webhooks.ProviderBitbucket: helpers.GetEnv("BITBUCKET_WEBHOOK_SECRET", ""),
+	}))
 
 	return &Handlers{
 		BuildHandler:      buildHandler,
 		DeploymentHandler: deploymentHandler,
 		WebhookHandler:    webhookHandler,
+		ProjectHandler:    projectHandler,
 	}
 }
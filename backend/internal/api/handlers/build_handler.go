@@ -1,6 +1,11 @@
 package handlers
 
 import (
+	"net/http"
+	"strconv"
+
+	"github.com/RajVerma97/golang-vercel/backend/internal/api/errors"
+	"github.com/RajVerma97/golang-vercel/backend/internal/dto"
 	"github.com/RajVerma97/golang-vercel/backend/internal/services"
 	"github.com/gin-gonic/gin"
 )
@@ -19,3 +24,39 @@ func NewBuildHandler(config *BuildHandlerConfig) *BuildHandler {
 func (h *BuildHandler) HandleBuild(c *gin.Context) {
 
 }
+
+func (h *BuildHandler) HandleGetBuild(c *gin.Context) {
+	build, ok := h.loadBuild(c)
+	if !ok {
+		return
+	}
+	SuccessResponse(c, build)
+}
+
+// HandleGetBuildLogs serves the logs as plain text so they can be linked to directly
+func (h *BuildHandler) HandleGetBuildLogs(c *gin.Context) {
+	build, ok := h.loadBuild(c)
+	if !ok {
+		return
+	}
+	c.String(http.StatusOK, build.Logs)
+}
+
+// loadBuild resolves the :id path param, writing the error response when it fails
+func (h *BuildHandler) loadBuild(c *gin.Context) (*dto.Build, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		ErrorResponse(c, errors.NewBadRequestError("Invalid build id"))
+		return nil, false
+	}
+	build, err := h.services.RedisService.GetBuild(c.Request.Context(), id)
+	if err != nil {
+		ErrorResponse(c, errors.NewInternalError(err))
+		return nil, false
+	}
+	if build == nil {
+		ErrorResponse(c, errors.NewNotFoundError("Build not found"))
+		return nil, false
+	}
+	return build, true
+}
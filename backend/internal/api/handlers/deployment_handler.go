@@ -44,11 +44,30 @@ func (h *DeploymentHandler) HandleDeployment(c *gin.Context) {
 
 	logger.Debug("", zap.Any("request", request))
 
+	if request.ProjectID != 0 {
+		project, err := h.services.ProjectService.Get(c.Request.Context(), request.ProjectID)
+		if err != nil {
+			ErrorResponse(c, errors.NewInternalError(err))
+			return
+		}
+		if project == nil {
+			ErrorResponse(c, errors.NewNotFoundError("Project not found"))
+			return
+		}
+		// the project's credentials, env vars and aliases only ever go to its own repository
+		if !services.SameRepository(project.RepoUrl, request.RepoURL) {
+			ErrorResponse(c, errors.NewValidationError(map[string][]string{"repo_url": {"Does not match the project's repository."}}))
+			return
+		}
+	}
+
 	now := time.Now()
 	err := h.services.RedisService.EnqueueBuild(c.Request.Context(), &dto.Build{
+		ProjectID:  request.ProjectID,
 		RepoUrl:    request.RepoURL,
 		Branch:     request.Branch,
 		CommitHash: request.CommitHash,
+		Trigger:    constants.BuildTriggerAPI,
 		Status:     constants.BuildStatusPending,
 		CreatedAt:  now,
 		UpdatedAt:  now,
@@ -0,0 +1,40 @@
+package handlers
+
+import (
+	"context"
+	"net"
+	"os"
+	"strconv"
+	"testing"
+
+	redis_client "github.com/RajVerma97/golang-vercel/backend/internal/client/redis"
+	"github.com/RajVerma97/golang-vercel/backend/internal/config"
+	"github.com/RajVerma97/golang-vercel/backend/internal/logger"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+)
+
+func TestMain(m *testing.M) {
+	if err := logger.Init("test"); err != nil {
+		panic(err)
+	}
+	gin.SetMode(gin.TestMode)
+	os.Exit(m.Run())
+}
+
+// newTestRedis connects to an in-memory redis that lives as long as the test
+func newTestRedis(t *testing.T) *redis_client.RedisClient {
+	t.Helper()
+	server := miniredis.RunT(t)
+	host, port, err := net.SplitHostPort(server.Addr())
+	if err != nil {
+		t.Fatal(err)
+	}
+	portNumber, _ := strconv.Atoi(port)
+	client, err := redis_client.NewRedisClient(context.Background(), &config.RedisConfig{Host: host, Port: portNumber})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { client.Close() })
+	return client
+}
@@ -0,0 +1,461 @@
+package handlers
+
+import (
+	stdErrors "errors"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/RajVerma97/golang-vercel/backend/internal/api/errors"
+	"github.com/RajVerma97/golang-vercel/backend/internal/api/requests"
+	"github.com/RajVerma97/golang-vercel/backend/internal/constants"
+	"github.com/RajVerma97/golang-vercel/backend/internal/dto"
+	"github.com/RajVerma97/golang-vercel/backend/internal/encryption"
+	"github.com/RajVerma97/golang-vercel/backend/internal/logger"
+	"github.com/RajVerma97/golang-vercel/backend/internal/services"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+type ProjectHandlerConfig struct {
+	services *services.Services
+}
+type ProjectHandler struct {
+	services *services.Services
+}
+
+func NewProjectHandler(config *ProjectHandlerConfig) *ProjectHandler {
+	return &ProjectHandler{services: config.services}
+}
+
+func (h *ProjectHandler) HandleCreateProject(c *gin.Context) {
+	var request requests.CreateProjectRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		ErrorResponse(c, errors.NewBadRequestError("Invalid Request"))
+		return
+	}
+	if err := request.Validate(); err != nil {
+		ErrorResponse(c, err)
+		return
+	}
+
+	var options dto.BuildOptions
+	if request.Build != nil {
+		options = buildOptions(request.Build)
+	}
+	var test dto.TestOptions
+	if request.Test != nil {
+		test = testOptions(request.Test)
+	}
+	// the race detector needs the cgo builder image
+	if !h.goVersionAvailable(c, request.GoVersion, options.CGO || test.Race) {
+		return
+	}
+	rootDirectory, mainPackage := repoPath(request.RootDirectory), repoPath(request.MainPackage)
+	existing, err := h.services.ProjectService.ListByRepoURL(c.Request.Context(), request.RepoURL)
+	if err != nil {
+		ErrorResponse(c, errors.NewInternalError(err))
+		return
+	}
+	// a repository can hold several apps, but only one project per app
+	for _, project := range existing {
+		if project.RootDirectory == rootDirectory && project.MainPackage == mainPackage {
+			ErrorResponse(c, errors.NewValidationErrorWithMetadata("PROJECT_EXISTS", "A project already exists for this app of the repository", project))
+			return
+		}
+	}
+
+	project := &dto.Project{
+		Name:             request.Name,
+		RepoUrl:          request.RepoURL,
+		ProductionBranch: request.ProductionBranch,
+		ResourceClass:    constants.ResourceClass(request.ResourceClass),
+		BuildProfile:     constants.BuildProfile(request.BuildProfile),
+		Submodules:       request.Submodules,
+		LFS:              request.LFS,
+		RootDirectory:    rootDirectory,
+		MainPackage:      mainPackage,
+		WatchPaths:       repoPaths(request.WatchPaths),
+		GoVersion:        request.GoVersion,
+		Build:            options,
+		Test:             test,
+		Builder:          constants.BuilderAuto,
+		Dockerfile:       repoPath(request.Dockerfile),
+	}
+	if request.Modules != nil {
+		project.Modules = goModules(request.Modules)
+	}
+	if request.Egress != nil {
+		project.Egress = egressPolicy(request.Egress)
+	}
+	if request.Builder != "" {
+		project.Builder = constants.Builder(request.Builder)
+	}
+	project.Timeouts = phaseTimeouts(request.Timeouts)
+	if err := h.services.ProjectService.Create(c.Request.Context(), project); err != nil {
+		ErrorResponse(c, errors.NewInternalError(err))
+		return
+	}
+	SuccessResponse(c, project)
+}
+
+func (h *ProjectHandler) HandleGetProject(c *gin.Context) {
+	project, ok := h.loadProject(c)
+	if !ok {
+		return
+	}
+	SuccessResponse(c, project)
+}
+
+func (h *ProjectHandler) HandleUpdateProject(c *gin.Context) {
+	project, ok := h.loadProject(c)
+	if !ok {
+		return
+	}
+	var request requests.UpdateProjectRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		ErrorResponse(c, errors.NewBadRequestError("Invalid Request"))
+		return
+	}
+	if err := request.Validate(); err != nil {
+		ErrorResponse(c, err)
+		return
+	}
+
+	applyProjectUpdate(project, &request)
+	if (request.GoVersion != nil || request.Build != nil || request.Test != nil) && !h.goVersionAvailable(c, project.GoVersion, project.Build.CGO || project.Test.Race) {
+		return
+	}
+	// applied again to the stored project, so a deployment promoted meanwhile is not reverted
+	project, err := h.services.ProjectService.Update(c.Request.Context(), project.ID, func(stored *dto.Project) {
+		applyProjectUpdate(stored, &request)
+	})
+	if err != nil {
+		ErrorResponse(c, errors.NewInternalError(err))
+		return
+	}
+	SuccessResponse(c, project)
+}
+
+// applyProjectUpdate sets the fields the request has on project
+func applyProjectUpdate(project *dto.Project, request *requests.UpdateProjectRequest) {
+	if request.ProductionBranch != nil {
+		project.ProductionBranch = *request.ProductionBranch
+	}
+	if request.ResourceClass != nil {
+		project.ResourceClass = constants.ResourceClass(*request.ResourceClass)
+	}
+	if request.BuildProfile != nil {
+		project.BuildProfile = constants.BuildProfile(*request.BuildProfile)
+	}
+	if request.Egress != nil {
+		// takes effect with the next deployment
+		project.Egress = egressPolicy(request.Egress)
+	}
+	if request.Submodules != nil {
+		project.Submodules = *request.Submodules
+	}
+	if request.LFS != nil {
+		project.LFS = *request.LFS
+	}
+	if request.RootDirectory != nil {
+		project.RootDirectory = repoPath(*request.RootDirectory)
+	}
+	if request.MainPackage != nil {
+		project.MainPackage = repoPath(*request.MainPackage)
+	}
+	if request.WatchPaths != nil {
+		// replaces the list, an empty list only watches the root directory
+		project.WatchPaths = repoPaths(request.WatchPaths)
+	}
+	if request.GoVersion != nil {
+		project.GoVersion = *request.GoVersion
+	}
+	if request.Build != nil {
+		// replaces all build options
+		project.Build = buildOptions(request.Build)
+	}
+	if request.Modules != nil {
+		// replaces all module settings
+		project.Modules = goModules(request.Modules)
+	}
+	if request.Test != nil {
+		project.Test = testOptions(request.Test)
+	}
+	if request.Builder != nil {
+		project.Builder = constants.Builder(*request.Builder)
+	}
+	if request.Dockerfile != nil {
+		project.Dockerfile = repoPath(*request.Dockerfile)
+	}
+	if request.Timeouts != nil {
+		// replaces all overrides, an empty object goes back to the defaults
+		project.Timeouts = phaseTimeouts(request.Timeouts)
+	}
+}
+
+func (h *ProjectHandler) HandleRollback(c *gin.Context) {
+	project, ok := h.loadProject(c)
+	if !ok {
+		return
+	}
+
+	// the body is optional, without it we roll back to the previous production deployment
+	var request requests.RollbackRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&request); err != nil {
+			ErrorResponse(c, errors.NewBadRequestError("Invalid Request"))
+			return
+		}
+	}
+
+	deployment, err := h.services.ReleaseService.Rollback(c.Request.Context(), project, request.DeploymentID)
+	if err != nil {
+		switch {
+		case stdErrors.Is(err, services.ErrDeploymentNotFound):
+			ErrorResponse(c, errors.NewNotFoundError("Deployment not found"))
+		case stdErrors.Is(err, services.ErrNoRollbackTarget), stdErrors.Is(err, services.ErrInvalidRollback):
+			ErrorResponse(c, errors.NewBadRequestError(err.Error()))
+		default:
+			logger.Error("failed to roll back", err, zap.Uint64("project_id", project.ID))
+			ErrorResponse(c, errors.NewInternalError(err))
+		}
+		return
+	}
+	SuccessResponse(c, deployment)
+}
+
+func (h *ProjectHandler) HandleListEnv(c *gin.Context) {
+	project, ok := h.loadProject(c)
+	if !ok {
+		return
+	}
+	envVars, err := h.services.EnvService.List(c.Request.Context(), project.ID)
+	if err != nil {
+		ErrorResponse(c, errors.NewInternalError(err))
+		return
+	}
+	SuccessResponse(c, envVars)
+}
+
+func (h *ProjectHandler) HandleCreateEnv(c *gin.Context) {
+	project, ok := h.loadProject(c)
+	if !ok {
+		return
+	}
+	var request requests.CreateEnvVarRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		ErrorResponse(c, errors.NewBadRequestError("Invalid Request"))
+		return
+	}
+	if err := request.Validate(); err != nil {
+		ErrorResponse(c, err)
+		return
+	}
+
+	envVar := &dto.EnvVar{
+		Key:          request.Key,
+		Value:        request.Value,
+		Secret:       request.Secret,
+		Environments: request.Environments,
+		Targets:      request.Targets,
+	}
+	if err := h.services.EnvService.Create(c.Request.Context(), project.ID, envVar); err != nil {
+		switch {
+		case stdErrors.Is(err, services.ErrEnvVarConflict):
+			ErrorResponse(c, errors.NewBadRequestError(err.Error()))
+		case stdErrors.Is(err, encryption.ErrKeyNotConfigured):
+			ErrorResponse(c, errors.NewError(errors.ErrorTypeInternal, "ENCRYPTION_NOT_CONFIGURED", "ENV_ENCRYPTION_KEY must be set to store env vars"))
+		default:
+			ErrorResponse(c, errors.NewInternalError(err))
+		}
+		return
+	}
+	SuccessResponse(c, envVar)
+}
+
+func (h *ProjectHandler) HandleDeleteEnv(c *gin.Context) {
+	project, ok := h.loadProject(c)
+	if !ok {
+		return
+	}
+	id, err := strconv.ParseUint(c.Param("env_id"), 10, 64)
+	if err != nil {
+		ErrorResponse(c, errors.NewBadRequestError("Invalid env var id"))
+		return
+	}
+	if err := h.services.EnvService.Delete(c.Request.Context(), project.ID, id); err != nil {
+		if stdErrors.Is(err, services.ErrEnvVarNotFound) {
+			ErrorResponse(c, errors.NewNotFoundError("Environment variable not found"))
+			return
+		}
+		ErrorResponse(c, errors.NewInternalError(err))
+		return
+	}
+	SuccessResponse(c, true)
+}
+
+func egressPolicy(request *requests.EgressPolicyRequest) dto.EgressPolicy {
+	return dto.EgressPolicy{
+		Mode:  constants.EgressMode(request.Mode),
+		Allow: request.Allow,
+	}
+}
+
+func (h *ProjectHandler) HandleGetGitAuth(c *gin.Context) {
+	project, ok := h.loadProject(c)
+	if !ok {
+		return
+	}
+	SuccessResponse(c, project.GitAuth)
+}
+
+// HandleSetGitAuth switches how the project's repository is cloned. The response carries
+// the deploy key's public half, which has to be added to the repository.
+func (h *ProjectHandler) HandleSetGitAuth(c *gin.Context) {
+	project, ok := h.loadProject(c)
+	if !ok {
+		return
+	}
+	var request requests.SetGitAuthRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		ErrorResponse(c, errors.NewBadRequestError("Invalid Request"))
+		return
+	}
+	if err := request.Validate(); err != nil {
+		ErrorResponse(c, err)
+		return
+	}
+
+	err := h.services.GitAuthService.Configure(c.Request.Context(), project, constants.GitAuthMethod(request.Method), request.Token, request.Username, request.KnownHosts, request.Rotate)
+	if err != nil {
+		switch {
+		case stdErrors.Is(err, services.ErrGitHubAppNotConfigured), stdErrors.Is(err, services.ErrGitTokenRequired), stdErrors.Is(err, services.ErrInvalidKnownHosts):
+			ErrorResponse(c, errors.NewBadRequestError(err.Error()))
+		case stdErrors.Is(err, encryption.ErrKeyNotConfigured):
+			ErrorResponse(c, errors.NewError(errors.ErrorTypeInternal, "ENCRYPTION_NOT_CONFIGURED", "ENV_ENCRYPTION_KEY must be set to store git credentials"))
+		default:
+			logger.Error("failed to configure git auth", err, zap.Uint64("project_id", project.ID))
+			ErrorResponse(c, errors.NewInternalError(err))
+		}
+		return
+	}
+	SuccessResponse(c, project.GitAuth)
+}
+
+func phaseTimeouts(timeouts map[string]int) map[constants.BuildPhase]int {
+	if len(timeouts) == 0 {
+		return nil
+	}
+	phases := make(map[constants.BuildPhase]int, len(timeouts))
+	for phase, seconds := range timeouts {
+		phases[constants.BuildPhase(phase)] = seconds
+	}
+	return phases
+}
+
+func buildOptions(request *requests.BuildOptionsRequest) dto.BuildOptions {
+	return dto.BuildOptions{
+		Tags:           request.Tags,
+		Ldflags:        request.Ldflags,
+		VersionPackage: request.VersionPackage,
+		TrimPath:       request.TrimPath,
+		CGO:            request.CGO,
+		GOARCH:         request.GOARCH,
+	}
+}
+
+func testOptions(request *requests.TestOptionsRequest) dto.TestOptions {
+	return dto.TestOptions{Enabled: request.Enabled, Race: request.Race}
+}
+
+func goModules(request *requests.GoModulesRequest) dto.GoModules {
+	modules := dto.GoModules{
+		GOPROXY:   request.GOPROXY,
+		GONOSUMDB: request.GONOSUMDB,
+		GOPRIVATE: request.GOPRIVATE,
+		GOFLAGS:   request.GOFLAGS,
+	}
+	for _, auth := range request.Auth {
+		modules.Auth = append(modules.Auth, dto.ModuleAuth{Host: auth.Host, Username: auth.Username, TokenEnv: auth.TokenEnv})
+	}
+	return modules
+}
+
+// repoPath normalizes a validated repository path, the root becomes ""
+func repoPath(p string) string {
+	p = path.Clean("/" + p)
+	return strings.TrimPrefix(p, "/")
+}
+
+func repoPaths(paths []string) []string {
+	if len(paths) == 0 {
+		return nil
+	}
+	cleaned := make([]string, 0, len(paths))
+	for _, p := range paths {
+		cleaned = append(cleaned, repoPath(p))
+	}
+	return cleaned
+}
+
+func (h *ProjectHandler) HandleGetCache(c *gin.Context) {
+	project, ok := h.loadProject(c)
+	if !ok {
+		return
+	}
+	stats, err := h.services.CacheService.Stats(c.Request.Context(), project)
+	if err != nil {
+		ErrorResponse(c, errors.NewInternalError(err))
+		return
+	}
+	SuccessResponse(c, stats)
+}
+
+func (h *ProjectHandler) HandlePurgeCache(c *gin.Context) {
+	project, ok := h.loadProject(c)
+	if !ok {
+		return
+	}
+	if err := h.services.CacheService.Purge(c.Request.Context(), project); err != nil {
+		if stdErrors.Is(err, services.ErrCacheInUse) {
+			ErrorResponse(c, errors.NewError(errors.ErrorTypeConflict, "CACHE_IN_USE", "A build of the project is running, try again once it is done"))
+			return
+		}
+		logger.Error("failed to purge cache", err, zap.Uint64("project_id", project.ID))
+		ErrorResponse(c, errors.NewInternalError(err))
+		return
+	}
+	SuccessResponse(c, true)
+}
+
+// goVersionAvailable checks the builder catalog has an image for goVersion, writing the error response when not
+func (h *ProjectHandler) goVersionAvailable(c *gin.Context, goVersion string, cgo bool) bool {
+	if goVersion == "" {
+		return true
+	}
+	if _, err := h.services.BuildService.Toolchains.Image(goVersion, cgo); err != nil {
+		ErrorResponse(c, errors.NewValidationError(map[string][]string{"go_version": {err.Error()}}))
+		return false
+	}
+	return true
+}
+
+// loadProject resolves the :id path param, writing the error response when it fails
+func (h *ProjectHandler) loadProject(c *gin.Context) (*dto.Project, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		ErrorResponse(c, errors.NewBadRequestError("Invalid project id"))
+		return nil, false
+	}
+	project, err := h.services.ProjectService.Get(c.Request.Context(), id)
+	if err != nil {
+		ErrorResponse(c, errors.NewInternalError(err))
+		return nil, false
+	}
+	if project == nil {
+		ErrorResponse(c, errors.NewNotFoundError("Project not found"))
+		return nil, false
+	}
+	return project, true
+}
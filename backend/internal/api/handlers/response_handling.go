@@ -24,6 +24,14 @@ func SuccessResponse(c *gin.Context, data interface{}) {
 	})
 }
 
+// AcceptedResponse acknowledges a request that was received but needs no further work
+func AcceptedResponse(c *gin.Context, data interface{}) {
+	c.JSON(http.StatusAccepted, Response{
+		Success: true,
+		Data:    data,
+	})
+}
+
 // ErrorResponse sends a standardized error response
 func ErrorResponse(c *gin.Context, err error) {
 	var appErr *appErrors.AppError
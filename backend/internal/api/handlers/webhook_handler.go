@@ -1,15 +1,17 @@
 package handlers
 
 import (
-	"crypto/hmac"
-	"crypto/sha256"
-	"encoding/hex"
+	"context"
+	stdErrors "errors"
+	"fmt"
 	"io"
 	"net/http"
-	"strings"
+	"strconv"
 	"time"
 
+	"github.com/RajVerma97/golang-vercel/backend/internal/api/errors"
 	"github.com/RajVerma97/golang-vercel/backend/internal/api/requests"
+	"github.com/RajVerma97/golang-vercel/backend/internal/api/webhooks"
 	"github.com/RajVerma97/golang-vercel/backend/internal/constants"
 	"github.com/RajVerma97/golang-vercel/backend/internal/dto"
 	"github.com/RajVerma97/golang-vercel/backend/internal/logger"
@@ -19,103 +21,251 @@ import (
 )
 
 type WebhookHandler struct {
-	webhookSecret string
-	services      *services.Services
+	providers map[string]webhooks.Provider
+	services  *services.Services
 }
 
-func NewWebhookHandler(services *services.Services, webhookSecret string) *WebhookHandler {
+func NewWebhookHandler(services *services.Services, providers map[string]webhooks.Provider) *WebhookHandler {
 	return &WebhookHandler{
-		webhookSecret: webhookSecret,
-		services:      services,
+		providers: providers,
+		services:  services,
 	}
 }
 
-type GitHubPushPayload struct {
-	Ref        string `json:"ref"`
-	After      string `json:"after"`
-	Repository struct {
-		CloneURL string `json:"clone_url"`
-	} `json:"repository"`
+// webhookResult is what handling a delivery came to, both for the response and the delivery history
+type webhookResult struct {
+	httpCode int
+	outcome  constants.WebhookDeliveryOutcome
+	detail   string
+	buildIDs []uint64
+	err      error
 }
 
-func (h *WebhookHandler) HandleGitHubWebhook(c *gin.Context) {
-	// 1. Verify signature if secret is configured
-	if h.webhookSecret != "" {
-		signature := c.GetHeader("X-Hub-Signature-256")
-		body, _ := io.ReadAll(c.Request.Body)
-
-		if !h.verifySignature(body, signature) {
-			logger.Error("GitHub Webhook: Invalid Signature detected!", nil,
-				zap.String("received_sig", signature),
-			)
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid signature"})
-			return
-		}
-
-		// Re-populate body for binding
-		c.Request.Body = io.NopCloser(strings.NewReader(string(body)))
+// HandleWebhook verifies the payload of the :provider git host and turns it into builds
+func (h *WebhookHandler) HandleWebhook(c *gin.Context) {
+	provider, ok := h.providers[c.Param("provider")]
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "unknown webhook provider"})
+		return
 	}
 
-	// 2. Parse GitHub payload
-	var payload GitHubPushPayload
-	if err := c.ShouldBindJSON(&payload); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid payload"})
-		return
+	delivery := &dto.WebhookDelivery{
+		DeliveryID: provider.DeliveryID(c.Request.Header),
+		Provider:   provider.Name(),
+		ReceivedAt: time.Now(),
 	}
+	result := h.handleDelivery(c, provider, delivery)
 
-	// 3. Extract branch from ref (refs/heads/main -> main)
-	branch := strings.TrimPrefix(payload.Ref, "refs/heads/")
+	delivery.Outcome = result.outcome
+	delivery.Detail = result.detail
+	delivery.BuildIDs = result.buildIDs
+	if result.err != nil {
+		delivery.Detail = result.err.Error()
+	}
+	h.services.WebhookDeliveryService.Record(c.Request.Context(), delivery)
 
-	// 4. Create deploy request
-	request := requests.DeployRequest{
-		RepoURL:    payload.Repository.CloneURL,
-		Branch:     &branch,
-		CommitHash: &payload.After,
+	switch {
+	case result.err != nil:
+		ErrorResponse(c, result.err)
+	case result.httpCode == http.StatusAccepted:
+		AcceptedResponse(c, result.detail)
+	case result.httpCode >= http.StatusBadRequest:
+		c.JSON(result.httpCode, gin.H{"error": result.detail})
+	default:
+		SuccessResponse(c, true)
 	}
+}
 
-	// 5. Validate
-	if err := request.Validate(); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+func (h *WebhookHandler) HandleListDeliveries(c *gin.Context) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if err != nil || limit < 0 {
+		ErrorResponse(c, errors.NewBadRequestError("Invalid limit"))
 		return
 	}
-
-	logger.Debug("", zap.Any("github_webhook_requst", request))
-	now := time.Now()
-	err := h.services.RedisService.EnqueueBuild(c.Request.Context(), &dto.Build{
-		RepoUrl:    request.RepoURL,
-		Branch:     request.Branch,
-		CommitHash: request.CommitHash,
-		Status:     constants.BuildStatusPending,
-		CreatedAt:  now,
-		UpdatedAt:  now,
-	})
-
+	deliveries, err := h.services.WebhookDeliveryService.List(c.Request.Context(), c.Query("provider"), limit)
 	if err != nil {
-		ErrorResponse(c, err)
+		ErrorResponse(c, errors.NewInternalError(err))
 		return
 	}
-	SuccessResponse(c, true)
+	SuccessResponse(c, deliveries)
 }
-func (h *WebhookHandler) verifySignature(payload []byte, signature string) bool {
-	if h.webhookSecret == "" {
-		logger.Warn("GitHub Webhook: Secret not configured, skipping verification")
-		return true // Or false depending on your security policy
+
+func (h *WebhookHandler) handleDelivery(c *gin.Context, provider webhooks.Provider, delivery *dto.WebhookDelivery) webhookResult {
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		return webhookResult{httpCode: http.StatusBadRequest, outcome: constants.WebhookDeliveryOutcomeInvalidPayload, detail: "invalid payload"}
 	}
 
-	if signature == "" || !strings.HasPrefix(signature, "sha256=") {
-		return false
+	// 1. Verify signature
+	if err := provider.Verify(c.Request.Header, body); err != nil {
+		logger.Error("Webhook: Invalid Signature detected!", err, zap.String("provider", provider.Name()))
+		if stdErrors.Is(err, webhooks.ErrInvalidSignature) {
+			return webhookResult{httpCode: http.StatusUnauthorized, outcome: constants.WebhookDeliveryOutcomeRejectedSignature, detail: "invalid signature"}
+		}
+		return webhookResult{outcome: constants.WebhookDeliveryOutcomeFailed, err: err}
 	}
 
-	// Remove the "sha256=" prefix
-	actualSig := signature[7:]
-	sigBytes, err := hex.DecodeString(actualSig)
+	// 2. Normalize the payload
+	event, err := provider.Parse(c.Request.Header, body)
 	if err != nil {
-		return false
+		return webhookResult{httpCode: http.StatusBadRequest, outcome: constants.WebhookDeliveryOutcomeInvalidPayload, detail: "invalid payload"}
 	}
+	delivery.Event = event.Name
+	delivery.Repository = event.Repository
 
-	mac := hmac.New(sha256.New, []byte(h.webhookSecret))
-	mac.Write(payload)
-	expectedMac := mac.Sum(nil)
+	// 3. Drop redeliveries; only signed deliveries get to claim an id
+	ctx := c.Request.Context()
+	first, err := h.services.WebhookDeliveryService.Claim(ctx, provider.Name(), delivery.DeliveryID)
+	if err != nil {
+		return webhookResult{outcome: constants.WebhookDeliveryOutcomeFailed, err: err}
+	}
+	if !first {
+		logger.Info("Webhook: ignoring redelivery", zap.String("provider", provider.Name()), zap.String("delivery_id", delivery.DeliveryID))
+		return webhookResult{httpCode: http.StatusOK, outcome: constants.WebhookDeliveryOutcomeDuplicate, detail: "delivery already processed"}
+	}
+
+	// 4. Dispatch on the event type
+	var result webhookResult
+	switch event.Type {
+	case webhooks.EventTypePing:
+		result = webhookResult{httpCode: http.StatusOK, outcome: constants.WebhookDeliveryOutcomeAcknowledged, detail: "pong"}
+	case webhooks.EventTypePush:
+		result = h.handlePush(c, event, delivery)
+	case webhooks.EventTypePullRequest:
+		result = h.handlePullRequest(c, event, delivery)
+	default:
+		logger.Debug("Webhook: ignoring unsupported event", zap.String("provider", event.Provider), zap.String("event", event.Name))
+		result = webhookResult{httpCode: http.StatusAccepted, outcome: constants.WebhookDeliveryOutcomeIgnored, detail: fmt.Sprintf("event %q ignored", event.Name)}
+	}
 
-	return hmac.Equal(sigBytes, expectedMac)
+	// let the provider's retry through when we could not handle the delivery
+	if result.err != nil || result.httpCode >= http.StatusBadRequest {
+		if err := h.services.WebhookDeliveryService.Release(ctx, provider.Name(), delivery.DeliveryID); err != nil {
+			logger.Error("failed to release webhook delivery", err, zap.String("delivery_id", delivery.DeliveryID))
+		}
+	}
+	return result
+}
+
+func (h *WebhookHandler) handlePush(c *gin.Context, event *webhooks.Event, delivery *dto.WebhookDelivery) webhookResult {
+	// deleting a branch or pushing a tag has nothing to build
+	if event.Deleted {
+		return webhookResult{httpCode: http.StatusAccepted, outcome: constants.WebhookDeliveryOutcomeIgnored, detail: "push ignored"}
+	}
+
+	projects, err := h.services.ProjectService.ResolveRepo(c.Request.Context(), event.RepoURL)
+	if err != nil {
+		return webhookResult{outcome: constants.WebhookDeliveryOutcomeFailed, err: err}
+	}
+	// in a monorepo only the apps whose directories changed get rebuilt
+	affected := projects[:0]
+	for _, project := range projects {
+		if h.services.ProjectService.Affected(project, event.ChangedFiles) {
+			affected = append(affected, project)
+		}
+	}
+	if len(affected) == 0 {
+		return webhookResult{httpCode: http.StatusAccepted, outcome: constants.WebhookDeliveryOutcomeIgnored, detail: "no project affected by the push"}
+	}
+
+	request := requests.DeployRequest{
+		RepoURL:    event.RepoURL,
+		Branch:     &event.Branch,
+		CommitHash: &event.CommitHash,
+	}
+	return h.enqueueBuilds(c, event, delivery, request, affected, nil)
+}
+
+func (h *WebhookHandler) handlePullRequest(c *gin.Context, event *webhooks.Event, delivery *dto.WebhookDelivery) webhookResult {
+	// previews belong to the base repository's projects, even when the head lives in a fork
+	switch event.Action {
+	case webhooks.PullRequestActionOpened, webhooks.PullRequestActionUpdated:
+		projects, err := h.services.ProjectService.ResolveRepo(c.Request.Context(), event.RepoURL)
+		if err != nil {
+			return webhookResult{outcome: constants.WebhookDeliveryOutcomeFailed, err: err}
+		}
+		// the head is fetched from the base repository's pull request refs, whatever fork it lives in
+		request := requests.DeployRequest{
+			RepoURL:    event.RepoURL,
+			Branch:     &event.Branch,
+			CommitHash: &event.CommitHash,
+		}
+		return h.enqueueBuilds(c, event, delivery, request, projects, &event.PullRequest)
+	case webhooks.PullRequestActionClosed:
+		projects, err := h.services.ProjectService.ListByRepoURL(c.Request.Context(), event.RepoURL)
+		if err != nil {
+			return webhookResult{outcome: constants.WebhookDeliveryOutcomeFailed, err: err}
+		}
+		if len(projects) == 0 {
+			return webhookResult{httpCode: http.StatusAccepted, outcome: constants.WebhookDeliveryOutcomeIgnored, detail: "no project for repository"}
+		}
+		ref := services.PreviewRef(&dto.Deployment{PullRequest: &event.PullRequest})
+		// removing containers can outlast the provider's delivery timeout
+		go func() {
+			for _, project := range projects {
+				if err := h.services.ReleaseService.TeardownPreview(context.Background(), project, ref); err != nil {
+					logger.Error("failed to tear down preview", err, zap.Uint64("project_id", project.ID), zap.String("ref", ref))
+				}
+				// a build of the pull request that is still running keeps its caches until the next purge
+				if err := h.services.CacheService.PurgePullRequest(project, event.PullRequest); err != nil {
+					logger.Warn("failed to purge pull request build cache", zap.Uint64("project_id", project.ID), zap.Error(err))
+				}
+			}
+		}()
+		return webhookResult{httpCode: http.StatusOK, outcome: constants.WebhookDeliveryOutcomePreviewRemoved, detail: ref}
+	default:
+		return webhookResult{httpCode: http.StatusAccepted, outcome: constants.WebhookDeliveryOutcomeIgnored, detail: fmt.Sprintf("pull request event %q ignored", event.Name)}
+	}
+}
+
+// enqueueBuilds queues one build of the event per project, skipping the projects an earlier attempt
+// at the delivery already queued a build for
+func (h *WebhookHandler) enqueueBuilds(c *gin.Context, event *webhooks.Event, delivery *dto.WebhookDelivery, request requests.DeployRequest, projects []*dto.Project, pullRequest *int) webhookResult {
+	// Validate
+	if err := request.Validate(); err != nil {
+		return webhookResult{httpCode: http.StatusBadRequest, outcome: constants.WebhookDeliveryOutcomeInvalidPayload, detail: err.Error()}
+	}
+
+	logger.Debug("", zap.String("provider", event.Provider), zap.Any("webhook_request", request))
+	ctx := c.Request.Context()
+	queued, err := h.services.WebhookDeliveryService.Queued(ctx, delivery.Provider, delivery.DeliveryID)
+	if err != nil {
+		return webhookResult{outcome: constants.WebhookDeliveryOutcomeFailed, err: err}
+	}
+	result := webhookResult{httpCode: http.StatusOK, outcome: constants.WebhookDeliveryOutcomeBuildCreated}
+	for _, project := range projects {
+		if buildID, ok := queued[project.ID]; ok {
+			result.buildIDs = append(result.buildIDs, buildID)
+			continue
+		}
+		now := time.Now()
+		build := &dto.Build{
+			ProjectID:   project.ID,
+			RepoUrl:     request.RepoURL,
+			Branch:      request.Branch,
+			CommitHash:  request.CommitHash,
+			PullRequest: pullRequest,
+			Trigger:     constants.BuildTrigger(event.Provider),
+			Status:      constants.BuildStatusPending,
+			CreatedAt:   now,
+			UpdatedAt:   now,
+		}
+		if pullRequest != nil && !services.SameRepository(event.HeadRepoURL, event.RepoURL) {
+			build.HeadRepoUrl = event.HeadRepoURL
+		}
+		if event.Provider == webhooks.ProviderGitHub {
+			build.GitHubRepository = &event.Repository
+		}
+		if err := h.services.RedisService.EnqueueBuild(ctx, build); err != nil {
+			// builds queued so far stay queued and are recorded, the redelivery only queues the rest
+			result.outcome = constants.WebhookDeliveryOutcomeFailed
+			result.err = err
+			return result
+		}
+		if err := h.services.WebhookDeliveryService.RecordQueued(ctx, delivery.Provider, delivery.DeliveryID, project.ID, build.ID); err != nil {
+			logger.Error("failed to record queued build", err, zap.String("delivery_id", delivery.DeliveryID), zap.Uint64("build_id", build.ID))
+		}
+		result.buildIDs = append(result.buildIDs, build.ID)
+	}
+	return result
 }
@@ -0,0 +1,89 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/RajVerma97/golang-vercel/backend/internal/api/webhooks"
+	"github.com/RajVerma97/golang-vercel/backend/internal/config"
+	"github.com/RajVerma97/golang-vercel/backend/internal/dto"
+	"github.com/RajVerma97/golang-vercel/backend/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+const testPullRequestPayload = `{"action":"synchronize","number":7,"pull_request":{
+	"head":{"ref":"fix","sha":"def456","repo":{"clone_url":"https://github.com/fork/app.git"}},
+	"base":{"repo":{"full_name":"acme/app","clone_url":"https://github.com/acme/app.git"}}}}`
+
+func TestWebhookHandlerRedeliveryAfterPartialFanOut(t *testing.T) {
+	ctx := context.Background()
+	redisClient := newTestRedis(t)
+	s := &services.Services{
+		RedisService:   services.NewRedisService(&services.RedisServiceConfig{RedisClient: redisClient}),
+		ProjectService: services.NewProjectService(&services.ProjectServiceConfig{RedisClient: redisClient}),
+		WebhookDeliveryService: services.NewWebhookDeliveryService(&services.WebhookDeliveryServiceConfig{
+			RedisClient:   redisClient,
+			WebhookConfig: &config.WebhookConfig{DeliveryTTL: time.Hour, HistorySize: 10},
+		}),
+	}
+	var projects []*dto.Project
+	for _, name := range []string{"api", "web"} {
+		project := &dto.Project{Name: name, RepoUrl: "https://github.com/acme/app.git"}
+		if err := s.ProjectService.Create(ctx, project); err != nil {
+			t.Fatal(err)
+		}
+		projects = append(projects, project)
+	}
+
+	// the first attempt queued the api's build, then failed and released the delivery
+	if _, err := s.WebhookDeliveryService.Claim(ctx, webhooks.ProviderGitHub, "d1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.WebhookDeliveryService.RecordQueued(ctx, webhooks.ProviderGitHub, "d1", projects[0].ID, 41); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.WebhookDeliveryService.Release(ctx, webhooks.ProviderGitHub, "d1"); err != nil {
+		t.Fatal(err)
+	}
+
+	router := gin.New()
+	router.POST("/webhook/:provider", NewWebhookHandler(s, map[string]webhooks.Provider{
+		webhooks.ProviderGitHub: webhooks.NewGitHubProvider(""),
+	}).HandleWebhook)
+	deliver := func() int {
+		req := httptest.NewRequest(http.MethodPost, "/webhook/github", strings.NewReader(testPullRequestPayload))
+		req.Header.Set("X-GitHub-Event", "pull_request")
+		req.Header.Set("X-GitHub-Delivery", "d1")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	if code := deliver(); code != http.StatusOK {
+		t.Fatalf("redelivery returned %d, want 200", code)
+	}
+	build, err := s.RedisService.DequeueBuild(ctx)
+	if err != nil || build == nil {
+		t.Fatalf("DequeueBuild() = %v, %v, want the missing project's build", build, err)
+	}
+	if build.ProjectID != projects[1].ID {
+		t.Errorf("queued a build of project %d, want only the missing project %d", build.ProjectID, projects[1].ID)
+	}
+	if build.RepoUrl != "https://github.com/acme/app.git" || build.HeadRepoUrl != "https://github.com/fork/app.git" {
+		t.Errorf("build clones %s with head %s, want the base repository with the fork as head", build.RepoUrl, build.HeadRepoUrl)
+	}
+	if deliveries, _ := s.WebhookDeliveryService.List(ctx, "", 1); len(deliveries) != 1 || !reflect.DeepEqual(deliveries[0].BuildIDs, []uint64{41, build.ID}) {
+		t.Errorf("delivery history = %+v, want both projects' builds", deliveries)
+	}
+
+	// a further redelivery is a duplicate of the completed delivery
+	deliver()
+	if extra, _ := s.RedisService.DequeueBuild(ctx); extra != nil {
+		t.Errorf("the duplicate queued build %d of project %d", extra.ID, extra.ProjectID)
+	}
+}
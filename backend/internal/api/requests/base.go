@@ -1,14 +1,35 @@
 package requests
 
 import (
+	"fmt"
+	"net"
+	"regexp"
+	"slices"
+	"strings"
+
 	"github.com/RajVerma97/golang-vercel/backend/internal/api/errors"
 	"github.com/RajVerma97/golang-vercel/backend/internal/api/validation"
+	"github.com/RajVerma97/golang-vercel/backend/internal/constants"
+)
+
+var (
+	envKeyPattern      = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+	egressHostPattern  = regexp.MustCompile(`^(\*\.)?([A-Za-z0-9-]+\.)*[A-Za-z0-9-]+$`)
+	buildTagPattern    = regexp.MustCompile(`^!?[A-Za-z0-9_.]+$`)
+	packagePathPattern = regexp.MustCompile(`^[A-Za-z0-9_.~-]+(/[A-Za-z0-9_.~-]+)*$`)
+	goVersionPattern   = regexp.MustCompile(`^1\.\d+(\.\d+)?$`)
+	repoPathPattern    = regexp.MustCompile(`^[A-Za-z0-9_.@+-]+(/[A-Za-z0-9_.@+-]+)*/?$`)
+	moduleGlobPattern  = regexp.MustCompile(`^[A-Za-z0-9_.*?\[\]~/-]+$`)
+	moduleHostPattern  = regexp.MustCompile(`^([A-Za-z0-9-]+\.)*[A-Za-z0-9-]+$`)
+	usernamePattern    = regexp.MustCompile(`^[A-Za-z0-9_.@-]+$`)
 )
 
 type DeployRequest struct {
 	RepoURL    string  `json:"repo_url" validate:"required"`
 	Branch     *string `json:"branch,omitempty"`
 	CommitHash *string `json:"commit_hash,omitempty"`
+	// ProjectID picks the app to build when the repository holds several, the first one otherwise
+	ProjectID uint64 `json:"project_id,omitempty"`
 }
 
 func (r *DeployRequest) Validate() error {
@@ -18,3 +39,258 @@ func (r *DeployRequest) Validate() error {
 	}
 	return nil
 }
+
+type CreateProjectRequest struct {
+	RepoURL          string               `json:"repo_url" validate:"required"`
+	Name             string               `json:"name,omitempty" validate:"omitempty,max=50"`
+	ProductionBranch string               `json:"production_branch,omitempty"`
+	ResourceClass    string               `json:"resource_class,omitempty" validate:"omitempty,oneof=small standard large"`
+	BuildProfile     string               `json:"build_profile,omitempty" validate:"omitempty,oneof=standard hardened"`
+	Egress           *EgressPolicyRequest `json:"egress,omitempty"`
+	Timeouts         map[string]int       `json:"timeouts,omitempty" validate:"omitempty,dive,keys,oneof=clone dependencies compile test step readiness,endkeys,min=1,max=7200"`
+	Submodules       bool                 `json:"submodules,omitempty"`
+	LFS              bool                 `json:"lfs,omitempty"`
+	RootDirectory    string               `json:"root_directory,omitempty" validate:"max=255"`
+	MainPackage      string               `json:"main_package,omitempty" validate:"max=255"`
+	WatchPaths       []string             `json:"watch_paths,omitempty" validate:"max=20,dive,max=255"`
+	GoVersion        string               `json:"go_version,omitempty"`
+	Build            *BuildOptionsRequest `json:"build,omitempty"`
+	Modules          *GoModulesRequest    `json:"modules,omitempty"`
+	Test             *TestOptionsRequest  `json:"test,omitempty"`
+	Builder          string               `json:"builder,omitempty" validate:"omitempty,oneof=auto go dockerfile"`
+	Dockerfile       string               `json:"dockerfile,omitempty" validate:"max=255"`
+}
+
+func (r *CreateProjectRequest) Validate() error {
+	validationErrors := validation.ValidateStruct(r)
+	validateEgress(r.Egress, validationErrors)
+	validateRepoPaths("root_directory", validationErrors, r.RootDirectory)
+	validateRepoPaths("main_package", validationErrors, r.MainPackage)
+	validateRepoPaths("watch_paths", validationErrors, r.WatchPaths...)
+	validateRepoPaths("dockerfile", validationErrors, r.Dockerfile)
+	validateGoVersion(r.GoVersion, validationErrors)
+	validateBuildOptions(r.Build, validationErrors)
+	validateGoModules(r.Modules, validationErrors)
+	if len(validationErrors) > 0 {
+		return errors.NewValidationError(validationErrors)
+	}
+	return nil
+}
+
+// UpdateProjectRequest only changes the settings that are present
+type UpdateProjectRequest struct {
+	ProductionBranch *string              `json:"production_branch,omitempty" validate:"omitempty,min=1"`
+	ResourceClass    *string              `json:"resource_class,omitempty" validate:"omitempty,oneof=small standard large"`
+	BuildProfile     *string              `json:"build_profile,omitempty" validate:"omitempty,oneof=standard hardened"`
+	Egress           *EgressPolicyRequest `json:"egress,omitempty"`
+	Timeouts         map[string]int       `json:"timeouts,omitempty" validate:"omitempty,dive,keys,oneof=clone dependencies compile test step readiness,endkeys,min=1,max=7200"`
+	Submodules       *bool                `json:"submodules,omitempty"`
+	LFS              *bool                `json:"lfs,omitempty"`
+	RootDirectory    *string              `json:"root_directory,omitempty" validate:"omitempty,max=255"`
+	MainPackage      *string              `json:"main_package,omitempty" validate:"omitempty,max=255"`
+	WatchPaths       []string             `json:"watch_paths,omitempty" validate:"omitempty,max=20,dive,max=255"`
+	// GoVersion set to "" goes back to the version go.mod asks for
+	GoVersion *string              `json:"go_version,omitempty"`
+	Build     *BuildOptionsRequest `json:"build,omitempty"`
+	Modules   *GoModulesRequest    `json:"modules,omitempty"`
+	Test      *TestOptionsRequest  `json:"test,omitempty"`
+	Builder   *string              `json:"builder,omitempty" validate:"omitempty,oneof=auto go dockerfile"`
+	// Dockerfile set to "" goes back to the Dockerfile in the root directory
+	Dockerfile *string `json:"dockerfile,omitempty" validate:"omitempty,max=255"`
+}
+
+func (r *UpdateProjectRequest) Validate() error {
+	validationErrors := validation.ValidateStruct(r)
+	validateEgress(r.Egress, validationErrors)
+	if r.RootDirectory != nil {
+		validateRepoPaths("root_directory", validationErrors, *r.RootDirectory)
+	}
+	if r.MainPackage != nil {
+		validateRepoPaths("main_package", validationErrors, *r.MainPackage)
+	}
+	validateRepoPaths("watch_paths", validationErrors, r.WatchPaths...)
+	if r.Dockerfile != nil {
+		validateRepoPaths("dockerfile", validationErrors, *r.Dockerfile)
+	}
+	if r.GoVersion != nil {
+		validateGoVersion(*r.GoVersion, validationErrors)
+	}
+	validateBuildOptions(r.Build, validationErrors)
+	validateGoModules(r.Modules, validationErrors)
+	if len(validationErrors) > 0 {
+		return errors.NewValidationError(validationErrors)
+	}
+	return nil
+}
+
+type EgressPolicyRequest struct {
+	Mode  string   `json:"mode" validate:"required,oneof=open deny allowlist"`
+	Allow []string `json:"allow,omitempty" validate:"max=100"`
+}
+
+// validateEgress checks every allow entry is a CIDR, an IP or a hostname
+func validateEgress(egress *EgressPolicyRequest, validationErrors map[string][]string) {
+	if egress == nil {
+		return
+	}
+	for _, entry := range egress.Allow {
+		if _, _, err := net.ParseCIDR(entry); err == nil || net.ParseIP(entry) != nil {
+			continue
+		}
+		if !egressHostPattern.MatchString(entry) {
+			validationErrors["egress.allow"] = append(validationErrors["egress.allow"], fmt.Sprintf("%q is not a CIDR, IP or hostname.", entry))
+		}
+	}
+	if egress.Mode != "allowlist" && len(egress.Allow) > 0 {
+		validationErrors["egress.allow"] = append(validationErrors["egress.allow"], "Only allowed in allowlist mode.")
+	}
+}
+
+// validateRepoPaths checks paths are relative to the repository and stay inside it, empty means the root
+func validateRepoPaths(field string, validationErrors map[string][]string, paths ...string) {
+	for _, p := range paths {
+		if p == "" || p == "." {
+			continue
+		}
+		if !repoPathPattern.MatchString(p) || slices.Contains(strings.Split(p, "/"), "..") {
+			validationErrors[field] = append(validationErrors[field], fmt.Sprintf("%q must be a path relative to the repository root.", p))
+		}
+	}
+}
+
+func validateGoVersion(goVersion string, validationErrors map[string][]string) {
+	if goVersion != "" && !goVersionPattern.MatchString(goVersion) {
+		validationErrors["go_version"] = append(validationErrors["go_version"], fmt.Sprintf("%q is not a Go version such as 1.24 or 1.24.1.", goVersion))
+	}
+}
+
+type BuildOptionsRequest struct {
+	Tags           []string `json:"tags,omitempty" validate:"max=20"`
+	Ldflags        string   `json:"ldflags,omitempty" validate:"max=1000"`
+	VersionPackage string   `json:"version_package,omitempty" validate:"max=255"`
+	TrimPath       bool     `json:"trimpath,omitempty"`
+	CGO            bool     `json:"cgo,omitempty"`
+	GOARCH         string   `json:"goarch,omitempty" validate:"omitempty,oneof=amd64 arm64"`
+}
+
+// validateBuildOptions checks the tags and the package path end up in the go build command as they are
+func validateBuildOptions(options *BuildOptionsRequest, validationErrors map[string][]string) {
+	if options == nil {
+		return
+	}
+	for _, tag := range options.Tags {
+		if !buildTagPattern.MatchString(tag) {
+			validationErrors["build.tags"] = append(validationErrors["build.tags"], fmt.Sprintf("%q is not a valid build tag.", tag))
+		}
+	}
+	if options.VersionPackage != "" && !packagePathPattern.MatchString(options.VersionPackage) {
+		validationErrors["build.version_package"] = append(validationErrors["build.version_package"], fmt.Sprintf("%q is not a package path.", options.VersionPackage))
+	}
+}
+
+type TestOptionsRequest struct {
+	Enabled bool `json:"enabled"`
+	Race    bool `json:"race,omitempty"`
+}
+
+type GoModulesRequest struct {
+	GOPROXY   string              `json:"goproxy,omitempty" validate:"max=1000"`
+	GONOSUMDB string              `json:"gonosumdb,omitempty" validate:"max=1000"`
+	GOPRIVATE string              `json:"goprivate,omitempty" validate:"max=1000"`
+	GOFLAGS   string              `json:"goflags,omitempty" validate:"max=1000"`
+	Auth      []ModuleAuthRequest `json:"auth,omitempty" validate:"max=10,dive"`
+}
+
+type ModuleAuthRequest struct {
+	Host     string `json:"host" validate:"required,max=255"`
+	Username string `json:"username,omitempty" validate:"max=100"`
+	TokenEnv string `json:"token_env" validate:"required,max=256"`
+}
+
+// validateGoModules checks the settings end up in the build's environment and netrc as they are
+func validateGoModules(modules *GoModulesRequest, validationErrors map[string][]string) {
+	if modules == nil {
+		return
+	}
+	if modules.GOPROXY != "" {
+		// entries are separated by commas, or by pipes to fall through on any error
+		for _, entry := range strings.FieldsFunc(modules.GOPROXY, func(r rune) bool { return r == ',' || r == '|' }) {
+			if entry != "direct" && entry != "off" && !strings.HasPrefix(entry, "https://") && !strings.HasPrefix(entry, "http://") || strings.ContainsAny(entry, " \t\n") {
+				validationErrors["modules.goproxy"] = append(validationErrors["modules.goproxy"], fmt.Sprintf("%q is not a proxy URL, direct or off.", entry))
+			}
+		}
+	}
+	for field, patterns := range map[string]string{"modules.gonosumdb": modules.GONOSUMDB, "modules.goprivate": modules.GOPRIVATE} {
+		if patterns == "" {
+			continue
+		}
+		for _, pattern := range strings.Split(patterns, ",") {
+			if !moduleGlobPattern.MatchString(pattern) {
+				validationErrors[field] = append(validationErrors[field], fmt.Sprintf("%q is not a module path pattern.", pattern))
+			}
+		}
+	}
+	for _, flag := range strings.Fields(modules.GOFLAGS) {
+		if !strings.HasPrefix(flag, "-") || strings.ContainsAny(flag, "'\"`") {
+			validationErrors["modules.goflags"] = append(validationErrors["modules.goflags"], fmt.Sprintf("%q is not a flag.", flag))
+		}
+	}
+	for _, auth := range modules.Auth {
+		if auth.Host != "" && !moduleHostPattern.MatchString(auth.Host) {
+			validationErrors["modules.auth.host"] = append(validationErrors["modules.auth.host"], fmt.Sprintf("%q is not a hostname.", auth.Host))
+		}
+		if auth.Username != "" && !usernamePattern.MatchString(auth.Username) {
+			validationErrors["modules.auth.username"] = append(validationErrors["modules.auth.username"], fmt.Sprintf("%q may only contain letters, digits and . _ @ -", auth.Username))
+		}
+		if auth.TokenEnv != "" && !envKeyPattern.MatchString(auth.TokenEnv) {
+			validationErrors["modules.auth.token_env"] = append(validationErrors["modules.auth.token_env"], fmt.Sprintf("%q is not an env var name.", auth.TokenEnv))
+		}
+	}
+}
+
+type SetGitAuthRequest struct {
+	Method   string `json:"method" validate:"required,oneof=none deploy_key token github_app"`
+	Token    string `json:"token,omitempty"`
+	Username string `json:"username,omitempty" validate:"max=100"`
+	// KnownHosts lists the host keys of a self-hosted git server in known_hosts format, for deploy keys
+	KnownHosts string `json:"known_hosts,omitempty" validate:"max=65536"`
+	// Rotate generates a new deploy key even when the project already has one
+	Rotate bool `json:"rotate,omitempty"`
+}
+
+func (r *SetGitAuthRequest) Validate() error {
+	validationErrors := validation.ValidateStruct(r)
+	if r.Method == "token" && r.Token == "" {
+		validationErrors["token"] = append(validationErrors["token"], "Required for token authentication.")
+	}
+	if r.KnownHosts != "" && r.Method != "deploy_key" {
+		validationErrors["known_hosts"] = append(validationErrors["known_hosts"], "Only used with deploy keys.")
+	}
+	if len(validationErrors) > 0 {
+		return errors.NewValidationError(validationErrors)
+	}
+	return nil
+}
+
+type RollbackRequest struct {
+	DeploymentID uint64 `json:"deployment_id,omitempty"`
+}
+
+type CreateEnvVarRequest struct {
+	Key          string                            `json:"key" validate:"required,max=256"`
+	Value        string                            `json:"value"`
+	Secret       bool                              `json:"secret"`
+	Environments []constants.DeploymentEnvironment `json:"environments" validate:"required,min=1,dive,oneof=production preview"`
+	Targets      []constants.EnvTarget             `json:"targets" validate:"required,min=1,dive,oneof=build runtime"`
+}
+
+func (r *CreateEnvVarRequest) Validate() error {
+	validationErrors := validation.ValidateStruct(r)
+	if r.Key != "" && !envKeyPattern.MatchString(r.Key) {
+		validationErrors["key"] = append(validationErrors["key"], "Must start with a letter or underscore and contain only letters, digits and underscores.")
+	}
+	if len(validationErrors) > 0 {
+		return errors.NewValidationError(validationErrors)
+	}
+	return nil
+}
@@ -0,0 +1,53 @@
+package requests
+
+import (
+	"testing"
+
+	"github.com/RajVerma97/golang-vercel/backend/internal/constants"
+)
+
+func TestCreateEnvVarRequestValidate(t *testing.T) {
+	tests := []struct {
+		name         string
+		key          string
+		environments []constants.DeploymentEnvironment
+		wantErr      bool
+	}{
+		{name: "production and preview", key: "API_KEY", environments: []constants.DeploymentEnvironment{"production", "preview"}},
+		{name: "development is not an environment", key: "API_KEY", environments: []constants.DeploymentEnvironment{"development"}, wantErr: true},
+		{name: "no environment", key: "API_KEY", wantErr: true},
+		{name: "invalid key", key: "1KEY", environments: []constants.DeploymentEnvironment{"production"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request := &CreateEnvVarRequest{Key: tt.key, Value: "v", Environments: tt.environments, Targets: []constants.EnvTarget{"runtime"}}
+			if err := request.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCreateProjectRequestBuildOptions(t *testing.T) {
+	tests := []struct {
+		name    string
+		build   BuildOptionsRequest
+		wantErr bool
+	}{
+		{name: "all options", build: BuildOptionsRequest{Tags: []string{"sqlite", "!nocgo"}, Ldflags: "-s -w", VersionPackage: "example.com/app/internal/version", TrimPath: true, CGO: true, GOARCH: "arm64"}},
+		{name: "amd64", build: BuildOptionsRequest{GOARCH: "amd64"}},
+		{name: "unsupported architecture", build: BuildOptionsRequest{GOARCH: "386"}, wantErr: true},
+		// tags and the version package end up in the go build command
+		{name: "tag with a space", build: BuildOptionsRequest{Tags: []string{"a b"}}, wantErr: true},
+		{name: "tag with shell characters", build: BuildOptionsRequest{Tags: []string{"a;rm"}}, wantErr: true},
+		{name: "version package with a space", build: BuildOptionsRequest{VersionPackage: "main version"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request := &CreateProjectRequest{RepoURL: "https://github.com/acme/app", Build: &tt.build}
+			if err := request.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
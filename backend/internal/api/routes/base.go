@@ -10,8 +10,9 @@ func InitRouter(services *services.Services) *gin.Engine {
 	router := gin.New()
 	handlers := handlers.NewHandlers(services)
 
-	// SetupBuildRoutes(router, services)
+	SetupBuildRoutes(router, handlers)
 	SetupDeploymentRoutes(router, handlers)
 	SetupWebhookRoutes(router, handlers)
+	SetupProjectRoutes(router, handlers)
 	return router
 }
@@ -6,5 +6,6 @@ import (
 )
 
 func SetupBuildRoutes(r *gin.Engine, handlers *handlers.Handlers) {
-	r.POST("/deploy", handlers.BuildHandler.HandleBuild)
+	r.GET("/builds/:id", handlers.BuildHandler.HandleGetBuild)
+	r.GET("/builds/:id/logs", handlers.BuildHandler.HandleGetBuildLogs)
 }
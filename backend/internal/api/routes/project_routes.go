@@ -0,0 +1,20 @@
+package routes
+
+import (
+	"github.com/RajVerma97/golang-vercel/backend/internal/api/handlers"
+	"github.com/gin-gonic/gin"
+)
+
+func SetupProjectRoutes(r *gin.Engine, handlers *handlers.Handlers) {
+	r.POST("/projects", handlers.ProjectHandler.HandleCreateProject)
+	r.GET("/projects/:id", handlers.ProjectHandler.HandleGetProject)
+	r.PATCH("/projects/:id", handlers.ProjectHandler.HandleUpdateProject)
+	r.POST("/projects/:id/rollback", handlers.ProjectHandler.HandleRollback)
+	r.GET("/projects/:id/env", handlers.ProjectHandler.HandleListEnv)
+	r.POST("/projects/:id/env", handlers.ProjectHandler.HandleCreateEnv)
+	r.DELETE("/projects/:id/env/:env_id", handlers.ProjectHandler.HandleDeleteEnv)
+	r.GET("/projects/:id/git-auth", handlers.ProjectHandler.HandleGetGitAuth)
+	r.PUT("/projects/:id/git-auth", handlers.ProjectHandler.HandleSetGitAuth)
+	r.GET("/projects/:id/cache", handlers.ProjectHandler.HandleGetCache)
+	r.POST("/projects/:id/cache/purge", handlers.ProjectHandler.HandlePurgeCache)
+}
@@ -6,5 +6,7 @@ import (
 )
 
 func SetupWebhookRoutes(r *gin.Engine, handlers *handlers.Handlers) {
-	r.POST("/webhook/github", handlers.WebhookHandler.HandleGitHubWebhook)
+	// github, gitlab, gitea or bitbucket
+	r.POST("/webhook/:provider", handlers.WebhookHandler.HandleWebhook)
+	r.GET("/webhook/deliveries", handlers.WebhookHandler.HandleListDeliveries)
 }
@@ -0,0 +1,135 @@
+package webhooks
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strings"
+)
+
+var ErrInvalidSignature = errors.New("invalid signature")
+
+type EventType string
+
+const (
+	EventTypePing        EventType = "ping"
+	EventTypePush        EventType = "push"
+	EventTypePullRequest EventType = "pull_request"
+	EventTypeUnsupported EventType = "unsupported"
+)
+
+type PullRequestAction string
+
+const (
+	PullRequestActionOpened  PullRequestAction = "opened"
+	PullRequestActionUpdated PullRequestAction = "updated"
+	PullRequestActionClosed  PullRequestAction = "closed"
+	PullRequestActionOther   PullRequestAction = "other"
+)
+
+// Event is a provider payload normalized into what the platform builds from
+type Event struct {
+	Provider string
+	Type     EventType
+	// Name is the provider's own event name, kept for logging
+	Name   string
+	Action PullRequestAction
+	// Repository is the full name (owner/name) of the repository the event belongs to
+	Repository string
+	// RepoURL is the clone URL of that repository, which identifies the project
+	RepoURL string
+	// HeadRepoURL is where the code is built from; it differs from RepoURL for pull requests from forks
+	HeadRepoURL string
+	Branch      string
+	CommitHash  string
+	// Deleted is set for pushes that delete a branch or push a tag
+	Deleted     bool
+	PullRequest int
+	// ChangedFiles lists the paths a push touched, nil when the provider did not send all of them
+	ChangedFiles []string
+}
+
+// Provider verifies and normalizes the webhooks of one git host
+type Provider interface {
+	Name() string
+	// Verify checks the request against the shared secret and returns ErrInvalidSignature on mismatch
+	Verify(header http.Header, body []byte) error
+	Parse(header http.Header, body []byte) (*Event, error)
+	// DeliveryID identifies a delivery across redeliveries, empty when the provider sends none
+	DeliveryID(header http.Header) string
+}
+
+// NewProviders returns the supported providers keyed by name
+func NewProviders(secrets map[string]string) map[string]Provider {
+	providers := []Provider{
+		NewGitHubProvider(secrets[ProviderGitHub]),
+		NewGitLabProvider(secrets[ProviderGitLab]),
+		NewGiteaProvider(secrets[ProviderGitea]),
+		NewBitbucketProvider(secrets[ProviderBitbucket]),
+	}
+	byName := make(map[string]Provider, len(providers))
+	for _, provider := range providers {
+		byName[provider.Name()] = provider
+	}
+	return byName
+}
+
+// verifyHMAC checks a hex encoded HMAC-SHA256 of payload, with an optional prefix such as "sha256="
+func verifyHMAC(secret string, payload []byte, signature, prefix string) bool {
+	if signature == "" || !strings.HasPrefix(signature, prefix) {
+		return false
+	}
+	sigBytes, err := hex.DecodeString(strings.TrimPrefix(signature, prefix))
+	if err != nil {
+		return false
+	}
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(payload)
+	return hmac.Equal(sigBytes, mac.Sum(nil))
+}
+
+// isZeroCommit reports whether hash is the all zero commit git hosts send for deleted refs
+func isZeroCommit(hash string) bool {
+	return strings.Trim(hash, "0") == ""
+}
+
+// branchFromRef returns the branch of a refs/heads/ ref and false for anything else, such as tags
+func branchFromRef(ref string) (string, bool) {
+	if !strings.HasPrefix(ref, "refs/heads/") {
+		return "", false
+	}
+	return strings.TrimPrefix(ref, "refs/heads/"), true
+}
+
+// maxPushCommits is how many commits GitHub, GitLab and Gitea list in a push payload
+const maxPushCommits = 20
+
+type pushCommit struct {
+	Added    []string `json:"added"`
+	Removed  []string `json:"removed"`
+	Modified []string `json:"modified"`
+}
+
+// changedFiles collects the paths touched by commits. It returns nil when the payload may have
+// left commits out, since a missing file would skip a build that was needed.
+func changedFiles(commits []pushCommit, totalCommits int) []string {
+	if len(commits) == 0 || len(commits) >= maxPushCommits || totalCommits > len(commits) {
+		return nil
+	}
+	seen := make(map[string]bool)
+	files := []string{}
+	for _, commit := range commits {
+		for _, list := range [][]string{commit.Added, commit.Removed, commit.Modified} {
+			for _, file := range list {
+				if !seen[file] {
+					seen[file] = true
+					files = append(files, file)
+				}
+			}
+		}
+	}
+	return files
+}
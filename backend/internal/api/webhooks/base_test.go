@@ -0,0 +1,96 @@
+package webhooks
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"reflect"
+	"testing"
+)
+
+func hmacHex(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestBranchFromRef(t *testing.T) {
+	tests := []struct {
+		ref        string
+		wantBranch string
+		wantOK     bool
+	}{
+		{ref: "refs/heads/main", wantBranch: "main", wantOK: true},
+		{ref: "refs/heads/feature/login", wantBranch: "feature/login", wantOK: true},
+		{ref: "refs/tags/v1.0.0"},
+		{ref: "main"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.ref, func(t *testing.T) {
+			branch, ok := branchFromRef(tt.ref)
+			if branch != tt.wantBranch || ok != tt.wantOK {
+				t.Errorf("branchFromRef(%q) = %q, %v, want %q, %v", tt.ref, branch, ok, tt.wantBranch, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestIsZeroCommit(t *testing.T) {
+	tests := map[string]bool{
+		"0000000000000000000000000000000000000000": true,
+		"": true,
+		"abc0000000000000000000000000000000000000": false,
+	}
+	for hash, want := range tests {
+		if got := isZeroCommit(hash); got != want {
+			t.Errorf("isZeroCommit(%q) = %v, want %v", hash, got, want)
+		}
+	}
+}
+
+func TestChangedFiles(t *testing.T) {
+	tests := []struct {
+		name         string
+		commits      []pushCommit
+		totalCommits int
+		want         []string
+	}{
+		{
+			name: "files of every commit, once each",
+			commits: []pushCommit{
+				{Added: []string{"api/main.go"}, Modified: []string{"go.mod"}},
+				{Removed: []string{"web/old.go"}, Modified: []string{"go.mod"}},
+			},
+			totalCommits: 2,
+			want:         []string{"api/main.go", "go.mod", "web/old.go"},
+		},
+		{
+			name:         "commits without files",
+			commits:      []pushCommit{{}},
+			totalCommits: 1,
+			want:         []string{},
+		},
+		{
+			name:         "no commits",
+			totalCommits: 0,
+		},
+		{
+			name:         "commits left out of the payload",
+			commits:      []pushCommit{{Modified: []string{"go.mod"}}},
+			totalCommits: 3,
+		},
+		{
+			name:         "as many commits as the payload holds",
+			commits:      make([]pushCommit, maxPushCommits),
+			totalCommits: maxPushCommits,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := changedFiles(tt.commits, tt.totalCommits)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("changedFiles() = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,260 @@
+package webhooks
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/RajVerma97/golang-vercel/backend/internal/logger"
+)
+
+const ProviderBitbucket = "bitbucket"
+
+// BitbucketProvider handles both Bitbucket Cloud and Bitbucket Server / Data Center.
+// They share the X-Event-Key header and X-Hub-Signature but not their payloads.
+type BitbucketProvider struct {
+	secret string
+}
+
+func NewBitbucketProvider(secret string) *BitbucketProvider {
+	return &BitbucketProvider{secret: secret}
+}
+
+type bitbucketCloudRepository struct {
+	FullName string `json:"full_name"`
+	Links    struct {
+		HTML struct {
+			Href string `json:"href"`
+		} `json:"html"`
+	} `json:"links"`
+}
+
+func (r bitbucketCloudRepository) cloneURL() string {
+	return strings.TrimSuffix(r.Links.HTML.Href, "/") + ".git"
+}
+
+type BitbucketCloudPushPayload struct {
+	Repository bitbucketCloudRepository `json:"repository"`
+	Push       struct {
+		Changes []struct {
+			New *struct {
+				Type   string `json:"type"`
+				Name   string `json:"name"`
+				Target struct {
+					Hash string `json:"hash"`
+				} `json:"target"`
+			} `json:"new"`
+		} `json:"changes"`
+	} `json:"push"`
+}
+
+type BitbucketCloudPullRequestPayload struct {
+	PullRequest struct {
+		ID     int `json:"id"`
+		Source struct {
+			Branch struct {
+				Name string `json:"name"`
+			} `json:"branch"`
+			Commit struct {
+				Hash string `json:"hash"`
+			} `json:"commit"`
+			Repository bitbucketCloudRepository `json:"repository"`
+		} `json:"source"`
+		Destination struct {
+			Repository bitbucketCloudRepository `json:"repository"`
+		} `json:"destination"`
+	} `json:"pullrequest"`
+}
+
+type bitbucketServerRepository struct {
+	Slug    string `json:"slug"`
+	Project struct {
+		Key string `json:"key"`
+	} `json:"project"`
+	Links struct {
+		Clone []struct {
+			Href string `json:"href"`
+			Name string `json:"name"`
+		} `json:"clone"`
+	} `json:"links"`
+}
+
+func (r bitbucketServerRepository) fullName() string {
+	return fmt.Sprintf("%s/%s", r.Project.Key, r.Slug)
+}
+
+func (r bitbucketServerRepository) cloneURL() string {
+	for _, link := range r.Links.Clone {
+		if link.Name == "http" || link.Name == "https" {
+			return link.Href
+		}
+	}
+	return ""
+}
+
+type BitbucketServerPushPayload struct {
+	Repository bitbucketServerRepository `json:"repository"`
+	Changes    []struct {
+		Ref struct {
+			ID   string `json:"id"`
+			Type string `json:"type"`
+		} `json:"ref"`
+		ToHash string `json:"toHash"`
+		Type   string `json:"type"`
+	} `json:"changes"`
+}
+
+type BitbucketServerPullRequestPayload struct {
+	PullRequest struct {
+		ID      int `json:"id"`
+		FromRef struct {
+			DisplayID    string                    `json:"displayId"`
+			LatestCommit string                    `json:"latestCommit"`
+			Repository   bitbucketServerRepository `json:"repository"`
+		} `json:"fromRef"`
+		ToRef struct {
+			Repository bitbucketServerRepository `json:"repository"`
+		} `json:"toRef"`
+	} `json:"pullRequest"`
+}
+
+func (p *BitbucketProvider) Name() string {
+	return ProviderBitbucket
+}
+
+func (p *BitbucketProvider) Verify(header http.Header, body []byte) error {
+	if p.secret == "" {
+		logger.Warn("Bitbucket Webhook: Secret not configured, skipping verification")
+		return nil
+	}
+	if !verifyHMAC(p.secret, body, header.Get("X-Hub-Signature"), "sha256=") {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+func (p *BitbucketProvider) DeliveryID(header http.Header) string {
+	// Cloud sends X-Request-UUID, Server X-Request-Id
+	if id := header.Get("X-Request-UUID"); id != "" {
+		return id
+	}
+	return header.Get("X-Request-Id")
+}
+
+func (p *BitbucketProvider) Parse(header http.Header, body []byte) (*Event, error) {
+	name := header.Get("X-Event-Key")
+	event := &Event{Provider: ProviderBitbucket, Name: name}
+
+	var err error
+	switch name {
+	case "diagnostics:ping":
+		event.Type = EventTypePing
+	case "repo:push":
+		err = p.parseCloudPush(event, body)
+	case "pullrequest:created", "pullrequest:updated", "pullrequest:fulfilled", "pullrequest:rejected":
+		err = p.parseCloudPullRequest(event, body)
+	case "repo:refs_changed":
+		err = p.parseServerPush(event, body)
+	case "pr:opened", "pr:from_ref_updated", "pr:merged", "pr:declined", "pr:deleted":
+		err = p.parseServerPullRequest(event, body)
+	default:
+		event.Type = EventTypeUnsupported
+	}
+	if err != nil {
+		return nil, err
+	}
+	return event, nil
+}
+
+func (p *BitbucketProvider) parseCloudPush(event *Event, body []byte) error {
+	var payload BitbucketCloudPushPayload
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return fmt.Errorf("invalid push payload: %w", err)
+	}
+	event.Type = EventTypePush
+	event.Repository = payload.Repository.FullName
+	event.RepoURL = payload.Repository.cloneURL()
+	event.HeadRepoURL = event.RepoURL
+	// a push can update several refs, the first branch wins; deletions have no new ref
+	event.Deleted = true
+	for _, change := range payload.Push.Changes {
+		if change.New != nil && change.New.Type == "branch" {
+			event.Branch = change.New.Name
+			event.CommitHash = change.New.Target.Hash
+			event.Deleted = false
+			break
+		}
+	}
+	return nil
+}
+
+func (p *BitbucketProvider) parseCloudPullRequest(event *Event, body []byte) error {
+	var payload BitbucketCloudPullRequestPayload
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return fmt.Errorf("invalid pull request payload: %w", err)
+	}
+	pr := payload.PullRequest
+	event.Type = EventTypePullRequest
+	event.Repository = pr.Destination.Repository.FullName
+	event.RepoURL = pr.Destination.Repository.cloneURL()
+	event.HeadRepoURL = pr.Source.Repository.cloneURL()
+	event.Branch = pr.Source.Branch.Name
+	event.CommitHash = pr.Source.Commit.Hash
+	event.PullRequest = pr.ID
+	switch event.Name {
+	case "pullrequest:created":
+		event.Action = PullRequestActionOpened
+	case "pullrequest:updated":
+		event.Action = PullRequestActionUpdated
+	default:
+		event.Action = PullRequestActionClosed
+	}
+	return nil
+}
+
+func (p *BitbucketProvider) parseServerPush(event *Event, body []byte) error {
+	var payload BitbucketServerPushPayload
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return fmt.Errorf("invalid push payload: %w", err)
+	}
+	event.Type = EventTypePush
+	event.Repository = payload.Repository.fullName()
+	event.RepoURL = payload.Repository.cloneURL()
+	event.HeadRepoURL = event.RepoURL
+	event.Deleted = true
+	for _, change := range payload.Changes {
+		branch, isBranch := branchFromRef(change.Ref.ID)
+		if isBranch && change.Type != "DELETE" {
+			event.Branch = branch
+			event.CommitHash = change.ToHash
+			event.Deleted = false
+			break
+		}
+	}
+	return nil
+}
+
+func (p *BitbucketProvider) parseServerPullRequest(event *Event, body []byte) error {
+	var payload BitbucketServerPullRequestPayload
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return fmt.Errorf("invalid pull request payload: %w", err)
+	}
+	pr := payload.PullRequest
+	event.Type = EventTypePullRequest
+	event.Repository = pr.ToRef.Repository.fullName()
+	event.RepoURL = pr.ToRef.Repository.cloneURL()
+	event.HeadRepoURL = pr.FromRef.Repository.cloneURL()
+	event.Branch = pr.FromRef.DisplayID
+	event.CommitHash = pr.FromRef.LatestCommit
+	event.PullRequest = pr.ID
+	switch event.Name {
+	case "pr:opened":
+		event.Action = PullRequestActionOpened
+	case "pr:from_ref_updated":
+		event.Action = PullRequestActionUpdated
+	default:
+		event.Action = PullRequestActionClosed
+	}
+	return nil
+}
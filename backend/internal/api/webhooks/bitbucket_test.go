@@ -0,0 +1,111 @@
+package webhooks
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestBitbucketProviderParse(t *testing.T) {
+	cloudRepository := `{"full_name":"acme/app","links":{"html":{"href":"https://bitbucket.org/acme/app"}}}`
+	serverRepository := `{"slug":"app","project":{"key":"ACME"},"links":{"clone":[
+		{"href":"ssh://git@bitbucket.example.com:7999/acme/app.git","name":"ssh"},
+		{"href":"https://bitbucket.example.com/scm/acme/app.git","name":"http"}]}}`
+	tests := []struct {
+		name  string
+		event string
+		body  string
+		want  *Event
+	}{
+		{
+			name:  "ping",
+			event: "diagnostics:ping",
+			body:  `{}`,
+			want:  &Event{Provider: ProviderBitbucket, Name: "diagnostics:ping", Type: EventTypePing},
+		},
+		{
+			name:  "cloud push, the first branch wins",
+			event: "repo:push",
+			body: `{"repository":` + cloudRepository + `,"push":{"changes":[
+				{"new":{"type":"tag","name":"v1.0.0","target":{"hash":"aaa111"}}},
+				{"new":{"type":"branch","name":"main","target":{"hash":"abc123"}}}]}}`,
+			want: &Event{
+				Provider: ProviderBitbucket, Name: "repo:push", Type: EventTypePush,
+				Repository: "acme/app", RepoURL: "https://bitbucket.org/acme/app.git", HeadRepoURL: "https://bitbucket.org/acme/app.git",
+				Branch: "main", CommitHash: "abc123",
+			},
+		},
+		{
+			name:  "cloud branch deletion",
+			event: "repo:push",
+			body:  `{"repository":` + cloudRepository + `,"push":{"changes":[{"new":null}]}}`,
+			want: &Event{
+				Provider: ProviderBitbucket, Name: "repo:push", Type: EventTypePush,
+				Repository: "acme/app", RepoURL: "https://bitbucket.org/acme/app.git", HeadRepoURL: "https://bitbucket.org/acme/app.git",
+				Deleted: true,
+			},
+		},
+		{
+			name:  "cloud pull request",
+			event: "pullrequest:created",
+			body: `{"pullrequest":{"id":9,"source":{"branch":{"name":"fix"},"commit":{"hash":"def456"},
+				"repository":{"full_name":"fork/app","links":{"html":{"href":"https://bitbucket.org/fork/app"}}}},
+				"destination":{"repository":` + cloudRepository + `}}}`,
+			want: &Event{
+				Provider: ProviderBitbucket, Name: "pullrequest:created", Type: EventTypePullRequest, Action: PullRequestActionOpened,
+				Repository: "acme/app", RepoURL: "https://bitbucket.org/acme/app.git", HeadRepoURL: "https://bitbucket.org/fork/app.git",
+				Branch: "fix", CommitHash: "def456", PullRequest: 9,
+			},
+		},
+		{
+			name:  "server push",
+			event: "repo:refs_changed",
+			body: `{"repository":` + serverRepository + `,"changes":[
+				{"ref":{"id":"refs/heads/old","type":"BRANCH"},"toHash":"0000000000000000000000000000000000000000","type":"DELETE"},
+				{"ref":{"id":"refs/heads/main","type":"BRANCH"},"toHash":"abc123","type":"UPDATE"}]}`,
+			want: &Event{
+				Provider: ProviderBitbucket, Name: "repo:refs_changed", Type: EventTypePush,
+				Repository: "ACME/app", RepoURL: "https://bitbucket.example.com/scm/acme/app.git", HeadRepoURL: "https://bitbucket.example.com/scm/acme/app.git",
+				Branch: "main", CommitHash: "abc123",
+			},
+		},
+		{
+			name:  "declined server pull request",
+			event: "pr:declined",
+			body: `{"pullRequest":{"id":4,"fromRef":{"displayId":"fix","latestCommit":"def456","repository":` + serverRepository + `},
+				"toRef":{"repository":` + serverRepository + `}}}`,
+			want: &Event{
+				Provider: ProviderBitbucket, Name: "pr:declined", Type: EventTypePullRequest, Action: PullRequestActionClosed,
+				Repository: "ACME/app", RepoURL: "https://bitbucket.example.com/scm/acme/app.git", HeadRepoURL: "https://bitbucket.example.com/scm/acme/app.git",
+				Branch: "fix", CommitHash: "def456", PullRequest: 4,
+			},
+		},
+		{
+			name:  "unsupported event",
+			event: "repo:fork",
+			body:  `{}`,
+			want:  &Event{Provider: ProviderBitbucket, Name: "repo:fork", Type: EventTypeUnsupported},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header := http.Header{}
+			header.Set("X-Event-Key", tt.event)
+			got, err := NewBitbucketProvider("").Parse(header, []byte(tt.body))
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Parse() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBitbucketProviderParseInvalidPayload(t *testing.T) {
+	header := http.Header{}
+	header.Set("X-Event-Key", "repo:push")
+	if _, err := NewBitbucketProvider("").Parse(header, []byte(`[]`)); err == nil {
+		t.Error("Parse() error = nil, want an error for a payload that is not an object")
+	}
+}
@@ -0,0 +1,125 @@
+package webhooks
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/RajVerma97/golang-vercel/backend/internal/logger"
+)
+
+const ProviderGitea = "gitea"
+
+// GiteaProvider handles Gitea and Forgejo, which share the same payloads
+type GiteaProvider struct {
+	secret string
+}
+
+func NewGiteaProvider(secret string) *GiteaProvider {
+	return &GiteaProvider{secret: secret}
+}
+
+type giteaRepository struct {
+	FullName string `json:"full_name"`
+	CloneURL string `json:"clone_url"`
+}
+
+type GiteaPushPayload struct {
+	Ref     string       `json:"ref"`
+	After   string       `json:"after"`
+	Commits []pushCommit `json:"commits"`
+	// TotalCommits counts every pushed commit when Commits was cut short
+	TotalCommits int             `json:"total_commits"`
+	Repository   giteaRepository `json:"repository"`
+}
+
+type GiteaPullRequestPayload struct {
+	Action      string `json:"action"`
+	Number      int    `json:"number"`
+	PullRequest struct {
+		Head struct {
+			Ref  string          `json:"ref"`
+			SHA  string          `json:"sha"`
+			Repo giteaRepository `json:"repo"`
+		} `json:"head"`
+		Base struct {
+			Repo giteaRepository `json:"repo"`
+		} `json:"base"`
+	} `json:"pull_request"`
+}
+
+func (p *GiteaProvider) Name() string {
+	return ProviderGitea
+}
+
+func (p *GiteaProvider) Verify(header http.Header, body []byte) error {
+	if p.secret == "" {
+		logger.Warn("Gitea Webhook: Secret not configured, skipping verification")
+		return nil
+	}
+	signature := header.Get("X-Gitea-Signature")
+	if signature == "" {
+		signature = header.Get("X-Forgejo-Signature")
+	}
+	if !verifyHMAC(p.secret, body, signature, "") {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+func (p *GiteaProvider) DeliveryID(header http.Header) string {
+	if id := header.Get("X-Gitea-Delivery"); id != "" {
+		return id
+	}
+	return header.Get("X-Forgejo-Delivery")
+}
+
+func (p *GiteaProvider) Parse(header http.Header, body []byte) (*Event, error) {
+	name := header.Get("X-Gitea-Event")
+	if name == "" {
+		name = header.Get("X-Forgejo-Event")
+	}
+	event := &Event{Provider: ProviderGitea, Name: name}
+
+	switch name {
+	case "push":
+		var payload GiteaPushPayload
+		if err := json.Unmarshal(body, &payload); err != nil {
+			return nil, fmt.Errorf("invalid push payload: %w", err)
+		}
+		branch, isBranch := branchFromRef(payload.Ref)
+		event.Type = EventTypePush
+		event.Repository = payload.Repository.FullName
+		event.RepoURL = payload.Repository.CloneURL
+		event.HeadRepoURL = payload.Repository.CloneURL
+		event.Branch = branch
+		event.CommitHash = payload.After
+		event.Deleted = !isBranch || isZeroCommit(payload.After)
+		event.ChangedFiles = changedFiles(payload.Commits, payload.TotalCommits)
+	case "pull_request":
+		var payload GiteaPullRequestPayload
+		if err := json.Unmarshal(body, &payload); err != nil {
+			return nil, fmt.Errorf("invalid pull_request payload: %w", err)
+		}
+		event.Type = EventTypePullRequest
+		event.Repository = payload.PullRequest.Base.Repo.FullName
+		event.RepoURL = payload.PullRequest.Base.Repo.CloneURL
+		event.HeadRepoURL = payload.PullRequest.Head.Repo.CloneURL
+		event.Branch = payload.PullRequest.Head.Ref
+		event.CommitHash = payload.PullRequest.Head.SHA
+		event.PullRequest = payload.Number
+		switch payload.Action {
+		case "opened", "reopened":
+			event.Action = PullRequestActionOpened
+		case "synchronized":
+			event.Action = PullRequestActionUpdated
+		case "closed":
+			event.Action = PullRequestActionClosed
+		default:
+			event.Action = PullRequestActionOther
+		}
+	default:
+		event.Type = EventTypeUnsupported
+	}
+	return event, nil
+}
@@ -0,0 +1,106 @@
+package webhooks
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestGiteaProviderParse(t *testing.T) {
+	repository := `{"full_name":"acme/app","clone_url":"https://gitea.example.com/acme/app.git"}`
+	tests := []struct {
+		name   string
+		header string
+		event  string
+		body   string
+		want   *Event
+	}{
+		{
+			name:   "gitea push",
+			header: "X-Gitea-Event",
+			event:  "push",
+			body:   `{"ref":"refs/heads/main","after":"abc123","total_commits":1,"commits":[{"removed":["old.go"]}],"repository":` + repository + `}`,
+			want: &Event{
+				Provider: ProviderGitea, Name: "push", Type: EventTypePush,
+				Repository: "acme/app", RepoURL: "https://gitea.example.com/acme/app.git", HeadRepoURL: "https://gitea.example.com/acme/app.git",
+				Branch: "main", CommitHash: "abc123", ChangedFiles: []string{"old.go"},
+			},
+		},
+		{
+			name:   "forgejo branch deletion",
+			header: "X-Forgejo-Event",
+			event:  "push",
+			body:   `{"ref":"refs/heads/old","after":"0000000000000000000000000000000000000000","repository":` + repository + `}`,
+			want: &Event{
+				Provider: ProviderGitea, Name: "push", Type: EventTypePush,
+				Repository: "acme/app", RepoURL: "https://gitea.example.com/acme/app.git", HeadRepoURL: "https://gitea.example.com/acme/app.git",
+				Branch: "old", CommitHash: "0000000000000000000000000000000000000000", Deleted: true,
+			},
+		},
+		{
+			name:   "reopened pull request",
+			header: "X-Gitea-Event",
+			event:  "pull_request",
+			body: `{"action":"reopened","number":5,"pull_request":{
+				"head":{"ref":"fix","sha":"def456","repo":{"clone_url":"https://gitea.example.com/fork/app.git"}},
+				"base":{"repo":` + repository + `}}}`,
+			want: &Event{
+				Provider: ProviderGitea, Name: "pull_request", Type: EventTypePullRequest, Action: PullRequestActionOpened,
+				Repository: "acme/app", RepoURL: "https://gitea.example.com/acme/app.git", HeadRepoURL: "https://gitea.example.com/fork/app.git",
+				Branch: "fix", CommitHash: "def456", PullRequest: 5,
+			},
+		},
+		{
+			name:   "synchronized pull request",
+			header: "X-Gitea-Event",
+			event:  "pull_request",
+			body:   `{"action":"synchronized","number":5}`,
+			want:   &Event{Provider: ProviderGitea, Name: "pull_request", Type: EventTypePullRequest, Action: PullRequestActionUpdated, PullRequest: 5},
+		},
+		{
+			name:   "unsupported event",
+			header: "X-Gitea-Event",
+			event:  "release",
+			body:   `{}`,
+			want:   &Event{Provider: ProviderGitea, Name: "release", Type: EventTypeUnsupported},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header := http.Header{}
+			header.Set(tt.header, tt.event)
+			got, err := NewGiteaProvider("").Parse(header, []byte(tt.body))
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Parse() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGiteaProviderVerify(t *testing.T) {
+	body := []byte(`{"ref":"refs/heads/main"}`)
+	tests := []struct {
+		name    string
+		header  string
+		wantErr bool
+	}{
+		{name: "gitea signature", header: "X-Gitea-Signature"},
+		{name: "forgejo signature", header: "X-Forgejo-Signature"},
+		{name: "unsigned", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header := http.Header{}
+			if tt.header != "" {
+				header.Set(tt.header, hmacHex("secret", body))
+			}
+			err := NewGiteaProvider("secret").Verify(header, body)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Verify() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,118 @@
+package webhooks
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/RajVerma97/golang-vercel/backend/internal/logger"
+)
+
+const ProviderGitHub = "github"
+
+type GitHubProvider struct {
+	secret string
+}
+
+func NewGitHubProvider(secret string) *GitHubProvider {
+	return &GitHubProvider{secret: secret}
+}
+
+type GitHubPushPayload struct {
+	Ref        string       `json:"ref"`
+	After      string       `json:"after"`
+	Deleted    bool         `json:"deleted"`
+	Commits    []pushCommit `json:"commits"`
+	Repository struct {
+		FullName string `json:"full_name"`
+		CloneURL string `json:"clone_url"`
+	} `json:"repository"`
+}
+
+type GitHubPullRequestPayload struct {
+	Action      string `json:"action"`
+	Number      int    `json:"number"`
+	PullRequest struct {
+		Head struct {
+			Ref  string `json:"ref"`
+			SHA  string `json:"sha"`
+			Repo struct {
+				CloneURL string `json:"clone_url"`
+			} `json:"repo"`
+		} `json:"head"`
+		Base struct {
+			Repo struct {
+				FullName string `json:"full_name"`
+				CloneURL string `json:"clone_url"`
+			} `json:"repo"`
+		} `json:"base"`
+	} `json:"pull_request"`
+}
+
+func (p *GitHubProvider) Name() string {
+	return ProviderGitHub
+}
+
+func (p *GitHubProvider) Verify(header http.Header, body []byte) error {
+	if p.secret == "" {
+		logger.Warn("GitHub Webhook: Secret not configured, skipping verification")
+		return nil
+	}
+	if !verifyHMAC(p.secret, body, header.Get("X-Hub-Signature-256"), "sha256=") {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+func (p *GitHubProvider) DeliveryID(header http.Header) string {
+	return header.Get("X-GitHub-Delivery")
+}
+
+func (p *GitHubProvider) Parse(header http.Header, body []byte) (*Event, error) {
+	name := header.Get("X-GitHub-Event")
+	event := &Event{Provider: ProviderGitHub, Name: name}
+
+	switch name {
+	case "ping":
+		event.Type = EventTypePing
+	case "push":
+		var payload GitHubPushPayload
+		if err := json.Unmarshal(body, &payload); err != nil {
+			return nil, fmt.Errorf("invalid push payload: %w", err)
+		}
+		branch, isBranch := branchFromRef(payload.Ref)
+		event.Type = EventTypePush
+		event.Repository = payload.Repository.FullName
+		event.RepoURL = payload.Repository.CloneURL
+		event.HeadRepoURL = payload.Repository.CloneURL
+		event.Branch = branch
+		event.CommitHash = payload.After
+		event.Deleted = payload.Deleted || !isBranch
+		event.ChangedFiles = changedFiles(payload.Commits, len(payload.Commits))
+	case "pull_request":
+		var payload GitHubPullRequestPayload
+		if err := json.Unmarshal(body, &payload); err != nil {
+			return nil, fmt.Errorf("invalid pull_request payload: %w", err)
+		}
+		event.Type = EventTypePullRequest
+		event.Repository = payload.PullRequest.Base.Repo.FullName
+		event.RepoURL = payload.PullRequest.Base.Repo.CloneURL
+		event.HeadRepoURL = payload.PullRequest.Head.Repo.CloneURL
+		event.Branch = payload.PullRequest.Head.Ref
+		event.CommitHash = payload.PullRequest.Head.SHA
+		event.PullRequest = payload.Number
+		switch payload.Action {
+		case "opened", "reopened":
+			event.Action = PullRequestActionOpened
+		case "synchronize":
+			event.Action = PullRequestActionUpdated
+		case "closed":
+			event.Action = PullRequestActionClosed
+		default:
+			event.Action = PullRequestActionOther
+		}
+	default:
+		event.Type = EventTypeUnsupported
+	}
+	return event, nil
+}
@@ -0,0 +1,127 @@
+package webhooks
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestGitHubProviderParse(t *testing.T) {
+	tests := []struct {
+		name  string
+		event string
+		body  string
+		want  *Event
+	}{
+		{
+			name:  "ping",
+			event: "ping",
+			body:  `{"zen":"Keep it logically awesome."}`,
+			want:  &Event{Provider: ProviderGitHub, Name: "ping", Type: EventTypePing},
+		},
+		{
+			name:  "push to a branch",
+			event: "push",
+			body: `{"ref":"refs/heads/main","after":"abc123","commits":[{"added":["cmd/main.go"],"modified":["go.mod"]}],
+				"repository":{"full_name":"acme/app","clone_url":"https://github.com/acme/app.git"}}`,
+			want: &Event{
+				Provider: ProviderGitHub, Name: "push", Type: EventTypePush,
+				Repository: "acme/app", RepoURL: "https://github.com/acme/app.git", HeadRepoURL: "https://github.com/acme/app.git",
+				Branch: "main", CommitHash: "abc123", ChangedFiles: []string{"cmd/main.go", "go.mod"},
+			},
+		},
+		{
+			name:  "branch deletion",
+			event: "push",
+			body:  `{"ref":"refs/heads/old","after":"0000000000000000000000000000000000000000","deleted":true,"repository":{"full_name":"acme/app","clone_url":"https://github.com/acme/app.git"}}`,
+			want: &Event{
+				Provider: ProviderGitHub, Name: "push", Type: EventTypePush,
+				Repository: "acme/app", RepoURL: "https://github.com/acme/app.git", HeadRepoURL: "https://github.com/acme/app.git",
+				Branch: "old", CommitHash: "0000000000000000000000000000000000000000", Deleted: true,
+			},
+		},
+		{
+			name:  "tag push",
+			event: "push",
+			body:  `{"ref":"refs/tags/v1.0.0","after":"abc123","repository":{"full_name":"acme/app","clone_url":"https://github.com/acme/app.git"}}`,
+			want: &Event{
+				Provider: ProviderGitHub, Name: "push", Type: EventTypePush,
+				Repository: "acme/app", RepoURL: "https://github.com/acme/app.git", HeadRepoURL: "https://github.com/acme/app.git",
+				CommitHash: "abc123", Deleted: true,
+			},
+		},
+		{
+			name:  "pull request from a fork",
+			event: "pull_request",
+			body: `{"action":"synchronize","number":7,"pull_request":{
+				"head":{"ref":"fix","sha":"def456","repo":{"clone_url":"https://github.com/fork/app.git"}},
+				"base":{"repo":{"full_name":"acme/app","clone_url":"https://github.com/acme/app.git"}}}}`,
+			want: &Event{
+				Provider: ProviderGitHub, Name: "pull_request", Type: EventTypePullRequest, Action: PullRequestActionUpdated,
+				Repository: "acme/app", RepoURL: "https://github.com/acme/app.git", HeadRepoURL: "https://github.com/fork/app.git",
+				Branch: "fix", CommitHash: "def456", PullRequest: 7,
+			},
+		},
+		{
+			name:  "labelled pull request",
+			event: "pull_request",
+			body:  `{"action":"labeled","number":7}`,
+			want:  &Event{Provider: ProviderGitHub, Name: "pull_request", Type: EventTypePullRequest, Action: PullRequestActionOther, PullRequest: 7},
+		},
+		{
+			name:  "unsupported event",
+			event: "issues",
+			body:  `{}`,
+			want:  &Event{Provider: ProviderGitHub, Name: "issues", Type: EventTypeUnsupported},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header := http.Header{}
+			header.Set("X-GitHub-Event", tt.event)
+			got, err := NewGitHubProvider("").Parse(header, []byte(tt.body))
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Parse() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGitHubProviderParseInvalidPayload(t *testing.T) {
+	header := http.Header{}
+	header.Set("X-GitHub-Event", "push")
+	if _, err := NewGitHubProvider("").Parse(header, []byte(`{"ref":`)); err == nil {
+		t.Error("Parse() error = nil, want an error for a truncated payload")
+	}
+}
+
+func TestGitHubProviderVerify(t *testing.T) {
+	body := []byte(`{"zen":"Design for failure."}`)
+	valid := "sha256=" + hmacHex("secret", body)
+	tests := []struct {
+		name      string
+		secret    string
+		signature string
+		wantErr   bool
+	}{
+		{name: "valid signature", secret: "secret", signature: valid},
+		{name: "wrong secret", secret: "other", signature: valid, wantErr: true},
+		{name: "missing prefix", secret: "secret", signature: hmacHex("secret", body), wantErr: true},
+		{name: "missing signature", secret: "secret", wantErr: true},
+		{name: "not hex", secret: "secret", signature: "sha256=zz", wantErr: true},
+		{name: "no secret configured", signature: "sha256=zz"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header := http.Header{}
+			header.Set("X-Hub-Signature-256", tt.signature)
+			err := NewGitHubProvider(tt.secret).Verify(header, body)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Verify() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
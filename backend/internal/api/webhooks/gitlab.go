@@ -0,0 +1,127 @@
+package webhooks
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/RajVerma97/golang-vercel/backend/internal/logger"
+)
+
+const ProviderGitLab = "gitlab"
+
+// GitLabProvider authenticates with the plain shared token GitLab sends in X-Gitlab-Token
+type GitLabProvider struct {
+	token string
+}
+
+func NewGitLabProvider(token string) *GitLabProvider {
+	return &GitLabProvider{token: token}
+}
+
+type gitLabProject struct {
+	PathWithNamespace string `json:"path_with_namespace"`
+	GitHTTPURL        string `json:"git_http_url"`
+}
+
+type GitLabPushPayload struct {
+	Ref         string       `json:"ref"`
+	After       string       `json:"after"`
+	CheckoutSHA *string      `json:"checkout_sha"`
+	Commits     []pushCommit `json:"commits"`
+	// TotalCommits counts every pushed commit, Commits holds at most 20 of them
+	TotalCommits int           `json:"total_commits_count"`
+	Project      gitLabProject `json:"project"`
+}
+
+type GitLabMergeRequestPayload struct {
+	Project          gitLabProject `json:"project"`
+	ObjectAttributes struct {
+		IID          int           `json:"iid"`
+		Action       string        `json:"action"`
+		OldRev       string        `json:"oldrev"`
+		SourceBranch string        `json:"source_branch"`
+		Source       gitLabProject `json:"source"`
+		Target       gitLabProject `json:"target"`
+		LastCommit   struct {
+			ID string `json:"id"`
+		} `json:"last_commit"`
+	} `json:"object_attributes"`
+}
+
+func (p *GitLabProvider) Name() string {
+	return ProviderGitLab
+}
+
+func (p *GitLabProvider) Verify(header http.Header, body []byte) error {
+	if p.token == "" {
+		logger.Warn("GitLab Webhook: Token not configured, skipping verification")
+		return nil
+	}
+	if subtle.ConstantTimeCompare([]byte(header.Get("X-Gitlab-Token")), []byte(p.token)) != 1 {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+func (p *GitLabProvider) DeliveryID(header http.Header) string {
+	// retries of the same event reuse the idempotency key, newer GitLab versions only
+	if key := header.Get("Idempotency-Key"); key != "" {
+		return key
+	}
+	return header.Get("X-Gitlab-Event-UUID")
+}
+
+func (p *GitLabProvider) Parse(header http.Header, body []byte) (*Event, error) {
+	name := header.Get("X-Gitlab-Event")
+	event := &Event{Provider: ProviderGitLab, Name: name}
+
+	switch name {
+	case "Push Hook":
+		var payload GitLabPushPayload
+		if err := json.Unmarshal(body, &payload); err != nil {
+			return nil, fmt.Errorf("invalid push payload: %w", err)
+		}
+		branch, isBranch := branchFromRef(payload.Ref)
+		event.Type = EventTypePush
+		event.Repository = payload.Project.PathWithNamespace
+		event.RepoURL = payload.Project.GitHTTPURL
+		event.HeadRepoURL = payload.Project.GitHTTPURL
+		event.Branch = branch
+		event.CommitHash = payload.After
+		event.Deleted = !isBranch || payload.CheckoutSHA == nil || isZeroCommit(payload.After)
+		event.ChangedFiles = changedFiles(payload.Commits, payload.TotalCommits)
+	case "Merge Request Hook":
+		var payload GitLabMergeRequestPayload
+		if err := json.Unmarshal(body, &payload); err != nil {
+			return nil, fmt.Errorf("invalid merge request payload: %w", err)
+		}
+		attributes := payload.ObjectAttributes
+		event.Type = EventTypePullRequest
+		event.Repository = payload.Project.PathWithNamespace
+		event.RepoURL = attributes.Target.GitHTTPURL
+		event.HeadRepoURL = attributes.Source.GitHTTPURL
+		event.Branch = attributes.SourceBranch
+		event.CommitHash = attributes.LastCommit.ID
+		event.PullRequest = attributes.IID
+		switch attributes.Action {
+		case "open", "reopen":
+			event.Action = PullRequestActionOpened
+		case "update":
+			// updates also fire for title or label changes; only new commits carry oldrev
+			if attributes.OldRev != "" {
+				event.Action = PullRequestActionUpdated
+			} else {
+				event.Action = PullRequestActionOther
+			}
+		case "close", "merge":
+			event.Action = PullRequestActionClosed
+		default:
+			event.Action = PullRequestActionOther
+		}
+	default:
+		event.Type = EventTypeUnsupported
+	}
+	return event, nil
+}
@@ -0,0 +1,133 @@
+package webhooks
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestGitLabProviderParse(t *testing.T) {
+	project := `"project":{"path_with_namespace":"acme/app","git_http_url":"https://gitlab.com/acme/app.git"}`
+	tests := []struct {
+		name  string
+		event string
+		body  string
+		want  *Event
+	}{
+		{
+			name:  "push to a branch",
+			event: "Push Hook",
+			body:  `{"ref":"refs/heads/main","after":"abc123","checkout_sha":"abc123","total_commits_count":1,"commits":[{"modified":["README.md"]}],` + project + `}`,
+			want: &Event{
+				Provider: ProviderGitLab, Name: "Push Hook", Type: EventTypePush,
+				Repository: "acme/app", RepoURL: "https://gitlab.com/acme/app.git", HeadRepoURL: "https://gitlab.com/acme/app.git",
+				Branch: "main", CommitHash: "abc123", ChangedFiles: []string{"README.md"},
+			},
+		},
+		{
+			name:  "push with commits left out",
+			event: "Push Hook",
+			body:  `{"ref":"refs/heads/main","after":"abc123","checkout_sha":"abc123","total_commits_count":40,"commits":[{"modified":["README.md"]}],` + project + `}`,
+			want: &Event{
+				Provider: ProviderGitLab, Name: "Push Hook", Type: EventTypePush,
+				Repository: "acme/app", RepoURL: "https://gitlab.com/acme/app.git", HeadRepoURL: "https://gitlab.com/acme/app.git",
+				Branch: "main", CommitHash: "abc123",
+			},
+		},
+		{
+			name:  "branch deletion",
+			event: "Push Hook",
+			body:  `{"ref":"refs/heads/old","after":"0000000000000000000000000000000000000000","checkout_sha":null,` + project + `}`,
+			want: &Event{
+				Provider: ProviderGitLab, Name: "Push Hook", Type: EventTypePush,
+				Repository: "acme/app", RepoURL: "https://gitlab.com/acme/app.git", HeadRepoURL: "https://gitlab.com/acme/app.git",
+				Branch: "old", CommitHash: "0000000000000000000000000000000000000000", Deleted: true,
+			},
+		},
+		{
+			name:  "merge request with new commits",
+			event: "Merge Request Hook",
+			body: `{` + project + `,"object_attributes":{"iid":3,"action":"update","oldrev":"abc123","source_branch":"fix",
+				"source":{"git_http_url":"https://gitlab.com/fork/app.git"},"target":{"git_http_url":"https://gitlab.com/acme/app.git"},
+				"last_commit":{"id":"def456"}}}`,
+			want: &Event{
+				Provider: ProviderGitLab, Name: "Merge Request Hook", Type: EventTypePullRequest, Action: PullRequestActionUpdated,
+				Repository: "acme/app", RepoURL: "https://gitlab.com/acme/app.git", HeadRepoURL: "https://gitlab.com/fork/app.git",
+				Branch: "fix", CommitHash: "def456", PullRequest: 3,
+			},
+		},
+		{
+			name:  "merge request title change",
+			event: "Merge Request Hook",
+			body:  `{` + project + `,"object_attributes":{"iid":3,"action":"update","source_branch":"fix","last_commit":{"id":"def456"}}}`,
+			want: &Event{
+				Provider: ProviderGitLab, Name: "Merge Request Hook", Type: EventTypePullRequest, Action: PullRequestActionOther,
+				Repository: "acme/app", Branch: "fix", CommitHash: "def456", PullRequest: 3,
+			},
+		},
+		{
+			name:  "merged merge request",
+			event: "Merge Request Hook",
+			body:  `{` + project + `,"object_attributes":{"iid":3,"action":"merge"}}`,
+			want: &Event{
+				Provider: ProviderGitLab, Name: "Merge Request Hook", Type: EventTypePullRequest, Action: PullRequestActionClosed,
+				Repository: "acme/app", PullRequest: 3,
+			},
+		},
+		{
+			name:  "unsupported event",
+			event: "Issue Hook",
+			body:  `{}`,
+			want:  &Event{Provider: ProviderGitLab, Name: "Issue Hook", Type: EventTypeUnsupported},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header := http.Header{}
+			header.Set("X-Gitlab-Event", tt.event)
+			got, err := NewGitLabProvider("").Parse(header, []byte(tt.body))
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Parse() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGitLabProviderVerify(t *testing.T) {
+	tests := []struct {
+		name    string
+		token   string
+		header  string
+		wantErr bool
+	}{
+		{name: "matching token", token: "secret", header: "secret"},
+		{name: "wrong token", token: "secret", header: "other", wantErr: true},
+		{name: "missing token", token: "secret", wantErr: true},
+		{name: "no token configured", header: "anything"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header := http.Header{}
+			header.Set("X-Gitlab-Token", tt.header)
+			err := NewGitLabProvider(tt.token).Verify(header, []byte(`{}`))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Verify() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestGitLabProviderDeliveryID(t *testing.T) {
+	header := http.Header{}
+	header.Set("X-Gitlab-Event-UUID", "event-uuid")
+	if got := NewGitLabProvider("").DeliveryID(header); got != "event-uuid" {
+		t.Errorf("DeliveryID() = %q, want the event uuid", got)
+	}
+	header.Set("Idempotency-Key", "retry-key")
+	if got := NewGitLabProvider("").DeliveryID(header); got != "retry-key" {
+		t.Errorf("DeliveryID() = %q, want the idempotency key to win", got)
+	}
+}
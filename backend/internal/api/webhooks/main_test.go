@@ -0,0 +1,15 @@
+package webhooks
+
+import (
+	"os"
+	"testing"
+
+	"github.com/RajVerma97/golang-vercel/backend/internal/logger"
+)
+
+func TestMain(m *testing.M) {
+	if err := logger.Init("test"); err != nil {
+		panic(err)
+	}
+	os.Exit(m.Run())
+}
@@ -13,26 +13,43 @@ import (
 	"github.com/RajVerma97/golang-vercel/backend/internal/constants"
 	"github.com/RajVerma97/golang-vercel/backend/internal/dto"
 	"github.com/RajVerma97/golang-vercel/backend/internal/logger"
+	"github.com/RajVerma97/golang-vercel/backend/internal/proxy"
 	"github.com/RajVerma97/golang-vercel/backend/internal/server"
 	"github.com/RajVerma97/golang-vercel/backend/internal/services"
+	"go.uber.org/zap"
 )
 
 type App struct {
-	Config   *config.Config
-	Server   *server.HTTPServer
-	Services *services.Services
+	Config      *config.Config
+	Server      *server.HTTPServer
+	ProxyServer *server.HTTPServer
+	Services    *services.Services
 }
 
 func NewApp() (*App, error) {
 	config := config.NewConfig()
 	ctx := context.Background()
 
+	// proxy
+	proxy := proxy.NewProxy()
+
 	// services
-	services, err := services.NewServices(ctx, config)
+	services, err := services.NewServices(ctx, config, proxy)
 	if err != nil {
 		logger.Error("failed to init services", err)
 		return nil, err
 	}
+	if err := services.ReleaseService.RestoreRoutes(ctx); err != nil {
+		logger.Error("failed to restore proxy routes", err)
+		return nil, err
+	}
+
+	// proxy server
+	proxyServer, err := server.NewHTTPServer(proxy, config.Proxy.ServerConfig())
+	if err != nil {
+		logger.Error("failed to init proxy server", err)
+		return nil, err
+	}
 
 	// router
 	router := routes.InitRouter(services)
@@ -45,9 +62,10 @@ func NewApp() (*App, error) {
 	}
 
 	return &App{
-		Config:   config,
-		Server:   server,
-		Services: services,
+		Config:      config,
+		Server:      server,
+		ProxyServer: proxyServer,
+		Services:    services,
 	}, nil
 }
 
@@ -82,9 +100,33 @@ func (a *App) ProcessJob(ctx context.Context, build *dto.Build) {
 	cwd, _ := os.Getwd()
 	tempDirPath := filepath.Join(cwd, "tmp", fmt.Sprintf("build-%d", build.ID))
 
+	project, err := a.Services.ProjectService.Resolve(ctx, build)
+	if err != nil {
+		logger.Error("failed to resolve project", err)
+		a.failBuild(ctx, build, err)
+		return
+	}
+	a.Services.GitHubStatusService.BuildStarted(ctx, build)
+	a.saveBuild(ctx, build)
+
+	environment := a.Services.ProjectService.Environment(project, build)
+	resolveEnv := a.Services.EnvService.Resolve
+	if a.Services.ProjectService.FromFork(project, build) {
+		logger.Info("Withholding secret env vars from a pull request from a fork", zap.Uint64("build_id", build.ID), zap.String("head_repo_url", build.HeadRepoUrl))
+		resolveEnv = a.Services.EnvService.ResolveWithoutSecrets
+	}
+	env, err := resolveEnv(ctx, project.ID, environment)
+	if err != nil {
+		logger.Error("failed to resolve env vars", err)
+		a.failBuild(ctx, build, err)
+		return
+	}
+	defer logger.RegisterSecrets(env.Secrets()...)()
+
 	// Init environment
 	if err := a.Services.WorkspaceManagerService.Create(ctx, build, tempDirPath); err != nil {
 		logger.Error("failed to create workspace", err)
+		a.failBuild(ctx, build, err)
 		return
 	}
 
@@ -93,27 +135,47 @@ func (a *App) ProcessJob(ctx context.Context, build *dto.Build) {
 			logger.Error("failed to cleanup workspace", err)
 		}
 	}()
-	// Clone repo
-	if err := a.Services.GitService.CloneRepository(ctx, build, tempDirPath); err != nil {
-		logger.Error("failed to clone repo", err)
+
+	job := &job{
+		app:         a,
+		project:     project,
+		build:       build,
+		env:         env,
+		environment: environment,
+		tempDirPath: tempDirPath,
+		pipeline:    services.NewPipeline(build, func() { a.saveBuild(ctx, build) }),
+	}
+	if err := job.pipeline.Add(services.PipelineStep{Name: stepClone, Run: job.clone}); err != nil {
+		a.failBuild(ctx, build, err)
 		return
 	}
-
-	// Build application
-	if err := a.Services.BuildService.BuildApplication(ctx, build, tempDirPath); err != nil {
-		logger.Error("failed to build", err)
+	if err := job.pipeline.Run(ctx); err != nil {
+		// an image that never went live is of no use, one that did is kept for rollbacks
+		if job.deployment == nil || job.deployment.Status != constants.DeploymentStatusRunning {
+			a.Services.ReleaseService.RemoveImage(ctx, build)
+		}
+		// the build's status is the pipeline's, so failing to deploy or a failing post_deploy step
+		// fail the build too. The deployment keeps its own status, which post_deploy steps leave live.
+		a.failBuild(ctx, build, err)
 		return
 	}
+	a.Services.GitHubStatusService.DeploymentSucceeded(ctx, build, job.deployment, job.deploymentURL)
+}
 
-	// Deploy application
-	deployment := &dto.Deployment{
-		BuildID:   build.ID,
-		Status:    constants.DeploymentStatusPending,
-		CreatedAt: time.Now(),
-	}
+func (a *App) failBuild(ctx context.Context, build *dto.Build, err error) {
+	reason := err.Error()
+	now := time.Now()
+	build.Status = constants.BuildStatusFailed
+	build.FailureReason = &reason
+	build.FailureCode = services.FailureCodeOf(err)
+	build.CompletedAt = &now
+	a.saveBuild(ctx, build)
+	a.Services.GitHubStatusService.BuildFailed(ctx, build, reason)
+}
 
-	if err := a.Services.DeployService.DeployApplication(ctx, build, deployment, tempDirPath); err != nil {
-		logger.Error("failed to deploy", err)
-		return
+func (a *App) saveBuild(ctx context.Context, build *dto.Build) {
+	build.UpdatedAt = time.Now()
+	if err := a.Services.RedisService.SaveBuild(ctx, build); err != nil {
+		logger.Error("failed to save build", err)
 	}
 }
@@ -0,0 +1,203 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"github.com/RajVerma97/golang-vercel/backend/internal/constants"
+	"github.com/RajVerma97/golang-vercel/backend/internal/dto"
+	"github.com/RajVerma97/golang-vercel/backend/internal/logger"
+	"github.com/RajVerma97/golang-vercel/backend/internal/services"
+)
+
+// the platform's steps, repository steps cannot take their names
+const (
+	stepClone  = "clone"
+	stepBuild  = "build"
+	stepTest   = "test"
+	stepDeploy = "deploy"
+)
+
+// job is a build being processed, its methods are the platform's pipeline steps.
+// The pipeline is clone, the repository's pre_build steps, build, test when enabled,
+// the post_build steps, deploy and the post_deploy steps. The build is marked successful
+// when deploy starts and failed by whichever step fails, deploy and post_deploy included.
+type job struct {
+	app           *App
+	project       *dto.Project
+	build         *dto.Build
+	env           *services.ResolvedEnv
+	environment   constants.DeploymentEnvironment
+	tempDirPath   string
+	pipeline      *services.Pipeline
+	deployment    *dto.Deployment
+	deploymentURL string
+}
+
+// clone checks the repository out and adds the rest of the pipeline, which depends on its build config
+func (j *job) clone(ctx context.Context, result *dto.StepResult) error {
+	if err := j.app.Services.GitService.CloneRepository(ctx, j.project, j.build, j.tempDirPath); err != nil {
+		return err
+	}
+	if err := j.app.Services.BuildService.PrepareBuild(j.project, j.build, j.tempDirPath); err != nil {
+		return err
+	}
+	if err := j.pipeline.Add(j.steps()...); err != nil {
+		return fmt.Errorf("invalid pipeline: %w", err)
+	}
+	return nil
+}
+
+// steps wires the repository's steps around the platform's, each stage waits for the one before
+func (j *job) steps() []services.PipelineStep {
+	stages := map[constants.StepStage][]dto.StepConfig{}
+	var config dto.BuildConfig
+	if j.build.Config != nil {
+		config = *j.build.Config
+	}
+	for _, step := range config.Steps {
+		stage := step.Stage
+		if stage == "" {
+			stage = constants.StepStagePostBuild
+		}
+		stages[stage] = append(stages[stage], step)
+	}
+
+	var steps []services.PipelineStep
+	stage := func(configs []dto.StepConfig, after []string) []string {
+		names := make([]string, 0, len(configs))
+		for _, config := range configs {
+			steps = append(steps, services.PipelineStep{Name: config.Name, Needs: append(append([]string{}, after...), config.Needs...), Run: j.repoStep(config)})
+			names = append(names, config.Name)
+		}
+		return names
+	}
+
+	preBuild := stage(stages[constants.StepStagePreBuild], []string{stepClone})
+	steps = append(steps, services.PipelineStep{Name: stepBuild, Needs: append([]string{stepClone}, preBuild...), Run: j.compile})
+	built := []string{stepBuild}
+	if j.project.Test.Enabled {
+		steps = append(steps, services.PipelineStep{Name: stepTest, Needs: []string{stepBuild}, Run: j.test})
+		built = append(built, stepTest)
+	}
+	postBuild := stage(stages[constants.StepStagePostBuild], built)
+	steps = append(steps, services.PipelineStep{Name: stepDeploy, Needs: append(built, postBuild...), Run: j.deploy})
+	stage(stages[constants.StepStagePostDeploy], []string{stepDeploy})
+	return steps
+}
+
+// compile builds the Dockerfile's image, or the binary which is kept so the deployment can be
+// restarted without a rebuild
+func (j *job) compile(ctx context.Context, result *dto.StepResult) error {
+	if j.build.Dockerfile != "" {
+		err := j.app.Services.BuildService.BuildImage(ctx, j.project, j.build, j.tempDirPath, j.env, func() { j.app.saveBuild(ctx, j.build) })
+		result.Logs = j.build.Logs
+		return err
+	}
+	err := j.app.Services.BuildService.BuildApplication(ctx, j.project, j.build, j.tempDirPath, j.env)
+	result.Logs = j.build.Logs
+	if err != nil {
+		return err
+	}
+	return j.app.Services.WorkspaceManagerService.RetainArtifact(ctx, j.build, filepath.Join(j.tempDirPath, j.project.RootDirectory))
+}
+
+func (j *job) test(ctx context.Context, result *dto.StepResult) error {
+	before := len(j.build.Logs)
+	err := j.app.Services.BuildService.TestApplication(ctx, j.project, j.build, j.tempDirPath, j.env)
+	result.Logs = j.build.Logs[before:]
+	return err
+}
+
+// deploy starts the deployment and puts it live. A failed deployment is recorded as such,
+// the current production deployment keeps serving.
+func (j *job) deploy(ctx context.Context, result *dto.StepResult) error {
+	// deploy waits for every step before it, so the build, its tests and the post_build steps passed
+	completedAt := time.Now()
+	j.build.Status = constants.BuildStatusSuccess
+	j.build.CompletedAt = &completedAt
+
+	deploymentID, err := j.app.Services.RedisService.NextDeploymentID(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to assign deployment id: %w", err)
+	}
+	deployment := &dto.Deployment{
+		ID:          deploymentID,
+		ProjectID:   j.project.ID,
+		BuildID:     j.build.ID,
+		Environment: j.environment,
+		Branch:      j.build.Branch,
+		CommitHash:  j.build.CommitHash,
+		PullRequest: j.build.PullRequest,
+		Status:      constants.DeploymentStatusPending,
+		CreatedAt:   time.Now(),
+	}
+	j.deployment = deployment
+	j.build.DeploymentID = deployment.ID
+	j.app.saveBuild(ctx, j.build)
+
+	err = j.app.Services.DeployService.DeployApplication(ctx, j.project, j.build, deployment, j.env)
+	result.Logs = deployment.Logs
+	if err != nil {
+		reason := err.Error()
+		deployment.Status = constants.DeploymentStatusFailed
+		deployment.FailureReason = &reason
+		deployment.FailureCode = services.FailureCodeOf(err)
+	}
+	deployment.UpdatedAt = time.Now()
+	if saveErr := j.app.Services.RedisService.SaveDeployment(ctx, deployment); saveErr != nil {
+		logger.Error("failed to save deployment", saveErr)
+	}
+	if err != nil {
+		return err
+	}
+
+	switch deployment.Environment {
+	case constants.DeploymentEnvironmentProduction:
+		if err := j.app.Services.ReleaseService.Promote(ctx, j.project, deployment); err != nil {
+			return fmt.Errorf("failed to promote deployment: %w", err)
+		}
+		j.deploymentURL = j.project.ProductionURL
+	case constants.DeploymentEnvironmentPreview:
+		if err := j.app.Services.ReleaseService.PublishPreview(ctx, j.project, deployment); err != nil {
+			return fmt.Errorf("failed to publish preview: %w", err)
+		}
+		// the immutable commit URL comes first when the build has a commit
+		j.deploymentURL = deployment.Aliases[0]
+	}
+	return nil
+}
+
+// repoStep runs a step of the repository's build config and keeps its artifacts
+func (j *job) repoStep(config dto.StepConfig) func(context.Context, *dto.StepResult) error {
+	return func(ctx context.Context, result *dto.StepResult) error {
+		if err := j.app.Services.BuildService.RunRepoStep(ctx, j.project, j.build, j.tempDirPath, j.env, config, j.stepEnv(), result); err != nil {
+			return err
+		}
+		rootDir := filepath.Join(j.tempDirPath, j.project.RootDirectory)
+		artifacts, err := j.app.Services.WorkspaceManagerService.RetainStepArtifacts(ctx, j.build, config.Name, rootDir, config.Artifacts)
+		result.Artifacts = artifacts
+		return err
+	}
+}
+
+// stepEnv describes the build to the repository's steps, post_deploy steps also get the deployment's URL
+func (j *job) stepEnv() []string {
+	env := []string{
+		"CI=true",
+		fmt.Sprintf("BUILD_ID=%d", j.build.ID),
+		"DEPLOYMENT_ENVIRONMENT=" + j.environment.String(),
+	}
+	if j.build.Branch != nil {
+		env = append(env, "GIT_BRANCH="+*j.build.Branch)
+	}
+	if j.build.CommitHash != nil {
+		env = append(env, "GIT_COMMIT="+*j.build.CommitHash)
+	}
+	if j.deploymentURL != "" {
+		env = append(env, "DEPLOYMENT_URL="+j.deploymentURL)
+	}
+	return env
+}
@@ -2,15 +2,20 @@ package docker_client
 
 import (
 	"context"
+	"fmt"
 	"io"
 	"os"
 	"strings"
 
+	"github.com/RajVerma97/golang-vercel/backend/internal/config"
 	"github.com/RajVerma97/golang-vercel/backend/internal/logger"
 	"github.com/docker/docker/api/types/container"
 	"github.com/docker/docker/api/types/image"
+	"github.com/docker/docker/api/types/network"
 	"github.com/docker/docker/client"
+	"github.com/docker/docker/pkg/stdcopy"
 	"github.com/docker/go-connections/nat"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
 	"go.uber.org/zap"
 )
 
@@ -32,10 +37,11 @@ func (c *DockerClient) Close() error {
 	return c.client.Close()
 }
 
-func (c *DockerClient) PullImage(ctx context.Context, imageName string) error {
-	logger.Debug("Pulling image", zap.String("image", imageName))
+// PullImage pulls imageName for platform, such as linux/arm64, or for the daemon's platform when it is empty
+func (c *DockerClient) PullImage(ctx context.Context, imageName, platform string) error {
+	logger.Debug("Pulling image", zap.String("image", imageName), zap.String("platform", platform))
 
-	reader, err := c.client.ImagePull(ctx, imageName, image.PullOptions{})
+	reader, err := c.client.ImagePull(ctx, imageName, image.PullOptions{Platform: platform})
 	if err != nil {
 		logger.Error("Failed to pull image", err)
 		return err
@@ -49,25 +55,45 @@ func (c *DockerClient) PullImage(ctx context.Context, imageName string) error {
 	return nil
 }
 
+// PinImage returns imageName pinned to the digest the registry serves for it
+func (c *DockerClient) PinImage(ctx context.Context, imageName string) (string, error) {
+	inspect, err := c.client.DistributionInspect(ctx, imageName, "")
+	if err != nil {
+		return "", err
+	}
+	return imageName + "@" + inspect.Descriptor.Digest.String(), nil
+}
+
+// Sandbox hardens a container that runs untrusted code
+type Sandbox struct {
+	User string
+	// SeccompProfile is the profile's JSON, docker's default profile applies when empty
+	SeccompProfile string
+	TmpfsSize      int64
+	UsernsMode     string
+}
+
 // 2. CREATE CONTAINER (with volume mounts for your build files)
-func (c *DockerClient) CreateBuildContainer(ctx context.Context, imageName, containerName, workDir string, volumeBinds []string) (string, error) {
+// networkName is the network the container joins, docker's default bridge when empty
+func (c *DockerClient) CreateBuildContainer(ctx context.Context, imageName, platform, containerName, workDir, cmd string, volumeBinds []string, env []string, networkName string, limits config.ResourceLimits, sandbox *Sandbox) (string, error) {
 	logger.Debug("Creating container", zap.String("image", imageName))
-	cmd := `
-		set -e
-		go mod tidy
-		 CGO_ENABLED=0 GOOS=linux GOARCH=amd64 go build -o bin/app .
-		`
+	containerConfig := &container.Config{
+		Image:      imageName,
+		WorkingDir: workDir,
+		Cmd:        []string{"sh", "-c", cmd},
+		Env:        env,
+	}
+	hostConfig := &container.HostConfig{
+		Binds:       volumeBinds, // e.g., ["/tmp/build-1:/app"]
+		NetworkMode: container.NetworkMode(networkName),
+		Resources:   resources(limits),
+		StorageOpt:  storageOpt(limits),
+	}
+	if sandbox != nil {
+		applySandbox(containerConfig, hostConfig, sandbox)
+	}
 
-	resp, err := c.client.ContainerCreate(ctx,
-		&container.Config{
-			Image:      imageName,
-			WorkingDir: workDir,
-			Cmd:        []string{"sh", "-c", cmd},
-		},
-		&container.HostConfig{
-			Binds: volumeBinds, // e.g., ["/tmp/build-1:/app"]
-		},
-		nil, nil, containerName)
+	resp, err := c.client.ContainerCreate(ctx, containerConfig, hostConfig, nil, ociPlatform(platform), containerName)
 
 	if err != nil {
 		logger.Error("Failed to create container", err)
@@ -77,41 +103,198 @@ func (c *DockerClient) CreateBuildContainer(ctx context.Context, imageName, cont
 	logger.Debug("✅ Successfully created container", zap.String("container_id", resp.ID))
 	return resp.ID, nil
 }
-func (c *DockerClient) CreateDeploymentContainer(ctx context.Context, imageName string, containerName string, volumeBinds []string, port string, deploymentID int) (string, error) {
+
+// ociPlatform turns os/arch into docker's platform, nil lets the daemon pick its own
+func ociPlatform(platform string) *ocispec.Platform {
+	if platform == "" {
+		return nil
+	}
+	goos, goarch, _ := strings.Cut(platform, "/")
+	return &ocispec.Platform{OS: goos, Architecture: goarch}
+}
+
+// NetworkAttachment puts a container on a user defined network instead of the default bridge
+type NetworkAttachment struct {
+	Name string
+	// Internal networks cannot publish ports, the container is reached on its network address
+	Internal bool
+	Aliases  []string
+}
+
+// CreateDeploymentContainer runs cmd in /app, a nil cmd runs the image's own entrypoint and command
+func (c *DockerClient) CreateDeploymentContainer(ctx context.Context, imageName, platform, containerName string, cmd []string, volumeBinds []string, port string, deploymentID int, env []string, limits config.ResourceLimits, attachment *NetworkAttachment) (string, error) {
 	logger.Debug("Creating deployment container", zap.String("name", containerName))
+	containerConfig := &container.Config{
+		Image: imageName,
+		Cmd:   cmd,
+		Env:   env,
+		ExposedPorts: nat.PortSet{
+			nat.Port(port + "/tcp"): struct{}{},
+		},
+	}
+	if cmd != nil {
+		containerConfig.WorkingDir = "/app"
+	}
+	hostConfig := &container.HostConfig{
+		Binds: volumeBinds,
+		RestartPolicy: container.RestartPolicy{
+			Name: "unless-stopped",
+		},
+		Resources:  resources(limits),
+		StorageOpt: storageOpt(limits),
+	}
+	if attachment == nil || !attachment.Internal {
+		// published on loopback only so the reverse proxy is the single way in
+		hostConfig.PortBindings = nat.PortMap{
+			nat.Port(port + "/tcp"): []nat.PortBinding{
+				{
+					HostIP:   "127.0.0.1",
+					HostPort: "0", // Docker assigns random port
+				},
+			},
+		}
+	}
+	networkingConfig := networkingConfig(hostConfig, attachment)
+
+	resp, err := c.client.ContainerCreate(ctx, containerConfig, hostConfig, networkingConfig, ociPlatform(platform), containerName)
+	if err != nil {
+		logger.Error("Failed to create deployment container", err)
+		return "", err
+	}
+
+	logger.Debug("✅ Successfully created deployment container", zap.String("container_id", resp.ID))
+	return resp.ID, nil
+}
+
+// CreateEgressProxyContainer runs a squid proxy with the config at configPath. It starts on the default
+// bridge for outbound access and has to be connected to the networks it serves.
+func (c *DockerClient) CreateEgressProxyContainer(ctx context.Context, imageName, containerName, configPath string) (string, error) {
+	logger.Debug("Creating egress proxy container", zap.String("name", containerName))
 	resp, err := c.client.ContainerCreate(ctx,
 		&container.Config{
-			Image:      imageName,
-			WorkingDir: "/app",
-			Cmd:        []string{"/app/app"},
-			ExposedPorts: nat.PortSet{
-				nat.Port(port + "/tcp"): struct{}{},
-			},
+			Image: imageName,
 		},
 		&container.HostConfig{
-			Binds: volumeBinds,
-			// ADD PORT BINDINGS BACK for direct access
-			PortBindings: nat.PortMap{
-				nat.Port(port + "/tcp"): []nat.PortBinding{
-					{
-						HostIP:   "0.0.0.0",
-						HostPort: "0", // Docker assigns random port
-					},
-				},
-			},
+			Binds: []string{configPath + ":/etc/squid/squid.conf:ro"},
 			RestartPolicy: container.RestartPolicy{
 				Name: "unless-stopped",
 			},
 		},
 		nil, nil, containerName)
+	if err != nil {
+		logger.Error("Failed to create egress proxy container", err)
+		return "", err
+	}
+	return resp.ID, nil
+}
+
+// EnsureNetwork creates the bridge network unless it already exists and returns the name of its
+// bridge interface on the host
+func (c *DockerClient) EnsureNetwork(ctx context.Context, name string, internal bool, labels map[string]string) (string, error) {
+	existing, err := c.client.NetworkInspect(ctx, name, network.InspectOptions{})
+	if err == nil {
+		if existing.Internal != internal {
+			return "", fmt.Errorf("network %s already exists with internal=%t", name, existing.Internal)
+		}
+		return bridgeInterface(existing.ID, existing.Options), nil
+	}
+	if !client.IsErrNotFound(err) {
+		logger.Error("Failed to inspect network", err, zap.String("network", name))
+		return "", err
+	}
 
+	resp, err := c.client.NetworkCreate(ctx, name, network.CreateOptions{
+		Driver:   "bridge",
+		Internal: internal,
+		Labels:   labels,
+	})
 	if err != nil {
-		logger.Error("Failed to create deployment container", err)
+		logger.Error("Failed to create network", err, zap.String("network", name))
 		return "", err
 	}
+	logger.Debug("Successfully created network", zap.String("network", name), zap.Bool("internal", internal))
+	return bridgeInterface(resp.ID, nil), nil
+}
 
-	logger.Debug("✅ Successfully created deployment container", zap.String("container_id", resp.ID))
-	return resp.ID, nil
+// bridgeInterface is the host interface of a bridge network, docker names it after the network's id
+// unless the network was created with a name for it
+func bridgeInterface(networkID string, options map[string]string) string {
+	if name := options["com.docker.network.bridge.name"]; name != "" {
+		return name
+	}
+	if len(networkID) > 12 {
+		networkID = networkID[:12]
+	}
+	return "br-" + networkID
+}
+
+// ConnectNetwork attaches a container to another network, doing nothing when it already is
+func (c *DockerClient) ConnectNetwork(ctx context.Context, networkName, containerID string, aliases []string) error {
+	inspect, err := c.client.ContainerInspect(ctx, containerID)
+	if err != nil {
+		return err
+	}
+	if inspect.NetworkSettings != nil {
+		if _, ok := inspect.NetworkSettings.Networks[networkName]; ok {
+			return nil
+		}
+	}
+	if err := c.client.NetworkConnect(ctx, networkName, containerID, &network.EndpointSettings{Aliases: aliases}); err != nil {
+		logger.Error("Failed to connect container to network", err, zap.String("network", networkName), zap.String("container_id", containerID))
+		return err
+	}
+	return nil
+}
+
+func networkingConfig(hostConfig *container.HostConfig, attachment *NetworkAttachment) *network.NetworkingConfig {
+	if attachment == nil {
+		return nil
+	}
+	hostConfig.NetworkMode = container.NetworkMode(attachment.Name)
+	return &network.NetworkingConfig{
+		EndpointsConfig: map[string]*network.EndpointSettings{
+			attachment.Name: {Aliases: attachment.Aliases},
+		},
+	}
+}
+
+func applySandbox(containerConfig *container.Config, hostConfig *container.HostConfig, sandbox *Sandbox) {
+	containerConfig.User = sandbox.User
+	hostConfig.CapDrop = []string{"ALL"}
+	hostConfig.SecurityOpt = []string{"no-new-privileges"}
+	if sandbox.SeccompProfile != "" {
+		hostConfig.SecurityOpt = append(hostConfig.SecurityOpt, "seccomp="+sandbox.SeccompProfile)
+	}
+	hostConfig.ReadonlyRootfs = true
+	// go runs the tools it compiles out of its temp dir, so /tmp needs exec
+	hostConfig.Tmpfs = map[string]string{
+		"/tmp": fmt.Sprintf("rw,exec,nosuid,nodev,size=%d", sandbox.TmpfsSize),
+	}
+	hostConfig.UsernsMode = container.UsernsMode(sandbox.UsernsMode)
+}
+
+// resources maps limits onto the container's cgroup settings
+func resources(limits config.ResourceLimits) container.Resources {
+	pidsLimit := limits.PidsLimit
+	res := container.Resources{
+		NanoCPUs: limits.NanoCPUs,
+		Memory:   limits.MemoryBytes,
+	}
+	if limits.MemoryBytes > 0 {
+		// no swap on top of the memory limit, so running out gets the container OOM killed
+		res.MemorySwap = limits.MemoryBytes
+	}
+	if pidsLimit > 0 {
+		res.PidsLimit = &pidsLimit
+	}
+	return res
+}
+
+func storageOpt(limits config.ResourceLimits) map[string]string {
+	if limits.DiskBytes <= 0 {
+		return nil
+	}
+	return map[string]string{"size": fmt.Sprintf("%d", limits.DiskBytes)}
 }
 
 func (c *DockerClient) ListContainers(ctx context.Context) error {
@@ -219,6 +402,15 @@ func (c *DockerClient) RemoveContainer(ctx context.Context, identifier string) e
 }
 
 func (c *DockerClient) GetContainerLogs(ctx context.Context, containerID string) (string, error) {
+	buf := new(strings.Builder)
+	if err := c.CopyContainerLogs(ctx, containerID, buf); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// CopyContainerLogs demultiplexes the container's stdout and stderr into dst
+func (c *DockerClient) CopyContainerLogs(ctx context.Context, containerID string, dst io.Writer) error {
 	options := container.LogsOptions{
 		ShowStdout: true,
 		ShowStderr: true,
@@ -228,12 +420,9 @@ func (c *DockerClient) GetContainerLogs(ctx context.Context, containerID string)
 	reader, err := c.client.ContainerLogs(ctx, containerID, options)
 	if err != nil {
 		logger.Error("failed to get container logs", err, zap.String("container_id", containerID))
-		return "", err
-	}
-	buf := new(strings.Builder)
-	_, err = io.Copy(buf, reader)
-	if err != nil {
-		return "", err
+		return err
 	}
-	return buf.String(), nil
+	defer reader.Close()
+	_, err = stdcopy.StdCopy(dst, dst, reader)
+	return err
 }
@@ -0,0 +1,105 @@
+package docker_client
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/RajVerma97/golang-vercel/backend/internal/config"
+	"github.com/docker/docker/api/types/container"
+)
+
+// createdContainer is the body of a container create request
+type createdContainer struct {
+	container.Config
+	HostConfig container.HostConfig
+}
+
+// recordCreates fakes a daemon that accepts container creates and returns their bodies
+func recordCreates(t *testing.T) (*DockerClient, *[]createdContainer) {
+	var created []createdContainer
+	c := newTestDockerClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || !strings.HasSuffix(r.URL.Path, "/containers/create") {
+			http.Error(w, `{"message":"unexpected request"}`, http.StatusNotImplemented)
+			return
+		}
+		var body createdContainer
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("invalid create body: %v", err)
+		}
+		created = append(created, body)
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(container.CreateResponse{ID: "c1"})
+	})
+	return c, &created
+}
+
+func TestCreateBuildContainerSandbox(t *testing.T) {
+	limits := config.ResourceLimits{NanoCPUs: 2e9, MemoryBytes: 1 << 30, PidsLimit: 512}
+	sandbox := &Sandbox{User: "10001:10001", SeccompProfile: `{"defaultAction":"SCMP_ACT_ERRNO"}`, TmpfsSize: 1 << 28, UsernsMode: "host"}
+	tests := []struct {
+		name    string
+		sandbox *Sandbox
+		check   func(t *testing.T, got createdContainer)
+	}{
+		{
+			name: "standard",
+			check: func(t *testing.T, got createdContainer) {
+				if got.User != "" || got.HostConfig.ReadonlyRootfs || len(got.HostConfig.CapDrop) != 0 || len(got.HostConfig.SecurityOpt) != 0 {
+					t.Errorf("standard build is hardened: user %q, host config %+v", got.User, got.HostConfig)
+				}
+			},
+		},
+		{
+			name:    "hardened",
+			sandbox: sandbox,
+			check: func(t *testing.T, got createdContainer) {
+				host := got.HostConfig
+				if got.User != "10001:10001" {
+					t.Errorf("user = %q, want the sandbox user", got.User)
+				}
+				if !reflect.DeepEqual([]string(host.CapDrop), []string{"ALL"}) {
+					t.Errorf("cap drop = %v, want ALL", host.CapDrop)
+				}
+				wantSecurity := []string{"no-new-privileges", "seccomp=" + sandbox.SeccompProfile}
+				if !reflect.DeepEqual(host.SecurityOpt, wantSecurity) {
+					t.Errorf("security opts = %q, want %q", host.SecurityOpt, wantSecurity)
+				}
+				if !host.ReadonlyRootfs {
+					t.Error("root filesystem is writable")
+				}
+				if want := "rw,exec,nosuid,nodev,size=268435456"; host.Tmpfs["/tmp"] != want {
+					t.Errorf("/tmp = %q, want %q", host.Tmpfs["/tmp"], want)
+				}
+				if host.UsernsMode != "host" {
+					t.Errorf("userns mode = %q, want host", host.UsernsMode)
+				}
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, created := recordCreates(t)
+			binds := []string{"/tmp/build-1:/app"}
+			if _, err := c.CreateBuildContainer(context.Background(), "golang:1.22", "", "build-1", "/app", "go build", binds, []string{"CGO_ENABLED=0"}, "platform-builds", limits, tt.sandbox); err != nil {
+				t.Fatal(err)
+			}
+			if len(*created) != 1 {
+				t.Fatalf("created %d containers, want 1", len(*created))
+			}
+			got := (*created)[0]
+			if got.Image != "golang:1.22" || !reflect.DeepEqual(got.HostConfig.Binds, binds) || got.HostConfig.NetworkMode != "platform-builds" {
+				t.Errorf("created %s with binds %v on %s", got.Image, got.HostConfig.Binds, got.HostConfig.NetworkMode)
+			}
+			// limits apply whatever the profile
+			res := got.HostConfig.Resources
+			if res.NanoCPUs != limits.NanoCPUs || res.Memory != limits.MemoryBytes || res.MemorySwap != limits.MemoryBytes || res.PidsLimit == nil || *res.PidsLimit != 512 {
+				t.Errorf("resources = %+v, want the limits %+v without swap", res, limits)
+			}
+			tt.check(t, got)
+		})
+	}
+}
@@ -0,0 +1,261 @@
+package docker_client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/RajVerma97/golang-vercel/backend/internal/config"
+	"github.com/RajVerma97/golang-vercel/backend/internal/logger"
+	"github.com/docker/docker/api/types/build"
+	"github.com/docker/docker/api/types/image"
+	"github.com/docker/docker/pkg/jsonmessage"
+	"go.uber.org/zap"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// cpuPeriod is the CFS period, in microseconds, that the CPU quota of image builds is a share of
+const cpuPeriod = 100000
+
+// buildkitTraceID marks the messages carrying BuildKit's progress, a StatusResponse protobuf
+const buildkitTraceID = "moby.buildkit.trace"
+
+// ImageBuild describes a Dockerfile build, the context is a tar of the build's directory
+type ImageBuild struct {
+	Context    io.Reader
+	Dockerfile string
+	Tag        string
+	Platform   string
+	BuildArgs  map[string]*string
+	Labels     map[string]string
+	// NetworkMode is the network of RUN instructions, "none" cuts them off, the daemon's default when empty.
+	// A user defined network needs the classic builder, BuildKit only knows the built in ones.
+	NetworkMode string
+	// Limits caps every RUN instruction, only the classic builder applies them
+	Limits config.ResourceLimits
+}
+
+// builderVersion is BuildKit unless the build has to run where only the classic builder can put it.
+// BuildKit ignores the memory and CPU options of a build, so a build with limits runs on the classic
+// builder, which puts every RUN instruction in a container with them.
+func builderVersion(options ImageBuild) build.BuilderVersion {
+	if options.Limits.MemoryBytes > 0 || options.Limits.NanoCPUs > 0 {
+		return build.BuilderV1
+	}
+	switch options.NetworkMode {
+	case "", "default", "none", "host":
+		return build.BuilderBuildKit
+	default:
+		return build.BuilderV1
+	}
+}
+
+// BuildImage builds with BuildKit, or the classic builder, see builderVersion, and returns the ID of the tagged
+// image. The build's progress is written to out as plain text while it runs, like docker build --progress=plain prints it.
+func (c *DockerClient) BuildImage(ctx context.Context, options ImageBuild, out io.Writer) (string, error) {
+	logger.Debug("Building image", zap.String("tag", options.Tag), zap.String("dockerfile", options.Dockerfile))
+	limits := resources(options.Limits)
+	resp, err := c.client.ImageBuild(ctx, options.Context, build.ImageBuildOptions{
+		Tags:        []string{options.Tag},
+		Dockerfile:  options.Dockerfile,
+		Platform:    options.Platform,
+		BuildArgs:   options.BuildArgs,
+		Labels:      options.Labels,
+		NetworkMode: options.NetworkMode,
+		Memory:      limits.Memory,
+		MemorySwap:  limits.MemorySwap,
+		CPUPeriod:   cpuPeriod,
+		CPUQuota:    limits.NanoCPUs * cpuPeriod / 1e9,
+		Remove:      true,
+		Version:     builderVersion(options),
+	})
+	if err != nil {
+		logger.Error("Failed to start image build", err)
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	progress := newBuildProgress(out)
+	decoder := json.NewDecoder(resp.Body)
+	for {
+		var message jsonmessage.JSONMessage
+		if err := decoder.Decode(&message); err == io.EOF {
+			break
+		} else if err != nil {
+			return "", fmt.Errorf("failed to read build output: %w", err)
+		}
+		if message.Error != nil {
+			return "", errors.New(message.Error.Message)
+		}
+		if message.ID == buildkitTraceID && message.Aux != nil {
+			var trace []byte
+			if err := json.Unmarshal(*message.Aux, &trace); err != nil {
+				return "", fmt.Errorf("invalid build progress: %w", err)
+			}
+			if err := progress.write(trace); err != nil {
+				return "", fmt.Errorf("invalid build progress: %w", err)
+			}
+			continue
+		}
+		// the classic builder's output, and BuildKit's own messages
+		io.WriteString(out, message.Stream)
+	}
+
+	inspect, err := c.client.ImageInspect(ctx, options.Tag)
+	if err != nil {
+		return "", fmt.Errorf("failed to inspect built image: %w", err)
+	}
+	logger.Debug("Successfully built image", zap.String("tag", options.Tag), zap.String("image_id", inspect.ID))
+	return inspect.ID, nil
+}
+
+// RemoveImage removes an image built for a deployment, tags of other builds keep their layers
+func (c *DockerClient) RemoveImage(ctx context.Context, imageName string) error {
+	_, err := c.client.ImageRemove(ctx, imageName, image.RemoveOptions{PruneChildren: true})
+	return err
+}
+
+// buildProgress numbers the vertexes of a BuildKit build as they show up and prints their
+// names, logs and outcomes
+type buildProgress struct {
+	out     io.Writer
+	numbers map[string]int
+	printed map[string]bool
+	done    map[string]bool
+}
+
+func newBuildProgress(out io.Writer) *buildProgress {
+	return &buildProgress{out: out, numbers: map[string]int{}, printed: map[string]bool{}, done: map[string]bool{}}
+}
+
+func (p *buildProgress) number(digest string) int {
+	if n, ok := p.numbers[digest]; ok {
+		return n
+	}
+	p.numbers[digest] = len(p.numbers) + 1
+	return p.numbers[digest]
+}
+
+// write decodes a StatusResponse, only the vertexes (1) and the logs (3) are of interest
+func (p *buildProgress) write(status []byte) error {
+	return eachField(status, func(num protowire.Number, value []byte) error {
+		switch num {
+		case 1:
+			return p.vertex(value)
+		case 3:
+			return p.log(value)
+		}
+		return nil
+	})
+}
+
+// vertex prints a Vertex: digest (1), name (3), cached (4), started (5), completed (6) and error (7)
+func (p *buildProgress) vertex(data []byte) error {
+	var digest, name, vertexErr string
+	var cached, started, completed bool
+	err := eachField(data, func(num protowire.Number, value []byte) error {
+		switch num {
+		case 1:
+			digest = string(value)
+		case 3:
+			name = string(value)
+		case 4:
+			cached = len(value) > 0 && value[0] != 0
+		case 5:
+			started = true
+		case 6:
+			completed = true
+		case 7:
+			vertexErr = string(value)
+		}
+		return nil
+	})
+	if err != nil || digest == "" {
+		return err
+	}
+
+	n := p.number(digest)
+	if !p.printed[digest] && (started || cached || completed) {
+		p.printed[digest] = true
+		fmt.Fprintf(p.out, "#%d %s\n", n, name)
+	}
+	if p.done[digest] || !completed && !cached {
+		return nil
+	}
+	p.done[digest] = true
+	switch {
+	case vertexErr != "":
+		fmt.Fprintf(p.out, "#%d ERROR: %s\n", n, vertexErr)
+	case cached:
+		fmt.Fprintf(p.out, "#%d CACHED\n", n)
+	default:
+		fmt.Fprintf(p.out, "#%d DONE\n", n)
+	}
+	return nil
+}
+
+// log prints a VertexLog: vertex (1) and msg (4)
+func (p *buildProgress) log(data []byte) error {
+	var digest string
+	var msg []byte
+	err := eachField(data, func(num protowire.Number, value []byte) error {
+		switch num {
+		case 1:
+			digest = string(value)
+		case 4:
+			msg = value
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	n := p.number(digest)
+	for _, line := range strings.SplitAfter(string(msg), "\n") {
+		if line != "" {
+			fmt.Fprintf(p.out, "#%d %s", n, line)
+		}
+	}
+	if len(msg) > 0 && msg[len(msg)-1] != '\n' {
+		io.WriteString(p.out, "\n")
+	}
+	return nil
+}
+
+// eachField walks the fields of a protobuf message. Length delimited fields are passed as their
+// bytes, varints as a single byte that is 0 when the value is, other types as nil.
+func eachField(data []byte, fn func(num protowire.Number, value []byte) error) error {
+	for len(data) > 0 {
+		num, typ, n := protowire.ConsumeTag(data)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		data = data[n:]
+
+		var value []byte
+		switch typ {
+		case protowire.BytesType:
+			value, n = protowire.ConsumeBytes(data)
+		case protowire.VarintType:
+			var v uint64
+			v, n = protowire.ConsumeVarint(data)
+			value = []byte{0}
+			if v != 0 {
+				value[0] = 1
+			}
+		default:
+			n = protowire.ConsumeFieldValue(num, typ, data)
+		}
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		data = data[n:]
+		if err := fn(num, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
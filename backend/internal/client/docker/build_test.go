@@ -0,0 +1,231 @@
+package docker_client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/RajVerma97/golang-vercel/backend/internal/config"
+	"github.com/docker/docker/api/types/image"
+	"github.com/docker/docker/pkg/jsonmessage"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+func bytesField(b []byte, num protowire.Number, value string) []byte {
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendString(b, value)
+}
+
+func varintField(b []byte, num protowire.Number, value uint64) []byte {
+	b = protowire.AppendTag(b, num, protowire.VarintType)
+	return protowire.AppendVarint(b, value)
+}
+
+func messageField(b []byte, num protowire.Number, message []byte) []byte {
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendBytes(b, message)
+}
+
+func TestEachField(t *testing.T) {
+	type field struct {
+		num   protowire.Number
+		value []byte
+	}
+	fixed := protowire.AppendTag(nil, 5, protowire.Fixed64Type)
+	fixed = protowire.AppendFixed64(fixed, 42)
+
+	tests := []struct {
+		name    string
+		data    []byte
+		want    []field
+		wantErr bool
+	}{
+		{name: "empty"},
+		{
+			name: "bytes and varints",
+			data: varintField(varintField(bytesField(nil, 1, "sha256:a"), 4, 1), 6, 0),
+			want: []field{{1, []byte("sha256:a")}, {4, []byte{1}}, {6, []byte{0}}},
+		},
+		{
+			name: "other wire types are skipped over",
+			data: bytesField(fixed, 3, "name"),
+			want: []field{{5, nil}, {3, []byte("name")}},
+		},
+		{
+			name:    "truncated",
+			data:    bytesField(nil, 1, "sha256:a")[:4],
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []field
+			err := eachField(tt.data, func(num protowire.Number, value []byte) error {
+				got = append(got, field{num, value})
+				return nil
+			})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("eachField() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("eachField() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEachFieldStopsOnError(t *testing.T) {
+	stop := errors.New("stop")
+	calls := 0
+	err := eachField(bytesField(bytesField(nil, 1, "a"), 1, "b"), func(protowire.Number, []byte) error {
+		calls++
+		return stop
+	})
+	if err != stop || calls != 1 {
+		t.Errorf("eachField() = %v after %d calls, want stop after 1", err, calls)
+	}
+}
+
+// vertex encodes a Vertex, timestamps are empty messages as only their presence matters
+func vertex(digest, name string, cached, started, completed bool, vertexErr string) []byte {
+	b := bytesField(nil, 1, digest)
+	b = bytesField(b, 3, name)
+	if cached {
+		b = varintField(b, 4, 1)
+	}
+	if started {
+		b = messageField(b, 5, nil)
+	}
+	if completed {
+		b = messageField(b, 6, nil)
+	}
+	if vertexErr != "" {
+		b = bytesField(b, 7, vertexErr)
+	}
+	return b
+}
+
+func vertexLog(digest, msg string) []byte {
+	return bytesField(bytesField(nil, 1, digest), 4, msg)
+}
+
+func TestBuildProgress(t *testing.T) {
+	tests := []struct {
+		name     string
+		statuses [][]byte
+		want     string
+	}{
+		{
+			name: "steps in order",
+			statuses: [][]byte{
+				messageField(nil, 1, vertex("a", "[1/2] FROM golang", false, true, false, "")),
+				messageField(nil, 1, vertex("a", "[1/2] FROM golang", false, true, true, "")),
+				messageField(nil, 1, vertex("b", "[2/2] RUN go build", false, true, false, "")),
+				messageField(nil, 3, vertexLog("b", "compiling\nlinking")),
+				messageField(nil, 1, vertex("b", "[2/2] RUN go build", false, true, true, "")),
+			},
+			want: "#1 [1/2] FROM golang\n#1 DONE\n#2 [2/2] RUN go build\n#2 compiling\n#2 linking\n#2 DONE\n",
+		},
+		{
+			name: "cached",
+			statuses: [][]byte{
+				messageField(nil, 1, vertex("a", "[1/2] COPY . .", true, false, false, "")),
+				messageField(nil, 1, vertex("a", "[1/2] COPY . .", true, false, true, "")),
+			},
+			want: "#1 [1/2] COPY . .\n#1 CACHED\n",
+		},
+		{
+			name: "error",
+			statuses: [][]byte{
+				messageField(nil, 1, vertex("a", "[1/1] RUN false", false, true, true, "exit code: 1")),
+			},
+			want: "#1 [1/1] RUN false\n#1 ERROR: exit code: 1\n",
+		},
+		{
+			name: "vertexes are numbered as they show up",
+			statuses: [][]byte{
+				messageField(messageField(nil, 1, vertex("a", "load", false, false, false, "")), 1, vertex("b", "resolve", false, true, false, "")),
+				messageField(nil, 1, vertex("a", "load", false, true, true, "")),
+			},
+			want: "#2 resolve\n#1 load\n#1 DONE\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out strings.Builder
+			progress := newBuildProgress(&out)
+			for _, status := range tt.statuses {
+				if err := progress.write(status); err != nil {
+					t.Fatalf("write() error = %v", err)
+				}
+			}
+			if out.String() != tt.want {
+				t.Errorf("progress = %q, want %q", out.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestBuildImageLimits(t *testing.T) {
+	// the client leaves unset limits out of the query
+	tests := []struct {
+		name   string
+		limits config.ResourceLimits
+		want   url.Values
+	}{
+		{
+			name: "no limits keep BuildKit",
+			want: url.Values{"version": {"2"}, "memory": nil, "memswap": nil, "cpuquota": nil},
+		},
+		{
+			name:   "limits need the classic builder",
+			limits: config.ResourceLimits{NanoCPUs: 1.5e9, MemoryBytes: 512 << 20},
+			want:   url.Values{"version": {"1"}, "memory": {"536870912"}, "memswap": {"536870912"}, "cpuperiod": {"100000"}, "cpuquota": {"150000"}},
+		},
+		{
+			name:   "memory alone",
+			limits: config.ResourceLimits{MemoryBytes: 256 << 20},
+			want:   url.Values{"version": {"1"}, "memory": {"268435456"}, "memswap": {"268435456"}, "cpuquota": nil},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var query url.Values
+			c := newTestDockerClient(t, func(w http.ResponseWriter, r *http.Request) {
+				switch {
+				case r.Method == http.MethodPost && strings.HasSuffix(r.URL.Path, "/build"):
+					query = r.URL.Query()
+					json.NewEncoder(w).Encode(jsonmessage.JSONMessage{Stream: "Successfully built\n"})
+				case r.Method == http.MethodGet && strings.HasSuffix(r.URL.Path, "/images/app:1/json"):
+					json.NewEncoder(w).Encode(image.InspectResponse{ID: "sha256:built"})
+				default:
+					http.Error(w, `{"message":"unexpected request"}`, http.StatusNotImplemented)
+				}
+			})
+
+			var out strings.Builder
+			id, err := c.BuildImage(context.Background(), ImageBuild{
+				Context:    strings.NewReader(""),
+				Dockerfile: "Dockerfile",
+				Tag:        "app:1",
+				Limits:     tt.limits,
+			}, &out)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if id != "sha256:built" {
+				t.Errorf("image ID = %q, want sha256:built", id)
+			}
+			for key, want := range tt.want {
+				if got := query[key]; !reflect.DeepEqual(got, want) {
+					t.Errorf("build query %s = %q, want %q", key, got, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,31 @@
+package docker_client
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/RajVerma97/golang-vercel/backend/internal/logger"
+	"github.com/docker/docker/client"
+)
+
+func TestMain(m *testing.M) {
+	if err := logger.Init("test"); err != nil {
+		panic(err)
+	}
+	os.Exit(m.Run())
+}
+
+// newTestDockerClient points a client at a fake daemon serving handler
+func newTestDockerClient(t *testing.T, handler http.HandlerFunc) *DockerClient {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	c, err := client.NewClientWithOpts(client.WithHost("tcp://" + server.Listener.Addr().String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.Close() })
+	return &DockerClient{client: c}
+}
@@ -0,0 +1,263 @@
+package github_client
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/RajVerma97/golang-vercel/backend/internal/config"
+	"github.com/RajVerma97/golang-vercel/backend/internal/logger"
+	"go.uber.org/zap"
+)
+
+const (
+	CommitStatePending = "pending"
+	CommitStateSuccess = "success"
+	CommitStateFailure = "failure"
+	CommitStateError   = "error"
+
+	CheckRunStatusInProgress = "in_progress"
+	CheckRunStatusCompleted  = "completed"
+
+	CheckRunConclusionSuccess = "success"
+	CheckRunConclusionFailure = "failure"
+)
+
+type installationToken struct {
+	token     string
+	expiresAt time.Time
+}
+
+type GitHubClient struct {
+	config     *config.GitHubConfig
+	httpClient *http.Client
+	privateKey *rsa.PrivateKey
+
+	mu     sync.Mutex
+	tokens map[string]installationToken // repository full name -> installation token
+}
+
+func NewGitHubClient(config *config.GitHubConfig) (*GitHubClient, error) {
+	c := &GitHubClient{
+		config:     config,
+		httpClient: &http.Client{Timeout: 10 * time.Second},
+		tokens:     make(map[string]installationToken),
+	}
+	if config.AppID != 0 && config.AppPrivateKeyPath != "" {
+		key, err := loadPrivateKey(config.AppPrivateKeyPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load github app private key: %w", err)
+		}
+		c.privateKey = key
+	}
+	return c, nil
+}
+
+// Enabled reports whether any credentials are configured
+func (c *GitHubClient) Enabled() bool {
+	return c.UsesApp() || c.config.Token != ""
+}
+
+// UsesApp reports whether requests are authenticated as a GitHub App.
+// Check runs can only be created by apps.
+func (c *GitHubClient) UsesApp() bool {
+	return c.privateKey != nil
+}
+
+type CommitStatus struct {
+	State       string `json:"state"`
+	TargetURL   string `json:"target_url,omitempty"`
+	Description string `json:"description,omitempty"`
+	Context     string `json:"context"`
+}
+
+func (c *GitHubClient) CreateCommitStatus(ctx context.Context, repo, sha string, status *CommitStatus) error {
+	path := fmt.Sprintf("/repos/%s/statuses/%s", repo, sha)
+	return c.do(ctx, repo, http.MethodPost, path, status, nil)
+}
+
+type CheckRunOutput struct {
+	Title   string `json:"title"`
+	Summary string `json:"summary"`
+}
+
+type CheckRun struct {
+	ID          int64           `json:"id,omitempty"`
+	Name        string          `json:"name,omitempty"`
+	HeadSHA     string          `json:"head_sha,omitempty"`
+	Status      string          `json:"status,omitempty"`
+	Conclusion  string          `json:"conclusion,omitempty"`
+	DetailsURL  string          `json:"details_url,omitempty"`
+	ExternalID  string          `json:"external_id,omitempty"`
+	CompletedAt *time.Time      `json:"completed_at,omitempty"`
+	Output      *CheckRunOutput `json:"output,omitempty"`
+}
+
+func (c *GitHubClient) CreateCheckRun(ctx context.Context, repo string, run *CheckRun) (*CheckRun, error) {
+	var created CheckRun
+	if err := c.do(ctx, repo, http.MethodPost, fmt.Sprintf("/repos/%s/check-runs", repo), run, &created); err != nil {
+		return nil, err
+	}
+	return &created, nil
+}
+
+func (c *GitHubClient) UpdateCheckRun(ctx context.Context, repo string, id int64, run *CheckRun) error {
+	return c.do(ctx, repo, http.MethodPatch, fmt.Sprintf("/repos/%s/check-runs/%d", repo, id), run, nil)
+}
+
+func (c *GitHubClient) CreateIssueComment(ctx context.Context, repo string, number int, body string) error {
+	path := fmt.Sprintf("/repos/%s/issues/%d/comments", repo, number)
+	return c.do(ctx, repo, http.MethodPost, path, map[string]string{"body": body}, nil)
+}
+
+func (c *GitHubClient) do(ctx context.Context, repo, method, path string, body, out any) error {
+	token, err := c.token(ctx, repo)
+	if err != nil {
+		return err
+	}
+	return c.request(ctx, method, path, "token "+token, body, out)
+}
+
+func (c *GitHubClient) request(ctx context.Context, method, path, authorization string, body, out any) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("failed to marshal data:%w", err)
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, strings.TrimRight(c.config.APIURL, "/")+path, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", authorization)
+	req.Header.Set("X-GitHub-Api-Version", "2022-11-28")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("github request %s %s failed: %w", method, path, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("github request %s %s returned %d: %s", method, path, resp.StatusCode, message)
+	}
+	if out != nil {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			return fmt.Errorf("failed to decode github response: %w", err)
+		}
+	}
+	logger.Debug("GitHub request succeeded", zap.String("method", method), zap.String("path", path))
+	return nil
+}
+
+// Token returns the credential used for repo, an installation token scoped to the repo when running as an app
+func (c *GitHubClient) Token(ctx context.Context, repo string) (string, error) {
+	return c.token(ctx, repo)
+}
+
+// token returns the credential for repo, exchanging the app JWT for an installation token when needed
+func (c *GitHubClient) token(ctx context.Context, repo string) (string, error) {
+	if !c.UsesApp() {
+		if c.config.Token == "" {
+			return "", fmt.Errorf("github credentials are not configured")
+		}
+		return c.config.Token, nil
+	}
+
+	c.mu.Lock()
+	cached, ok := c.tokens[repo]
+	c.mu.Unlock()
+	if ok && time.Until(cached.expiresAt) > time.Minute {
+		return cached.token, nil
+	}
+
+	jwt, err := c.appJWT()
+	if err != nil {
+		return "", err
+	}
+	var installation struct {
+		ID int64 `json:"id"`
+	}
+	if err := c.request(ctx, http.MethodGet, fmt.Sprintf("/repos/%s/installation", repo), "Bearer "+jwt, nil, &installation); err != nil {
+		return "", fmt.Errorf("failed to find app installation for %s: %w", repo, err)
+	}
+	var access struct {
+		Token     string    `json:"token"`
+		ExpiresAt time.Time `json:"expires_at"`
+	}
+	path := fmt.Sprintf("/app/installations/%d/access_tokens", installation.ID)
+	if err := c.request(ctx, http.MethodPost, path, "Bearer "+jwt, nil, &access); err != nil {
+		return "", fmt.Errorf("failed to create installation token for %s: %w", repo, err)
+	}
+
+	c.mu.Lock()
+	c.tokens[repo] = installationToken{token: access.Token, expiresAt: access.ExpiresAt}
+	c.mu.Unlock()
+	return access.Token, nil
+}
+
+// appJWT signs the RS256 token GitHub expects from apps
+func (c *GitHubClient) appJWT() (string, error) {
+	now := time.Now()
+	header := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"RS256","typ":"JWT"}`))
+	claims, err := json.Marshal(map[string]any{
+		// backdated to allow for clock drift
+		"iat": now.Add(-time.Minute).Unix(),
+		"exp": now.Add(9 * time.Minute).Unix(),
+		"iss": c.config.AppID,
+	})
+	if err != nil {
+		return "", err
+	}
+	unsigned := header + "." + base64.RawURLEncoding.EncodeToString(claims)
+
+	digest := sha256.Sum256([]byte(unsigned))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, c.privateKey, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", fmt.Errorf("failed to sign github app jwt: %w", err)
+	}
+	return unsigned + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+func loadPrivateKey(path string) (*rsa.PrivateKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM data found in %s", path)
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("private key in %s is not an RSA key", path)
+	}
+	return key, nil
+}
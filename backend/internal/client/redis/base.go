@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"time"
 
 	"github.com/RajVerma97/golang-vercel/backend/internal/config"
 	"github.com/RajVerma97/golang-vercel/backend/internal/dto"
@@ -71,3 +72,200 @@ func (c *RedisClient) DequeueBuild(ctx context.Context) (*dto.Build, error) {
 	logger.Debug("Successfully Dequeued Build ", zap.Any("build", build))
 	return build, nil
 }
+
+// NextID returns the next value of the counter stored at key
+func (c *RedisClient) NextID(ctx context.Context, key string) (uint64, error) {
+	id, err := c.client.Incr(ctx, key).Result()
+	if err != nil {
+		return 0, fmt.Errorf("failed to incr %s: %w", key, err)
+	}
+	return uint64(id), nil
+}
+
+// SetJSON marshals value and stores it at key. A zero ttl keeps the key forever.
+func (c *RedisClient) SetJSON(ctx context.Context, key string, value any, ttl time.Duration) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("failed to marshal data:%w", err)
+	}
+	if err := c.client.Set(ctx, key, data, ttl).Err(); err != nil {
+		return fmt.Errorf("failed to set %s: %w", key, err)
+	}
+	return nil
+}
+
+// GetJSON loads the value stored at key into dest. It reports false when the key does not exist.
+func (c *RedisClient) GetJSON(ctx context.Context, key string, dest any) (bool, error) {
+	data, err := c.client.Get(ctx, key).Result()
+	if err != nil {
+		if err == redis.Nil {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to get %s: %w", key, err)
+	}
+	if err := json.Unmarshal([]byte(data), dest); err != nil {
+		return false, fmt.Errorf("failed to unmarshal %s: %w", key, err)
+	}
+	return true, nil
+}
+
+func (c *RedisClient) Delete(ctx context.Context, keys ...string) error {
+	if err := c.client.Del(ctx, keys...).Err(); err != nil {
+		return fmt.Errorf("failed to delete keys: %w", err)
+	}
+	return nil
+}
+
+func (c *RedisClient) SetHashField(ctx context.Context, key, field, value string) error {
+	if err := c.client.HSet(ctx, key, field, value).Err(); err != nil {
+		return fmt.Errorf("failed to hset %s: %w", key, err)
+	}
+	return nil
+}
+
+// SetHashFieldWithTTL sets field and (re)starts the expiry of the whole hash
+func (c *RedisClient) SetHashFieldWithTTL(ctx context.Context, key, field, value string, ttl time.Duration) error {
+	pipe := c.client.TxPipeline()
+	pipe.HSet(ctx, key, field, value)
+	pipe.Expire(ctx, key, ttl)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("failed to hset %s: %w", key, err)
+	}
+	return nil
+}
+
+// GetHashField reports false when the field does not exist
+func (c *RedisClient) GetHashField(ctx context.Context, key, field string) (string, bool, error) {
+	value, err := c.client.HGet(ctx, key, field).Result()
+	if err != nil {
+		if err == redis.Nil {
+			return "", false, nil
+		}
+		return "", false, fmt.Errorf("failed to hget %s: %w", key, err)
+	}
+	return value, true, nil
+}
+
+func (c *RedisClient) GetHash(ctx context.Context, key string) (map[string]string, error) {
+	values, err := c.client.HGetAll(ctx, key).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to hgetall %s: %w", key, err)
+	}
+	return values, nil
+}
+
+func (c *RedisClient) IncrementHashField(ctx context.Context, key, field string, by int64) error {
+	if err := c.client.HIncrBy(ctx, key, field, by).Err(); err != nil {
+		return fmt.Errorf("failed to hincrby %s: %w", key, err)
+	}
+	return nil
+}
+
+func (c *RedisClient) DeleteHashField(ctx context.Context, key string, fields ...string) error {
+	if err := c.client.HDel(ctx, key, fields...).Err(); err != nil {
+		return fmt.Errorf("failed to hdel %s: %w", key, err)
+	}
+	return nil
+}
+
+func (c *RedisClient) AddToSet(ctx context.Context, key string, members ...string) error {
+	if err := c.client.SAdd(ctx, key, members).Err(); err != nil {
+		return fmt.Errorf("failed to sadd %s: %w", key, err)
+	}
+	return nil
+}
+
+func (c *RedisClient) GetSet(ctx context.Context, key string) ([]string, error) {
+	members, err := c.client.SMembers(ctx, key).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to smembers %s: %w", key, err)
+	}
+	return members, nil
+}
+
+// PushList prepends value to the list at key and trims it to maxLen entries
+func (c *RedisClient) PushList(ctx context.Context, key, value string, maxLen int64) error {
+	pipe := c.client.TxPipeline()
+	pipe.LPush(ctx, key, value)
+	if maxLen > 0 {
+		pipe.LTrim(ctx, key, 0, maxLen-1)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("failed to push to %s: %w", key, err)
+	}
+	return nil
+}
+
+// PushListTrimmed is PushList that returns the entries the trim dropped
+func (c *RedisClient) PushListTrimmed(ctx context.Context, key, value string, maxLen int64) ([]string, error) {
+	pipe := c.client.TxPipeline()
+	pipe.LPush(ctx, key, value)
+	dropped := pipe.LRange(ctx, key, maxLen, -1)
+	pipe.LTrim(ctx, key, 0, maxLen-1)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, fmt.Errorf("failed to push to %s: %w", key, err)
+	}
+	return dropped.Val(), nil
+}
+
+func (c *RedisClient) GetList(ctx context.Context, key string) ([]string, error) {
+	values, err := c.client.LRange(ctx, key, 0, -1).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to lrange %s: %w", key, err)
+	}
+	return values, nil
+}
+
+// SetIfAbsent stores value at key only when the key does not exist yet and reports whether it did
+func (c *RedisClient) SetIfAbsent(ctx context.Context, key, value string, ttl time.Duration) (bool, error) {
+	ok, err := c.client.SetNX(ctx, key, value, ttl).Result()
+	if err != nil {
+		return false, fmt.Errorf("failed to setnx %s: %w", key, err)
+	}
+	return ok, nil
+}
+
+// maxUpdateAttempts bounds how often UpdateJSON retries when the key changes under it
+const maxUpdateAttempts = 10
+
+// UpdateJSON loads the value stored at key into dest, calls update and stores dest again, keeping the
+// key's ttl, in one optimistic transaction that is retried when the key is written concurrently. It reports false,
+// without calling update, when the key does not exist.
+func (c *RedisClient) UpdateJSON(ctx context.Context, key string, dest any, update func() error) (bool, error) {
+	for range maxUpdateAttempts {
+		found := false
+		err := c.client.Watch(ctx, func(tx *redis.Tx) error {
+			data, err := tx.Get(ctx, key).Result()
+			if err == redis.Nil {
+				return nil
+			}
+			if err != nil {
+				return fmt.Errorf("failed to get %s: %w", key, err)
+			}
+			found = true
+			if err := json.Unmarshal([]byte(data), dest); err != nil {
+				return fmt.Errorf("failed to unmarshal %s: %w", key, err)
+			}
+			if err := update(); err != nil {
+				return err
+			}
+			updated, err := json.Marshal(dest)
+			if err != nil {
+				return fmt.Errorf("failed to marshal data:%w", err)
+			}
+			_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+				pipe.Set(ctx, key, updated, redis.KeepTTL)
+				return nil
+			})
+			return err
+		}, key)
+		if err == redis.TxFailedErr {
+			continue
+		}
+		if err != nil {
+			return false, err
+		}
+		return found, nil
+	}
+	return false, fmt.Errorf("failed to update %s: too much contention", key)
+}
@@ -1,30 +1,298 @@
 package config
 
-import "github.com/RajVerma97/golang-vercel/backend/internal/helpers"
+import (
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"github.com/RajVerma97/golang-vercel/backend/internal/constants"
+	"github.com/RajVerma97/golang-vercel/backend/internal/helpers"
+)
 
 type ServerConfig struct {
 	Host string
 	Port int
+	// PublicURL is where users reach the API, used for links such as build logs
+	PublicURL string
 }
 type RedisConfig struct {
 	Host string
 	Port int
 }
 
+type ProxyConfig struct {
+	Host string
+	Port int
+	// Domain is appended to project names to build alias hosts, e.g. myapp.localhost
+	Domain string
+}
+
+func (c *ProxyConfig) ServerConfig() *ServerConfig {
+	return &ServerConfig{Host: c.Host, Port: c.Port}
+}
+
+type DeployConfig struct {
+	HealthCheckPath  string
+	DrainGracePeriod time.Duration
+	// RuntimeImage runs static binaries, GlibcRuntimeImage the ones built with cgo against glibc
+	RuntimeImage      string
+	GlibcRuntimeImage string
+}
+
+// TimeoutsConfig holds the default deadline of every build phase
+type TimeoutsConfig struct {
+	Clone        time.Duration
+	Dependencies time.Duration
+	Compile      time.Duration
+	Test         time.Duration
+	Step         time.Duration
+	Readiness    time.Duration
+}
+
+// For returns the deadline of phase, preferring the project's override in seconds
+func (c *TimeoutsConfig) For(phase constants.BuildPhase, overrides map[constants.BuildPhase]int) time.Duration {
+	if seconds, ok := overrides[phase]; ok && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	switch phase {
+	case constants.BuildPhaseClone:
+		return c.Clone
+	case constants.BuildPhaseDependencies:
+		return c.Dependencies
+	case constants.BuildPhaseCompile:
+		return c.Compile
+	case constants.BuildPhaseTest:
+		return c.Test
+	case constants.BuildPhaseStep:
+		return c.Step
+	default:
+		return c.Readiness
+	}
+}
+
+type StorageConfig struct {
+	// DataDir holds everything the platform keeps between builds, such as retained artifacts
+	DataDir string
+}
+
+func (c *StorageConfig) ArtifactsDir() string {
+	return filepath.Join(c.DataDir, "artifacts")
+}
+
+func (c *StorageConfig) MirrorsDir() string {
+	return filepath.Join(c.DataDir, "mirrors")
+}
+
+func (c *StorageConfig) EgressDir() string {
+	return filepath.Join(c.DataDir, "egress")
+}
+
+func (c *StorageConfig) CachesDir() string {
+	return filepath.Join(c.DataDir, "caches")
+}
+
+// GitMirrorConfig configures the bare mirrors builds check out from
+type GitMirrorConfig struct {
+	Enabled bool
+	// MaxBytes bounds the mirrors directory, the least recently used mirrors are evicted past it
+	MaxBytes int64
+}
+
+// GitHubConfig authenticates either as a GitHub App or with a token. The app takes precedence.
+type GitHubConfig struct {
+	APIURL            string
+	Token             string
+	AppID             int
+	AppPrivateKeyPath string
+}
+
+type WebhookConfig struct {
+	// DeliveryTTL is how long delivery ids are remembered to drop redeliveries
+	DeliveryTTL time.Duration
+	// HistorySize caps how many deliveries are kept for /webhook/deliveries
+	HistorySize int
+}
+
+type EncryptionConfig struct {
+	// Key is the base64 encoded 32 byte master key that env vars are encrypted with
+	Key string
+}
+
+// ResourceLimits caps a single container. Zero leaves the limit unset.
+type ResourceLimits struct {
+	NanoCPUs    int64
+	MemoryBytes int64
+	PidsLimit   int64
+	// DiskBytes caps the container's writable layer, only when StorageLimits is on. Bind mounts are
+	// never limited: the workspace, the go caches and the mirrors grow on the host's disk, so
+	// DataDir and the workspaces belong on a volume of their own that a build may fill.
+	DiskBytes int64
+}
+
+type ResourcesConfig struct {
+	DefaultClass constants.ResourceClass
+	Classes      map[constants.ResourceClass]ResourceLimits
+	// StorageLimits enables per container disk quotas, which need overlay2 on xfs mounted with pquota.
+	// It is off by default, when disk is not limited at all.
+	StorageLimits bool
+}
+
+// Limits returns the limits for class, falling back to the default class for unknown ones
+func (c *ResourcesConfig) Limits(class constants.ResourceClass) ResourceLimits {
+	limits, ok := c.Classes[class]
+	if !ok {
+		limits = c.Classes[c.DefaultClass]
+	}
+	if !c.StorageLimits {
+		limits.DiskBytes = 0
+	}
+	return limits
+}
+
+// SandboxConfig configures the hardened build profile
+type SandboxConfig struct {
+	DefaultProfile constants.BuildProfile
+	// UID and GID the build runs as, the workspace is handed over to them before the build starts
+	UID int
+	GID int
+	// SeccompProfilePath points at a JSON seccomp profile, docker's default profile is used when empty
+	SeccompProfilePath string
+	// TmpfsSize caps the writable /tmp that holds HOME and the go caches
+	TmpfsSize int64
+	// UsernsMode is passed to docker as is, e.g. "host" to opt out of daemon wide userns-remap
+	UsernsMode string
+}
+
+// ToolchainConfig selects the builder image for the Go version a project needs
+type ToolchainConfig struct {
+	// DefaultVersion builds projects whose go.mod does not say which Go they need
+	DefaultVersion string
+	// CatalogPath points at a JSON object of Go versions to builder images, the built-in catalog
+	// is used when empty. Images without a digest are pinned to the one the registry serves at startup.
+	CatalogPath string
+	// CgoCatalogPath is the same for the glibc based images that cgo builds use
+	CgoCatalogPath string
+}
+
+type NetworkConfig struct {
+	// EgressProxyImage runs the allowlist egress proxy, it has to read squid's config format
+	EgressProxyImage string
+	// BuildNetwork is the bridge build containers run on instead of docker's default one
+	BuildNetwork string
+	// HostFirewall keeps containers on the build and project bridges off the host, where Redis and
+	// the API listen. It adds iptables rules, so the platform needs CAP_NET_ADMIN in the host's
+	// network namespace. Turn it off only when the host is firewalled otherwise.
+	HostFirewall bool
+}
+
+const (
+	mb = 1024 * 1024
+	gb = 1024 * mb
+)
+
 type Config struct {
-	Server *ServerConfig
-	Redis  *RedisConfig
+	Server     *ServerConfig
+	Redis      *RedisConfig
+	Proxy      *ProxyConfig
+	Deploy     *DeployConfig
+	Timeouts   *TimeoutsConfig
+	Storage    *StorageConfig
+	GitMirror  *GitMirrorConfig
+	GitHub     *GitHubConfig
+	Webhook    *WebhookConfig
+	Encryption *EncryptionConfig
+	Resources  *ResourcesConfig
+	Sandbox    *SandboxConfig
+	Network    *NetworkConfig
+	Toolchain  *ToolchainConfig
 }
 
 func NewConfig() *Config {
+	serverPort := helpers.GetEnv("SERVER_PORT", 0)
 	return &Config{
 		Server: &ServerConfig{
-			Host: helpers.GetEnv("SERVER_HOST", ""),
-			Port: helpers.GetEnv("SERVER_PORT", 0),
+			Host:      helpers.GetEnv("SERVER_HOST", ""),
+			Port:      serverPort,
+			PublicURL: helpers.GetEnv("SERVER_PUBLIC_URL", fmt.Sprintf("http://localhost:%d", serverPort)),
 		},
 		Redis: &RedisConfig{
 			Host: helpers.GetEnv("REDIS_HOST", ""),
 			Port: helpers.GetEnv("REDIS_PORT", 0),
 		},
+		Proxy: &ProxyConfig{
+			Host:   helpers.GetEnv("PROXY_HOST", ""),
+			Port:   helpers.GetEnv("PROXY_PORT", 8000),
+			Domain: helpers.GetEnv("PROXY_DOMAIN", "localhost"),
+		},
+		Deploy: &DeployConfig{
+			HealthCheckPath:   helpers.GetEnv("DEPLOY_HEALTH_CHECK_PATH", "/"),
+			DrainGracePeriod:  time.Duration(helpers.GetEnv("DEPLOY_DRAIN_GRACE_SECONDS", 30)) * time.Second,
+			RuntimeImage:      helpers.GetEnv("DEPLOY_RUNTIME_IMAGE", "alpine:latest"),
+			GlibcRuntimeImage: helpers.GetEnv("DEPLOY_GLIBC_RUNTIME_IMAGE", "debian:bookworm-slim"),
+		},
+		Timeouts: &TimeoutsConfig{
+			Clone:        time.Duration(helpers.GetEnv("CLONE_TIMEOUT_SECONDS", 300)) * time.Second,
+			Dependencies: time.Duration(helpers.GetEnv("DEPENDENCIES_TIMEOUT_SECONDS", 600)) * time.Second,
+			Compile:      time.Duration(helpers.GetEnv("COMPILE_TIMEOUT_SECONDS", 900)) * time.Second,
+			Test:         time.Duration(helpers.GetEnv("TEST_TIMEOUT_SECONDS", 1200)) * time.Second,
+			Step:         time.Duration(helpers.GetEnv("STEP_TIMEOUT_SECONDS", 600)) * time.Second,
+			Readiness:    time.Duration(helpers.GetEnv("DEPLOY_READINESS_TIMEOUT_SECONDS", 30)) * time.Second,
+		},
+		Storage: &StorageConfig{
+			DataDir: absPath(helpers.GetEnv("DATA_DIR", "data")),
+		},
+		GitMirror: &GitMirrorConfig{
+			Enabled:  helpers.GetEnv("GIT_MIRROR_CACHE", true),
+			MaxBytes: int64(helpers.GetEnv("GIT_MIRROR_CACHE_MAX_MB", 10240)) * mb,
+		},
+		GitHub: &GitHubConfig{
+			APIURL:            helpers.GetEnv("GITHUB_API_URL", "https://api.github.com"),
+			Token:             helpers.GetEnv("GITHUB_TOKEN", ""),
+			AppID:             helpers.GetEnv("GITHUB_APP_ID", 0),
+			AppPrivateKeyPath: helpers.GetEnv("GITHUB_APP_PRIVATE_KEY_PATH", ""),
+		},
+		Webhook: &WebhookConfig{
+			DeliveryTTL: time.Duration(helpers.GetEnv("WEBHOOK_DELIVERY_TTL_HOURS", 72)) * time.Hour,
+			HistorySize: helpers.GetEnv("WEBHOOK_DELIVERY_HISTORY_SIZE", 200),
+		},
+		Encryption: &EncryptionConfig{
+			Key: helpers.GetEnv("ENV_ENCRYPTION_KEY", ""),
+		},
+		Resources: &ResourcesConfig{
+			DefaultClass: constants.ResourceClass(helpers.GetEnv("RESOURCE_DEFAULT_CLASS", constants.ResourceClassStandard.String())),
+			Classes: map[constants.ResourceClass]ResourceLimits{
+				constants.ResourceClassSmall:    {NanoCPUs: 500_000_000, MemoryBytes: 512 * mb, PidsLimit: 256, DiskBytes: 2 * gb},
+				constants.ResourceClassStandard: {NanoCPUs: 1_000_000_000, MemoryBytes: 1 * gb, PidsLimit: 512, DiskBytes: 5 * gb},
+				constants.ResourceClassLarge:    {NanoCPUs: 2_000_000_000, MemoryBytes: 4 * gb, PidsLimit: 1024, DiskBytes: 10 * gb},
+			},
+			StorageLimits: helpers.GetEnv("CONTAINER_STORAGE_LIMITS", false),
+		},
+		Sandbox: &SandboxConfig{
+			DefaultProfile:     constants.BuildProfile(helpers.GetEnv("BUILD_DEFAULT_PROFILE", constants.BuildProfileStandard.String())),
+			UID:                helpers.GetEnv("SANDBOX_UID", 65534),
+			GID:                helpers.GetEnv("SANDBOX_GID", 65534),
+			SeccompProfilePath: helpers.GetEnv("SANDBOX_SECCOMP_PROFILE", ""),
+			TmpfsSize:          int64(helpers.GetEnv("SANDBOX_TMPFS_SIZE_MB", 2048)) * mb,
+			UsernsMode:         helpers.GetEnv("SANDBOX_USERNS_MODE", ""),
+		},
+		Network: &NetworkConfig{
+			EgressProxyImage: helpers.GetEnv("EGRESS_PROXY_IMAGE", "ubuntu/squid:latest"),
+			BuildNetwork:     helpers.GetEnv("BUILD_NETWORK", "platform-builds"),
+			HostFirewall:     helpers.GetEnv("HOST_FIREWALL", true),
+		},
+		Toolchain: &ToolchainConfig{
+			DefaultVersion: helpers.GetEnv("GO_DEFAULT_VERSION", "1.24"),
+			CatalogPath:    helpers.GetEnv("GO_BUILDER_CATALOG", ""),
+			CgoCatalogPath: helpers.GetEnv("GO_BUILDER_CATALOG_CGO", ""),
+		},
+	}
+}
+
+// absPath resolves path against the working directory since it ends up in docker bind mounts
+func absPath(path string) string {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return path
 	}
+	return abs
 }
@@ -0,0 +1,35 @@
+package config
+
+import (
+	"testing"
+	"time"
+
+	"github.com/RajVerma97/golang-vercel/backend/internal/constants"
+)
+
+func TestTimeoutsConfigFor(t *testing.T) {
+	c := &TimeoutsConfig{Clone: time.Minute, Dependencies: 2 * time.Minute, Compile: 3 * time.Minute, Test: 4 * time.Minute, Step: 5 * time.Minute, Readiness: 30 * time.Second}
+	tests := []struct {
+		name      string
+		phase     constants.BuildPhase
+		overrides map[constants.BuildPhase]int
+		want      time.Duration
+	}{
+		{name: "clone default", phase: constants.BuildPhaseClone, want: time.Minute},
+		{name: "dependencies default", phase: constants.BuildPhaseDependencies, want: 2 * time.Minute},
+		{name: "compile default", phase: constants.BuildPhaseCompile, want: 3 * time.Minute},
+		{name: "test default", phase: constants.BuildPhaseTest, want: 4 * time.Minute},
+		{name: "step default", phase: constants.BuildPhaseStep, want: 5 * time.Minute},
+		{name: "readiness default", phase: constants.BuildPhaseReadiness, want: 30 * time.Second},
+		{name: "override in seconds", phase: constants.BuildPhaseCompile, overrides: map[constants.BuildPhase]int{constants.BuildPhaseCompile: 90}, want: 90 * time.Second},
+		{name: "override of another phase", phase: constants.BuildPhaseClone, overrides: map[constants.BuildPhase]int{constants.BuildPhaseCompile: 90}, want: time.Minute},
+		{name: "zero override", phase: constants.BuildPhaseClone, overrides: map[constants.BuildPhase]int{constants.BuildPhaseClone: 0}, want: time.Minute},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := c.For(tt.phase, tt.overrides); got != tt.want {
+				t.Errorf("For(%s) = %s, want %s", tt.phase, got, tt.want)
+			}
+		})
+	}
+}
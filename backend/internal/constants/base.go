@@ -13,15 +13,197 @@ func (t BuildStatus) String() string {
 	return string(t)
 }
 
+type BuildTrigger string
+
+const (
+	BuildTriggerAPI       BuildTrigger = "api"
+	BuildTriggerGitHub    BuildTrigger = "github"
+	BuildTriggerGitLab    BuildTrigger = "gitlab"
+	BuildTriggerGitea     BuildTrigger = "gitea"
+	BuildTriggerBitbucket BuildTrigger = "bitbucket"
+)
+
+func (t BuildTrigger) String() string {
+	return string(t)
+}
+
 type DeploymentStatus string
 
 const (
-	DeploymentStatusPending DeploymentStatus = "pending"
-	DeploymentStatusRunning DeploymentStatus = "running"
-	DeploymentStatusStopped DeploymentStatus = "stopped"
-	DeploymentStatusFailed  DeploymentStatus = "failed"
+	DeploymentStatusPending  DeploymentStatus = "pending"
+	DeploymentStatusRunning  DeploymentStatus = "running"
+	DeploymentStatusDraining DeploymentStatus = "draining"
+	DeploymentStatusStopped  DeploymentStatus = "stopped"
+	DeploymentStatusFailed   DeploymentStatus = "failed"
 )
 
 func (s DeploymentStatus) String() string {
 	return string(s)
 }
+
+type DeploymentEnvironment string
+
+const (
+	DeploymentEnvironmentProduction DeploymentEnvironment = "production"
+	DeploymentEnvironmentPreview    DeploymentEnvironment = "preview"
+)
+
+func (e DeploymentEnvironment) String() string {
+	return string(e)
+}
+
+// EnvTarget is the stage an environment variable is injected into
+type EnvTarget string
+
+const (
+	EnvTargetBuild   EnvTarget = "build"
+	EnvTargetRuntime EnvTarget = "runtime"
+)
+
+func (t EnvTarget) String() string {
+	return string(t)
+}
+
+type WebhookDeliveryOutcome string
+
+const (
+	WebhookDeliveryOutcomeBuildCreated      WebhookDeliveryOutcome = "build_created"
+	WebhookDeliveryOutcomePreviewRemoved    WebhookDeliveryOutcome = "preview_removed"
+	WebhookDeliveryOutcomeAcknowledged      WebhookDeliveryOutcome = "acknowledged"
+	WebhookDeliveryOutcomeIgnored           WebhookDeliveryOutcome = "ignored"
+	WebhookDeliveryOutcomeDuplicate         WebhookDeliveryOutcome = "duplicate"
+	WebhookDeliveryOutcomeRejectedSignature WebhookDeliveryOutcome = "rejected_signature"
+	WebhookDeliveryOutcomeInvalidPayload    WebhookDeliveryOutcome = "invalid_payload"
+	WebhookDeliveryOutcomeFailed            WebhookDeliveryOutcome = "failed"
+)
+
+func (o WebhookDeliveryOutcome) String() string {
+	return string(o)
+}
+
+// ResourceClass sizes the CPU, memory, pids and disk limits of a project's containers
+type ResourceClass string
+
+const (
+	ResourceClassSmall    ResourceClass = "small"
+	ResourceClassStandard ResourceClass = "standard"
+	ResourceClassLarge    ResourceClass = "large"
+)
+
+func (c ResourceClass) String() string {
+	return string(c)
+}
+
+// FailureCode classifies why a build or deployment failed
+type FailureCode string
+
+const (
+	FailureCodeError       FailureCode = "ERROR"
+	FailureCodeOOMKilled   FailureCode = "OOM_KILLED"
+	FailureCodeTimeout     FailureCode = "TIMEOUT"
+	FailureCodeTestsFailed FailureCode = "TESTS_FAILED"
+)
+
+func (c FailureCode) String() string {
+	return string(c)
+}
+
+// BuildProfile selects how much a project's build container is trusted
+type BuildProfile string
+
+const (
+	BuildProfileStandard BuildProfile = "standard"
+	// BuildProfileHardened runs the build unprivileged on a read-only root filesystem
+	BuildProfileHardened BuildProfile = "hardened"
+)
+
+func (p BuildProfile) String() string {
+	return string(p)
+}
+
+// EgressMode controls what a project's deployments can reach outside their network
+type EgressMode string
+
+const (
+	EgressModeOpen EgressMode = "open"
+	EgressModeDeny EgressMode = "deny"
+	// EgressModeAllowlist routes traffic through an egress proxy that only lets allowed destinations through
+	EgressModeAllowlist EgressMode = "allowlist"
+)
+
+func (m EgressMode) String() string {
+	return string(m)
+}
+
+// BuildPhase is a step of the pipeline that runs under its own deadline
+type BuildPhase string
+
+const (
+	BuildPhaseClone        BuildPhase = "clone"
+	BuildPhaseDependencies BuildPhase = "dependencies"
+	BuildPhaseCompile      BuildPhase = "compile"
+	BuildPhaseTest         BuildPhase = "test"
+	BuildPhaseStep         BuildPhase = "step"
+	BuildPhaseReadiness    BuildPhase = "readiness"
+)
+
+func (p BuildPhase) String() string {
+	return string(p)
+}
+
+// Builder is how a project's app is built
+type Builder string
+
+const (
+	// BuilderAuto builds the Dockerfile when the repository has one and compiles the Go app otherwise
+	BuilderAuto       Builder = "auto"
+	BuilderGo         Builder = "go"
+	BuilderDockerfile Builder = "dockerfile"
+)
+
+func (b Builder) String() string {
+	return string(b)
+}
+
+// StepStatus is the state of one step of a build's pipeline
+type StepStatus string
+
+const (
+	StepStatusPending StepStatus = "pending"
+	StepStatusRunning StepStatus = "running"
+	StepStatusSuccess StepStatus = "success"
+	StepStatusFailed  StepStatus = "failed"
+	// StepStatusSkipped steps did not run because an earlier step failed
+	StepStatusSkipped StepStatus = "skipped"
+)
+
+func (s StepStatus) String() string {
+	return string(s)
+}
+
+// StepStage places a repository's step relative to the platform's build and deploy steps
+type StepStage string
+
+const (
+	StepStagePreBuild   StepStage = "pre_build"
+	StepStagePostBuild  StepStage = "post_build"
+	StepStagePostDeploy StepStage = "post_deploy"
+)
+
+func (s StepStage) String() string {
+	return string(s)
+}
+
+// GitAuthMethod is how clones of a project's repository authenticate
+type GitAuthMethod string
+
+const (
+	GitAuthMethodNone      GitAuthMethod = "none"
+	GitAuthMethodDeployKey GitAuthMethod = "deploy_key"
+	GitAuthMethodToken     GitAuthMethod = "token"
+	GitAuthMethodGitHubApp GitAuthMethod = "github_app"
+)
+
+func (m GitAuthMethod) String() string {
+	return string(m)
+}
@@ -12,30 +12,257 @@ type Container struct {
 	Port string `json:"port"`
 }
 type Build struct {
-	ID            uint64                `json:"id"`
-	DeploymentID  uint64                `json:"deployment_id"`
-	RepoUrl       string                `json:"repo_url"`
-	Branch        *string               `json:"branch"`
-	CommitHash    *string               `json:"commit_hash"`
-	Status        constants.BuildStatus `json:"status"`
-	Logs          string                `json:"logs"`
-	FailureReason *string               `json:"failure_reason"`
-	Container     *Container            `json:"container"`
-	BinaryPath    *string               `json:"binary_path"`
-	CreatedAt     time.Time             `json:"created_at"`
-	UpdatedAt     time.Time             `json:"updated_at"`
-	StartedAt     *time.Time            `json:"started_at"`
-	CompletedAt   *time.Time            `json:"completed_at"`
+	ID           uint64 `json:"id"`
+	ProjectID    uint64 `json:"project_id"`
+	DeploymentID uint64 `json:"deployment_id"`
+	RepoUrl      string `json:"repo_url"`
+	// HeadRepoUrl is the fork a pull request comes from. It is only shown, the pull request's
+	// commits are fetched from the project's own repository.
+	HeadRepoUrl      string                 `json:"head_repo_url,omitempty"`
+	Branch           *string                `json:"branch"`
+	CommitHash       *string                `json:"commit_hash"`
+	Commit           *CommitInfo            `json:"commit,omitempty"`
+	Config           *BuildConfig           `json:"config,omitempty"`
+	GoVersion        string                 `json:"go_version,omitempty"`
+	Tests            *TestReport            `json:"tests,omitempty"`
+	Steps            []StepResult           `json:"steps,omitempty"`
+	PullRequest      *int                   `json:"pull_request"`
+	Trigger          constants.BuildTrigger `json:"trigger"`
+	GitHubRepository *string                `json:"github_repository"`
+	CheckRunID       *int64                 `json:"check_run_id"`
+	Status           constants.BuildStatus  `json:"status"`
+	Logs             string                 `json:"logs"`
+	FailureReason    *string                `json:"failure_reason"`
+	FailureCode      constants.FailureCode  `json:"failure_code,omitempty"`
+	Container        *Container             `json:"container"`
+	BinaryPath       *string                `json:"binary_path"`
+	Image            string                 `json:"image,omitempty"`
+	CreatedAt        time.Time              `json:"created_at"`
+	UpdatedAt        time.Time              `json:"updated_at"`
+	StartedAt        *time.Time             `json:"started_at"`
+	CompletedAt      *time.Time             `json:"completed_at"`
+	// CGO and GOARCH are what the binary was built with, deployments need a matching runtime image
+	CGO    bool   `json:"cgo,omitempty"`
+	GOARCH string `json:"goarch,omitempty"`
+	// Dockerfile is set when the build ran it, relative to the root directory. Deployments run
+	// the resulting Image instead of the binary.
+	Dockerfile string `json:"dockerfile,omitempty"`
+}
+
+// CacheStats sums up a project's go caches since they were last purged
+type CacheStats struct {
+	Modules  CacheTotals `json:"modules"`
+	Build    CacheTotals `json:"build"`
+	PurgedAt *time.Time  `json:"purged_at,omitempty"`
+}
+
+type CacheTotals struct {
+	Bytes int64 `json:"bytes"`
+}
+
+// StepResult is the outcome of one step of the build's pipeline, kept in the order steps were added
+type StepResult struct {
+	Name          string                `json:"name"`
+	Status        constants.StepStatus  `json:"status"`
+	Logs          string                `json:"logs,omitempty"`
+	Artifacts     []string              `json:"artifacts,omitempty"`
+	FailureReason *string               `json:"failure_reason,omitempty"`
+	FailureCode   constants.FailureCode `json:"failure_code,omitempty"`
+	StartedAt     *time.Time            `json:"started_at,omitempty"`
+	CompletedAt   *time.Time            `json:"completed_at,omitempty"`
+}
+
+// TestReport sums up the test stage, Packages is empty when go vet failed
+type TestReport struct {
+	Passed    bool            `json:"passed"`
+	VetPassed bool            `json:"vet_passed"`
+	Race      bool            `json:"race"`
+	Packages  []PackageResult `json:"packages"`
+}
+
+// PackageResult is the outcome of go test for one package. Status is pass, fail or skip,
+// the latter for packages without tests.
+type PackageResult struct {
+	Package     string   `json:"package"`
+	Status      string   `json:"status"`
+	Elapsed     float64  `json:"elapsed"`
+	FailedTests []string `json:"failed_tests,omitempty"`
+}
+
+// CommitInfo describes the commit a build checked out
+type CommitInfo struct {
+	SHA         string    `json:"sha"`
+	Author      string    `json:"author"`
+	AuthorEmail string    `json:"author_email"`
+	Message     string    `json:"message"`
+	CommittedAt time.Time `json:"committed_at"`
+}
+
+// BuildConfig is the repository's deploy.yaml or deploy.json, every field is optional
+type BuildConfig struct {
+	// BuildCommand replaces the go build of the compile step and has to write the binary to Output
+	BuildCommand string `json:"build_command,omitempty"`
+	// Output is the binary's path relative to the project's root directory
+	Output          string            `json:"output,omitempty"`
+	GoVersion       string            `json:"go_version,omitempty"`
+	Tags            []string          `json:"tags,omitempty"`
+	Ldflags         string            `json:"ldflags,omitempty"`
+	Port            int               `json:"port,omitempty"`
+	Env             map[string]string `json:"env,omitempty"`
+	HealthCheckPath string            `json:"health_check_path,omitempty"`
+	// ResourceClass can only lower the project's resource class
+	ResourceClass constants.ResourceClass `json:"resource_class,omitempty"`
+	// Ignore lists path.Match patterns of files removed from the workspace before building
+	Ignore []string `json:"ignore,omitempty"`
+	// Steps are added to the platform's pipeline
+	Steps []StepConfig `json:"steps,omitempty"`
+}
+
+// StepConfig is a step the repository adds to its pipeline, run in a container against the workspace
+type StepConfig struct {
+	Name string `json:"name"`
+	// Stage is post_build when empty
+	Stage constants.StepStage `json:"stage,omitempty"`
+	// Image is the builder image when empty
+	Image string            `json:"image,omitempty"`
+	Run   string            `json:"run"`
+	Env   map[string]string `json:"env,omitempty"`
+	// Needs names steps of the same stage that have to succeed first
+	Needs []string `json:"needs,omitempty"`
+	// Timeout is in seconds, the platform's step timeout when 0
+	Timeout int `json:"timeout,omitempty"`
+	// Cache mounts the project's Go module and build caches
+	Cache bool `json:"cache,omitempty"`
+	// Artifacts are paths relative to the root directory kept once the step succeeds
+	Artifacts []string `json:"artifacts,omitempty"`
 }
 
 type Deployment struct {
-	ID        uint64                     `json:"id"`
-	BuildID   uint64                     `json:"build_id"`
-	URL       string                     `json:"url"`
-	Container *Container                 `json:"container"`
-	Logs      string                     `json:"logs"`
-	CreatedAt time.Time                  `json:"created_at"`
-	UpdatedAt time.Time                  `json:"updated_at"`
-	Status    constants.DeploymentStatus `json:"status"`
-	StoppedAt *time.Time                 `json:"stopped_at"`
+	ID            uint64                          `json:"id"`
+	ProjectID     uint64                          `json:"project_id"`
+	BuildID       uint64                          `json:"build_id"`
+	Environment   constants.DeploymentEnvironment `json:"environment"`
+	Branch        *string                         `json:"branch"`
+	CommitHash    *string                         `json:"commit_hash"`
+	PullRequest   *int                            `json:"pull_request"`
+	URL           string                          `json:"url"`
+	Aliases       []string                        `json:"aliases"`
+	Container     *Container                      `json:"container"`
+	Logs          string                          `json:"logs"`
+	FailureReason *string                         `json:"failure_reason"`
+	FailureCode   constants.FailureCode           `json:"failure_code,omitempty"`
+	CreatedAt     time.Time                       `json:"created_at"`
+	UpdatedAt     time.Time                       `json:"updated_at"`
+	Status        constants.DeploymentStatus      `json:"status"`
+	StoppedAt     *time.Time                      `json:"stopped_at"`
+}
+
+type Project struct {
+	ID               uint64 `json:"id"`
+	Name             string `json:"name"`
+	RepoUrl          string `json:"repo_url"`
+	ProductionBranch string `json:"production_branch"`
+	// RootDirectory is where go.mod lives, relative to the repository root
+	RootDirectory string `json:"root_directory,omitempty"`
+	// MainPackage is built relative to RootDirectory, "." when empty
+	MainPackage string `json:"main_package,omitempty"`
+	// WatchPaths are directories outside RootDirectory whose changes also trigger builds
+	WatchPaths             []string                `json:"watch_paths,omitempty"`
+	ProductionDeploymentID uint64                  `json:"production_deployment_id"`
+	ProductionURL          string                  `json:"production_url"`
+	ResourceClass          constants.ResourceClass `json:"resource_class"`
+	BuildProfile           constants.BuildProfile  `json:"build_profile"`
+	Egress                 EgressPolicy            `json:"egress"`
+	GitAuth                GitAuth                 `json:"git_auth"`
+	Submodules             bool                    `json:"submodules"`
+	LFS                    bool                    `json:"lfs"`
+	// GoVersion overrides the Go version go.mod asks for
+	GoVersion string       `json:"go_version,omitempty"`
+	Build     BuildOptions `json:"build"`
+	Modules   GoModules    `json:"modules"`
+	Test      TestOptions  `json:"test"`
+	// Builder is set to auto for new projects. Projects from before Dockerfile builds have none
+	// and compile the Go app, unless they name a Dockerfile.
+	Builder constants.Builder `json:"builder,omitempty"`
+	// Dockerfile is relative to RootDirectory, a Dockerfile there is used when empty
+	Dockerfile string `json:"dockerfile,omitempty"`
+	// Timeouts overrides phase deadlines, in seconds
+	Timeouts  map[constants.BuildPhase]int `json:"timeouts,omitempty"`
+	CreatedAt time.Time                    `json:"created_at"`
+	UpdatedAt time.Time                    `json:"updated_at"`
+}
+
+// BuildOptions tune the go build of the compile step, they do not apply to a custom build command
+type BuildOptions struct {
+	Tags    []string `json:"tags,omitempty"`
+	Ldflags string   `json:"ldflags,omitempty"`
+	// VersionPackage gets its Version, Commit and BuildTime string variables set through -X, "main" when empty
+	VersionPackage string `json:"version_package,omitempty"`
+	TrimPath       bool   `json:"trimpath"`
+	CGO            bool   `json:"cgo"`
+	// GOARCH is amd64 when empty, other architectures run emulated unless the docker host matches
+	GOARCH string `json:"goarch,omitempty"`
+}
+
+// TestOptions gate deployments on go vet and go test ./..., run after the compile step
+type TestOptions struct {
+	Enabled bool `json:"enabled"`
+	// Race runs the tests with the race detector, which needs cgo and the cgo builder image
+	Race bool `json:"race"`
+}
+
+// GoModules configures where the build fetches modules from. GOFLAGS gets -mod=readonly,
+// or -mod=vendor with a vendor directory, unless it sets -mod itself.
+type GoModules struct {
+	GOPROXY   string `json:"goproxy,omitempty"`
+	GONOSUMDB string `json:"gonosumdb,omitempty"`
+	GOPRIVATE string `json:"goprivate,omitempty"`
+	GOFLAGS   string `json:"goflags,omitempty"`
+	// Auth gives the build credentials for private module hosts
+	Auth []ModuleAuth `json:"auth,omitempty"`
+}
+
+// ModuleAuth takes the token for Host from one of the project's build env vars, ideally a secret
+type ModuleAuth struct {
+	Host     string `json:"host"`
+	Username string `json:"username,omitempty"`
+	TokenEnv string `json:"token_env"`
+}
+
+// GitAuth describes how clones authenticate, the private key and token are stored encrypted elsewhere
+type GitAuth struct {
+	Method constants.GitAuthMethod `json:"method"`
+	// PublicKey is the deploy key to add to the repository, read access is enough
+	PublicKey string `json:"public_key,omitempty"`
+	Username  string `json:"username,omitempty"`
+	// KnownHosts holds the host keys of a self-hosted git server, the hosted providers' keys are pinned
+	KnownHosts string `json:"known_hosts,omitempty"`
+}
+
+type EgressPolicy struct {
+	Mode constants.EgressMode `json:"mode"`
+	// Allow lists CIDRs and hostnames reachable in allowlist mode, *.example.com matches subdomains
+	Allow []string `json:"allow,omitempty"`
+}
+
+type EnvVar struct {
+	ID           uint64                            `json:"id"`
+	Key          string                            `json:"key"`
+	Value        string                            `json:"value"`
+	Secret       bool                              `json:"secret"`
+	Environments []constants.DeploymentEnvironment `json:"environments"`
+	Targets      []constants.EnvTarget             `json:"targets"`
+	CreatedAt    time.Time                         `json:"created_at"`
+	UpdatedAt    time.Time                         `json:"updated_at"`
+}
+
+type WebhookDelivery struct {
+	DeliveryID string                           `json:"delivery_id"`
+	Provider   string                           `json:"provider"`
+	Event      string                           `json:"event"`
+	Repository string                           `json:"repository"`
+	Outcome    constants.WebhookDeliveryOutcome `json:"outcome"`
+	Detail     string                           `json:"detail,omitempty"`
+	BuildIDs   []uint64                         `json:"build_ids,omitempty"`
+	ReceivedAt time.Time                        `json:"received_at"`
 }
@@ -0,0 +1,117 @@
+package encryption
+
+import (
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func testKey(b byte) string {
+	return base64.StdEncoding.EncodeToString([]byte(strings.Repeat(string(b), 32)))
+}
+
+func newTestCipher(t *testing.T, key string) *Cipher {
+	t.Helper()
+	c, err := NewCipher(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestNewCipher(t *testing.T) {
+	tests := []struct {
+		name    string
+		key     string
+		wantNil bool
+		wantErr bool
+	}{
+		{name: "valid key", key: testKey('k')},
+		{name: "no key", key: "", wantNil: true},
+		{name: "not base64", key: "not base64!", wantErr: true},
+		{name: "short key", key: base64.StdEncoding.EncodeToString([]byte("short")), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := NewCipher(tt.key)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewCipher() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && (c == nil) != tt.wantNil {
+				t.Errorf("NewCipher() = %v, want nil %v", c, tt.wantNil)
+			}
+		})
+	}
+}
+
+func TestCipherRoundTrip(t *testing.T) {
+	c := newTestCipher(t, testKey('k'))
+	for _, plaintext := range []string{"", "s3cr3t", "multi\nline ünïcode value", strings.Repeat("x", 4096)} {
+		sealed, err := c.Encrypt(plaintext)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if plaintext != "" && strings.Contains(sealed, plaintext) {
+			t.Errorf("Encrypt(%q) = %q, contains the plaintext", plaintext, sealed)
+		}
+		got, err := c.Decrypt(sealed)
+		if err != nil {
+			t.Fatalf("Decrypt() error = %v", err)
+		}
+		if got != plaintext {
+			t.Errorf("Decrypt(Encrypt(%q)) = %q", plaintext, got)
+		}
+	}
+
+	first, _ := c.Encrypt("s3cr3t")
+	second, _ := c.Encrypt("s3cr3t")
+	if first == second {
+		t.Error("Encrypt() returned the same ciphertext twice, want a fresh nonce each time")
+	}
+}
+
+func TestCipherRejectsTampering(t *testing.T) {
+	c := newTestCipher(t, testKey('k'))
+	sealed, err := c.Encrypt("s3cr3t")
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw, _ := base64.StdEncoding.DecodeString(sealed)
+	flip := func(i int) string {
+		tampered := append([]byte(nil), raw...)
+		tampered[i] ^= 0x01
+		return base64.StdEncoding.EncodeToString(tampered)
+	}
+
+	tests := []struct {
+		name   string
+		cipher *Cipher
+		sealed string
+	}{
+		{name: "flipped nonce", cipher: c, sealed: flip(0)},
+		{name: "flipped ciphertext", cipher: c, sealed: flip(c.aead.NonceSize())},
+		{name: "flipped tag", cipher: c, sealed: flip(len(raw) - 1)},
+		{name: "truncated", cipher: c, sealed: base64.StdEncoding.EncodeToString(raw[:len(raw)-1])},
+		{name: "shorter than a nonce", cipher: c, sealed: base64.StdEncoding.EncodeToString(raw[:4])},
+		{name: "not base64", cipher: c, sealed: "%%%"},
+		{name: "other key", cipher: newTestCipher(t, testKey('o')), sealed: sealed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got, err := tt.cipher.Decrypt(tt.sealed); err == nil {
+				t.Errorf("Decrypt() = %q, want an error", got)
+			}
+		})
+	}
+}
+
+func TestCipherWithoutKey(t *testing.T) {
+	var c *Cipher
+	if _, err := c.Encrypt("s3cr3t"); !errors.Is(err, ErrKeyNotConfigured) {
+		t.Errorf("Encrypt() error = %v, want ErrKeyNotConfigured", err)
+	}
+	if _, err := c.Decrypt("c2VjcmV0"); !errors.Is(err, ErrKeyNotConfigured) {
+		t.Errorf("Decrypt() error = %v, want ErrKeyNotConfigured", err)
+	}
+}
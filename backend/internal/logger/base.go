@@ -26,6 +26,7 @@ func Init(env string) error {
 	log, err = config.Build(
 		zap.AddCallerSkip(1),
 		zap.AddStacktrace(zapcore.ErrorLevel),
+		zap.WrapCore(newRedactingCore),
 	)
 	if err != nil {
 		return err
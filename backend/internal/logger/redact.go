@@ -0,0 +1,128 @@
+package logger
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/RajVerma97/golang-vercel/backend/internal/redact"
+	"go.uber.org/zap/zapcore"
+)
+
+// secrets are the values masked in everything that gets logged, on top of well known token formats
+var secrets = &secretRegistry{counts: make(map[string]int), redactor: redact.New(nil)}
+
+// secretRegistry counts registrations, so a value two builds share stays masked until both are done
+type secretRegistry struct {
+	mu       sync.RWMutex
+	counts   map[string]int
+	redactor *redact.Redactor
+}
+
+// RegisterSecrets masks values in the logs until the returned func is called.
+// Builds register their secret env vars and git credentials for as long as they run.
+func RegisterSecrets(values ...string) (unregister func()) {
+	secrets.update(values, 1)
+	var once sync.Once
+	return func() {
+		once.Do(func() { secrets.update(values, -1) })
+	}
+}
+
+func (r *secretRegistry) update(values []string, delta int) {
+	if len(values) == 0 {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, value := range values {
+		if r.counts[value] += delta; r.counts[value] <= 0 {
+			delete(r.counts, value)
+		}
+	}
+	registered := make([]string, 0, len(r.counts))
+	for value := range r.counts {
+		registered = append(registered, value)
+	}
+	r.redactor = redact.New(registered)
+}
+
+func (r *secretRegistry) current() *redact.Redactor {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.redactor
+}
+
+// redactingCore redacts messages and fields before they are written out
+type redactingCore struct {
+	zapcore.Core
+}
+
+func newRedactingCore(core zapcore.Core) zapcore.Core {
+	return &redactingCore{Core: core}
+}
+
+func (c *redactingCore) With(fields []zapcore.Field) zapcore.Core {
+	return &redactingCore{Core: c.Core.With(redactFields(fields))}
+}
+
+func (c *redactingCore) Check(entry zapcore.Entry, checked *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(entry.Level) {
+		return checked.AddCore(entry, c)
+	}
+	return checked
+}
+
+func (c *redactingCore) Write(entry zapcore.Entry, fields []zapcore.Field) error {
+	entry.Message = secrets.current().Redact(entry.Message)
+	return c.Core.Write(entry, redactFields(fields))
+}
+
+// redactFields masks secrets in every field that can hold text. Fields that are encoded as
+// structures, such as zap.Any of a struct or zap.Strings, are checked in their JSON form and
+// replaced by the redacted JSON string when they leak anything.
+func redactFields(fields []zapcore.Field) []zapcore.Field {
+	redactor := secrets.current()
+	redacted := make([]zapcore.Field, len(fields))
+	for i, field := range fields {
+		switch field.Type {
+		case zapcore.StringType:
+			field.String = redactor.Redact(field.String)
+		case zapcore.ErrorType:
+			if err, ok := field.Interface.(error); ok {
+				field = zapcore.Field{Key: field.Key, Type: zapcore.StringType, String: redactor.Redact(err.Error())}
+			}
+		case zapcore.StringerType, zapcore.ByteStringType:
+			field = zapcore.Field{Key: field.Key, Type: zapcore.StringType, String: redactor.Redact(stringify(field))}
+		case zapcore.ReflectType, zapcore.ArrayMarshalerType, zapcore.ObjectMarshalerType, zapcore.InlineMarshalerType:
+			text := stringify(field)
+			if masked := redactor.Redact(text); masked != text {
+				field = zapcore.Field{Key: field.Key, Type: zapcore.StringType, String: masked}
+			}
+		}
+		redacted[i] = field
+	}
+	return redacted
+}
+
+// stringify renders field the way the JSON encoder would, strings as they are
+func stringify(field zapcore.Field) string {
+	enc := zapcore.NewMapObjectEncoder()
+	field.AddTo(enc)
+	var value any = enc.Fields
+	if v, ok := enc.Fields[field.Key]; ok && len(enc.Fields) == 1 {
+		value = v
+	}
+	if s, ok := value.(string); ok {
+		return s
+	}
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(value); err != nil {
+		return fmt.Sprint(value)
+	}
+	return strings.TrimSuffix(buf.String(), "\n")
+}
@@ -0,0 +1,163 @@
+package proxy
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/RajVerma97/golang-vercel/backend/internal/logger"
+	"go.uber.org/zap"
+)
+
+type upstream struct {
+	target   *url.URL
+	handler  *httputil.ReverseProxy
+	inflight atomic.Int64
+}
+
+// Proxy routes requests by Host header to deployment containers.
+// Routes are swapped under a lock so an alias always points at exactly one upstream.
+type Proxy struct {
+	mu        sync.RWMutex
+	routes    map[string]*upstream // host -> upstream
+	upstreams map[string]*upstream // target url -> upstream
+}
+
+func NewProxy() *Proxy {
+	return &Proxy{
+		routes:    make(map[string]*upstream),
+		upstreams: make(map[string]*upstream),
+	}
+}
+
+// SetRoute points host at target and returns the target it pointed at before, if any
+func (p *Proxy) SetRoute(host, target string) (string, error) {
+	targetURL, err := url.Parse(target)
+	if err != nil {
+		return "", fmt.Errorf("invalid upstream url %s: %w", target, err)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	up, ok := p.upstreams[target]
+	if !ok {
+		up = &upstream{
+			target:  targetURL,
+			handler: httputil.NewSingleHostReverseProxy(targetURL),
+		}
+		p.upstreams[target] = up
+	}
+
+	var previous string
+	if old, ok := p.routes[normalizeHost(host)]; ok {
+		previous = old.target.String()
+	}
+	p.routes[normalizeHost(host)] = up
+	logger.Debug("Proxy route updated", zap.String("host", host), zap.String("target", target), zap.String("previous", previous))
+	return previous, nil
+}
+
+func (p *Proxy) RemoveRoute(host string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	delete(p.routes, normalizeHost(host))
+}
+
+// Route returns the target host currently points at
+func (p *Proxy) Route(host string) (string, bool) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	up, ok := p.routes[normalizeHost(host)]
+	if !ok {
+		return "", false
+	}
+	return up.target.String(), true
+}
+
+// Drain waits until target has no in-flight requests or the grace period elapses.
+// It reports whether the upstream drained cleanly.
+func (p *Proxy) Drain(ctx context.Context, target string, grace time.Duration) bool {
+	p.mu.RLock()
+	up, ok := p.upstreams[target]
+	p.mu.RUnlock()
+	if !ok {
+		return true
+	}
+
+	deadline := time.NewTimer(grace)
+	defer deadline.Stop()
+	ticker := time.NewTicker(250 * time.Millisecond)
+	defer ticker.Stop()
+
+	for up.inflight.Load() > 0 {
+		select {
+		case <-ctx.Done():
+			return false
+		case <-deadline.C:
+			logger.Warn("Upstream did not drain within grace period",
+				zap.String("target", target), zap.Int64("inflight", up.inflight.Load()))
+			p.forget(target)
+			return false
+		case <-ticker.C:
+		}
+	}
+	p.forget(target)
+	return true
+}
+
+// Serves reports whether any route points at target
+func (p *Proxy) Serves(target string) bool {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.serves(target)
+}
+
+func (p *Proxy) serves(target string) bool {
+	for _, up := range p.routes {
+		if up.target.String() == target {
+			return true
+		}
+	}
+	return false
+}
+
+// forget drops an upstream that no route points at anymore
+func (p *Proxy) forget(target string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if !p.serves(target) {
+		delete(p.upstreams, target)
+	}
+}
+
+func (p *Proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	p.mu.RLock()
+	up, ok := p.routes[normalizeHost(r.Host)]
+	if ok {
+		// count the request before releasing the lock so a concurrent Drain cannot miss it
+		up.inflight.Add(1)
+	}
+	p.mu.RUnlock()
+
+	if !ok {
+		http.Error(w, "deployment not found", http.StatusNotFound)
+		return
+	}
+	defer up.inflight.Add(-1)
+	up.handler.ServeHTTP(w, r)
+}
+
+func normalizeHost(host string) string {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	return strings.ToLower(host)
+}
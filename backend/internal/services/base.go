@@ -4,9 +4,12 @@ import (
 	"context"
 
 	docker_client "github.com/RajVerma97/golang-vercel/backend/internal/client/docker"
+	github_client "github.com/RajVerma97/golang-vercel/backend/internal/client/github"
 	redis_client "github.com/RajVerma97/golang-vercel/backend/internal/client/redis"
 	"github.com/RajVerma97/golang-vercel/backend/internal/config"
+	"github.com/RajVerma97/golang-vercel/backend/internal/encryption"
 	"github.com/RajVerma97/golang-vercel/backend/internal/logger"
+	"github.com/RajVerma97/golang-vercel/backend/internal/proxy"
 )
 
 type Services struct {
@@ -15,9 +18,17 @@ type Services struct {
 	WorkspaceManagerService *WorkspaceManagerService
 	GitService              *GitService
 	RedisService            *RedisService
+	ProjectService          *ProjectService
+	ReleaseService          *ReleaseService
+	GitHubStatusService     *GitHubStatusService
+	WebhookDeliveryService  *WebhookDeliveryService
+	EnvService              *EnvService
+	NetworkService          *NetworkService
+	GitAuthService          *GitAuthService
+	CacheService            *CacheService
 }
 
-func NewServices(ctx context.Context, config *config.Config) (*Services, error) {
+func NewServices(ctx context.Context, config *config.Config, proxy *proxy.Proxy) (*Services, error) {
 	dockerClient, err := docker_client.NewDockerClient()
 	if err != nil {
 		logger.Error("failed to init docker client", err)
@@ -29,18 +40,98 @@ func NewServices(ctx context.Context, config *config.Config) (*Services, error)
 		logger.Error("failed to init redis client", err)
 		return nil, err
 	}
+	githubClient, err := github_client.NewGitHubClient(config.GitHub)
+	if err != nil {
+		logger.Error("failed to init github client", err)
+		return nil, err
+	}
+	cipher, err := encryption.NewCipher(config.Encryption.Key)
+	if err != nil {
+		logger.Error("failed to init encryption", err)
+		return nil, err
+	}
+	seccompProfile, err := LoadSeccompProfile(config.Sandbox.SeccompProfilePath)
+	if err != nil {
+		logger.Error("failed to load seccomp profile", err)
+		return nil, err
+	}
+	toolchains, err := LoadToolchainCatalog(config.Toolchain)
+	if err != nil {
+		logger.Error("failed to load builder catalog", err)
+		return nil, err
+	}
+	toolchains.Pin(ctx, dockerClient)
+	cacheService := NewCacheService(&CacheServiceConfig{
+		RedisClient:   redisClient,
+		StorageConfig: config.Storage,
+	})
+	networkService := NewNetworkService(&NetworkServiceConfig{
+		DockerClient:  dockerClient,
+		NetworkConfig: config.Network,
+		StorageConfig: config.Storage,
+	})
 	buildService := NewBuildService(&BuildServiceConfig{
-		DockerClient: dockerClient,
+		DockerClient:    dockerClient,
+		CacheService:    cacheService,
+		NetworkService:  networkService,
+		Toolchains:      toolchains,
+		ResourcesConfig: config.Resources,
+		SandboxConfig:   config.Sandbox,
+		TimeoutsConfig:  config.Timeouts,
+		SeccompProfile:  seccompProfile,
 	})
 	deployService := NewDeployService(&DeployServiceConfig{
-		DockerClient: dockerClient,
+		DockerClient:    dockerClient,
+		NetworkService:  networkService,
+		DeployConfig:    config.Deploy,
+		ResourcesConfig: config.Resources,
+		TimeoutsConfig:  config.Timeouts,
+	})
+	workspaceManagerService := NewWorkspaceManagerService(&WorkspaceManagerServiceConfig{
+		StorageConfig: config.Storage,
 	})
-	workspaceManagerService := NewWorkspaceManagerService(&WorkspaceManagerServiceConfig{})
-	gitService := NewGitService(&GitServiceConfig{})
 
 	redisService := NewRedisService(&RedisServiceConfig{
 		RedisClient: redisClient,
 	})
+	projectService := NewProjectService(&ProjectServiceConfig{
+		RedisClient: redisClient,
+	})
+	gitAuthService := NewGitAuthService(&GitAuthServiceConfig{
+		RedisClient:    redisClient,
+		GitHubClient:   githubClient,
+		ProjectService: projectService,
+		Cipher:         cipher,
+	})
+	gitService := NewGitService(&GitServiceConfig{
+		GitAuthService: gitAuthService,
+		TimeoutsConfig: config.Timeouts,
+		MirrorConfig:   config.GitMirror,
+		StorageConfig:  config.Storage,
+	})
+	envService := NewEnvService(&EnvServiceConfig{
+		RedisClient: redisClient,
+		Cipher:      cipher,
+	})
+	releaseService := NewReleaseService(&ReleaseServiceConfig{
+		DockerClient:   dockerClient,
+		RedisClient:    redisClient,
+		RedisService:   redisService,
+		ProjectService: projectService,
+		DeployService:  deployService,
+		EnvService:     envService,
+		Proxy:          proxy,
+		ProxyConfig:    config.Proxy,
+		DeployConfig:   config.Deploy,
+	})
+	githubStatusService := NewGitHubStatusService(&GitHubStatusServiceConfig{
+		GitHubClient: githubClient,
+		ServerConfig: config.Server,
+	})
+	webhookDeliveryService := NewWebhookDeliveryService(&WebhookDeliveryServiceConfig{
+		RedisClient:   redisClient,
+		WebhookConfig: config.Webhook,
+	})
 
 	return &Services{
 		BuildService:            buildService,
@@ -48,5 +139,13 @@ func NewServices(ctx context.Context, config *config.Config) (*Services, error)
 		WorkspaceManagerService: workspaceManagerService,
 		GitService:              gitService,
 		RedisService:            redisService,
+		ProjectService:          projectService,
+		ReleaseService:          releaseService,
+		GitHubStatusService:     githubStatusService,
+		WebhookDeliveryService:  webhookDeliveryService,
+		EnvService:              envService,
+		NetworkService:          networkService,
+		GitAuthService:          gitAuthService,
+		CacheService:            cacheService,
 	}, nil
 }
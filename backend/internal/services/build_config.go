@@ -0,0 +1,351 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/RajVerma97/golang-vercel/backend/internal/constants"
+	"github.com/RajVerma97/golang-vercel/backend/internal/dto"
+	"github.com/RajVerma97/golang-vercel/backend/internal/logger"
+	"github.com/goccy/go-yaml"
+	"go.uber.org/zap"
+)
+
+const (
+	defaultOutput     = "bin/app"
+	defaultAppPort    = 8080
+	maxBuildConfigLen = 64 * 1024
+)
+
+const (
+	maxSteps       = 20
+	maxStepTimeout = 7200
+)
+
+// buildConfigFiles are looked up in the project's root directory, at most one of them may exist
+var buildConfigFiles = []string{"deploy.yaml", "deploy.yml", "deploy.json"}
+
+var (
+	goVersionPattern = regexp.MustCompile(`^1\.\d+(\.\d+)?$`)
+	buildTagPattern  = regexp.MustCompile(`^!?[A-Za-z0-9_.]+$`)
+	configEnvPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+	stepNamePattern  = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,39}$`)
+	imagePattern     = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._/:@-]*$`)
+)
+
+// reservedStepNames are the platform's steps and the containers they run
+var reservedStepNames = []string{"clone", "build", "dependencies", "compile", "test", "vet", "deploy"}
+
+// LoadBuildConfig reads the build configuration in dir, nil when the repository has none.
+// Errors name the file and the offending key so they can be shown to the user as they are.
+func LoadBuildConfig(dir string) (*dto.BuildConfig, error) {
+	var name string
+	for _, candidate := range buildConfigFiles {
+		info, err := os.Lstat(filepath.Join(dir, candidate))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to stat %s: %w", candidate, err)
+		}
+		// the workspace is untrusted, a symlink could point anywhere on the host
+		if !info.Mode().IsRegular() {
+			return nil, fmt.Errorf("%s must be a regular file", candidate)
+		}
+		if info.Size() > maxBuildConfigLen {
+			return nil, fmt.Errorf("%s is larger than %d bytes", candidate, maxBuildConfigLen)
+		}
+		if name != "" {
+			return nil, fmt.Errorf("found both %s and %s, keep only one", name, candidate)
+		}
+		name = candidate
+	}
+	if name == "" {
+		return nil, nil
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, name))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", name, err)
+	}
+	if filepath.Ext(name) != ".json" {
+		if data, err = yaml.YAMLToJSON(data); err != nil {
+			return nil, fmt.Errorf("%s is not valid YAML: %w", name, err)
+		}
+	}
+
+	var config dto.BuildConfig
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&config); err != nil {
+		return nil, fmt.Errorf("invalid %s: %s", name, decodeErrorMessage(err))
+	}
+	if problems := validateBuildConfig(&config); len(problems) > 0 {
+		return nil, fmt.Errorf("invalid %s: %s", name, strings.Join(problems, "; "))
+	}
+	logger.Info("Loaded build config", zap.String("file", name))
+	return &config, nil
+}
+
+func decodeErrorMessage(err error) string {
+	var typeErr *json.UnmarshalTypeError
+	var syntaxErr *json.SyntaxError
+	switch {
+	case errors.As(err, &typeErr):
+		if typeErr.Field == "" {
+			return fmt.Sprintf("expected an object, got %s", typeErr.Value)
+		}
+		return fmt.Sprintf("%s: expected %s, got %s", typeErr.Field, typeName(typeErr), typeErr.Value)
+	case errors.As(err, &syntaxErr):
+		return fmt.Sprintf("%s at byte %d", syntaxErr, syntaxErr.Offset)
+	}
+	// unknown keys only come as a plain error: json: unknown field "x"
+	if field, ok := strings.CutPrefix(err.Error(), "json: unknown field "); ok {
+		return fmt.Sprintf("unknown key %s", field)
+	}
+	return err.Error()
+}
+
+func typeName(err *json.UnmarshalTypeError) string {
+	switch err.Type.Kind().String() {
+	case "slice":
+		return "a list"
+	case "map", "struct":
+		return "an object"
+	case "int":
+		return "a number"
+	}
+	return "a " + err.Type.String()
+}
+
+// validateBuildConfig returns one message per invalid key, in key order
+func validateBuildConfig(config *dto.BuildConfig) []string {
+	var problems []string
+	add := func(key, format string, args ...any) {
+		problems = append(problems, key+": "+fmt.Sprintf(format, args...))
+	}
+
+	if config.BuildCommand != "" && (len(config.Tags) > 0 || config.Ldflags != "") {
+		add("build_command", "cannot be combined with tags or ldflags, pass them in the command")
+	}
+	if config.Env != nil {
+		keys := make([]string, 0, len(config.Env))
+		for key := range config.Env {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			if !configEnvPattern.MatchString(key) {
+				add("env."+key, "must start with a letter or underscore and contain only letters, digits and underscores")
+			}
+		}
+	}
+	if config.GoVersion != "" && !goVersionPattern.MatchString(config.GoVersion) {
+		add("go_version", "%q is not a Go version such as 1.24 or 1.24.1", config.GoVersion)
+	}
+	if config.HealthCheckPath != "" && !strings.HasPrefix(config.HealthCheckPath, "/") {
+		add("health_check_path", "must start with /")
+	}
+	for i, pattern := range config.Ignore {
+		if _, err := path.Match(pattern, ""); err != nil || !isRepoPath(pattern) {
+			add(fmt.Sprintf("ignore.%d", i), "%q is not a relative path pattern", pattern)
+		}
+	}
+	if config.Output != "" && !isRepoPath(config.Output) {
+		add("output", "%q must be a path inside the root directory", config.Output)
+	}
+	if config.Port != 0 && (config.Port < 1 || config.Port > 65535) {
+		add("port", "%d is not between 1 and 65535", config.Port)
+	}
+	if config.ResourceClass != "" && !slices.Contains(resourceClassOrder, config.ResourceClass) {
+		add("resource_class", "invalid value %q, must be one of small standard large", config.ResourceClass)
+	}
+	problems = append(problems, validateSteps(config.Steps)...)
+	for i, tag := range config.Tags {
+		if !buildTagPattern.MatchString(tag) {
+			add(fmt.Sprintf("tags.%d", i), "%q is not a valid build tag", tag)
+		}
+	}
+	return problems
+}
+
+// validateSteps checks the steps one by one, the pipeline rejects cycles among them when it adds them
+func validateSteps(steps []dto.StepConfig) []string {
+	var problems []string
+	if len(steps) > maxSteps {
+		return []string{fmt.Sprintf("steps: at most %d steps are allowed", maxSteps)}
+	}
+	stages := make(map[string]constants.StepStage, len(steps))
+	for _, step := range steps {
+		stages[step.Name] = stepStage(step)
+	}
+	seen := make(map[string]bool, len(steps))
+	for i, step := range steps {
+		add := func(key, format string, args ...any) {
+			problems = append(problems, fmt.Sprintf("steps.%d.%s: ", i, key)+fmt.Sprintf(format, args...))
+		}
+		switch {
+		case !stepNamePattern.MatchString(step.Name):
+			add("name", "%q must be up to 40 lowercase letters, digits, _ and -", step.Name)
+		case slices.Contains(reservedStepNames, step.Name):
+			add("name", "%q is the name of a platform step", step.Name)
+		case seen[step.Name]:
+			add("name", "%q is used by another step", step.Name)
+		}
+		seen[step.Name] = true
+		if !slices.Contains([]constants.StepStage{constants.StepStagePreBuild, constants.StepStagePostBuild, constants.StepStagePostDeploy}, stepStage(step)) {
+			add("stage", "invalid value %q, must be one of pre_build post_build post_deploy", step.Stage)
+		}
+		if step.Image != "" && !imagePattern.MatchString(step.Image) {
+			add("image", "%q is not an image reference", step.Image)
+		}
+		if strings.TrimSpace(step.Run) == "" {
+			add("run", "is required")
+		}
+		keys := make([]string, 0, len(step.Env))
+		for key := range step.Env {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			if !configEnvPattern.MatchString(key) {
+				add("env."+key, "must start with a letter or underscore and contain only letters, digits and underscores")
+			}
+		}
+		for _, need := range step.Needs {
+			if stage, ok := stages[need]; !ok || stage != stepStage(step) {
+				add("needs", "%q is not a step of the %s stage", need, stepStage(step))
+			}
+		}
+		if step.Timeout < 0 || step.Timeout > maxStepTimeout {
+			add("timeout", "%d is not between 1 and %d seconds", step.Timeout, maxStepTimeout)
+		}
+		for j, artifact := range step.Artifacts {
+			if !isRepoPath(artifact) {
+				add(fmt.Sprintf("artifacts.%d", j), "%q must be a path inside the root directory", artifact)
+			}
+		}
+	}
+	return problems
+}
+
+// stepStage defaults the stage of a repository's step to post_build
+func stepStage(step dto.StepConfig) constants.StepStage {
+	if step.Stage == "" {
+		return constants.StepStagePostBuild
+	}
+	return step.Stage
+}
+
+// isRepoPath reports whether p is relative and stays inside the directory it is relative to
+func isRepoPath(p string) bool {
+	return p != "" && !path.IsAbs(p) && !slices.Contains(strings.Split(p, "/"), "..")
+}
+
+// buildConfigOf returns the build's configuration, the zero value for builds without one
+func buildConfigOf(build *dto.Build) dto.BuildConfig {
+	if build.Config == nil {
+		return dto.BuildConfig{}
+	}
+	return *build.Config
+}
+
+// resourceClassOrder ranks the resource classes from the smallest
+var resourceClassOrder = []constants.ResourceClass{constants.ResourceClassSmall, constants.ResourceClassStandard, constants.ResourceClassLarge}
+
+// resourceClassOf is the project's resource class, defaultClass when it has none. The repository can
+// only ask for a smaller one, since anyone who can push, or open a pull request, controls its config.
+func resourceClassOf(project *dto.Project, build *dto.Build, defaultClass constants.ResourceClass) constants.ResourceClass {
+	class := project.ResourceClass
+	if class == "" {
+		class = defaultClass
+	}
+	requested := buildConfigOf(build).ResourceClass
+	if requested != "" && slices.Index(resourceClassOrder, requested) < slices.Index(resourceClassOrder, class) {
+		return requested
+	}
+	return class
+}
+
+// appPort is the port the deployed app listens on
+func appPort(build *dto.Build) string {
+	port := defaultAppPort
+	if configured := buildConfigOf(build).Port; configured != 0 {
+		port = configured
+	}
+	return fmt.Sprint(port)
+}
+
+// withEnvDefaults adds the defaults whose keys env does not set already
+func withEnvDefaults(env []string, defaults map[string]string) []string {
+	if len(defaults) == 0 {
+		return env
+	}
+	set := make(map[string]bool, len(env))
+	for _, pair := range env {
+		key, _, _ := strings.Cut(pair, "=")
+		set[key] = true
+	}
+	keys := make([]string, 0, len(defaults))
+	for key := range defaults {
+		if !set[key] {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	merged := append([]string{}, env...)
+	for _, key := range keys {
+		merged = append(merged, key+"="+defaults[key])
+	}
+	return merged
+}
+
+// removeIgnored deletes what the ignore patterns match below root. Patterns with a slash match
+// paths relative to root, patterns without one match file and directory names at any depth.
+func removeIgnored(root string, patterns []string) error {
+	if len(patterns) == 0 {
+		return nil
+	}
+	return filepath.WalkDir(root, func(p string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(root, p)
+		if err != nil || rel == "." {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		for _, pattern := range patterns {
+			subject := rel
+			if !strings.Contains(pattern, "/") {
+				subject = path.Base(rel)
+			}
+			if matched, _ := path.Match(strings.TrimSuffix(pattern, "/"), subject); !matched {
+				continue
+			}
+			if err := os.RemoveAll(p); err != nil {
+				return fmt.Errorf("failed to remove ignored path %s: %w", rel, err)
+			}
+			if entry.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		return nil
+	})
+}
+
+// shellQuote quotes s for sh
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
@@ -0,0 +1,274 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/RajVerma97/golang-vercel/backend/internal/constants"
+	"github.com/RajVerma97/golang-vercel/backend/internal/dto"
+)
+
+func TestLoadBuildConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		files   map[string]string
+		want    *dto.BuildConfig
+		wantErr string
+	}{
+		{
+			name: "no config",
+		},
+		{
+			name: "yaml",
+			files: map[string]string{"deploy.yaml": `
+output: bin/server
+go_version: "1.24"
+tags: [netgo]
+port: 3000
+env:
+  APP_ENV: production
+resource_class: small
+`},
+			want: &dto.BuildConfig{
+				Output: "bin/server", GoVersion: "1.24", Tags: []string{"netgo"}, Port: 3000,
+				Env: map[string]string{"APP_ENV": "production"}, ResourceClass: constants.ResourceClassSmall,
+			},
+		},
+		{
+			name:  "json",
+			files: map[string]string{"deploy.json": `{"build_command": "make build", "health_check_path": "/healthz"}`},
+			want:  &dto.BuildConfig{BuildCommand: "make build", HealthCheckPath: "/healthz"},
+		},
+		{
+			name:    "two configs",
+			files:   map[string]string{"deploy.yaml": "port: 3000", "deploy.json": `{"port": 3000}`},
+			wantErr: "found both deploy.yaml and deploy.json, keep only one",
+		},
+		{
+			name:    "unknown key",
+			files:   map[string]string{"deploy.yml": "prot: 3000"},
+			wantErr: `invalid deploy.yml: unknown key "prot"`,
+		},
+		{
+			name:    "wrong type",
+			files:   map[string]string{"deploy.yaml": "port: three"},
+			wantErr: "invalid deploy.yaml: port: expected a number, got string",
+		},
+		{
+			name:    "not an object",
+			files:   map[string]string{"deploy.json": `[]`},
+			wantErr: "invalid deploy.json: expected an object, got array",
+		},
+		{
+			name:    "invalid yaml",
+			files:   map[string]string{"deploy.yaml": "port: [3000"},
+			wantErr: "deploy.yaml is not valid YAML",
+		},
+		{
+			name:    "invalid values",
+			files:   map[string]string{"deploy.yaml": "port: 70000\noutput: ../app"},
+			wantErr: `invalid deploy.yaml: output: "../app" must be a path inside the root directory; port: 70000 is not between 1 and 65535`,
+		},
+		{
+			name:    "too large",
+			files:   map[string]string{"deploy.yaml": "# " + strings.Repeat("x", maxBuildConfigLen)},
+			wantErr: "deploy.yaml is larger than",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for name, content := range tt.files {
+				if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			got, err := LoadBuildConfig(dir)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("LoadBuildConfig() error = %v, want it to contain %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("LoadBuildConfig() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("LoadBuildConfig() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadBuildConfigRejectsSymlinks(t *testing.T) {
+	dir := t.TempDir()
+	target := filepath.Join(t.TempDir(), "secret.yaml")
+	if err := os.WriteFile(target, []byte("port: 3000"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(target, filepath.Join(dir, "deploy.yaml")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadBuildConfig(dir); err == nil || !strings.Contains(err.Error(), "must be a regular file") {
+		t.Errorf("LoadBuildConfig() error = %v, want a symlinked config to be rejected", err)
+	}
+}
+
+func TestValidateBuildConfig(t *testing.T) {
+	tests := []struct {
+		name   string
+		config dto.BuildConfig
+		want   []string
+	}{
+		{
+			name:   "empty",
+			config: dto.BuildConfig{},
+		},
+		{
+			name:   "build command with tags",
+			config: dto.BuildConfig{BuildCommand: "make", Tags: []string{"netgo"}},
+			want:   []string{"build_command: cannot be combined with tags or ldflags, pass them in the command"},
+		},
+		{
+			name:   "env keys",
+			config: dto.BuildConfig{Env: map[string]string{"OK_KEY": "", "9BAD": "", "ALSO-BAD": ""}},
+			want: []string{
+				"env.9BAD: must start with a letter or underscore and contain only letters, digits and underscores",
+				"env.ALSO-BAD: must start with a letter or underscore and contain only letters, digits and underscores",
+			},
+		},
+		{
+			name:   "go version",
+			config: dto.BuildConfig{GoVersion: "go1.24"},
+			want:   []string{`go_version: "go1.24" is not a Go version such as 1.24 or 1.24.1`},
+		},
+		{
+			name:   "health check path",
+			config: dto.BuildConfig{HealthCheckPath: "healthz"},
+			want:   []string{"health_check_path: must start with /"},
+		},
+		{
+			name:   "ignore patterns",
+			config: dto.BuildConfig{Ignore: []string{"*.md", "/etc", "[", "docs/../.."}},
+			want: []string{
+				`ignore.1: "/etc" is not a relative path pattern`,
+				`ignore.2: "[" is not a relative path pattern`,
+				`ignore.3: "docs/../.." is not a relative path pattern`,
+			},
+		},
+		{
+			name:   "resource class",
+			config: dto.BuildConfig{ResourceClass: "huge"},
+			want:   []string{`resource_class: invalid value "huge", must be one of small standard large`},
+		},
+		{
+			name:   "tags",
+			config: dto.BuildConfig{Tags: []string{"netgo", "!cgo", "bad tag"}},
+			want:   []string{`tags.2: "bad tag" is not a valid build tag`},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := validateBuildConfig(&tt.config); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("validateBuildConfig() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestResourceClassOf(t *testing.T) {
+	tests := []struct {
+		name      string
+		project   constants.ResourceClass
+		requested constants.ResourceClass
+		want      constants.ResourceClass
+	}{
+		{name: "project class", project: constants.ResourceClassLarge, want: constants.ResourceClassLarge},
+		{name: "default class", want: constants.ResourceClassStandard},
+		{name: "repository lowers", project: constants.ResourceClassLarge, requested: constants.ResourceClassSmall, want: constants.ResourceClassSmall},
+		{name: "repository lowers the default", requested: constants.ResourceClassSmall, want: constants.ResourceClassSmall},
+		{name: "repository cannot raise", project: constants.ResourceClassSmall, requested: constants.ResourceClassLarge, want: constants.ResourceClassSmall},
+		{name: "repository cannot raise the default", requested: constants.ResourceClassLarge, want: constants.ResourceClassStandard},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			project := &dto.Project{ResourceClass: tt.project}
+			build := &dto.Build{Config: &dto.BuildConfig{ResourceClass: tt.requested}}
+			if got := resourceClassOf(project, build, constants.ResourceClassStandard); got != tt.want {
+				t.Errorf("resourceClassOf() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateSteps(t *testing.T) {
+	tests := []struct {
+		name  string
+		steps []dto.StepConfig
+		want  []string
+	}{
+		{
+			name: "valid",
+			steps: []dto.StepConfig{
+				{Name: "generate", Stage: constants.StepStagePreBuild, Run: "go generate ./..."},
+				{Name: "lint", Run: "golangci-lint run", Image: "golangci/golangci-lint:v2.1", Artifacts: []string{"report.xml"}},
+				{Name: "upload", Run: "./upload.sh", Needs: []string{"lint"}, Env: map[string]string{"BUCKET": "b"}, Timeout: 60},
+				{Name: "smoke", Stage: constants.StepStagePostDeploy, Run: "curl -f $DEPLOYMENT_URL"},
+			},
+		},
+		{
+			name:  "too many",
+			steps: make([]dto.StepConfig, maxSteps+1),
+			want:  []string{"steps: at most 20 steps are allowed"},
+		},
+		{
+			name: "names",
+			steps: []dto.StepConfig{
+				{Name: "Lint", Run: "x"},
+				{Name: "deploy", Run: "x"},
+				{Name: "lint", Run: "x"},
+				{Name: "lint", Run: "x"},
+			},
+			want: []string{
+				`steps.0.name: "Lint" must be up to 40 lowercase letters, digits, _ and -`,
+				`steps.1.name: "deploy" is the name of a platform step`,
+				`steps.3.name: "lint" is used by another step`,
+			},
+		},
+		{
+			name: "fields",
+			steps: []dto.StepConfig{
+				{Name: "a", Stage: "after_build", Image: "Not An Image", Run: " ", Env: map[string]string{"1X": ""}, Timeout: maxStepTimeout + 1, Artifacts: []string{"../out"}},
+			},
+			want: []string{
+				`steps.0.stage: invalid value "after_build", must be one of pre_build post_build post_deploy`,
+				`steps.0.image: "Not An Image" is not an image reference`,
+				"steps.0.run: is required",
+				"steps.0.env.1X: must start with a letter or underscore and contain only letters, digits and underscores",
+				"steps.0.timeout: 7201 is not between 1 and 7200 seconds",
+				`steps.0.artifacts.0: "../out" must be a path inside the root directory`,
+			},
+		},
+		{
+			name: "needs across stages",
+			steps: []dto.StepConfig{
+				{Name: "generate", Stage: constants.StepStagePreBuild, Run: "x"},
+				{Name: "lint", Run: "x", Needs: []string{"generate", "missing"}},
+			},
+			want: []string{
+				`steps.1.needs: "generate" is not a step of the post_build stage`,
+				`steps.1.needs: "missing" is not a step of the post_build stage`,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := validateSteps(tt.steps); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("validateSteps() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -1,43 +1,384 @@
 package services
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
+	"io/fs"
 	"os"
+	"path"
 	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
 	"time"
 
 	docker_client "github.com/RajVerma97/golang-vercel/backend/internal/client/docker"
+	"github.com/RajVerma97/golang-vercel/backend/internal/config"
 	"github.com/RajVerma97/golang-vercel/backend/internal/constants"
 	"github.com/RajVerma97/golang-vercel/backend/internal/dto"
 	"github.com/RajVerma97/golang-vercel/backend/internal/logger"
+	"github.com/RajVerma97/golang-vercel/backend/internal/redact"
 	"github.com/docker/docker/api/types/container"
 	"go.uber.org/zap"
 )
 
+// versionUnsafeChars are replaced in the branch part of the version
+var versionUnsafeChars = regexp.MustCompile(`[^A-Za-z0-9._/-]+`)
+
 type BuildServiceConfig struct {
-	DockerClient *docker_client.DockerClient
+	DockerClient    *docker_client.DockerClient
+	CacheService    *CacheService
+	NetworkService  *NetworkService
+	ResourcesConfig *config.ResourcesConfig
+	SandboxConfig   *config.SandboxConfig
+	TimeoutsConfig  *config.TimeoutsConfig
+	Toolchains      *ToolchainCatalog
+	// SeccompProfile is the compacted JSON of SandboxConfig.SeccompProfilePath, see LoadSeccompProfile
+	SeccompProfile string
 }
 type BuildService struct {
-	DockerClient *docker_client.DockerClient
+	DockerClient    *docker_client.DockerClient
+	CacheService    *CacheService
+	NetworkService  *NetworkService
+	Toolchains      *ToolchainCatalog
+	resourcesConfig *config.ResourcesConfig
+	sandboxConfig   *config.SandboxConfig
+	timeoutsConfig  *config.TimeoutsConfig
+	seccompProfile  string
 }
 
 func NewBuildService(config *BuildServiceConfig) *BuildService {
 	return &BuildService{
-		DockerClient: config.DockerClient,
+		DockerClient:    config.DockerClient,
+		CacheService:    config.CacheService,
+		NetworkService:  config.NetworkService,
+		Toolchains:      config.Toolchains,
+		resourcesConfig: config.ResourcesConfig,
+		sandboxConfig:   config.SandboxConfig,
+		timeoutsConfig:  config.TimeoutsConfig,
+		seccompProfile:  config.SeccompProfile,
+	}
+}
+
+// LoadSeccompProfile reads a seccomp profile, docker expects its JSON inline rather than a path
+func LoadSeccompProfile(path string) (string, error) {
+	if path == "" {
+		return "", nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read seccomp profile: %w", err)
+	}
+	var compacted bytes.Buffer
+	if err := json.Compact(&compacted, data); err != nil {
+		return "", fmt.Errorf("invalid seccomp profile %s: %w", path, err)
+	}
+	return compacted.String(), nil
+}
+
+// buildStep is one phase of the build, each runs in its own container against the shared workspace.
+// Phases with several steps name them.
+type buildStep struct {
+	phase constants.BuildPhase
+	name  string
+	cmd   string
+}
+
+func (s buildStep) String() string {
+	if s.name != "" {
+		return s.name
+	}
+	return s.phase.String()
+}
+
+// timedOut names the step when its phase has several
+func (s buildStep) timedOut(timeout time.Duration) error {
+	if s.name == "" {
+		return phaseTimedOut(s.phase, timeout)
+	}
+	return &FailureError{
+		Code: constants.FailureCodeTimeout,
+		Err:  fmt.Errorf("%s step timed out after %s", s.name, timeout),
+	}
+}
+
+// stepFailedError is a step that exited non-zero, its logs are part of the message
+type stepFailedError struct {
+	step     buildStep
+	exitCode int64
+	logs     string
+}
+
+func (e *stepFailedError) Error() string {
+	return fmt.Sprintf("%s step failed with exit code %d: %s", e.step, e.exitCode, e.logs)
+}
+
+// buildSteps run in the project's root directory, the repository's build config can replace the compile command.
+// CGO_ENABLED, GOOS, GOARCH and GOFLAGS are set in the environment so custom commands honour them too.
+// Modules are downloaded as go.sum pins them, a vendored module needs nothing downloaded. Listing the
+// dependencies downloads them under -mod=readonly, go mod download would add missing checksums to go.sum.
+func buildSteps(project *dto.Project, build *dto.Build, config dto.BuildConfig, vendor bool) []buildStep {
+	compile := config.BuildCommand
+	if compile == "" {
+		compile = goBuildCommand(project, build, config)
+	}
+	var steps []buildStep
+	if !vendor {
+		steps = append(steps, buildStep{phase: constants.BuildPhaseDependencies, cmd: "go list -deps -test ./... > /dev/null"})
+	}
+	return append(steps, buildStep{phase: constants.BuildPhaseCompile, cmd: compile})
+}
+
+// goBuildCommand applies the project's build options, the repository's tags and ldflags are added to them
+func goBuildCommand(project *dto.Project, build *dto.Build, config dto.BuildConfig) string {
+	options := project.Build
+	mainPackage := "."
+	if project.MainPackage != "" {
+		mainPackage = "./" + project.MainPackage
+	}
+
+	args := []string{"go", "build"}
+	if options.TrimPath {
+		args = append(args, "-trimpath")
+	}
+	args = append(args, "-o", shellQuote(binaryOutput(config)))
+	if tags := append(slices.Clone(options.Tags), config.Tags...); len(tags) > 0 {
+		args = append(args, "-tags", shellQuote(strings.Join(tags, ",")))
+	}
+	ldflags := []string{versionLdflags(options.VersionPackage, build)}
+	for _, flags := range []string{options.Ldflags, config.Ldflags} {
+		if flags != "" {
+			ldflags = append(ldflags, flags)
+		}
+	}
+	args = append(args, "-ldflags", shellQuote(strings.Join(ldflags, " ")))
+	return strings.Join(append(args, shellQuote(mainPackage)), " ")
+}
+
+// versionLdflags sets the Version, Commit and BuildTime variables of pkg.
+// The linker skips variables that pkg does not declare, so apps opt in by declaring them.
+func versionLdflags(pkg string, build *dto.Build) string {
+	if pkg == "" {
+		pkg = "main"
+	}
+	commit := ""
+	if build.CommitHash != nil {
+		commit = *build.CommitHash
 	}
+	return fmt.Sprintf("-X %[1]s.Version=%[2]s -X %[1]s.Commit=%[3]s -X %[1]s.BuildTime=%[4]s",
+		pkg, buildVersion(build), commit, time.Now().UTC().Format(time.RFC3339))
 }
 
-func (a *BuildService) BuildApplication(ctx context.Context, build *dto.Build, tempDirPath string) error {
+// buildVersion is branch-shortsha, falling back to what the build has of either
+func buildVersion(build *dto.Build) string {
+	var parts []string
+	if build.Branch != nil && *build.Branch != "" {
+		// branch names may hold characters the linker's flag parsing treats as separators
+		parts = append(parts, strings.Trim(versionUnsafeChars.ReplaceAllString(*build.Branch, "-"), "-"))
+	}
+	if build.CommitHash != nil && *build.CommitHash != "" {
+		parts = append(parts, (*build.CommitHash)[:min(7, len(*build.CommitHash))])
+	}
+	if len(parts) == 0 {
+		return "dev"
+	}
+	return strings.Join(parts, "-")
+}
+
+// platformEnv targets linux on the project's architecture
+func platformEnv(options dto.BuildOptions) []string {
+	cgo := "0"
+	if options.CGO {
+		cgo = "1"
+	}
+	return []string{"CGO_ENABLED=" + cgo, "GOOS=linux", "GOARCH=" + goarch(options)}
+}
+
+func goarch(options dto.BuildOptions) string {
+	if options.GOARCH == "" {
+		return "amd64"
+	}
+	return options.GOARCH
+}
+
+// binaryOutput is where the compile step writes the binary, relative to the root directory
+func binaryOutput(config dto.BuildConfig) string {
+	if config.Output != "" {
+		return config.Output
+	}
+	return defaultOutput
+}
+
+// PrepareBuild loads the repository's build config, picks the Go version and decides whether a
+// Dockerfile is built, which the pipeline's steps need before anything is compiled
+func (a *BuildService) PrepareBuild(project *dto.Project, build *dto.Build, tempDirPath string) error {
 	// mark the build as building
 	now := time.Now()
 	build.StartedAt = &now
 	build.Status = constants.BuildStatusBuilding
+	build.Logs = ""
+	rootDir := filepath.Join(tempDirPath, project.RootDirectory)
+	buildConfig, err := LoadBuildConfig(rootDir)
+	if err != nil {
+		return err
+	}
+	build.Config = buildConfig
+	if err := removeIgnored(rootDir, buildConfigOf(build).Ignore); err != nil {
+		return err
+	}
+	build.CGO, build.GOARCH = project.Build.CGO, goarch(project.Build)
+	if build.Dockerfile, err = dockerfileOf(project, rootDir); err != nil {
+		return err
+	}
+	if build.Dockerfile != "" {
+		if err := a.checkDockerfileBuild(project); err != nil {
+			return err
+		}
+	}
+	if _, err := a.builderImage(project, build, rootDir); err != nil {
+		if build.Dockerfile == "" {
+			return err
+		}
+		// the Dockerfile brings its own toolchain, only the test stage and steps need the builder image
+		logger.Warn("No builder image for the Dockerfile build", zap.Uint64("build_id", build.ID), zap.Error(err))
+	}
+	return nil
+}
+
+// BuildApplication compiles the prepared workspace, see PrepareBuild
+func (a *BuildService) BuildApplication(ctx context.Context, project *dto.Project, build *dto.Build, tempDirPath string, env *ResolvedEnv) error {
 	logger.Info("Starting Build Phase")
-	buildImageName := "golang:1.24-alpine"
-	workDir := "/app"
+	rootDir := filepath.Join(tempDirPath, project.RootDirectory)
+	config := buildConfigOf(build)
+
+	// modules and compiled packages are kept between builds of the project
+	cache, err := a.CacheService.Acquire(project, build)
+	if err != nil {
+		return err
+	}
+	defer cache.Unlock()
+	vendor := vendored(rootDir)
+	builder, err := a.newBuilder(ctx, project, build, tempDirPath, env, cache, project.Build, vendor)
+	if err != nil {
+		return err
+	}
+	defer builder.Close()
+
+	image, err := a.Toolchains.Image(build.GoVersion, build.CGO)
+	if err != nil {
+		return err
+	}
+	platform := builderPlatform(build)
+	if err := a.DockerClient.PullImage(ctx, image, platform); err != nil {
+		return fmt.Errorf("failed to pull build image %s: %w", image, err)
+	}
+	workDir := path.Join("/app", project.RootDirectory)
+	for _, step := range buildSteps(project, build, config, vendor) {
+		timeout := a.timeoutsConfig.For(step.phase, project.Timeouts)
+		logs, err := a.runStep(ctx, build, step, image, platform, workDir, builder, timeout, env)
+		build.Logs += logs
+		if err != nil {
+			return err
+		}
+	}
+
+	// Verify binary was created
+	binaryPath := filepath.Join(rootDir, filepath.FromSlash(binaryOutput(config)))
+	if _, err := os.Stat(binaryPath); os.IsNotExist(err) {
+		logger.Error("Binary was not created", nil, zap.String("path", binaryPath))
+		return fmt.Errorf("binary was not created at %s", binaryPath)
+	}
+	build.BinaryPath = &binaryPath
+
+	logger.Info("Build successful! Binary created", zap.String("path", binaryPath))
+	return nil
+}
+
+// builderPlatform is the platform the builder image runs on, the docker host's unless the build uses cgo,
+// which cannot cross compile without a cross toolchain
+func builderPlatform(build *dto.Build) string {
+	if build.CGO {
+		return "linux/" + build.GOARCH
+	}
+	return ""
+}
+
+// builder is what the containers of a build share besides their image
+type builder struct {
+	binds   []string
+	env     []string
+	network string
+	limits  config.ResourceLimits
+	sandbox *docker_client.Sandbox
+	auth    *ModuleAuth
+}
+
+// newBuilder sets up the environment, mounts and hardening of the build's containers, options decide
+// the platform env and a nil cache leaves the caches out. Close removes the module credentials.
+func (a *BuildService) newBuilder(ctx context.Context, project *dto.Project, build *dto.Build, tempDirPath string, env *ResolvedEnv, cache *ProjectCache, options dto.BuildOptions, vendor bool) (*builder, error) {
+	buildEnv := withEnvDefaults(env.BuildEnv(), buildConfigOf(build).Env)
+	sandbox, err := a.sandbox(project, tempDirPath)
+	if err != nil {
+		return nil, err
+	}
+	network, err := a.NetworkService.PrepareBuildNetwork(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if sandbox != nil {
+		// the root filesystem is read-only, so everything go writes outside the workspace and caches goes to /tmp
+		buildEnv = append(buildEnv, "HOME=/tmp", "GOPATH=/tmp/go")
+	}
 	volumeBinds := []string{fmt.Sprintf("%s:/app", tempDirPath)}
-	buildContainerName := fmt.Sprintf("build-worker-%d", build.ID)
+	if cache != nil {
+		if sandbox != nil {
+			if err := cache.ChownTo(a.sandboxConfig.UID, a.sandboxConfig.GID); err != nil {
+				return nil, err
+			}
+		}
+		buildEnv = append(buildEnv, cache.Env()...)
+		volumeBinds = append(volumeBinds, cache.Binds()...)
+	}
+
+	buildEnv = append(buildEnv, moduleEnv(project.Modules, vendor)...)
+	auth, err := moduleAuth(project.Modules, env)
+	if err != nil {
+		return nil, err
+	}
+	if sandbox != nil {
+		if err := auth.ChownTo(a.sandboxConfig.UID, a.sandboxConfig.GID); err != nil {
+			auth.Close()
+			return nil, err
+		}
+	}
+	buildEnv = append(buildEnv, auth.Env...)
+	volumeBinds = append(volumeBinds, auth.Binds...)
+
+	// the pinned builder image is the toolchain, go must not download another one
+	buildEnv = append(buildEnv, "GOTOOLCHAIN=local")
+	buildEnv = append(buildEnv, platformEnv(options)...)
+	return &builder{
+		binds:   volumeBinds,
+		env:     buildEnv,
+		network: network,
+		limits:  a.resourcesConfig.Limits(resourceClassOf(project, build, a.resourcesConfig.DefaultClass)),
+		sandbox: sandbox,
+		auth:    auth,
+	}, nil
+}
+
+func (b *builder) Close() error {
+	return b.auth.Close()
+}
+
+// runStep runs a build step to completion and returns its logs. A step that outlives
+// its timeout has its container killed and fails with a TIMEOUT reason.
+func (a *BuildService) runStep(ctx context.Context, build *dto.Build, step buildStep, image, platform, workDir string, builder *builder, timeout time.Duration, env *ResolvedEnv) (string, error) {
+	limits := builder.limits
+	buildContainerName := fmt.Sprintf("build-worker-%d-%s", build.ID, step)
+	logger.Info("Starting build step", zap.String("step", step.String()), zap.Duration("timeout", timeout))
 
 	//When a build container with same buildContainerName already exists
 	if a.DockerClient.DoesContainerExist(ctx, buildContainerName) {
@@ -45,22 +386,29 @@ func (a *BuildService) BuildApplication(ctx context.Context, build *dto.Build, t
 		// remove existing build container
 		if err := a.DockerClient.RemoveContainer(ctx, buildContainerName); err != nil {
 			logger.Error("failed to remove existing build container %s", err, zap.String("buildContainerName", buildContainerName))
-			return fmt.Errorf("failed to remove existing build container:%w", err)
+			return "", fmt.Errorf("failed to remove existing build container:%w", err)
 		}
 	}
 
 	// Create Build Container
-	buildContainerId, err := a.DockerClient.CreateBuildContainer(ctx, buildImageName, buildContainerName, workDir, volumeBinds)
+	cmd := "set -e\n" + step.cmd
+	buildContainerId, err := a.DockerClient.CreateBuildContainer(ctx, image, platform, buildContainerName, workDir, cmd, builder.binds, builder.env, builder.network, limits, builder.sandbox)
 	if err != nil {
 		logger.Error("failed to create build container", err)
-		return fmt.Errorf("failed to create build container:%w", err)
+		return "", fmt.Errorf("failed to create build container:%w", err)
 	}
+	defer func() {
+		// also kills the container when the step timed out
+		if err := a.DockerClient.RemoveContainer(ctx, buildContainerId); err != nil {
+			logger.Warn("Failed to remove build container", zap.Error(err))
+		}
+	}()
 
 	// Start Build Container
 	err = a.DockerClient.StartContainer(ctx, buildContainerId)
 	if err != nil {
 		logger.Error("failed to start build container", err)
-		return fmt.Errorf("failed to start build container:%w", err)
+		return "", fmt.Errorf("failed to start build container:%w", err)
 	}
 	if build.Container == nil {
 		build.Container = &dto.Container{}
@@ -68,47 +416,114 @@ func (a *BuildService) BuildApplication(ctx context.Context, build *dto.Build, t
 	build.Container.ID = buildContainerId
 	build.Container.Name = buildContainerName
 
-	// Wait for build to complete
-	statusCh, errCh := a.DockerClient.WaitContainer(ctx, buildContainerId, container.WaitConditionNotRunning)
+	// Wait for the step to complete
+	waitCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	statusCh, errCh := a.DockerClient.WaitContainer(waitCtx, buildContainerId, container.WaitConditionNotRunning)
 	select {
 	case err := <-errCh:
 		if err != nil {
+			if waitCtx.Err() == context.DeadlineExceeded {
+				logs, _ := containerLogs(ctx, a.DockerClient, buildContainerId, env.Redactor())
+				logger.Error("Build step timed out", nil, zap.String("step", step.String()), zap.Duration("timeout", timeout))
+				return logs, step.timedOut(timeout)
+			}
 			logger.Error("Error waiting for container", err)
-			return fmt.Errorf("error waiting for container: %w", err)
+			return "", fmt.Errorf("error waiting for container: %w", err)
 		}
 	case status := <-statusCh:
-		logger.Info("Build container finished", zap.Int64("exit_code", status.StatusCode))
+		logger.Info("Build container finished", zap.String("step", step.String()), zap.Int64("exit_code", status.StatusCode))
 
 		if status.StatusCode != 0 {
-			logs, _ := a.DockerClient.GetContainerLogs(ctx, buildContainerId)
+			logs, _ := containerLogs(ctx, a.DockerClient, buildContainerId, env.Redactor())
 			logger.Error("Build failed", nil, zap.String("logs", logs))
-			return fmt.Errorf("build failed with exit code %d: %s", status.StatusCode, logs)
+			if err := oomKilled(ctx, a.DockerClient, buildContainerId, limits); err != nil {
+				return logs, fmt.Errorf("%s step failed: %w", step, err)
+			}
+			return logs, &stepFailedError{step: step, exitCode: status.StatusCode, logs: logs}
 		}
 	}
 
 	// Get build logs
-	buildLogs, err := a.DockerClient.GetContainerLogs(ctx, buildContainerId)
+	buildLogs, err := containerLogs(ctx, a.DockerClient, buildContainerId, env.Redactor())
 	if err != nil {
 		logger.Error("Failed to get container logs", err)
 	} else {
 		logger.Info("Build Output", zap.String("logs", buildLogs))
 	}
-	build.Logs = buildLogs
-	// Verify binary was created
-	binaryPath := filepath.Join(tempDirPath, "bin", "app")
-	if _, err := os.Stat(binaryPath); os.IsNotExist(err) {
-		logger.Error("Binary was not created", nil, zap.String("path", binaryPath))
-		return fmt.Errorf("binary was not created at %s", binaryPath)
+	return buildLogs, nil
+}
+
+// builderImage picks the image for the project's Go version override, the build config's or the one
+// go.mod asks for, in that order, and records the version on the build
+func (a *BuildService) builderImage(project *dto.Project, build *dto.Build, rootDir string) (string, error) {
+	goVersion, source := project.GoVersion, "project settings"
+	if goVersion == "" {
+		goVersion, source = buildConfigOf(build).GoVersion, "build config"
+	}
+	if goVersion == "" {
+		modVersion, err := goModVersion(rootDir)
+		if err != nil {
+			return "", err
+		}
+		goVersion, source = modVersion, "go.mod"
+	}
+	if goVersion == "" {
+		goVersion, source = a.Toolchains.defaultVersion, "default"
 	}
-	build.BinaryPath = &binaryPath
 
-	logger.Info("Build successful! Binary created", zap.String("path", binaryPath))
-	// Clean up build container
-	err = a.DockerClient.RemoveContainer(ctx, buildContainerId)
+	image, err := a.Toolchains.Image(goVersion, build.CGO)
 	if err != nil {
-		logger.Warn("Failed to remove build container", zap.Error(err))
+		return "", fmt.Errorf("%s requires %w", source, err)
 	}
-	build.Status = constants.BuildStatusSuccess
-	build.CompletedAt = &now
-	return nil
+	build.GoVersion = goVersion
+	logger.Info("Selected builder image", zap.String("go_version", goVersion), zap.String("source", source), zap.String("image", image))
+	return image, nil
+}
+
+// sandbox returns the hardening for the project's build profile, or nil for standard builds.
+// Hardened builds run unprivileged, so the workspace is handed over to the sandbox user first.
+func (a *BuildService) sandbox(project *dto.Project, tempDirPath string) (*docker_client.Sandbox, error) {
+	if a.buildProfile(project) != constants.BuildProfileHardened {
+		return nil, nil
+	}
+	if err := chownTree(tempDirPath, a.sandboxConfig.UID, a.sandboxConfig.GID); err != nil {
+		return nil, fmt.Errorf("failed to hand workspace to sandbox user: %w", err)
+	}
+	return &docker_client.Sandbox{
+		User:           fmt.Sprintf("%d:%d", a.sandboxConfig.UID, a.sandboxConfig.GID),
+		SeccompProfile: a.seccompProfile,
+		TmpfsSize:      a.sandboxConfig.TmpfsSize,
+		UsernsMode:     a.sandboxConfig.UsernsMode,
+	}, nil
+}
+
+// buildProfile is the project's build profile, the platform's default when it has none
+func (a *BuildService) buildProfile(project *dto.Project) constants.BuildProfile {
+	if project.BuildProfile == "" {
+		return a.sandboxConfig.DefaultProfile
+	}
+	return project.BuildProfile
+}
+
+func chownTree(root string, uid, gid int) error {
+	return filepath.WalkDir(root, func(path string, _ fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		return os.Lchown(path, uid, gid)
+	})
+}
+
+// containerLogs captures the container's output with secrets redacted before it is stored or logged
+func containerLogs(ctx context.Context, dockerClient *docker_client.DockerClient, containerID string, redactor *redact.Redactor) (string, error) {
+	buf := new(strings.Builder)
+	w := redactor.Writer(buf)
+	if err := dockerClient.CopyContainerLogs(ctx, containerID, w); err != nil {
+		return "", err
+	}
+	if err := w.Close(); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
 }
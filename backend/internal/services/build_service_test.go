@@ -0,0 +1,247 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/RajVerma97/golang-vercel/backend/internal/config"
+	"github.com/RajVerma97/golang-vercel/backend/internal/constants"
+	"github.com/RajVerma97/golang-vercel/backend/internal/dto"
+)
+
+func TestBuildServiceSandbox(t *testing.T) {
+	// only root can hand files to another user, everyone can hand them to themselves
+	uid, gid := os.Getuid(), os.Getgid()
+	if uid == 0 {
+		uid, gid = 10001, 10001
+	}
+	tests := []struct {
+		name           string
+		defaultProfile constants.BuildProfile
+		project        dto.Project
+		wantSandbox    bool
+	}{
+		{name: "standard by default", defaultProfile: constants.BuildProfileStandard},
+		{name: "hardened by default", defaultProfile: constants.BuildProfileHardened, wantSandbox: true},
+		{name: "project opts in", defaultProfile: constants.BuildProfileStandard, project: dto.Project{BuildProfile: constants.BuildProfileHardened}, wantSandbox: true},
+		{name: "project opts out", defaultProfile: constants.BuildProfileHardened, project: dto.Project{BuildProfile: constants.BuildProfileStandard}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewBuildService(&BuildServiceConfig{
+				SandboxConfig:  &config.SandboxConfig{DefaultProfile: tt.defaultProfile, UID: uid, GID: gid, TmpfsSize: 1 << 28, UsernsMode: "host"},
+				SeccompProfile: `{"defaultAction":"SCMP_ACT_ERRNO"}`,
+			})
+			workspace := t.TempDir()
+			writeFile(t, filepath.Join(workspace, "src", "main.go"), "package main\n")
+
+			sandbox, err := s.sandbox(&tt.project, workspace)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if (sandbox != nil) != tt.wantSandbox {
+				t.Fatalf("sandbox() = %+v, want a sandbox %v", sandbox, tt.wantSandbox)
+			}
+			if sandbox == nil {
+				return
+			}
+			if want := fmt.Sprintf("%d:%d", uid, gid); sandbox.User != want {
+				t.Errorf("user = %q, want %q", sandbox.User, want)
+			}
+			if sandbox.SeccompProfile == "" || sandbox.TmpfsSize != 1<<28 || sandbox.UsernsMode != "host" {
+				t.Errorf("sandbox = %+v, want the configured profile", sandbox)
+			}
+			info, err := os.Stat(filepath.Join(workspace, "src", "main.go"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if stat := info.Sys().(*syscall.Stat_t); int(stat.Uid) != uid || int(stat.Gid) != gid {
+				t.Errorf("workspace file owned by %d:%d, want the sandbox user %d:%d", stat.Uid, stat.Gid, uid, gid)
+			}
+		})
+	}
+}
+
+func TestLoadSeccompProfile(t *testing.T) {
+	dir := t.TempDir()
+	valid := filepath.Join(dir, "seccomp.json")
+	writeFile(t, valid, "{\n  \"defaultAction\": \"SCMP_ACT_ERRNO\",\n  \"syscalls\": []\n}\n")
+	invalid := filepath.Join(dir, "broken.json")
+	writeFile(t, invalid, "{\"defaultAction\":")
+
+	tests := []struct {
+		name    string
+		path    string
+		want    string
+		wantErr bool
+	}{
+		{name: "docker's default", path: ""},
+		{name: "compacted", path: valid, want: `{"defaultAction":"SCMP_ACT_ERRNO","syscalls":[]}`},
+		{name: "invalid json", path: invalid, wantErr: true},
+		{name: "missing", path: filepath.Join(dir, "missing.json"), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := LoadSeccompProfile(tt.path)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("LoadSeccompProfile() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("LoadSeccompProfile() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBuildServiceStepTimeout(t *testing.T) {
+	var removed atomic.Bool
+	client := newTestDockerClient(t, func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/containers/create"):
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprint(w, `{"Id":"step"}`)
+		case strings.HasSuffix(r.URL.Path, "/containers/step/start"):
+			w.WriteHeader(http.StatusNoContent)
+		case strings.HasSuffix(r.URL.Path, "/containers/step/wait"):
+			// the step hangs until the client gives up on it
+			<-r.Context().Done()
+		case strings.HasSuffix(r.URL.Path, "/containers/step/logs"):
+			w.WriteHeader(http.StatusOK)
+		case r.Method == http.MethodDelete && strings.HasSuffix(r.URL.Path, "/containers/step"):
+			if r.URL.Query().Get("force") == "1" {
+				removed.Store(true)
+			}
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			http.Error(w, `{"message":"no such container"}`, http.StatusNotFound)
+		}
+	})
+	s := NewBuildService(&BuildServiceConfig{DockerClient: client})
+	step := buildStep{phase: constants.BuildPhaseCompile, cmd: "go build ."}
+
+	started := time.Now()
+	_, err := s.runStep(context.Background(), &dto.Build{ID: 1}, step, "golang:1.24-alpine", "", "/app", &builder{}, 200*time.Millisecond, nil)
+	if FailureCodeOf(err) != constants.FailureCodeTimeout {
+		t.Fatalf("runStep() = %v, want a TIMEOUT failure", err)
+	}
+	if !strings.Contains(err.Error(), "compile phase timed out after 200ms") {
+		t.Errorf("runStep() = %q, want it to name the phase that hung", err)
+	}
+	if elapsed := time.Since(started); elapsed > 5*time.Second {
+		t.Errorf("runStep() took %s, want it to give up at the deadline", elapsed)
+	}
+	if !removed.Load() {
+		t.Error("the hung container was not force removed")
+	}
+}
+
+func TestGoBuildCommand(t *testing.T) {
+	if _, err := exec.LookPath("go"); err != nil || runtime.GOOS != "linux" || runtime.GOARCH != "amd64" {
+		t.Skip("needs go on linux/amd64 to run what it builds")
+	}
+	branch, commit := "feature/my branch", "abcdef1234567"
+	build := &dto.Build{Branch: &branch, CommitHash: &commit}
+	tests := []struct {
+		name    string
+		options dto.BuildOptions
+		config  dto.BuildConfig
+		want    string
+	}{
+		{
+			name: "defaults",
+			want: "feature/my-branch-abcdef1 abcdef1234567 basic",
+		},
+		{
+			name:    "tags from the project and the repository",
+			options: dto.BuildOptions{Tags: []string{"premium"}, TrimPath: true, Ldflags: "-s -w"},
+			config:  dto.BuildConfig{Tags: []string{"extra"}, Output: "out/server"},
+			want:    "feature/my-branch-abcdef1 abcdef1234567 premium",
+		},
+		{
+			name:    "version package",
+			options: dto.BuildOptions{VersionPackage: "example.com/app/version"},
+			want:    "feature/my-branch-abcdef1 abcdef1234567 basic",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeFile(t, filepath.Join(dir, "go.mod"), "module example.com/app\n\ngo 1.21\n")
+			// the version variables are declared in main, or in the version package
+			pkg, declarations := "", "var Version, Commit, BuildTime string\n"
+			if tt.options.VersionPackage != "" {
+				writeFile(t, filepath.Join(dir, "version", "version.go"), "package version\n\n"+declarations)
+				pkg, declarations = "version.", `import "example.com/app/version"`+"\n"
+			}
+			writeFile(t, filepath.Join(dir, "main.go"), "package main\n\nimport \"fmt\"\n\n"+declarations+
+				"\nfunc main() { fmt.Println("+pkg+"Version, "+pkg+"Commit, "+pkg+"BuildTime, tagged) }\n")
+			writeFile(t, filepath.Join(dir, "premium.go"), "//go:build premium && extra\n\npackage main\n\nconst tagged = \"premium\"\n")
+			writeFile(t, filepath.Join(dir, "basic.go"), "//go:build !(premium && extra)\n\npackage main\n\nconst tagged = \"basic\"\n")
+
+			cmd := exec.Command("sh", "-c", goBuildCommand(&dto.Project{Build: tt.options}, build, tt.config))
+			cmd.Dir = dir
+			cmd.Env = append(os.Environ(), platformEnv(tt.options)...)
+			if out, err := cmd.CombinedOutput(); err != nil {
+				t.Fatalf("%s: %v\n%s", cmd.Args[2], err, out)
+			}
+			binary := filepath.Join(dir, filepath.FromSlash(binaryOutput(tt.config)))
+			out, err := exec.Command(binary).Output()
+			if err != nil {
+				t.Fatal(err)
+			}
+			fields := strings.Fields(string(out))
+			if len(fields) != 4 {
+				t.Fatalf("binary printed %q, want version, commit, build time and tag", out)
+			}
+			if got := strings.Join([]string{fields[0], fields[1], fields[3]}, " "); got != tt.want {
+				t.Errorf("binary printed %q, want %q", got, tt.want)
+			}
+			if _, err := time.Parse(time.RFC3339, fields[2]); err != nil {
+				t.Errorf("build time %q is not RFC 3339", fields[2])
+			}
+			content, err := os.ReadFile(binary)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if trimmed := !strings.Contains(string(content), dir); trimmed != tt.options.TrimPath {
+				t.Errorf("workspace path trimmed = %v, want %v", trimmed, tt.options.TrimPath)
+			}
+		})
+	}
+}
+
+func TestPlatformEnv(t *testing.T) {
+	tests := []struct {
+		name         string
+		options      dto.BuildOptions
+		want         []string
+		wantPlatform string
+	}{
+		{name: "static amd64 by default", want: []string{"CGO_ENABLED=0", "GOOS=linux", "GOARCH=amd64"}},
+		// static builds cross compile on the docker host's own architecture
+		{name: "static arm64", options: dto.BuildOptions{GOARCH: "arm64"}, want: []string{"CGO_ENABLED=0", "GOOS=linux", "GOARCH=arm64"}},
+		{name: "cgo arm64", options: dto.BuildOptions{CGO: true, GOARCH: "arm64"}, want: []string{"CGO_ENABLED=1", "GOOS=linux", "GOARCH=arm64"}, wantPlatform: "linux/arm64"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := platformEnv(tt.options); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("platformEnv() = %q, want %q", got, tt.want)
+			}
+			build := &dto.Build{CGO: tt.options.CGO, GOARCH: goarch(tt.options)}
+			if got := builderPlatform(build); got != tt.wantPlatform {
+				t.Errorf("builderPlatform() = %q, want %q", got, tt.wantPlatform)
+			}
+		})
+	}
+}
@@ -0,0 +1,215 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	redis_client "github.com/RajVerma97/golang-vercel/backend/internal/client/redis"
+	"github.com/RajVerma97/golang-vercel/backend/internal/config"
+	"github.com/RajVerma97/golang-vercel/backend/internal/dto"
+	"github.com/RajVerma97/golang-vercel/backend/internal/logger"
+	"go.uber.org/zap"
+)
+
+const (
+	// cacheMount is where the project's cache directory is mounted in build containers
+	cacheMount    = "/cache"
+	modulesCache  = "gomod"
+	buildCache    = "gobuild"
+	cachePurgedAt = "purged_at"
+)
+
+var ErrCacheInUse = errors.New("the cache is in use by a running build")
+
+// projectCacheStatsKey holds when the project's caches were last purged
+func projectCacheStatsKey(projectID uint64) string {
+	return fmt.Sprintf("project:%d:cache_stats", projectID)
+}
+
+type CacheServiceConfig struct {
+	RedisClient   *redis_client.RedisClient
+	StorageConfig *config.StorageConfig
+}
+
+// CacheService keeps a GOMODCACHE and a GOCACHE per project on the host. The go command locks
+// both itself, so concurrent builds of a project share them safely. Builds hold a shared flock
+// on the project's caches so a purge never pulls them out from under a running build.
+// Pull request builds run code nobody has reviewed yet and every pull request gets caches of its own,
+// so it cannot plant modules or build results that builds of the branches or of other pull requests,
+// forks' among them, would pick up.
+type CacheService struct {
+	RedisClient   *redis_client.RedisClient
+	storageConfig *config.StorageConfig
+}
+
+func NewCacheService(config *CacheServiceConfig) *CacheService {
+	return &CacheService{
+		RedisClient:   config.RedisClient,
+		storageConfig: config.StorageConfig,
+	}
+}
+
+// cacheDir is the caches of the project's branches, or of one of its pull requests
+func (s *CacheService) cacheDir(projectID uint64, pullRequest *int) string {
+	name := fmt.Sprintf("project-%d", projectID)
+	if pullRequest != nil {
+		name += fmt.Sprintf("-pr-%d", *pullRequest)
+	}
+	return filepath.Join(s.storageConfig.CachesDir(), name)
+}
+
+// cacheDirs are the project's caches, of branch and of every pull request's builds
+func (s *CacheService) cacheDirs(projectID uint64) []string {
+	dirs := []string{s.cacheDir(projectID, nil)}
+	matches, _ := filepath.Glob(filepath.Join(s.storageConfig.CachesDir(), fmt.Sprintf("project-%d-pr-*", projectID)))
+	for _, match := range matches {
+		if !strings.HasSuffix(match, ".lock") {
+			dirs = append(dirs, match)
+		}
+	}
+	return dirs
+}
+
+// ProjectCache is a build's hold on its project's caches, see CacheService.Acquire
+type ProjectCache struct {
+	dir  string
+	lock *os.File
+}
+
+// Acquire locks the caches the build may use for the duration of the build, creating them when needed
+func (s *CacheService) Acquire(project *dto.Project, build *dto.Build) (*ProjectCache, error) {
+	dir := s.cacheDir(project.ID, build.PullRequest)
+	if err := os.MkdirAll(filepath.Dir(dir), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create caches dir: %w", err)
+	}
+	var lock *os.File
+	for lock == nil {
+		var err error
+		if lock, err = lockFile(dir+".lock", syscall.LOCK_SH); err != nil {
+			return nil, err
+		}
+	}
+	// created once locked, so a purge that was under way cannot remove them
+	for _, cache := range []string{modulesCache, buildCache} {
+		if err := os.MkdirAll(filepath.Join(dir, cache), 0755); err != nil {
+			lock.Close()
+			return nil, fmt.Errorf("failed to create %s cache: %w", cache, err)
+		}
+	}
+	return &ProjectCache{dir: dir, lock: lock}, nil
+}
+
+// Binds mounts the caches into a build container
+func (c *ProjectCache) Binds() []string {
+	return []string{c.dir + ":" + cacheMount}
+}
+
+func (c *ProjectCache) Env() []string {
+	return []string{
+		"GOMODCACHE=" + cacheMount + "/" + modulesCache,
+		"GOCACHE=" + cacheMount + "/" + buildCache,
+	}
+}
+
+// ChownTo hands the caches to the sandbox user. Standard builds run as root and may have added
+// files since the last hardened build, so the whole tree is walked.
+func (c *ProjectCache) ChownTo(uid, gid int) error {
+	if err := chownTree(c.dir, uid, gid); err != nil {
+		return fmt.Errorf("failed to hand cache to sandbox user: %w", err)
+	}
+	return nil
+}
+
+// Unlock gives up the hold on the caches once the build is done with them
+func (c *ProjectCache) Unlock() {
+	syscall.Flock(int(c.lock.Fd()), syscall.LOCK_UN)
+	c.lock.Close()
+}
+
+// Stats returns the size of the project's caches and when they were last purged.
+// The caches are measured on request, builds never walk them.
+func (s *CacheService) Stats(ctx context.Context, project *dto.Project) (*dto.CacheStats, error) {
+	counters, err := s.RedisClient.GetHash(ctx, projectCacheStatsKey(project.ID))
+	if err != nil {
+		return nil, err
+	}
+
+	stats := &dto.CacheStats{}
+	for _, dir := range s.cacheDirs(project.ID) {
+		stats.Modules.Bytes += dirSize(filepath.Join(dir, modulesCache))
+		stats.Build.Bytes += dirSize(filepath.Join(dir, buildCache))
+	}
+	if purgedAt, err := time.Parse(time.RFC3339, counters[cachePurgedAt]); err == nil {
+		stats.PurgedAt = &purgedAt
+	}
+	return stats, nil
+}
+
+// Purge deletes the project's caches and records when. It fails with ErrCacheInUse
+// rather than waiting for running builds.
+func (s *CacheService) Purge(ctx context.Context, project *dto.Project) error {
+	for _, dir := range s.cacheDirs(project.ID) {
+		if err := purgeCache(dir); err != nil {
+			return err
+		}
+	}
+
+	key := projectCacheStatsKey(project.ID)
+	if err := s.RedisClient.Delete(ctx, key); err != nil {
+		return err
+	}
+	if err := s.RedisClient.SetHashField(ctx, key, cachePurgedAt, time.Now().UTC().Format(time.RFC3339)); err != nil {
+		return err
+	}
+	logger.Info("Purged build cache", zap.Uint64("project_id", project.ID))
+	return nil
+}
+
+// PurgePullRequest deletes the caches of a pull request once it is closed
+func (s *CacheService) PurgePullRequest(project *dto.Project, pullRequest int) error {
+	if err := purgeCache(s.cacheDir(project.ID, &pullRequest)); err != nil {
+		return err
+	}
+	logger.Info("Purged pull request build cache", zap.Uint64("project_id", project.ID), zap.Int("pull_request", pullRequest))
+	return nil
+}
+
+// purgeCache removes the cache in dir unless a build holds it
+func purgeCache(dir string) error {
+	if err := os.MkdirAll(filepath.Dir(dir), 0755); err != nil {
+		return fmt.Errorf("failed to create caches dir: %w", err)
+	}
+	lock, err := lockFile(dir+".lock", syscall.LOCK_EX|syscall.LOCK_NB)
+	if errors.Is(err, syscall.EWOULDBLOCK) {
+		return ErrCacheInUse
+	}
+	if err != nil {
+		return err
+	}
+	if lock == nil {
+		// purged by someone else in the meantime
+		return nil
+	}
+	defer lock.Close()
+
+	// the module cache is read-only by design
+	filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err == nil && entry.IsDir() {
+			os.Chmod(path, 0755)
+		}
+		return nil
+	})
+	if err := os.RemoveAll(dir); err != nil {
+		return fmt.Errorf("failed to remove cache: %w", err)
+	}
+	// builds waiting on the lock notice it is gone and lock a new one
+	os.Remove(dir + ".lock")
+	return nil
+}
@@ -0,0 +1,169 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/RajVerma97/golang-vercel/backend/internal/config"
+	"github.com/RajVerma97/golang-vercel/backend/internal/dto"
+)
+
+func TestCacheServiceAcquire(t *testing.T) {
+	s := NewCacheService(&CacheServiceConfig{StorageConfig: &config.StorageConfig{DataDir: t.TempDir()}})
+	project := &dto.Project{ID: 7}
+
+	acquire := func(build *dto.Build) string {
+		t.Helper()
+		cache, err := s.Acquire(project, build)
+		if err != nil {
+			t.Fatalf("Acquire() error = %v", err)
+		}
+		defer cache.Unlock()
+		for _, dir := range []string{modulesCache, buildCache} {
+			if _, err := os.Stat(filepath.Join(cache.dir, dir)); err != nil {
+				t.Errorf("%s cache was not created: %v", dir, err)
+			}
+		}
+		return cache.dir
+	}
+	three, four := 3, 4
+	branch := acquire(&dto.Build{ProjectID: 7})
+	otherBranch := acquire(&dto.Build{ProjectID: 7})
+	pullRequest := acquire(&dto.Build{ProjectID: 7, PullRequest: &three})
+	samePullRequest := acquire(&dto.Build{ProjectID: 7, PullRequest: &three})
+	otherPullRequest := acquire(&dto.Build{ProjectID: 7, PullRequest: &four})
+
+	if branch != otherBranch || pullRequest != samePullRequest {
+		t.Error("builds of the same branches or pull request do not share their caches")
+	}
+	if branch == pullRequest {
+		t.Errorf("pull request builds share the branch cache %s", branch)
+	}
+	// a pull request, from a fork or not, cannot poison the caches of another
+	if pullRequest == otherPullRequest {
+		t.Errorf("pull requests 3 and 4 share the cache %s", pullRequest)
+	}
+}
+
+func TestCacheServicePurge(t *testing.T) {
+	s := NewCacheService(&CacheServiceConfig{RedisClient: newTestRedis(t), StorageConfig: &config.StorageConfig{DataDir: t.TempDir()}})
+	ctx := context.Background()
+	project, other := &dto.Project{ID: 7}, &dto.Project{ID: 70}
+	three := 3
+	var dirs []string
+	for _, build := range []*dto.Build{{ProjectID: 7}, {ProjectID: 7, PullRequest: &three}, {ProjectID: 70}} {
+		cache, err := s.Acquire(&dto.Project{ID: build.ProjectID}, build)
+		if err != nil {
+			t.Fatal(err)
+		}
+		writeFile(t, filepath.Join(cache.dir, modulesCache, "example.com", "mod.go"), "package mod\n")
+		cache.Unlock()
+		dirs = append(dirs, cache.dir)
+	}
+
+	stats, err := s.Stats(ctx, project)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := int64(2 * len("package mod\n")); stats.Modules.Bytes != want || stats.PurgedAt != nil {
+		t.Errorf("Stats() = %+v, want %d module bytes of the branch and pull request caches", stats, want)
+	}
+
+	// a running build keeps the caches
+	cache, err := s.Acquire(project, &dto.Build{ProjectID: 7})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Purge(ctx, project); !errors.Is(err, ErrCacheInUse) {
+		t.Errorf("Purge() during a build = %v, want %v", err, ErrCacheInUse)
+	}
+	cache.Unlock()
+
+	if err := s.Purge(ctx, project); err != nil {
+		t.Fatalf("Purge() error = %v", err)
+	}
+	for i, dir := range dirs {
+		_, err := os.Stat(dir)
+		_, lockErr := os.Stat(dir + ".lock")
+		if wantPurged := i < 2; os.IsNotExist(err) != wantPurged || os.IsNotExist(lockErr) != wantPurged {
+			t.Errorf("%s purged = %v, want %v", filepath.Base(dir), os.IsNotExist(err), wantPurged)
+		}
+	}
+	if stats, err := s.Stats(ctx, project); err != nil || stats.Modules.Bytes != 0 || stats.PurgedAt == nil {
+		t.Errorf("Stats() after the purge = %+v, %v, want empty caches and the purge time", stats, err)
+	}
+	if stats, err := s.Stats(ctx, other); err != nil || stats.Modules.Bytes == 0 {
+		t.Errorf("Stats() of another project = %+v, %v, want its cache kept", stats, err)
+	}
+}
+
+func TestCacheServicePurgePullRequest(t *testing.T) {
+	s := NewCacheService(&CacheServiceConfig{StorageConfig: &config.StorageConfig{DataDir: t.TempDir()}})
+	project := &dto.Project{ID: 7}
+	three, four := 3, 4
+	closed, err := s.Acquire(project, &dto.Build{ProjectID: 7, PullRequest: &three})
+	if err != nil {
+		t.Fatal(err)
+	}
+	closed.Unlock()
+	open, err := s.Acquire(project, &dto.Build{ProjectID: 7, PullRequest: &four})
+	if err != nil {
+		t.Fatal(err)
+	}
+	open.Unlock()
+
+	if err := s.PurgePullRequest(project, three); err != nil {
+		t.Fatalf("PurgePullRequest() error = %v", err)
+	}
+	if _, err := os.Stat(closed.dir); !os.IsNotExist(err) {
+		t.Error("the closed pull request's cache was kept")
+	}
+	if _, err := os.Stat(open.dir); err != nil {
+		t.Errorf("another pull request's cache was removed: %v", err)
+	}
+}
+
+func TestCacheServiceAcquireAfterPurge(t *testing.T) {
+	s := NewCacheService(&CacheServiceConfig{StorageConfig: &config.StorageConfig{DataDir: t.TempDir()}})
+	project := &dto.Project{ID: 7}
+	dir := s.cacheDir(project.ID, nil)
+	if err := os.MkdirAll(filepath.Dir(dir), 0755); err != nil {
+		t.Fatal(err)
+	}
+	// a purge holds the lock while a build waits for it
+	purging, err := lockFile(dir+".lock", syscall.LOCK_EX)
+	if err != nil || purging == nil {
+		t.Fatalf("lockFile() = %v, %v", purging, err)
+	}
+	acquired := make(chan *ProjectCache)
+	go func() {
+		cache, err := s.Acquire(project, &dto.Build{ProjectID: 7})
+		if err != nil {
+			t.Error(err)
+		}
+		acquired <- cache
+	}()
+	time.Sleep(100 * time.Millisecond)
+	if err := os.Remove(dir + ".lock"); err != nil {
+		t.Fatal(err)
+	}
+	purging.Close()
+
+	cache := <-acquired
+	if cache == nil {
+		return
+	}
+	defer cache.Unlock()
+	if _, err := os.Stat(filepath.Join(cache.dir, modulesCache)); err != nil {
+		t.Errorf("the build's caches are missing after the purge: %v", err)
+	}
+	// a later purge must see the build's hold on the caches
+	if err := purgeCache(dir); !errors.Is(err, ErrCacheInUse) {
+		t.Errorf("purgeCache() = %v, want %v", err, ErrCacheInUse)
+	}
+}
@@ -3,39 +3,75 @@ package services
 import (
 	"context"
 	"fmt"
+	"net/http"
+	"path/filepath"
 	"time"
 
 	docker_client "github.com/RajVerma97/golang-vercel/backend/internal/client/docker"
+	"github.com/RajVerma97/golang-vercel/backend/internal/config"
 	"github.com/RajVerma97/golang-vercel/backend/internal/constants"
 	"github.com/RajVerma97/golang-vercel/backend/internal/dto"
 	"github.com/RajVerma97/golang-vercel/backend/internal/logger"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/go-connections/nat"
 	"go.uber.org/zap"
 )
 
 type DeployServiceConfig struct {
-	DockerClient *docker_client.DockerClient
+	DockerClient    *docker_client.DockerClient
+	NetworkService  *NetworkService
+	DeployConfig    *config.DeployConfig
+	ResourcesConfig *config.ResourcesConfig
+	TimeoutsConfig  *config.TimeoutsConfig
 }
 type DeployService struct {
-	DockerClient *docker_client.DockerClient
+	DockerClient    *docker_client.DockerClient
+	NetworkService  *NetworkService
+	deployConfig    *config.DeployConfig
+	resourcesConfig *config.ResourcesConfig
+	timeoutsConfig  *config.TimeoutsConfig
+	httpClient      *http.Client
 }
 
 func NewDeployService(config *DeployServiceConfig) *DeployService {
 	return &DeployService{
-		DockerClient: config.DockerClient,
+		DockerClient:    config.DockerClient,
+		NetworkService:  config.NetworkService,
+		deployConfig:    config.DeployConfig,
+		resourcesConfig: config.ResourcesConfig,
+		timeoutsConfig:  config.TimeoutsConfig,
+		httpClient:      &http.Client{Timeout: 5 * time.Second},
 	}
 }
 
-func (a *DeployService) DeployApplication(ctx context.Context, build *dto.Build, deployment *dto.Deployment, tempDirPath string) error {
+func (a *DeployService) DeployApplication(ctx context.Context, project *dto.Project, build *dto.Build, deployment *dto.Deployment, env *ResolvedEnv) error {
 	logger.Info("Starting Deployment Phase")
-
-	// Pull alpine for runtime
-	deployImageName := "alpine:latest"
-	err := a.DockerClient.PullImage(ctx, deployImageName)
-	if err != nil {
-		return fmt.Errorf("failed to pull deploy image: %w", err)
+	platform := ""
+	if build.GOARCH != "" {
+		platform = "linux/" + build.GOARCH
+	}
+	// Dockerfile builds run their own image, binaries are mounted into the runtime image
+	var deployImageName string
+	var cmd, deployVolumeBinds []string
+	switch {
+	case build.Image != "":
+		deployImageName = build.Image
+	case build.BinaryPath != nil:
+		// Pull the runtime image, cgo binaries need glibc
+		deployImageName = a.deployConfig.RuntimeImage
+		if build.CGO {
+			deployImageName = a.deployConfig.GlibcRuntimeImage
+		}
+		if err := a.DockerClient.PullImage(ctx, deployImageName, platform); err != nil {
+			return fmt.Errorf("failed to pull deploy image: %w", err)
+		}
+		cmd = []string{"/app/app"}
+		deployVolumeBinds = []string{fmt.Sprintf("%s:/app", filepath.Dir(*build.BinaryPath))}
+	default:
+		return fmt.Errorf("build %d has no binary or image to deploy", build.ID)
 	}
 
-	deployContainerName := fmt.Sprintf("deployment-%d", build.ID)
+	deployContainerName := fmt.Sprintf("deployment-%d", deployment.ID)
 
 	// Check and remove existing deployment container (ADD THIS)
 	if a.DockerClient.DoesContainerExist(ctx, deployContainerName) {
@@ -46,15 +82,24 @@ func (a *DeployService) DeployApplication(ctx context.Context, build *dto.Build,
 			return err
 		}
 	}
-	deployVolumeBinds := []string{fmt.Sprintf("%s/bin:/app", tempDirPath)}
+	limits := a.resourcesConfig.Limits(resourceClassOf(project, build, a.resourcesConfig.DefaultClass))
+	network, err := a.NetworkService.Prepare(ctx, project)
+	if err != nil {
+		return err
+	}
 
 	deployContainerID, err := a.DockerClient.CreateDeploymentContainer(
 		ctx,
 		deployImageName,
+		platform,
 		deployContainerName,
+		cmd,
 		deployVolumeBinds,
-		"8080", // Your app's port
-		int(build.ID),
+		appPort(build),
+		int(deployment.ID),
+		append(withEnvDefaults(env.RuntimeEnv(), buildConfigOf(build).Env), network.Env...),
+		limits,
+		network.Attachment,
 	)
 	if err != nil {
 		logger.Error("failed to create deployment container", err)
@@ -75,7 +120,7 @@ func (a *DeployService) DeployApplication(ctx context.Context, build *dto.Build,
 	// After starting the deployment container, get its logs
 	time.Sleep(2 * time.Second) // Give it a moment to start
 
-	deployLogs, err := a.DockerClient.GetContainerLogs(ctx, deployContainerID)
+	deployLogs, err := containerLogs(ctx, a.DockerClient, deployContainerID, env.Redactor())
 	if err != nil {
 		logger.Error("Failed to get deployment logs", err)
 	} else {
@@ -104,27 +149,136 @@ func (a *DeployService) DeployApplication(ctx context.Context, build *dto.Build,
 			zap.String("error", inspect.State.Error))
 
 		// Get logs to see why it exited
-		logs, _ := a.DockerClient.GetContainerLogs(ctx, deployContainerID)
+		logs, _ := containerLogs(ctx, a.DockerClient, deployContainerID, env.Redactor())
 		logger.Error("Container logs", nil, zap.String("logs", logs))
+		if err := oomKilled(ctx, a.DockerClient, deployContainerID, limits); err != nil {
+			return fmt.Errorf("deployment container exited unexpectedly: %w", err)
+		}
 		return fmt.Errorf("deployment container exited unexpectedly (exit code: %d): %s",
 			inspect.State.ExitCode, inspect.State.Error)
 	}
 
-	// Check if port bindings exist
-	portBindings, exists := inspect.NetworkSettings.Ports["8080/tcp"]
-	if !exists || len(portBindings) == 0 {
-		logger.Error("No port bindings found for container", nil)
-		return fmt.Errorf("no port bindings found for container")
+	deploymentURL, err := containerURL(inspect, appPort(build))
+	if err != nil {
+		logger.Error("No address found for container", err)
+		return err
 	}
+	deployment.URL = deploymentURL
 
-	hostPort := portBindings[0].HostPort
-	deploymentURL := fmt.Sprintf("http://localhost:%s", hostPort)
+	// Never hand out a deployment that does not answer requests
+	if err := a.WaitForReady(ctx, deployment, a.healthCheckPath(build), a.timeoutsConfig.For(constants.BuildPhaseReadiness, project.Timeouts)); err != nil {
+		logger.Error("Deployment failed readiness check", err, zap.String("url", deploymentURL))
+		// the app may have been OOM killed and restarted while we were waiting for it
+		if oomErr := oomKilled(ctx, a.DockerClient, deployContainerID, limits); oomErr != nil {
+			err = oomErr
+		}
+		if removeErr := a.DockerClient.RemoveContainer(ctx, deployContainerID); removeErr != nil {
+			logger.Warn("Failed to remove unhealthy deployment container", zap.Error(removeErr))
+		}
+		deployment.Status = constants.DeploymentStatusFailed
+		return fmt.Errorf("deployment failed readiness check: %w", err)
+	}
 
 	logger.Info("✅ Deployment successful!",
 		zap.String("url", deploymentURL),
 		zap.String("containerID", deployContainerID))
 
+	deployment.Status = constants.DeploymentStatusRunning
+	return nil
+}
+
+// RestartDeployment brings a previously stopped deployment back from its retained artifact.
+// A container that still exists is started again, otherwise a new one is created.
+func (a *DeployService) RestartDeployment(ctx context.Context, project *dto.Project, build *dto.Build, deployment *dto.Deployment, env *ResolvedEnv) error {
+	if deployment.Container == nil || !a.DockerClient.DoesContainerExist(ctx, deployment.Container.ID) {
+		logger.Info("Recreating deployment container from artifact", zap.Uint64("deployment_id", deployment.ID))
+		return a.DeployApplication(ctx, project, build, deployment, env)
+	}
+
+	inspect, err := a.DockerClient.InspectContainer(ctx, deployment.Container.ID)
+	if err != nil {
+		return fmt.Errorf("failed to inspect deployment container: %w", err)
+	}
+	if !inspect.State.Running {
+		if err := a.DockerClient.StartContainer(ctx, deployment.Container.ID); err != nil {
+			return fmt.Errorf("failed to start deployment container: %w", err)
+		}
+		// docker hands out a new host port on every start
+		inspect, err = a.DockerClient.InspectContainer(ctx, deployment.Container.ID)
+		if err != nil {
+			return fmt.Errorf("failed to inspect deployment container: %w", err)
+		}
+	}
+
+	deploymentURL, err := containerURL(inspect, appPort(build))
+	if err != nil {
+		return err
+	}
 	deployment.URL = deploymentURL
+
+	if err := a.WaitForReady(ctx, deployment, a.healthCheckPath(build), a.timeoutsConfig.For(constants.BuildPhaseReadiness, project.Timeouts)); err != nil {
+		return fmt.Errorf("deployment failed readiness check: %w", err)
+	}
 	deployment.Status = constants.DeploymentStatusRunning
+	deployment.StoppedAt = nil
 	return nil
 }
+
+// containerURL is where the proxy reaches the app. Containers on internal networks
+// cannot publish ports, so they are reached on their network address instead.
+func containerURL(inspect *container.InspectResponse, port string) (string, error) {
+	if portBindings := inspect.NetworkSettings.Ports[nat.Port(port+"/tcp")]; len(portBindings) > 0 {
+		return fmt.Sprintf("http://localhost:%s", portBindings[0].HostPort), nil
+	}
+	for _, endpoint := range inspect.NetworkSettings.Networks {
+		if endpoint.IPAddress != "" {
+			return fmt.Sprintf("http://%s:%s", endpoint.IPAddress, port), nil
+		}
+	}
+	return "", fmt.Errorf("no port bindings found for container")
+}
+
+// healthCheckPath is the repository's health check path, the configured default otherwise
+func (a *DeployService) healthCheckPath(build *dto.Build) string {
+	if path := buildConfigOf(build).HealthCheckPath; path != "" {
+		return path
+	}
+	return a.deployConfig.HealthCheckPath
+}
+
+// WaitForReady polls healthCheckPath on the deployment until it answers or timeout elapses.
+// Any response below 500 counts as ready since apps without a health route answer 404.
+func (a *DeployService) WaitForReady(ctx context.Context, deployment *dto.Deployment, healthCheckPath string, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	healthURL := deployment.URL + healthCheckPath
+	ticker := time.NewTicker(500 * time.Millisecond)
+	defer ticker.Stop()
+
+	var lastErr error
+	for {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, healthURL, nil)
+		if err != nil {
+			return fmt.Errorf("failed to build readiness request: %w", err)
+		}
+		resp, err := a.httpClient.Do(req)
+		if err == nil {
+			resp.Body.Close()
+			if resp.StatusCode < http.StatusInternalServerError {
+				logger.Debug("Deployment is ready", zap.String("url", healthURL), zap.Int("status", resp.StatusCode))
+				return nil
+			}
+			lastErr = fmt.Errorf("health check returned status %d", resp.StatusCode)
+		} else {
+			lastErr = err
+		}
+
+		select {
+		case <-ctx.Done():
+			logger.Warn("Deployment never became ready", zap.String("url", healthURL), zap.Error(lastErr))
+			return phaseTimedOut(constants.BuildPhaseReadiness, timeout)
+		case <-ticker.C:
+		}
+	}
+}
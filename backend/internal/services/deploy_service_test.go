@@ -0,0 +1,52 @@
+package services
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/RajVerma97/golang-vercel/backend/internal/config"
+	"github.com/RajVerma97/golang-vercel/backend/internal/constants"
+	"github.com/RajVerma97/golang-vercel/backend/internal/dto"
+)
+
+func TestDeployServiceWaitForReady(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		wantErr bool
+	}{
+		{name: "healthy", status: http.StatusOK},
+		// apps without a health route still answer
+		{name: "no health route", status: http.StatusNotFound},
+		{name: "never ready", status: http.StatusServiceUnavailable, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != "/healthz" {
+					t.Errorf("polled %s, want the health check path", r.URL.Path)
+				}
+				w.WriteHeader(tt.status)
+			}))
+			t.Cleanup(app.Close)
+			s := NewDeployService(&DeployServiceConfig{DeployConfig: &config.DeployConfig{}})
+
+			err := s.WaitForReady(context.Background(), &dto.Deployment{URL: app.URL}, "/healthz", 300*time.Millisecond)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("WaitForReady() = %v, want an error %v", err, tt.wantErr)
+			}
+			if err == nil {
+				return
+			}
+			if FailureCodeOf(err) != constants.FailureCodeTimeout {
+				t.Errorf("WaitForReady() = %v, want a TIMEOUT failure", err)
+			}
+			if err.Error() != "readiness phase timed out after 300ms" {
+				t.Errorf("WaitForReady() = %q, want it to name the readiness phase", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,271 @@
+package services
+
+import (
+	"archive/tar"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	docker_client "github.com/RajVerma97/golang-vercel/backend/internal/client/docker"
+	"github.com/RajVerma97/golang-vercel/backend/internal/constants"
+	"github.com/RajVerma97/golang-vercel/backend/internal/dto"
+	"github.com/RajVerma97/golang-vercel/backend/internal/logger"
+	"github.com/moby/patternmatcher"
+	"github.com/moby/patternmatcher/ignorefile"
+	"go.uber.org/zap"
+)
+
+const (
+	defaultDockerfile = "Dockerfile"
+	dockerignoreFile  = ".dockerignore"
+	// logFlushInterval is how often the logs of an image build are saved while it runs
+	logFlushInterval = 2 * time.Second
+)
+
+// ErrDockerfileBuildNotAllowed rejects Dockerfile builds of projects whose builds or egress
+// the platform cannot enforce on BuildKit
+var ErrDockerfileBuildNotAllowed = errors.New("dockerfile builds are not allowed")
+
+// dockerfileOf returns the Dockerfile the build runs, relative to rootDir, or "" when the Go app is compiled instead.
+// Projects without a builder predate Dockerfile builds and keep compiling the Go app unless they name a Dockerfile.
+func dockerfileOf(project *dto.Project, rootDir string) (string, error) {
+	if project.Builder == constants.BuilderGo || project.Builder == "" && project.Dockerfile == "" {
+		return "", nil
+	}
+	dockerfile := project.Dockerfile
+	if dockerfile == "" {
+		dockerfile = defaultDockerfile
+	}
+	info, err := os.Lstat(filepath.Join(rootDir, filepath.FromSlash(dockerfile)))
+	switch {
+	case os.IsNotExist(err) && project.Builder != constants.BuilderDockerfile && project.Dockerfile == "":
+		return "", nil
+	case os.IsNotExist(err):
+		return "", fmt.Errorf("%s does not exist in the root directory", dockerfile)
+	case err != nil:
+		return "", fmt.Errorf("failed to stat %s: %w", dockerfile, err)
+	case !info.Mode().IsRegular():
+		return "", fmt.Errorf("%s must be a regular file", dockerfile)
+	}
+	return dockerfile, nil
+}
+
+// checkDockerfileBuild refuses what a Dockerfile build cannot honor: the hardened profile's sandbox
+// does not apply to BuildKit's RUN instructions, nor can they be routed through the egress proxy
+func (a *BuildService) checkDockerfileBuild(project *dto.Project) error {
+	if a.buildProfile(project) == constants.BuildProfileHardened {
+		return fmt.Errorf("%w with the hardened build profile, use the go builder", ErrDockerfileBuildNotAllowed)
+	}
+	if egressMode(project) == constants.EgressModeAllowlist {
+		return fmt.Errorf("%w with allowlist egress, use the go builder or deny egress", ErrDockerfileBuildNotAllowed)
+	}
+	return nil
+}
+
+// dockerfileNetworkMode cuts RUN instructions off the network when the project denies egress,
+// they run on the build network otherwise
+func (a *BuildService) dockerfileNetworkMode(ctx context.Context, project *dto.Project) (string, error) {
+	if egressMode(project) == constants.EgressModeDeny {
+		return "none", nil
+	}
+	return a.NetworkService.PrepareBuildNetwork(ctx)
+}
+
+// imageTag names the image of a Dockerfile build, one per build so deployments can be rolled back
+func imageTag(build *dto.Build) string {
+	return fmt.Sprintf("project-%d:build-%d", build.ProjectID, build.ID)
+}
+
+// BuildImage builds the build's Dockerfile with the project's root directory as the context and tags
+// the image for the deployment. The output is appended to the build's logs as it arrives and save
+// is called every logFlushInterval while it does. Build env vars that are not secrets are passed
+// as build args, the Dockerfile has to declare them with ARG.
+func (a *BuildService) BuildImage(ctx context.Context, project *dto.Project, build *dto.Build, tempDirPath string, env *ResolvedEnv, save func()) error {
+	logger.Info("Starting Dockerfile build", zap.Uint64("build_id", build.ID), zap.String("dockerfile", build.Dockerfile))
+	rootDir := filepath.Join(tempDirPath, project.RootDirectory)
+	buildContext, err := contextTar(rootDir, build.Dockerfile)
+	if err != nil {
+		return err
+	}
+	defer buildContext.Close()
+
+	networkMode, err := a.dockerfileNetworkMode(ctx, project)
+	if err != nil {
+		return err
+	}
+
+	timeout := a.timeoutsConfig.For(constants.BuildPhaseCompile, project.Timeouts)
+	buildCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	out := env.Redactor().Writer(&logAppender{build: build, save: save, savedAt: time.Now()})
+	tag := imageTag(build)
+	_, err = a.DockerClient.BuildImage(buildCtx, docker_client.ImageBuild{
+		Context:     buildContext,
+		Dockerfile:  build.Dockerfile,
+		Tag:         tag,
+		Platform:    "linux/" + build.GOARCH,
+		BuildArgs:   buildArgs(env),
+		NetworkMode: networkMode,
+		Limits:      a.resourcesConfig.Limits(resourceClassOf(project, build, a.resourcesConfig.DefaultClass)),
+		Labels: map[string]string{
+			"project_id": fmt.Sprint(build.ProjectID),
+			"build_id":   fmt.Sprint(build.ID),
+		},
+	}, out)
+	out.Close()
+	if err != nil {
+		if buildCtx.Err() == context.DeadlineExceeded {
+			return phaseTimedOut(constants.BuildPhaseCompile, timeout)
+		}
+		return fmt.Errorf("docker build failed: %w", err)
+	}
+
+	build.Image = tag
+	logger.Info("Build successful! Image created", zap.String("image", tag))
+	return nil
+}
+
+// logAppender streams output into the build's logs and saves them every logFlushInterval,
+// so they can be followed while the image builds
+type logAppender struct {
+	build   *dto.Build
+	save    func()
+	savedAt time.Time
+}
+
+func (w *logAppender) Write(p []byte) (int, error) {
+	w.build.Logs += string(p)
+	if now := time.Now(); now.Sub(w.savedAt) >= logFlushInterval {
+		w.savedAt = now
+		w.save()
+	}
+	return len(p), nil
+}
+
+func buildArgs(env *ResolvedEnv) map[string]*string {
+	if env == nil || len(env.BuildArgs) == 0 {
+		return nil
+	}
+	args := make(map[string]*string, len(env.BuildArgs))
+	for _, pair := range env.BuildArgs {
+		key, value, _ := strings.Cut(pair, "=")
+		args[key] = &value
+	}
+	return args
+}
+
+// contextTar streams rootDir as a tar, leaving out what .dockerignore excludes. Symlinks are archived
+// as links, never followed, so the context cannot reach outside the workspace.
+func contextTar(rootDir, dockerfile string) (io.ReadCloser, error) {
+	ignore, err := loadDockerignore(rootDir)
+	if err != nil {
+		return nil, err
+	}
+
+	reader, writer := io.Pipe()
+	go func() {
+		tw := tar.NewWriter(writer)
+		// the matches of a directory decide those of its entries
+		dirMatches := map[string]patternmatcher.MatchInfo{}
+		err := filepath.WalkDir(rootDir, func(p string, entry fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			rel, err := filepath.Rel(rootDir, p)
+			if err != nil || rel == "." {
+				return err
+			}
+			rel = filepath.ToSlash(rel)
+			excluded, matches, err := ignore.MatchesUsingParentResults(rel, dirMatches[path.Dir(rel)])
+			if err != nil {
+				return err
+			}
+			if entry.IsDir() {
+				dirMatches[rel] = matches
+			}
+			// docker needs the Dockerfile and .dockerignore whatever the patterns say
+			if excluded && rel != dockerfile && rel != dockerignoreFile {
+				// exceptions may bring back entries below an excluded directory
+				if entry.IsDir() && !ignore.Exclusions() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			return addToTar(tw, p, rel)
+		})
+		if err == nil {
+			err = tw.Close()
+		}
+		writer.CloseWithError(err)
+	}()
+	return reader, nil
+}
+
+func addToTar(tw *tar.Writer, p, rel string) error {
+	info, err := os.Lstat(p)
+	if err != nil {
+		return err
+	}
+	link := ""
+	if info.Mode()&os.ModeSymlink != 0 {
+		if link, err = os.Readlink(p); err != nil {
+			return err
+		}
+	}
+	header, err := tar.FileInfoHeader(info, link)
+	if err != nil {
+		// sockets and the like cannot be archived and have no place in a build context
+		return nil
+	}
+	header.Name = rel
+	// ownership on the host is meaningless in the image
+	header.Uid, header.Gid, header.Uname, header.Gname = 0, 0, "", ""
+	if err := tw.WriteHeader(header); err != nil {
+		return err
+	}
+	if !info.Mode().IsRegular() {
+		return nil
+	}
+	file, err := os.Open(p)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	_, err = io.Copy(tw, file)
+	return err
+}
+
+// loadDockerignore reads the patterns of the .dockerignore in rootDir the way docker does
+func loadDockerignore(rootDir string) (*patternmatcher.PatternMatcher, error) {
+	var patterns []string
+	p := filepath.Join(rootDir, dockerignoreFile)
+	info, err := os.Lstat(p)
+	switch {
+	case os.IsNotExist(err):
+	case err != nil:
+		return nil, fmt.Errorf("failed to stat %s: %w", dockerignoreFile, err)
+	case !info.Mode().IsRegular():
+		return nil, fmt.Errorf("%s must be a regular file", dockerignoreFile)
+	default:
+		file, err := os.Open(p)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", dockerignoreFile, err)
+		}
+		defer file.Close()
+		if patterns, err = ignorefile.ReadAll(file); err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", dockerignoreFile, err)
+		}
+	}
+	ignore, err := patternmatcher.New(patterns)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", dockerignoreFile, err)
+	}
+	return ignore, nil
+}
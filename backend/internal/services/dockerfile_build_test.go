@@ -0,0 +1,201 @@
+package services
+
+import (
+	"archive/tar"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/RajVerma97/golang-vercel/backend/internal/config"
+	"github.com/RajVerma97/golang-vercel/backend/internal/constants"
+	"github.com/RajVerma97/golang-vercel/backend/internal/dto"
+)
+
+func TestContextTar(t *testing.T) {
+	tests := []struct {
+		name         string
+		files        []string
+		dockerignore string
+		want         []string
+	}{
+		{
+			name:  "no .dockerignore",
+			files: []string{"Dockerfile", "main.go", "web/index.html"},
+			want:  []string{"Dockerfile", "main.go", "web", "web/index.html"},
+		},
+		{
+			name:         "excluded files and directories",
+			files:        []string{"Dockerfile", "main.go", "README.md", "docs/guide.md", ".git/HEAD"},
+			dockerignore: "# comment\n\n*.md\n.git\ndocs\n",
+			want:         []string{".dockerignore", "Dockerfile", "main.go"},
+		},
+		{
+			name:         "double star",
+			files:        []string{"Dockerfile", "main.go", "a/b/main_test.go", "a/b/c.go"},
+			dockerignore: "**/*_test.go\n",
+			want:         []string{".dockerignore", "Dockerfile", "a", "a/b", "a/b/c.go", "main.go"},
+		},
+		{
+			name:         "exception below an excluded directory",
+			files:        []string{"Dockerfile", "vendor/a/a.go", "vendor/modules.txt"},
+			dockerignore: "vendor\n!vendor/modules.txt\n",
+			want:         []string{".dockerignore", "Dockerfile", "vendor/modules.txt"},
+		},
+		{
+			name:         "leading slash and dot segments",
+			files:        []string{"Dockerfile", "tmp/a", "src/tmp/b"},
+			dockerignore: "/tmp\n./src/../src/tmp\n",
+			want:         []string{".dockerignore", "Dockerfile", "src"},
+		},
+		{
+			name:         "the Dockerfile and .dockerignore are always sent",
+			files:        []string{"Dockerfile", "main.go"},
+			dockerignore: "*\n",
+			want:         []string{".dockerignore", "Dockerfile"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rootDir := t.TempDir()
+			for _, file := range tt.files {
+				writeFile(t, filepath.Join(rootDir, filepath.FromSlash(file)), file)
+			}
+			if tt.dockerignore != "" {
+				writeFile(t, filepath.Join(rootDir, dockerignoreFile), tt.dockerignore)
+			}
+			if got := tarEntries(t, rootDir, "Dockerfile"); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("contextTar() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestContextTarKeepsSymlinks(t *testing.T) {
+	rootDir := t.TempDir()
+	writeFile(t, filepath.Join(rootDir, "Dockerfile"), "FROM scratch")
+	symlink(t, "/etc/passwd", filepath.Join(rootDir, "passwd"))
+
+	buildContext, err := contextTar(rootDir, "Dockerfile")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer buildContext.Close()
+	tr := tar.NewReader(buildContext)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			t.Fatal("passwd is missing from the context")
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if header.Name == "passwd" {
+			if header.Typeflag != tar.TypeSymlink || header.Linkname != "/etc/passwd" || header.Size != 0 {
+				t.Errorf("passwd was archived as %c -> %q with %d bytes, want the link", header.Typeflag, header.Linkname, header.Size)
+			}
+			return
+		}
+	}
+}
+
+func TestLoadDockerignoreRejectsSymlinks(t *testing.T) {
+	rootDir := t.TempDir()
+	outside := filepath.Join(t.TempDir(), "ignore")
+	writeFile(t, outside, "*.md")
+	symlink(t, outside, filepath.Join(rootDir, dockerignoreFile))
+	if _, err := loadDockerignore(rootDir); err == nil {
+		t.Error("loadDockerignore() followed a symlink")
+	}
+}
+
+func tarEntries(t *testing.T, rootDir, dockerfile string) []string {
+	t.Helper()
+	buildContext, err := contextTar(rootDir, dockerfile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer buildContext.Close()
+	var names []string
+	tr := tar.NewReader(buildContext)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, header.Name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func TestDockerfileOf(t *testing.T) {
+	tests := []struct {
+		name    string
+		project dto.Project
+		files   []string
+		want    string
+		wantErr bool
+	}{
+		{name: "project without a builder", project: dto.Project{}, files: []string{"Dockerfile"}},
+		{name: "project without a builder naming a Dockerfile", project: dto.Project{Dockerfile: "build/Dockerfile"}, files: []string{"build/Dockerfile"}, want: "build/Dockerfile"},
+		{name: "go", project: dto.Project{Builder: constants.BuilderGo}, files: []string{"Dockerfile"}},
+		{name: "auto with a Dockerfile", project: dto.Project{Builder: constants.BuilderAuto}, files: []string{"Dockerfile"}, want: "Dockerfile"},
+		{name: "auto without a Dockerfile", project: dto.Project{Builder: constants.BuilderAuto}},
+		{name: "dockerfile without a Dockerfile", project: dto.Project{Builder: constants.BuilderDockerfile}, wantErr: true},
+		{name: "named Dockerfile missing", project: dto.Project{Builder: constants.BuilderAuto, Dockerfile: "Dockerfile.prod"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rootDir := t.TempDir()
+			for _, file := range tt.files {
+				writeFile(t, filepath.Join(rootDir, filepath.FromSlash(file)), "FROM scratch")
+			}
+			got, err := dockerfileOf(&tt.project, rootDir)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("dockerfileOf() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("dockerfileOf() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDockerfileOfRejectsSymlinks(t *testing.T) {
+	rootDir := t.TempDir()
+	if err := os.Symlink("/etc/passwd", filepath.Join(rootDir, "Dockerfile")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := dockerfileOf(&dto.Project{Builder: constants.BuilderAuto}, rootDir); err == nil {
+		t.Error("dockerfileOf() accepted a symlinked Dockerfile")
+	}
+}
+
+func TestCheckDockerfileBuild(t *testing.T) {
+	tests := []struct {
+		name    string
+		project dto.Project
+		wantErr bool
+	}{
+		{name: "standard", project: dto.Project{}},
+		{name: "deny egress", project: dto.Project{Egress: dto.EgressPolicy{Mode: constants.EgressModeDeny}}},
+		{name: "hardened", project: dto.Project{BuildProfile: constants.BuildProfileHardened}, wantErr: true},
+		{name: "allowlist egress", project: dto.Project{Egress: dto.EgressPolicy{Mode: constants.EgressModeAllowlist}}, wantErr: true},
+	}
+	s := &BuildService{sandboxConfig: &config.SandboxConfig{DefaultProfile: constants.BuildProfileStandard}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := s.checkDockerfileBuild(&tt.project)
+			if tt.wantErr != errors.Is(err, ErrDockerfileBuildNotAllowed) {
+				t.Errorf("checkDockerfileBuild() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
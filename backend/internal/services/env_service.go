@@ -0,0 +1,263 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	redis_client "github.com/RajVerma97/golang-vercel/backend/internal/client/redis"
+	"github.com/RajVerma97/golang-vercel/backend/internal/constants"
+	"github.com/RajVerma97/golang-vercel/backend/internal/dto"
+	"github.com/RajVerma97/golang-vercel/backend/internal/encryption"
+	"github.com/RajVerma97/golang-vercel/backend/internal/logger"
+	"github.com/RajVerma97/golang-vercel/backend/internal/redact"
+	"go.uber.org/zap"
+)
+
+const (
+	envVarIDKey = "env:id"
+
+	// MaskedValue replaces secret values in API responses
+	MaskedValue = redact.Mask
+)
+
+var (
+	ErrEnvVarNotFound = errors.New("environment variable not found")
+	ErrEnvVarConflict = errors.New("environment variable is already defined")
+)
+
+// projectEnvKey maps env var ids to their encrypted definition
+func projectEnvKey(projectID uint64) string {
+	return fmt.Sprintf("project:%d:env", projectID)
+}
+
+// ResolvedEnv is what a project's env vars come to for one deployment environment
+type ResolvedEnv struct {
+	Build   []string
+	Runtime []string
+	// BuildArgs are the build env vars that are not secrets, Dockerfile builds get them as build args
+	// since those end up in the image's history
+	BuildArgs []string
+	secrets   []string
+	redactor  *redact.Redactor
+}
+
+// BuildEnv returns KEY=value pairs for the build container
+func (e *ResolvedEnv) BuildEnv() []string {
+	if e == nil {
+		return nil
+	}
+	return e.Build
+}
+
+// BuildValue looks up key in the build env
+func (e *ResolvedEnv) BuildValue(key string) (string, bool) {
+	for _, pair := range e.BuildEnv() {
+		if k, value, _ := strings.Cut(pair, "="); k == key {
+			return value, true
+		}
+	}
+	return "", false
+}
+
+// RuntimeEnv returns KEY=value pairs for the deployment container
+func (e *ResolvedEnv) RuntimeEnv() []string {
+	if e == nil {
+		return nil
+	}
+	return e.Runtime
+}
+
+// Secrets returns the values of the secret env vars
+func (e *ResolvedEnv) Secrets() []string {
+	if e == nil {
+		return nil
+	}
+	return e.secrets
+}
+
+// Redactor masks the secret values and well known token patterns in build and runtime output
+func (e *ResolvedEnv) Redactor() *redact.Redactor {
+	if e == nil || e.redactor == nil {
+		return redact.New(nil)
+	}
+	return e.redactor
+}
+
+type EnvServiceConfig struct {
+	RedisClient *redis_client.RedisClient
+	Cipher      *encryption.Cipher
+}
+
+// EnvService stores project env vars encrypted at rest
+type EnvService struct {
+	RedisClient *redis_client.RedisClient
+	cipher      *encryption.Cipher
+}
+
+func NewEnvService(config *EnvServiceConfig) *EnvService {
+	return &EnvService{
+		RedisClient: config.RedisClient,
+		cipher:      config.Cipher,
+	}
+}
+
+// List returns the project's env vars with secret values masked
+func (s *EnvService) List(ctx context.Context, projectID uint64) ([]*dto.EnvVar, error) {
+	envVars, err := s.load(ctx, projectID)
+	if err != nil {
+		return nil, err
+	}
+	for _, envVar := range envVars {
+		if err := s.reveal(envVar); err != nil {
+			return nil, err
+		}
+	}
+	return envVars, nil
+}
+
+// Create stores envVar, which may not overlap with a variable of the same key in any environment and target.
+// The value is left masked on envVar when it is a secret.
+func (s *EnvService) Create(ctx context.Context, projectID uint64, envVar *dto.EnvVar) error {
+	existing, err := s.load(ctx, projectID)
+	if err != nil {
+		return err
+	}
+	for _, other := range existing {
+		if other.Key == envVar.Key && overlaps(other.Environments, envVar.Environments) && overlaps(other.Targets, envVar.Targets) {
+			return fmt.Errorf("%w: %s (id %d)", ErrEnvVarConflict, envVar.Key, other.ID)
+		}
+	}
+
+	id, err := s.RedisClient.NextID(ctx, envVarIDKey)
+	if err != nil {
+		return fmt.Errorf("failed to assign env var id: %w", err)
+	}
+	envVar.ID = id
+	now := time.Now()
+	envVar.CreatedAt = now
+	envVar.UpdatedAt = now
+
+	plaintext := envVar.Value
+	envVar.Value, err = s.cipher.Encrypt(plaintext)
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(envVar)
+	if err != nil {
+		return fmt.Errorf("failed to marshal data:%w", err)
+	}
+	if err := s.RedisClient.SetHashField(ctx, projectEnvKey(projectID), strconv.FormatUint(id, 10), string(data)); err != nil {
+		return err
+	}
+
+	envVar.Value = plaintext
+	if envVar.Secret {
+		envVar.Value = MaskedValue
+	}
+	logger.Debug("Created env var", zap.Uint64("project_id", projectID), zap.String("key", envVar.Key))
+	return nil
+}
+
+func (s *EnvService) Delete(ctx context.Context, projectID, id uint64) error {
+	field := strconv.FormatUint(id, 10)
+	if _, found, err := s.RedisClient.GetHashField(ctx, projectEnvKey(projectID), field); err != nil {
+		return err
+	} else if !found {
+		return ErrEnvVarNotFound
+	}
+	return s.RedisClient.DeleteHashField(ctx, projectEnvKey(projectID), field)
+}
+
+// Resolve decrypts the env vars that apply to environment and splits them by target
+func (s *EnvService) Resolve(ctx context.Context, projectID uint64, environment constants.DeploymentEnvironment) (*ResolvedEnv, error) {
+	return s.resolve(ctx, projectID, environment, true)
+}
+
+// ResolveWithoutSecrets is Resolve for code the project's owners have not vetted, such as pull
+// requests from forks: secret env vars are left out so that the code cannot read them
+func (s *EnvService) ResolveWithoutSecrets(ctx context.Context, projectID uint64, environment constants.DeploymentEnvironment) (*ResolvedEnv, error) {
+	return s.resolve(ctx, projectID, environment, false)
+}
+
+func (s *EnvService) resolve(ctx context.Context, projectID uint64, environment constants.DeploymentEnvironment, withSecrets bool) (*ResolvedEnv, error) {
+	envVars, err := s.load(ctx, projectID)
+	if err != nil {
+		return nil, err
+	}
+
+	resolved := &ResolvedEnv{}
+	var secrets []string
+	for _, envVar := range envVars {
+		if !slices.Contains(envVar.Environments, environment) || envVar.Secret && !withSecrets {
+			continue
+		}
+		value, err := s.cipher.Decrypt(envVar.Value)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decrypt %s: %w", envVar.Key, err)
+		}
+		pair := envVar.Key + "=" + value
+		if slices.Contains(envVar.Targets, constants.EnvTargetBuild) {
+			resolved.Build = append(resolved.Build, pair)
+			if !envVar.Secret {
+				resolved.BuildArgs = append(resolved.BuildArgs, pair)
+			}
+		}
+		if slices.Contains(envVar.Targets, constants.EnvTargetRuntime) {
+			resolved.Runtime = append(resolved.Runtime, pair)
+		}
+		if envVar.Secret {
+			secrets = append(secrets, value)
+		}
+	}
+	resolved.secrets = secrets
+	resolved.redactor = redact.New(secrets)
+	return resolved, nil
+}
+
+// load returns the stored env vars, values still encrypted, ordered by id
+func (s *EnvService) load(ctx context.Context, projectID uint64) ([]*dto.EnvVar, error) {
+	values, err := s.RedisClient.GetHash(ctx, projectEnvKey(projectID))
+	if err != nil {
+		return nil, err
+	}
+	envVars := make([]*dto.EnvVar, 0, len(values))
+	for _, value := range values {
+		var envVar dto.EnvVar
+		if err := json.Unmarshal([]byte(value), &envVar); err != nil {
+			return nil, fmt.Errorf("failed to load env var: %w", err)
+		}
+		envVars = append(envVars, &envVar)
+	}
+	sort.Slice(envVars, func(i, j int) bool { return envVars[i].ID < envVars[j].ID })
+	return envVars, nil
+}
+
+// reveal decrypts non secret values and masks secret ones
+func (s *EnvService) reveal(envVar *dto.EnvVar) error {
+	if envVar.Secret {
+		envVar.Value = MaskedValue
+		return nil
+	}
+	value, err := s.cipher.Decrypt(envVar.Value)
+	if err != nil {
+		return fmt.Errorf("failed to decrypt %s: %w", envVar.Key, err)
+	}
+	envVar.Value = value
+	return nil
+}
+
+func overlaps[T comparable](a, b []T) bool {
+	for _, v := range a {
+		if slices.Contains(b, v) {
+			return true
+		}
+	}
+	return false
+}
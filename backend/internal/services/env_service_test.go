@@ -0,0 +1,155 @@
+package services
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/RajVerma97/golang-vercel/backend/internal/constants"
+	"github.com/RajVerma97/golang-vercel/backend/internal/dto"
+	"github.com/RajVerma97/golang-vercel/backend/internal/encryption"
+)
+
+func newTestEnvService(t *testing.T) *EnvService {
+	t.Helper()
+	cipher, err := encryption.NewCipher(base64.StdEncoding.EncodeToString([]byte(strings.Repeat("k", 32))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewEnvService(&EnvServiceConfig{RedisClient: newTestRedis(t), Cipher: cipher})
+}
+
+func TestEnvServiceResolve(t *testing.T) {
+	ctx := context.Background()
+	s := newTestEnvService(t)
+	production, preview := constants.DeploymentEnvironmentProduction, constants.DeploymentEnvironmentPreview
+	build, runtime := constants.EnvTargetBuild, constants.EnvTargetRuntime
+	for _, envVar := range []*dto.EnvVar{
+		{Key: "LOG_LEVEL", Value: "debug", Environments: []constants.DeploymentEnvironment{preview}, Targets: []constants.EnvTarget{runtime}},
+		{Key: "LOG_LEVEL", Value: "info", Environments: []constants.DeploymentEnvironment{production}, Targets: []constants.EnvTarget{runtime}},
+		{Key: "GOPRIVATE", Value: "github.com/acme", Environments: []constants.DeploymentEnvironment{production, preview}, Targets: []constants.EnvTarget{build}},
+		{Key: "NPM_TOKEN", Value: "npm-secret", Secret: true, Environments: []constants.DeploymentEnvironment{production, preview}, Targets: []constants.EnvTarget{build}},
+		{Key: "DATABASE_URL", Value: "postgres://preview-secret", Secret: true, Environments: []constants.DeploymentEnvironment{preview}, Targets: []constants.EnvTarget{runtime}},
+	} {
+		if err := s.Create(ctx, 1, envVar); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name        string
+		resolve     func(context.Context, uint64, constants.DeploymentEnvironment) (*ResolvedEnv, error)
+		environment constants.DeploymentEnvironment
+		wantBuild   []string
+		wantArgs    []string
+		wantRuntime []string
+		wantMasked  string
+	}{
+		{
+			name: "production", resolve: s.Resolve, environment: production,
+			wantBuild:   []string{"GOPRIVATE=github.com/acme", "NPM_TOKEN=npm-secret"},
+			wantArgs:    []string{"GOPRIVATE=github.com/acme"},
+			wantRuntime: []string{"LOG_LEVEL=info"},
+			wantMasked:  "npm-secret",
+		},
+		{
+			name: "preview", resolve: s.Resolve, environment: preview,
+			wantBuild:   []string{"GOPRIVATE=github.com/acme", "NPM_TOKEN=npm-secret"},
+			wantArgs:    []string{"GOPRIVATE=github.com/acme"},
+			wantRuntime: []string{"LOG_LEVEL=debug", "DATABASE_URL=postgres://preview-secret"},
+			wantMasked:  "postgres://preview-secret",
+		},
+		{
+			name: "preview without secrets", resolve: s.ResolveWithoutSecrets, environment: preview,
+			wantBuild:   []string{"GOPRIVATE=github.com/acme"},
+			wantArgs:    []string{"GOPRIVATE=github.com/acme"},
+			wantRuntime: []string{"LOG_LEVEL=debug"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env, err := tt.resolve(ctx, 1, tt.environment)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(env.BuildEnv(), tt.wantBuild) {
+				t.Errorf("build env = %q, want %q", env.BuildEnv(), tt.wantBuild)
+			}
+			if !reflect.DeepEqual(env.BuildArgs, tt.wantArgs) {
+				t.Errorf("build args = %q, want %q", env.BuildArgs, tt.wantArgs)
+			}
+			if !reflect.DeepEqual(env.RuntimeEnv(), tt.wantRuntime) {
+				t.Errorf("runtime env = %q, want %q", env.RuntimeEnv(), tt.wantRuntime)
+			}
+			if tt.wantMasked != "" && strings.Contains(env.Redactor().Redact("value: "+tt.wantMasked), tt.wantMasked) {
+				t.Errorf("the redactor does not mask %q", tt.wantMasked)
+			}
+		})
+	}
+}
+
+func TestEnvServiceCreate(t *testing.T) {
+	ctx := context.Background()
+	s := newTestEnvService(t)
+	secret := &dto.EnvVar{
+		Key: "API_KEY", Value: "s3cr3t", Secret: true,
+		Environments: []constants.DeploymentEnvironment{constants.DeploymentEnvironmentProduction},
+		Targets:      []constants.EnvTarget{constants.EnvTargetRuntime},
+	}
+	if err := s.Create(ctx, 1, secret); err != nil {
+		t.Fatal(err)
+	}
+	if secret.Value != MaskedValue {
+		t.Errorf("created secret value = %q, want it masked", secret.Value)
+	}
+	stored, _, err := s.RedisClient.GetHashField(ctx, projectEnvKey(1), "1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(stored, "s3cr3t") {
+		t.Errorf("stored env var %s contains the plaintext value", stored)
+	}
+
+	conflicting := &dto.EnvVar{
+		Key: "API_KEY", Value: "other",
+		Environments: []constants.DeploymentEnvironment{constants.DeploymentEnvironmentProduction, constants.DeploymentEnvironmentPreview},
+		Targets:      []constants.EnvTarget{constants.EnvTargetRuntime},
+	}
+	if err := s.Create(ctx, 1, conflicting); !errors.Is(err, ErrEnvVarConflict) {
+		t.Errorf("Create() of an overlapping variable error = %v, want ErrEnvVarConflict", err)
+	}
+
+	envVars, err := s.List(ctx, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(envVars) != 1 || envVars[0].Value != MaskedValue {
+		t.Errorf("List() = %+v, want the secret masked", envVars)
+	}
+}
+
+func TestProjectServiceFromFork(t *testing.T) {
+	number := 7
+	project := &dto.Project{RepoUrl: "https://github.com/acme/app.git"}
+	tests := []struct {
+		name  string
+		build dto.Build
+		want  bool
+	}{
+		{name: "push", build: dto.Build{RepoUrl: project.RepoUrl}},
+		{name: "pull request from a branch", build: dto.Build{RepoUrl: project.RepoUrl, PullRequest: &number}},
+		{name: "pull request naming its own repository", build: dto.Build{RepoUrl: project.RepoUrl, HeadRepoUrl: "git@github.com:acme/app.git", PullRequest: &number}},
+		{name: "pull request from a fork", build: dto.Build{RepoUrl: project.RepoUrl, HeadRepoUrl: "https://github.com/evil/app.git", PullRequest: &number}, want: true},
+	}
+	s := &ProjectService{}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := s.FromFork(project, &tt.build); got != tt.want {
+				t.Errorf("FromFork() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
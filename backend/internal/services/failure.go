@@ -0,0 +1,55 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	docker_client "github.com/RajVerma97/golang-vercel/backend/internal/client/docker"
+	"github.com/RajVerma97/golang-vercel/backend/internal/config"
+	"github.com/RajVerma97/golang-vercel/backend/internal/constants"
+)
+
+// FailureError tags an error with the code reported on the failed build or deployment
+type FailureError struct {
+	Code constants.FailureCode
+	Err  error
+}
+
+func (e *FailureError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *FailureError) Unwrap() error {
+	return e.Err
+}
+
+// FailureCodeOf returns the code err was tagged with, or FailureCodeError for untagged errors
+func FailureCodeOf(err error) constants.FailureCode {
+	var failure *FailureError
+	if errors.As(err, &failure) {
+		return failure.Code
+	}
+	return constants.FailureCodeError
+}
+
+// oomKilled returns an OOM_KILLED failure when the kernel killed the container for exceeding its memory limit
+func oomKilled(ctx context.Context, dockerClient *docker_client.DockerClient, containerID string, limits config.ResourceLimits) error {
+	inspect, err := dockerClient.InspectContainer(ctx, containerID)
+	if err != nil || inspect.State == nil || !inspect.State.OOMKilled {
+		return nil
+	}
+	return &FailureError{
+		Code: constants.FailureCodeOOMKilled,
+		Err:  fmt.Errorf("container was killed after exceeding its memory limit of %d MiB", limits.MemoryBytes/(1024*1024)),
+	}
+}
+
+// phaseTimedOut reports a phase that did not finish before its deadline
+func phaseTimedOut(phase constants.BuildPhase, timeout time.Duration) error {
+	return &FailureError{
+		Code: constants.FailureCodeTimeout,
+		Err:  fmt.Errorf("%s phase timed out after %s", phase, timeout),
+	}
+}
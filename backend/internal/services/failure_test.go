@@ -0,0 +1,40 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/RajVerma97/golang-vercel/backend/internal/config"
+	"github.com/RajVerma97/golang-vercel/backend/internal/constants"
+)
+
+func TestOOMKilled(t *testing.T) {
+	client := newTestDockerClient(t, func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/containers/killed/json"):
+			json.NewEncoder(w).Encode(map[string]any{"Id": "killed", "State": map[string]any{"Status": "exited", "ExitCode": 137, "OOMKilled": true}})
+		case strings.HasSuffix(r.URL.Path, "/containers/crashed/json"):
+			json.NewEncoder(w).Encode(map[string]any{"Id": "crashed", "State": map[string]any{"Status": "exited", "ExitCode": 137, "OOMKilled": false}})
+		default:
+			http.Error(w, `{"message":"no such container"}`, http.StatusNotFound)
+		}
+	})
+	limits := config.ResourceLimits{MemoryBytes: 512 * 1024 * 1024}
+
+	err := oomKilled(context.Background(), client, "killed", limits)
+	if FailureCodeOf(err) != constants.FailureCodeOOMKilled {
+		t.Fatalf("oomKilled() = %v, want an OOM_KILLED failure", err)
+	}
+	if !strings.Contains(err.Error(), "512 MiB") {
+		t.Errorf("oomKilled() = %q, want the memory limit in the message", err)
+	}
+	// killed for another reason, or gone, is not reported as running out of memory
+	for _, id := range []string{"crashed", "missing"} {
+		if err := oomKilled(context.Background(), client, id, limits); err != nil {
+			t.Errorf("oomKilled(%s) = %v, want nil", id, err)
+		}
+	}
+}
@@ -0,0 +1,301 @@
+package services
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	github_client "github.com/RajVerma97/golang-vercel/backend/internal/client/github"
+	redis_client "github.com/RajVerma97/golang-vercel/backend/internal/client/redis"
+	"github.com/RajVerma97/golang-vercel/backend/internal/constants"
+	"github.com/RajVerma97/golang-vercel/backend/internal/dto"
+	"github.com/RajVerma97/golang-vercel/backend/internal/encryption"
+	"github.com/RajVerma97/golang-vercel/backend/internal/logger"
+	"github.com/RajVerma97/golang-vercel/backend/internal/redact"
+	"go.uber.org/zap"
+	"golang.org/x/crypto/ssh"
+)
+
+const defaultTokenUsername = "x-access-token"
+
+var (
+	ErrGitHubAppNotConfigured = errors.New("github app credentials are not configured")
+	ErrGitTokenRequired       = errors.New("a token is required for token authentication")
+
+	// scpLikeURL matches git@host:owner/repo.git
+	scpLikeURL = regexp.MustCompile(`^(?:[^@/]+@)?([^:/]+):(.+)$`)
+)
+
+// gitSecretKey holds the project's encrypted deploy key and token
+func gitSecretKey(projectID uint64) string {
+	return fmt.Sprintf("project:%d:git_secret", projectID)
+}
+
+type gitSecret struct {
+	PrivateKey string `json:"private_key,omitempty"`
+	Token      string `json:"token,omitempty"`
+}
+
+type GitAuthServiceConfig struct {
+	RedisClient    *redis_client.RedisClient
+	GitHubClient   *github_client.GitHubClient
+	ProjectService *ProjectService
+	Cipher         *encryption.Cipher
+}
+
+// GitAuthService manages the credentials used to clone private repositories
+type GitAuthService struct {
+	RedisClient    *redis_client.RedisClient
+	GitHubClient   *github_client.GitHubClient
+	ProjectService *ProjectService
+	cipher         *encryption.Cipher
+}
+
+func NewGitAuthService(config *GitAuthServiceConfig) *GitAuthService {
+	return &GitAuthService{
+		RedisClient:    config.RedisClient,
+		GitHubClient:   config.GitHubClient,
+		ProjectService: config.ProjectService,
+		cipher:         config.Cipher,
+	}
+}
+
+// Configure switches the project to method. A deploy key is generated the first time, or again when rotate is set.
+// knownHosts adds the host keys of git servers other than the hosted providers, for deploy keys.
+func (s *GitAuthService) Configure(ctx context.Context, project *dto.Project, method constants.GitAuthMethod, token, username, knownHosts string, rotate bool) error {
+	var secret gitSecret
+	if _, err := s.RedisClient.GetJSON(ctx, gitSecretKey(project.ID), &secret); err != nil {
+		return err
+	}
+
+	auth := dto.GitAuth{Method: method}
+	switch method {
+	case constants.GitAuthMethodDeployKey:
+		if err := validateKnownHosts(knownHosts); err != nil {
+			return err
+		}
+		auth.KnownHosts = knownHosts
+		auth.PublicKey = project.GitAuth.PublicKey
+		if secret.PrivateKey == "" || auth.PublicKey == "" || rotate {
+			privateKey, publicKey, err := generateDeployKey(project.Name)
+			if err != nil {
+				return err
+			}
+			if secret.PrivateKey, err = s.cipher.Encrypt(privateKey); err != nil {
+				return err
+			}
+			auth.PublicKey = publicKey
+		}
+		secret.Token = ""
+	case constants.GitAuthMethodToken:
+		if token == "" {
+			return ErrGitTokenRequired
+		}
+		encrypted, err := s.cipher.Encrypt(token)
+		if err != nil {
+			return err
+		}
+		secret = gitSecret{Token: encrypted}
+		auth.Username = username
+		if auth.Username == "" {
+			auth.Username = defaultTokenUsername
+		}
+	case constants.GitAuthMethodGitHubApp:
+		// a personal access token cannot mint installation tokens
+		if !s.GitHubClient.UsesApp() {
+			return ErrGitHubAppNotConfigured
+		}
+		secret = gitSecret{}
+	default:
+		secret = gitSecret{}
+	}
+
+	if secret == (gitSecret{}) {
+		if err := s.RedisClient.Delete(ctx, gitSecretKey(project.ID)); err != nil {
+			return err
+		}
+	} else if err := s.RedisClient.SetJSON(ctx, gitSecretKey(project.ID), secret, 0); err != nil {
+		return err
+	}
+	updated, err := s.ProjectService.Update(ctx, project.ID, func(stored *dto.Project) {
+		stored.GitAuth = auth
+	})
+	if err != nil {
+		return err
+	}
+	*project = *updated
+	logger.Info("Configured git authentication", zap.Uint64("project_id", project.ID), zap.String("method", method.String()))
+	return nil
+}
+
+// GitCredentials hands credentials to git through its environment and -c options,
+// so nothing ends up in the workspace's .git/config. Close removes the key files.
+type GitCredentials struct {
+	// URL is the repository URL in the form the method needs, deploy keys only work over ssh
+	URL  string
+	Env  []string
+	Args []string
+
+	secrets []string
+	keyDir  string
+}
+
+// Redactor masks the token in git's output
+func (c *GitCredentials) Redactor() *redact.Redactor {
+	if c == nil {
+		return redact.New(nil)
+	}
+	return redact.New(c.secrets)
+}
+
+func (c *GitCredentials) Close() error {
+	if c == nil || c.keyDir == "" {
+		return nil
+	}
+	return os.RemoveAll(c.keyDir)
+}
+
+// Credentials resolves what git needs to clone the build's repository
+func (s *GitAuthService) Credentials(ctx context.Context, project *dto.Project, build *dto.Build) (*GitCredentials, error) {
+	// never let git wait for a password prompt
+	creds := &GitCredentials{URL: build.RepoUrl, Env: []string{"GIT_TERMINAL_PROMPT=0"}}
+
+	var secret gitSecret
+	if project.GitAuth.Method == constants.GitAuthMethodDeployKey || project.GitAuth.Method == constants.GitAuthMethodToken {
+		found, err := s.RedisClient.GetJSON(ctx, gitSecretKey(project.ID), &secret)
+		if err != nil {
+			return nil, err
+		}
+		if !found {
+			return nil, fmt.Errorf("%s credentials for project %d are missing", project.GitAuth.Method, project.ID)
+		}
+	}
+
+	switch project.GitAuth.Method {
+	case constants.GitAuthMethodDeployKey:
+		privateKey, err := s.cipher.Decrypt(secret.PrivateKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decrypt deploy key: %w", err)
+		}
+		if err := creds.useDeployKey(privateKey, project.GitAuth.KnownHosts); err != nil {
+			return nil, err
+		}
+		creds.URL = sshURL(build.RepoUrl)
+	case constants.GitAuthMethodToken:
+		token, err := s.cipher.Decrypt(secret.Token)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decrypt git token: %w", err)
+		}
+		creds.useToken(project.GitAuth.Username, token)
+		creds.URL = httpsURL(build.RepoUrl)
+	case constants.GitAuthMethodGitHubApp:
+		repo := repositoryName(build)
+		token, err := s.GitHubClient.Token(ctx, repo)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get github token for %s: %w", repo, err)
+		}
+		creds.useToken(defaultTokenUsername, token)
+		creds.URL = httpsURL(build.RepoUrl)
+	}
+	return creds, nil
+}
+
+// useDeployKey writes the key outside the workspace and points GIT_SSH_COMMAND at it. Only hosts
+// whose keys are pinned are trusted, so the key is never offered to a server impersonating the host.
+func (c *GitCredentials) useDeployKey(privateKey, projectKnownHosts string) error {
+	dir, err := os.MkdirTemp("", "git-key-")
+	if err != nil {
+		return fmt.Errorf("failed to create key dir: %w", err)
+	}
+	c.keyDir = dir
+	keyPath := filepath.Join(dir, "id_ed25519")
+	if err := os.WriteFile(keyPath, []byte(privateKey), 0600); err != nil {
+		return fmt.Errorf("failed to write deploy key: %w", err)
+	}
+	knownHostsPath := filepath.Join(dir, "known_hosts")
+	if err := os.WriteFile(knownHostsPath, []byte(knownHosts(projectKnownHosts)), 0600); err != nil {
+		return fmt.Errorf("failed to write known_hosts: %w", err)
+	}
+	c.Env = append(c.Env, fmt.Sprintf(
+		"GIT_SSH_COMMAND=ssh -i '%s' -o IdentitiesOnly=yes -o StrictHostKeyChecking=yes -o UserKnownHostsFile='%s' -o GlobalKnownHostsFile=/dev/null",
+		keyPath, knownHostsPath,
+	))
+	return nil
+}
+
+// useToken answers git's credential requests from the environment, the token never shows up in argv
+func (c *GitCredentials) useToken(username, token string) {
+	c.Env = append(c.Env, "GIT_AUTH_USERNAME="+username, "GIT_AUTH_TOKEN="+token)
+	c.Args = append(c.Args,
+		// the empty helper drops any helper configured on the host
+		"-c", "credential.helper=",
+		"-c", `credential.helper=!f() { test "$1" = get && echo "username=$GIT_AUTH_USERNAME" && echo "password=$GIT_AUTH_TOKEN"; }; f`,
+	)
+	c.secrets = append(c.secrets, token)
+}
+
+// generateDeployKey returns an OpenSSH ed25519 private key and its authorized_keys line
+func generateDeployKey(comment string) (string, string, error) {
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to generate deploy key: %w", err)
+	}
+	block, err := ssh.MarshalPrivateKey(privateKey, comment)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to encode deploy key: %w", err)
+	}
+	sshPublicKey, err := ssh.NewPublicKey(publicKey)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to encode deploy key: %w", err)
+	}
+	authorized := strings.TrimSpace(string(ssh.MarshalAuthorizedKey(sshPublicKey))) + " " + comment
+	return string(pem.EncodeToMemory(block)), authorized, nil
+}
+
+// sshURL turns https://host/owner/repo into git@host:owner/repo.git
+func sshURL(repoURL string) string {
+	u, err := url.Parse(repoURL)
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") {
+		return repoURL
+	}
+	path := strings.TrimPrefix(u.Path, "/")
+	if !strings.HasSuffix(path, ".git") {
+		path += ".git"
+	}
+	return fmt.Sprintf("git@%s:%s", u.Hostname(), path)
+}
+
+// httpsURL turns git@host:owner/repo.git into https://host/owner/repo.git
+func httpsURL(repoURL string) string {
+	if strings.Contains(repoURL, "://") {
+		if u, err := url.Parse(repoURL); err == nil && u.Scheme == "ssh" {
+			return fmt.Sprintf("https://%s%s", u.Hostname(), u.Path)
+		}
+		return repoURL
+	}
+	match := scpLikeURL.FindStringSubmatch(repoURL)
+	if match == nil {
+		return repoURL
+	}
+	return fmt.Sprintf("https://%s/%s", match[1], match[2])
+}
+
+// repositoryName returns owner/repo for the build's GitHub repository
+func repositoryName(build *dto.Build) string {
+	if build.GitHubRepository != nil && *build.GitHubRepository != "" {
+		return *build.GitHubRepository
+	}
+	path := httpsURL(build.RepoUrl)
+	if u, err := url.Parse(path); err == nil {
+		path = u.Path
+	}
+	return strings.TrimSuffix(strings.Trim(path, "/"), ".git")
+}
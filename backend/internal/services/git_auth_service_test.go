@@ -0,0 +1,368 @@
+package services
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/cgi"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	github_client "github.com/RajVerma97/golang-vercel/backend/internal/client/github"
+	"github.com/RajVerma97/golang-vercel/backend/internal/config"
+	"github.com/RajVerma97/golang-vercel/backend/internal/constants"
+	"github.com/RajVerma97/golang-vercel/backend/internal/dto"
+	"github.com/RajVerma97/golang-vercel/backend/internal/encryption"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// newTestGitAuthService stores a project and returns a git auth service that encrypts its credentials
+func newTestGitAuthService(t *testing.T, githubClient *github_client.GitHubClient) (*GitAuthService, *dto.Project) {
+	t.Helper()
+	redisClient := newTestRedis(t)
+	cipher, err := encryption.NewCipher(base64.StdEncoding.EncodeToString([]byte(strings.Repeat("k", 32))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	projectService := NewProjectService(&ProjectServiceConfig{RedisClient: redisClient})
+	project := &dto.Project{Name: "app", RepoUrl: "https://github.com/acme/app"}
+	if err := projectService.Create(context.Background(), project); err != nil {
+		t.Fatal(err)
+	}
+	return NewGitAuthService(&GitAuthServiceConfig{
+		RedisClient:    redisClient,
+		GitHubClient:   githubClient,
+		ProjectService: projectService,
+		Cipher:         cipher,
+	}), project
+}
+
+// newTestGitService clones without a mirror, with credentials from gitAuth
+func newTestGitService(t *testing.T, gitAuth *GitAuthService) *GitService {
+	t.Helper()
+	return NewGitService(&GitServiceConfig{
+		GitAuthService: gitAuth,
+		TimeoutsConfig: &config.TimeoutsConfig{Clone: time.Minute},
+		MirrorConfig:   &config.GitMirrorConfig{},
+		StorageConfig:  &config.StorageConfig{DataDir: t.TempDir()},
+	})
+}
+
+// newTestHTTPRemote serves the repositories under root over git's smart http protocol,
+// only to requests that authenticate as username with password
+func newTestHTTPRemote(t *testing.T, root, username, password string) *httptest.Server {
+	t.Helper()
+	out, err := exec.Command("git", "--exec-path").Output()
+	if err != nil {
+		t.Skip("git is not installed")
+	}
+	backend := filepath.Join(strings.TrimSpace(string(out)), "git-http-backend")
+	if _, err := os.Stat(backend); err != nil {
+		t.Skip("git-http-backend is not installed")
+	}
+	handler := &cgi.Handler{Path: backend, Env: []string{"GIT_PROJECT_ROOT=" + root, "GIT_HTTP_EXPORT_ALL=1"}}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, pass, ok := r.BasicAuth(); !ok || user != username || pass != password {
+			w.Header().Set("WWW-Authenticate", `Basic realm="git"`)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		handler.ServeHTTP(w, r)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// newTestSSHRemote serves the repositories under root over ssh to clients holding authorized.
+// It returns the server's address and host key.
+func newTestSSHRemote(t *testing.T, root string, authorized ssh.PublicKey) (string, ssh.PublicKey) {
+	t.Helper()
+	_, hostKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := ssh.NewSignerFromKey(hostKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	serverConfig := &ssh.ServerConfig{
+		PublicKeyCallback: func(conn ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+			if string(key.Marshal()) != string(authorized.Marshal()) {
+				return nil, errors.New("unknown key")
+			}
+			return nil, nil
+		},
+	}
+	serverConfig.AddHostKey(signer)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go serveTestSSH(conn, serverConfig, root)
+		}
+	}()
+	return listener.Addr().String(), signer.PublicKey()
+}
+
+// serveTestSSH runs the git-upload-pack a client asks for
+func serveTestSSH(conn net.Conn, serverConfig *ssh.ServerConfig, root string) {
+	defer conn.Close()
+	_, channels, requests, err := ssh.NewServerConn(conn, serverConfig)
+	if err != nil {
+		return
+	}
+	go ssh.DiscardRequests(requests)
+	for newChannel := range channels {
+		if newChannel.ChannelType() != "session" {
+			newChannel.Reject(ssh.UnknownChannelType, "session only")
+			continue
+		}
+		channel, requests, err := newChannel.Accept()
+		if err != nil {
+			return
+		}
+		go func() {
+			defer channel.Close()
+			for request := range requests {
+				var payload struct{ Command string }
+				if request.Type != "exec" || ssh.Unmarshal(request.Payload, &payload) != nil {
+					request.Reply(false, nil)
+					continue
+				}
+				request.Reply(true, nil)
+				// git asks for git-upload-pack '/path'
+				service, path, _ := strings.Cut(payload.Command, " ")
+				cmd := exec.Command(service, filepath.Join(root, strings.Trim(path, "'")))
+				cmd.Stdin, cmd.Stdout, cmd.Stderr = channel, channel, channel.Stderr()
+				status := make([]byte, 4)
+				if err := cmd.Run(); err != nil {
+					binary.BigEndian.PutUint32(status, 1)
+				}
+				channel.SendRequest("exit-status", false, status)
+				return
+			}
+		}()
+	}
+}
+
+// assertNoSecret fails when secret was written anywhere under dir
+func assertNoSecret(t *testing.T, dir, secret string) {
+	t.Helper()
+	filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		if content, err := os.ReadFile(path); err == nil && strings.Contains(string(content), secret) {
+			t.Errorf("%s holds the secret", path)
+		}
+		return nil
+	})
+}
+
+func TestGitServiceCloneWithToken(t *testing.T) {
+	origin, _ := newTestOrigin(t, "1")
+	main := gitCmd(t, origin, "rev-parse", "main")
+	remote := newTestHTTPRemote(t, filepath.Dir(origin), "deploy-bot", "s3cret-token")
+	repoURL := remote.URL + "/" + filepath.Base(origin)
+
+	tests := []struct {
+		name    string
+		token   string
+		wantErr bool
+	}{
+		{name: "valid token", token: "s3cret-token"},
+		{name: "wrong token", token: "wrong-token", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gitAuth, project := newTestGitAuthService(t, nil)
+			project.RepoUrl = repoURL
+			if err := gitAuth.Configure(context.Background(), project, constants.GitAuthMethodToken, tt.token, "deploy-bot", "", false); err != nil {
+				t.Fatal(err)
+			}
+			workspace := t.TempDir()
+			err := newTestGitService(t, gitAuth).CloneRepository(context.Background(), project, &dto.Build{RepoUrl: repoURL}, workspace)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("CloneRepository() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr {
+				if got := gitCmd(t, workspace, "rev-parse", "HEAD"); got != main {
+					t.Errorf("checked out %s, want the remote's HEAD %s", got, main)
+				}
+			}
+			assertNoSecret(t, workspace, tt.token)
+		})
+	}
+}
+
+func TestGitServiceCloneWithGitHubApp(t *testing.T) {
+	githubClient, _ := newTestGitHubClient(t)
+	origin, _ := newTestOrigin(t, "1")
+	// installation tokens authenticate as x-access-token
+	remote := newTestHTTPRemote(t, filepath.Dir(origin), defaultTokenUsername, "installation-token")
+	repoURL := remote.URL + "/" + filepath.Base(origin)
+
+	gitAuth, project := newTestGitAuthService(t, githubClient)
+	project.RepoUrl = repoURL
+	if err := gitAuth.Configure(context.Background(), project, constants.GitAuthMethodGitHubApp, "", "", "", false); err != nil {
+		t.Fatal(err)
+	}
+	workspace := t.TempDir()
+	if err := newTestGitService(t, gitAuth).CloneRepository(context.Background(), project, &dto.Build{RepoUrl: repoURL}, workspace); err != nil {
+		t.Fatalf("CloneRepository() error = %v", err)
+	}
+	assertNoSecret(t, workspace, "installation-token")
+}
+
+func TestGitServiceCloneWithDeployKey(t *testing.T) {
+	if _, err := exec.LookPath("ssh"); err != nil {
+		t.Skip("ssh is not installed")
+	}
+	origin, _ := newTestOrigin(t, "1")
+
+	tests := []struct {
+		name    string
+		pinned  bool
+		wantErr bool
+	}{
+		{name: "pinned host", pinned: true},
+		// the key is never offered to a host whose key is unknown
+		{name: "unknown host", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			keyDirs := t.TempDir()
+			t.Setenv("TMPDIR", keyDirs)
+			gitAuth, project := newTestGitAuthService(t, nil)
+			if err := gitAuth.Configure(context.Background(), project, constants.GitAuthMethodDeployKey, "", "", "", false); err != nil {
+				t.Fatal(err)
+			}
+			deployKey, _, _, _, err := ssh.ParseAuthorizedKey([]byte(project.GitAuth.PublicKey))
+			if err != nil {
+				t.Fatalf("public key %q does not parse: %v", project.GitAuth.PublicKey, err)
+			}
+			addr, hostKey := newTestSSHRemote(t, filepath.Dir(origin), deployKey)
+			if tt.pinned {
+				line := knownhosts.Line([]string{knownhosts.Normalize(addr)}, hostKey)
+				if err := gitAuth.Configure(context.Background(), project, constants.GitAuthMethodDeployKey, "", "", line, false); err != nil {
+					t.Fatal(err)
+				}
+			}
+			repoURL := "ssh://git@" + addr + "/" + filepath.Base(origin)
+			project.RepoUrl = repoURL
+
+			workspace := t.TempDir()
+			err = newTestGitService(t, gitAuth).CloneRepository(context.Background(), project, &dto.Build{RepoUrl: repoURL}, workspace)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("CloneRepository() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			assertNoSecret(t, workspace, "PRIVATE KEY")
+			// the key is removed once the clone is done
+			if entries, _ := os.ReadDir(keyDirs); len(entries) != 0 {
+				t.Errorf("left %d key dirs behind", len(entries))
+			}
+		})
+	}
+}
+
+func TestGitAuthServiceConfigure(t *testing.T) {
+	ctx := context.Background()
+	// a personal access token cannot mint installation tokens
+	githubClient, err := github_client.NewGitHubClient(&config.GitHubConfig{Token: "personal-token"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	gitAuth, project := newTestGitAuthService(t, githubClient)
+
+	if err := gitAuth.Configure(ctx, project, constants.GitAuthMethodDeployKey, "", "", "", false); err != nil {
+		t.Fatal(err)
+	}
+	publicKey := project.GitAuth.PublicKey
+	if _, _, _, _, err := ssh.ParseAuthorizedKey([]byte(publicKey)); err != nil {
+		t.Fatalf("public key %q does not parse: %v", publicKey, err)
+	}
+	var secret gitSecret
+	if _, err := gitAuth.RedisClient.GetJSON(ctx, gitSecretKey(project.ID), &secret); err != nil {
+		t.Fatal(err)
+	}
+	if secret.PrivateKey == "" || strings.Contains(secret.PrivateKey, "PRIVATE KEY") {
+		t.Errorf("stored private key %q, want it encrypted", secret.PrivateKey)
+	}
+
+	// the key survives reconfiguring, until it is rotated
+	if err := gitAuth.Configure(ctx, project, constants.GitAuthMethodDeployKey, "", "", "", false); err != nil {
+		t.Fatal(err)
+	}
+	if project.GitAuth.PublicKey != publicKey {
+		t.Error("reconfiguring the deploy key replaced it")
+	}
+	if err := gitAuth.Configure(ctx, project, constants.GitAuthMethodDeployKey, "", "", "", true); err != nil {
+		t.Fatal(err)
+	}
+	if project.GitAuth.PublicKey == publicKey {
+		t.Error("rotating the deploy key kept it")
+	}
+
+	if err := gitAuth.Configure(ctx, project, constants.GitAuthMethodDeployKey, "", "", "not a host key", false); !errors.Is(err, ErrInvalidKnownHosts) {
+		t.Errorf("Configure() with broken known_hosts = %v, want %v", err, ErrInvalidKnownHosts)
+	}
+	if err := gitAuth.Configure(ctx, project, constants.GitAuthMethodToken, "", "", "", false); !errors.Is(err, ErrGitTokenRequired) {
+		t.Errorf("Configure() without a token = %v, want %v", err, ErrGitTokenRequired)
+	}
+	if err := gitAuth.Configure(ctx, project, constants.GitAuthMethodGitHubApp, "", "", "", false); !errors.Is(err, ErrGitHubAppNotConfigured) {
+		t.Errorf("Configure() without an app = %v, want %v", err, ErrGitHubAppNotConfigured)
+	}
+
+	if err := gitAuth.Configure(ctx, project, constants.GitAuthMethodToken, "s3cret-token", "", "", false); err != nil {
+		t.Fatal(err)
+	}
+	if project.GitAuth.Username != defaultTokenUsername || project.GitAuth.PublicKey != "" {
+		t.Errorf("git auth = %+v, want the default username and no deploy key", project.GitAuth)
+	}
+	if err := gitAuth.Configure(ctx, project, constants.GitAuthMethodNone, "", "", "", false); err != nil {
+		t.Fatal(err)
+	}
+	if found, err := gitAuth.RedisClient.GetJSON(ctx, gitSecretKey(project.ID), &secret); err != nil || found {
+		t.Errorf("secret found = %v, err = %v after switching to no auth, want it deleted", found, err)
+	}
+}
+
+func TestGitURLs(t *testing.T) {
+	tests := []struct {
+		url, wantSSH, wantHTTPS string
+	}{
+		{url: "https://github.com/acme/app", wantSSH: "git@github.com:acme/app.git", wantHTTPS: "https://github.com/acme/app"},
+		{url: "https://github.com/acme/app.git", wantSSH: "git@github.com:acme/app.git", wantHTTPS: "https://github.com/acme/app.git"},
+		{url: "git@github.com:acme/app.git", wantSSH: "git@github.com:acme/app.git", wantHTTPS: "https://github.com/acme/app.git"},
+		{url: "ssh://git@gitlab.com/acme/app.git", wantSSH: "ssh://git@gitlab.com/acme/app.git", wantHTTPS: "https://gitlab.com/acme/app.git"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.url, func(t *testing.T) {
+			if got := sshURL(tt.url); got != tt.wantSSH {
+				t.Errorf("sshURL() = %q, want %q", got, tt.wantSSH)
+			}
+			if got := httpsURL(tt.url); got != tt.wantHTTPS {
+				t.Errorf("httpsURL() = %q, want %q", got, tt.wantHTTPS)
+			}
+		})
+	}
+}
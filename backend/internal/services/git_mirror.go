@@ -0,0 +1,266 @@
+package services
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/RajVerma97/golang-vercel/backend/internal/dto"
+	"github.com/RajVerma97/golang-vercel/backend/internal/logger"
+	"go.uber.org/zap"
+)
+
+// defaultHeadRef keeps the remote's HEAD in mirrors, so builds without a branch get the default branch
+const defaultHeadRef = "refs/platform/default"
+
+// gitMirror is a bare repository shared by every build of one repository URL.
+// Updating it takes an exclusive flock, checking out from it a shared one,
+// so workers in any process never read a mirror that is being fetched or evicted.
+type gitMirror struct {
+	path string
+	lock *os.File
+}
+
+// mirrorName is the same for every URL of a repository, so switching a project between https
+// and ssh credentials keeps its mirror. URLs carry no credentials, those are passed to git apart.
+func mirrorName(repoURL string) string {
+	sum := sha256.Sum256([]byte(repositoryIdentity(repoURL)))
+	return hex.EncodeToString(sum[:8]) + ".git"
+}
+
+// openMirror locks the mirror of repoURL exclusively, creating its directory when needed
+func (a *GitService) openMirror(repoURL string) (*gitMirror, error) {
+	dir := a.storageConfig.MirrorsDir()
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create mirrors dir: %w", err)
+	}
+	path := filepath.Join(dir, mirrorName(repoURL))
+	for {
+		lock, err := lockFile(path+".lock", syscall.LOCK_EX)
+		if err != nil {
+			return nil, err
+		}
+		if lock != nil {
+			return &gitMirror{path: path, lock: lock}, nil
+		}
+	}
+}
+
+// lockFile flocks path, creating it when needed. It returns nil without an error when the file
+// was removed while it waited for the lock, the caller has to lock the new file instead.
+// Mirrors and build caches remove their lock files along with them.
+func lockFile(path string, how int) (*os.File, error) {
+	lock, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open lock %s: %w", path, err)
+	}
+	if err := syscall.Flock(int(lock.Fd()), how); err != nil {
+		lock.Close()
+		if errors.Is(err, syscall.EWOULDBLOCK) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to lock %s: %w", path, err)
+	}
+	locked, err := lock.Stat()
+	if err != nil {
+		lock.Close()
+		return nil, fmt.Errorf("failed to stat lock %s: %w", path, err)
+	}
+	if current, err := os.Stat(path); err != nil || !os.SameFile(locked, current) {
+		lock.Close()
+		return nil, nil
+	}
+	return lock, nil
+}
+
+// mirrorSizePath is the mirror's entry in the size index, eviction reads it instead of walking the mirror
+func mirrorSizePath(path string) string {
+	return strings.TrimSuffix(path, ".git") + ".size"
+}
+
+// recordSize updates the size index from git's own object count, which is cheap on big repositories
+func (m *gitMirror) recordSize(bare *gitRunner) error {
+	out, err := bare.command("count-objects", "-v").Output()
+	if err != nil {
+		return fmt.Errorf("failed to count mirror objects: %w", err)
+	}
+	var kib int64
+	for _, line := range strings.Split(string(out), "\n") {
+		key, value, _ := strings.Cut(line, ": ")
+		if key == "size" || key == "size-pack" || key == "size-garbage" {
+			n, _ := strconv.ParseInt(strings.TrimSpace(value), 10, 64)
+			kib += n
+		}
+	}
+	return os.WriteFile(mirrorSizePath(m.path), []byte(strconv.FormatInt(kib*1024, 10)), 0644)
+}
+
+// mirrorSize reads the mirror at path's size from the index. Mirrors without an entry
+// are measured once and indexed.
+func mirrorSize(path string) int64 {
+	sizePath := mirrorSizePath(path)
+	if data, err := os.ReadFile(sizePath); err == nil {
+		if size, err := strconv.ParseInt(strings.TrimSpace(string(data)), 10, 64); err == nil {
+			return size
+		}
+	}
+	size := dirSize(path)
+	os.WriteFile(sizePath, []byte(strconv.FormatInt(size, 10)), 0644)
+	return size
+}
+
+// shared downgrades the lock once the mirror is up to date so other builds can check out concurrently
+func (m *gitMirror) shared() error {
+	return syscall.Flock(int(m.lock.Fd()), syscall.LOCK_SH)
+}
+
+func (m *gitMirror) Close() error {
+	syscall.Flock(int(m.lock.Fd()), syscall.LOCK_UN)
+	return m.lock.Close()
+}
+
+// fetchFromMirror brings the mirror up to date incrementally, then makes a depth 1 checkout from it
+func (a *GitService) fetchFromMirror(git *gitRunner, build *dto.Build) error {
+	mirror, err := a.openMirror(build.RepoUrl)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		mirror.Close()
+		a.evictMirrors(mirror.path)
+	}()
+
+	bare := git.in(mirror.path)
+	if _, err := os.Stat(filepath.Join(mirror.path, "HEAD")); os.IsNotExist(err) {
+		logger.Info("Creating git mirror", zap.String("path", mirror.path))
+		if err := os.MkdirAll(mirror.path, 0755); err != nil {
+			return fmt.Errorf("failed to create mirror: %w", err)
+		}
+		if err := bare.run("init", "--bare", "--quiet"); err != nil {
+			return fmt.Errorf("failed to init mirror: %w", err)
+		}
+	}
+	if err := bare.run("fetch", "--prune", "--no-tags", git.creds.URL, "+refs/heads/*:refs/heads/*", "+HEAD:"+defaultHeadRef); err != nil {
+		return fmt.Errorf("failed to update mirror: %w", err)
+	}
+	ref := buildRef(build)
+	if ref == "HEAD" {
+		ref = defaultHeadRef
+	}
+	// commits that are on no branch, such as pull request heads, are fetched on their own
+	missing := func() bool {
+		return build.CommitHash != nil && *build.CommitHash != "" && bare.command("cat-file", "-e", ref+"^{commit}").Run() != nil
+	}
+	if build.PullRequest != nil && missing() {
+		args := append([]string{"fetch", "--no-tags", git.creds.URL}, pullRequestRefspecs(*build.PullRequest, "refs/platform/")...)
+		if err := bare.run(args...); err != nil {
+			return fmt.Errorf("failed to fetch pull request %d into mirror: %w", *build.PullRequest, err)
+		}
+	}
+	if missing() {
+		if err := bare.run("fetch", "--no-tags", git.creds.URL, ref); err != nil {
+			return fmt.Errorf("failed to fetch %s into mirror: %w", ref, err)
+		}
+	}
+	if err := mirror.recordSize(bare); err != nil {
+		logger.Warn("Failed to index git mirror size", zap.String("path", mirror.path), zap.Error(err))
+	}
+	if err := mirror.shared(); err != nil {
+		return fmt.Errorf("failed to lock mirror: %w", err)
+	}
+	now := time.Now()
+	os.Chtimes(mirror.path, now, now)
+
+	if err := initWorkspace(git); err != nil {
+		return err
+	}
+	if err := git.run("fetch", "--depth=1", "--no-tags", "file://"+mirror.path, ref); err != nil {
+		return fmt.Errorf("failed to fetch %s from mirror: %w", ref, err)
+	}
+	if err := git.run("checkout", "--quiet", "--detach", "FETCH_HEAD"); err != nil {
+		return fmt.Errorf("failed to checkout %s: %w", ref, err)
+	}
+	return nil
+}
+
+// evictMirrors removes the least recently used mirrors until the cache fits its size bound.
+// Mirrors that are locked are in use and skipped, as is keep.
+func (a *GitService) evictMirrors(keep string) {
+	dir := a.storageConfig.MirrorsDir()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		logger.Warn("Failed to list git mirrors", zap.Error(err))
+		return
+	}
+
+	type mirrorUsage struct {
+		path     string
+		size     int64
+		lastUsed time.Time
+	}
+	var mirrors []mirrorUsage
+	var total int64
+	for _, entry := range entries {
+		if !entry.IsDir() || !strings.HasSuffix(entry.Name(), ".git") {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		path := filepath.Join(dir, entry.Name())
+		size := mirrorSize(path)
+		total += size
+		mirrors = append(mirrors, mirrorUsage{path: path, size: size, lastUsed: info.ModTime()})
+	}
+	if total <= a.mirrorConfig.MaxBytes {
+		return
+	}
+
+	sort.Slice(mirrors, func(i, j int) bool { return mirrors[i].lastUsed.Before(mirrors[j].lastUsed) })
+	for _, mirror := range mirrors {
+		if total <= a.mirrorConfig.MaxBytes {
+			return
+		}
+		if mirror.path == keep {
+			continue
+		}
+		lock, err := lockFile(mirror.path+".lock", syscall.LOCK_EX|syscall.LOCK_NB)
+		if err != nil || lock == nil {
+			continue
+		}
+		if err := os.RemoveAll(mirror.path); err != nil {
+			logger.Warn("Failed to evict git mirror", zap.String("path", mirror.path), zap.Error(err))
+		} else {
+			logger.Info("Evicted git mirror", zap.String("path", mirror.path), zap.Int64("bytes", mirror.size))
+			total -= mirror.size
+			// builds waiting on the lock notice it is gone and lock a new one
+			os.Remove(mirrorSizePath(mirror.path))
+			os.Remove(mirror.path + ".lock")
+		}
+		lock.Close()
+	}
+}
+
+func dirSize(path string) int64 {
+	var size int64
+	filepath.WalkDir(path, func(_ string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if info, err := entry.Info(); err == nil && !entry.IsDir() {
+			size += info.Size()
+		}
+		return nil
+	})
+	return size
+}
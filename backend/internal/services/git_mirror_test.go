@@ -0,0 +1,146 @@
+package services
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strconv"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/RajVerma97/golang-vercel/backend/internal/config"
+	"github.com/RajVerma97/golang-vercel/backend/internal/dto"
+)
+
+func newTestMirrorService(t *testing.T, maxBytes int64) *GitService {
+	t.Helper()
+	return NewGitService(&GitServiceConfig{
+		GitAuthService: NewGitAuthService(&GitAuthServiceConfig{}),
+		TimeoutsConfig: &config.TimeoutsConfig{Clone: time.Minute},
+		MirrorConfig:   &config.GitMirrorConfig{Enabled: true, MaxBytes: maxBytes},
+		StorageConfig:  &config.StorageConfig{DataDir: t.TempDir()},
+	})
+}
+
+func TestMirrorName(t *testing.T) {
+	name := mirrorName("https://github.com/acme/app")
+	// the forms credentials rewrite the URL into share the mirror
+	for _, repoURL := range []string{"https://github.com/acme/app.git", "git@github.com:acme/app.git", "ssh://git@github.com/acme/app.git", "https://GitHub.com/acme/app/"} {
+		if got := mirrorName(repoURL); got != name {
+			t.Errorf("mirrorName(%s) = %s, want %s", repoURL, got, name)
+		}
+	}
+	if mirrorName("https://github.com/acme/other") == name {
+		t.Error("two repositories share a mirror")
+	}
+}
+
+func TestGitServiceMirrorUpdates(t *testing.T) {
+	origin, _ := newTestOrigin(t, "1")
+	s := newTestMirrorService(t, 1<<30)
+	project := &dto.Project{ID: 1, RepoUrl: origin}
+	mirrorPath := filepath.Join(s.storageConfig.MirrorsDir(), mirrorName(origin))
+
+	for i := range 2 {
+		if i == 1 {
+			writeFile(t, filepath.Join(origin, "later.go"), "package main\n")
+			gitCmd(t, origin, "add", "-A")
+			gitCmd(t, origin, "commit", "--quiet", "-m", "later")
+		}
+		workspace := t.TempDir()
+		if err := s.CloneRepository(context.Background(), project, &dto.Build{RepoUrl: origin}, workspace); err != nil {
+			t.Fatalf("CloneRepository() error = %v", err)
+		}
+		tip := gitCmd(t, origin, "rev-parse", "main")
+		if got := gitCmd(t, workspace, "rev-parse", "HEAD"); got != tip {
+			t.Errorf("build %d checked out %s, want the new tip %s", i, got, tip)
+		}
+		if got := gitCmd(t, mirrorPath, "rev-parse", "refs/heads/main"); got != tip {
+			t.Errorf("mirror has main at %s after build %d, want %s", got, i, tip)
+		}
+	}
+	data, err := os.ReadFile(mirrorSizePath(mirrorPath))
+	if err != nil {
+		t.Fatalf("mirror has no size index: %v", err)
+	}
+	if size, err := strconv.ParseInt(string(data), 10, 64); err != nil || size <= 0 {
+		t.Errorf("indexed size %q, want the mirror's size", data)
+	}
+}
+
+func TestGitServiceEvictMirrors(t *testing.T) {
+	s := newTestMirrorService(t, 1000)
+	dir := s.storageConfig.MirrorsDir()
+	// the index is trusted over the mirrors' contents, which are empty here
+	mirror := func(name string, size int, age time.Duration) string {
+		path := filepath.Join(dir, name+".git")
+		if err := os.MkdirAll(path, 0755); err != nil {
+			t.Fatal(err)
+		}
+		writeFile(t, mirrorSizePath(path), strconv.Itoa(size))
+		writeFile(t, path+".lock", "")
+		used := time.Now().Add(-age)
+		if err := os.Chtimes(path, used, used); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	locked := mirror("locked", 400, 4*time.Hour)
+	oldest := mirror("oldest", 400, 3*time.Hour)
+	older := mirror("older", 400, 2*time.Hour)
+	current := mirror("current", 400, 3*time.Hour)
+	lock, err := lockFile(locked+".lock", syscall.LOCK_SH)
+	if err != nil || lock == nil {
+		t.Fatalf("lockFile() = %v, %v", lock, err)
+	}
+	defer lock.Close()
+
+	s.evictMirrors(current)
+
+	for path, wantEvicted := range map[string]bool{locked: false, oldest: true, older: true, current: false} {
+		for _, file := range []string{path, path + ".lock", mirrorSizePath(path)} {
+			_, err := os.Stat(file)
+			if evicted := os.IsNotExist(err); evicted != wantEvicted {
+				t.Errorf("%s evicted = %v, want %v", filepath.Base(file), evicted, wantEvicted)
+			}
+		}
+	}
+}
+
+func TestOpenMirrorAfterEviction(t *testing.T) {
+	s := newTestMirrorService(t, 1<<30)
+	first, err := s.openMirror("https://github.com/acme/app")
+	if err != nil {
+		t.Fatal(err)
+	}
+	opened := make(chan *gitMirror)
+	go func() {
+		second, err := s.openMirror("https://github.com/acme/app")
+		if err != nil {
+			t.Error(err)
+		}
+		opened <- second
+	}()
+
+	// evicted while the second build waits for the lock
+	time.Sleep(100 * time.Millisecond)
+	if err := os.Remove(first.path + ".lock"); err != nil {
+		t.Fatal(err)
+	}
+	first.Close()
+
+	second := <-opened
+	if second == nil {
+		return
+	}
+	defer second.Close()
+	locked, err := second.lock.Stat()
+	if err != nil {
+		t.Fatal(err)
+	}
+	current, err := os.Stat(second.path + ".lock")
+	if err != nil || !os.SameFile(locked, current) {
+		t.Error("locked the evicted lock file, want the one other builds lock")
+	}
+}
@@ -3,56 +3,242 @@ package services
 import (
 	"context"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
 
+	"github.com/RajVerma97/golang-vercel/backend/internal/config"
+	"github.com/RajVerma97/golang-vercel/backend/internal/constants"
 	"github.com/RajVerma97/golang-vercel/backend/internal/dto"
 	"github.com/RajVerma97/golang-vercel/backend/internal/logger"
 	"go.uber.org/zap"
 )
 
 type GitServiceConfig struct {
+	GitAuthService *GitAuthService
+	TimeoutsConfig *config.TimeoutsConfig
+	MirrorConfig   *config.GitMirrorConfig
+	StorageConfig  *config.StorageConfig
 }
 type GitService struct {
+	GitAuthService *GitAuthService
+	timeoutsConfig *config.TimeoutsConfig
+	mirrorConfig   *config.GitMirrorConfig
+	storageConfig  *config.StorageConfig
 }
 
 func NewGitService(config *GitServiceConfig) *GitService {
-	return &GitService{}
+	return &GitService{
+		GitAuthService: config.GitAuthService,
+		timeoutsConfig: config.TimeoutsConfig,
+		mirrorConfig:   config.MirrorConfig,
+		storageConfig:  config.StorageConfig,
+	}
 }
 
-func (a *GitService) CloneRepository(ctx context.Context, build *dto.Build, tempDirPath string) error {
-	// Execute: git clone <repo_url> <temp_dir>
-	args := []string{"clone"}
+// CloneRepository fetches just the build's commit into tempDirPath and records its metadata on the build.
+// git is killed when the clone phase deadline passes.
+func (a *GitService) CloneRepository(ctx context.Context, project *dto.Project, build *dto.Build, tempDirPath string) error {
+	timeout := a.timeoutsConfig.For(constants.BuildPhaseClone, project.Timeouts)
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
 
-	// If branch is provided, add "-b branchName"
-	if build.Branch != nil && *build.Branch != "" {
-		args = append(args, "-b", *build.Branch)
+	creds, err := a.GitAuthService.Credentials(ctx, project, build)
+	if err != nil {
+		return fmt.Errorf("failed to resolve git credentials: %w", err)
+	}
+	defer creds.Close()
+	defer logger.RegisterSecrets(creds.secrets...)()
+
+	// git echoes remote URLs, which may carry credentials
+	output := creds.Redactor().Writer(os.Stdout)
+	defer output.Close()
+	git := &gitRunner{ctx: ctx, creds: creds, dir: tempDirPath, output: output}
+
+	if err := a.checkout(git, build); err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return phaseTimedOut(constants.BuildPhaseClone, timeout)
+		}
+		logger.Error("Git fetch failed", err)
+		return err
+	}
+	if project.Submodules {
+		if err := git.run("submodule", "update", "--init", "--recursive", "--depth=1"); err != nil {
+			return a.cloneError(ctx, timeout, fmt.Errorf("failed to update submodules: %w", err))
+		}
+	}
+	if project.LFS {
+		if _, err := exec.LookPath("git-lfs"); err != nil {
+			return fmt.Errorf("project uses git lfs but git-lfs is not installed")
+		}
+		if err := git.run("lfs", "pull"); err != nil {
+			return a.cloneError(ctx, timeout, fmt.Errorf("failed to pull lfs objects: %w", err))
+		}
+	}
+
+	commit, err := git.commit()
+	if err != nil {
+		return fmt.Errorf("failed to read commit metadata: %w", err)
+	}
+	build.Commit = commit
+	build.CommitHash = &commit.SHA
+
+	logger.Debug("✅ Successfully cloned Repository", zap.Stringp("branch", build.Branch), zap.String("hash", commit.SHA))
+	return nil
+}
+
+// checkout populates the workspace from the mirror cache when it is enabled, falling back to the remote
+func (a *GitService) checkout(git *gitRunner, build *dto.Build) error {
+	if !a.mirrorConfig.Enabled {
+		return a.fetch(git, build)
 	}
+	err := a.fetchFromMirror(git, build)
+	if err == nil || git.ctx.Err() != nil {
+		return err
+	}
+	logger.Warn("Mirror checkout failed, fetching from the remote", zap.Error(err))
+	if err := os.RemoveAll(filepath.Join(git.dir, ".git")); err != nil {
+		return fmt.Errorf("failed to reset workspace: %w", err)
+	}
+	return a.fetch(git, build)
+}
 
-	// Add repo URL and destination
-	args = append(args, build.RepoUrl, tempDirPath)
+// initWorkspace creates an empty repository whose origin is the real remote,
+// which relative submodule URLs and git lfs resolve against
+func initWorkspace(git *gitRunner) error {
+	if err := git.run("init", "--quiet"); err != nil {
+		return fmt.Errorf("failed to git init: %w", err)
+	}
+	if err := git.run("remote", "add", "origin", git.creds.URL); err != nil {
+		return fmt.Errorf("failed to add remote: %w", err)
+	}
+	// lfs objects are pulled separately, and only when the project asks for them
+	git.env = []string{"GIT_LFS_SKIP_SMUDGE=1"}
+	return nil
+}
 
-	cloneCmd := exec.CommandContext(ctx, "git", args...)
-	cloneCmd.Stdout = os.Stdout
-	cloneCmd.Stderr = os.Stderr
-	if err := cloneCmd.Run(); err != nil {
-		logger.Error("Git clone failed", err)
-		return fmt.Errorf("failed to git clone:%w", err)
+// pullRequestRefspecs fetch the refs of one pull request into dst, commits from forks are on no branch
+// of the repository. GitHub and Gitea, GitLab and Bitbucket Server each keep them under their own refs,
+// patterns that match nothing fetch nothing.
+func pullRequestRefspecs(number int, dst string) []string {
+	return []string{
+		fmt.Sprintf("+refs/pull/%d/*:%spr/%d/*", number, dst, number),
+		fmt.Sprintf("+refs/merge-requests/%d/*:%smr/%d/*", number, dst, number),
+		fmt.Sprintf("+refs/pull-requests/%d/*:%spull-requests/%d/*", number, dst, number),
 	}
-	// logger.Debug("Successfully cloned Repository")
+}
 
-	// 2. CHECKOUT COMMIT HASH(if provided)
+// buildRef is what the build checks out: its commit, the tip of its branch or the remote's HEAD
+func buildRef(build *dto.Build) string {
+	ref := "HEAD"
+	if build.Branch != nil && *build.Branch != "" {
+		ref = "refs/heads/" + *build.Branch
+	}
 	if build.CommitHash != nil && *build.CommitHash != "" {
-		checkoutCmd := exec.CommandContext(ctx, "git", "checkout", *build.CommitHash)
-		checkoutCmd.Dir = tempDirPath
-		checkoutCmd.Stdout = os.Stdout
-		checkoutCmd.Stderr = os.Stderr
-
-		if err := checkoutCmd.Run(); err != nil {
-			logger.Error("Git checkout failed", err)
-			return fmt.Errorf("failed to checkout commit %s: %w", *build.CommitHash, err)
+		ref = *build.CommitHash
+	}
+	return ref
+}
+
+// fetch checks out the build's commit, or the tip of its branch, with a depth 1 fetch.
+// Servers that refuse to serve a commit by its sha get a full fetch instead, of the branches
+// and, for pull request builds, the pull request heads.
+func (a *GitService) fetch(git *gitRunner, build *dto.Build) error {
+	ref := buildRef(build)
+	if err := initWorkspace(git); err != nil {
+		return err
+	}
+
+	if err := git.run("fetch", "--depth=1", "--no-tags", "origin", ref); err == nil {
+		if err := git.run("checkout", "--quiet", "--detach", "FETCH_HEAD"); err != nil {
+			return fmt.Errorf("failed to checkout %s: %w", ref, err)
+		}
+		return nil
+	} else if git.ctx.Err() != nil {
+		return err
+	}
+
+	logger.Warn("Shallow fetch failed, falling back to a full fetch", zap.String("ref", ref))
+	args := []string{"fetch", "--no-tags", "origin", "+refs/heads/*:refs/remotes/origin/*"}
+	if build.PullRequest != nil {
+		args = append(args, pullRequestRefspecs(*build.PullRequest, "refs/remotes/origin/")...)
+	}
+	if err := git.run(args...); err != nil {
+		return fmt.Errorf("failed to git fetch: %w", err)
+	}
+	target := ref
+	if build.CommitHash == nil || *build.CommitHash == "" {
+		target = "FETCH_HEAD"
+		if build.Branch != nil && *build.Branch != "" {
+			target = "refs/remotes/origin/" + *build.Branch
 		}
 	}
-	logger.Debug("✅ Successfully cloned Repository", zap.String("branch", *build.Branch), zap.String("hash", *build.CommitHash))
+	if err := git.run("checkout", "--quiet", "--detach", target); err != nil {
+		return fmt.Errorf("failed to checkout %s: %w", ref, err)
+	}
 	return nil
 }
+
+func (a *GitService) cloneError(ctx context.Context, timeout time.Duration, err error) error {
+	if ctx.Err() == context.DeadlineExceeded {
+		return phaseTimedOut(constants.BuildPhaseClone, timeout)
+	}
+	logger.Error("Git clone failed", err)
+	return err
+}
+
+// gitRunner runs git in dir with the credentials applied
+type gitRunner struct {
+	ctx    context.Context
+	creds  *GitCredentials
+	dir    string
+	env    []string
+	output io.Writer
+}
+
+// in returns a runner for another repository that shares the credentials and output
+func (g *gitRunner) in(dir string) *gitRunner {
+	return &gitRunner{ctx: g.ctx, creds: g.creds, dir: dir, output: g.output}
+}
+
+func (g *gitRunner) command(args ...string) *exec.Cmd {
+	cmd := exec.CommandContext(g.ctx, "git", append(append([]string{}, g.creds.Args...), args...)...)
+	cmd.Dir = g.dir
+	cmd.Env = append(append(os.Environ(), g.creds.Env...), g.env...)
+	return cmd
+}
+
+func (g *gitRunner) run(args ...string) error {
+	cmd := g.command(args...)
+	cmd.Stdout = g.output
+	cmd.Stderr = g.output
+	return cmd.Run()
+}
+
+// commit reads the metadata of the checked out commit
+func (g *gitRunner) commit() (*dto.CommitInfo, error) {
+	cmd := g.command("log", "-1", "--format=%H%x00%an%x00%ae%x00%cI%x00%B")
+	cmd.Stderr = g.output
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, err
+	}
+	fields := strings.SplitN(string(out), "\x00", 5)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("unexpected git log output")
+	}
+	committedAt, err := time.Parse(time.RFC3339, fields[3])
+	if err != nil {
+		return nil, fmt.Errorf("invalid commit date %q: %w", fields[3], err)
+	}
+	return &dto.CommitInfo{
+		SHA:         fields[0],
+		Author:      fields[1],
+		AuthorEmail: fields[2],
+		CommittedAt: committedAt,
+		Message:     strings.TrimSpace(fields[4]),
+	}, nil
+}
@@ -0,0 +1,264 @@
+package services
+
+import (
+	"context"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/RajVerma97/golang-vercel/backend/internal/config"
+	"github.com/RajVerma97/golang-vercel/backend/internal/constants"
+	"github.com/RajVerma97/golang-vercel/backend/internal/dto"
+)
+
+// gitCmd runs git in dir and returns its trimmed output
+func gitCmd(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	cmd.Env = append(cmd.Environ(),
+		"GIT_AUTHOR_NAME=Dev", "GIT_AUTHOR_EMAIL=dev@example.com",
+		"GIT_COMMITTER_NAME=Dev", "GIT_COMMITTER_EMAIL=dev@example.com",
+	)
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("git %s: %v\n%s", strings.Join(args, " "), err, out)
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// newTestOrigin creates a repository with a main branch and a pull request whose head is on no branch,
+// the way a pull request from a fork shows up in the base repository. It returns the head's sha.
+func newTestOrigin(t *testing.T, number string) (string, string) {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	origin := t.TempDir()
+	gitCmd(t, origin, "init", "--quiet", "--initial-branch=main")
+	writeFile(t, filepath.Join(origin, "main.go"), "package main\n")
+	gitCmd(t, origin, "add", "-A")
+	gitCmd(t, origin, "commit", "--quiet", "-m", "initial")
+	gitCmd(t, origin, "checkout", "--quiet", "-b", "fork-feature")
+	writeFile(t, filepath.Join(origin, "feature.go"), "package main\n")
+	gitCmd(t, origin, "add", "-A")
+	gitCmd(t, origin, "commit", "--quiet", "-m", "feature from a fork")
+	head := gitCmd(t, origin, "rev-parse", "HEAD")
+	gitCmd(t, origin, "update-ref", "refs/pull/"+number+"/head", head)
+	gitCmd(t, origin, "checkout", "--quiet", "main")
+	gitCmd(t, origin, "branch", "--quiet", "-D", "fork-feature")
+	return origin, head
+}
+
+func TestGitServiceClonePullRequest(t *testing.T) {
+	origin, head := newTestOrigin(t, "7")
+	for _, mirror := range []bool{false, true} {
+		t.Run(map[bool]string{false: "remote", true: "mirror"}[mirror], func(t *testing.T) {
+			storage := &config.StorageConfig{DataDir: t.TempDir()}
+			s := NewGitService(&GitServiceConfig{
+				GitAuthService: NewGitAuthService(&GitAuthServiceConfig{}),
+				TimeoutsConfig: &config.TimeoutsConfig{Clone: time.Minute},
+				MirrorConfig:   &config.GitMirrorConfig{Enabled: mirror, MaxBytes: 1 << 30},
+				StorageConfig:  storage,
+			})
+			number, branch, commit := 7, "fork-feature", head
+			build := &dto.Build{
+				RepoUrl:     origin,
+				HeadRepoUrl: "https://github.com/evil/app.git",
+				Branch:      &branch,
+				CommitHash:  &commit,
+				PullRequest: &number,
+			}
+			workspace := t.TempDir()
+			if err := s.CloneRepository(context.Background(), &dto.Project{ID: 1, RepoUrl: origin}, build, workspace); err != nil {
+				t.Fatalf("CloneRepository() error = %v", err)
+			}
+			if got := gitCmd(t, workspace, "rev-parse", "HEAD"); got != head {
+				t.Errorf("checked out %s, want the pull request head %s", got, head)
+			}
+			if got := gitCmd(t, workspace, "remote", "get-url", "origin"); got != origin {
+				t.Errorf("origin = %s, want the project's repository %s", got, origin)
+			}
+			if mirror {
+				mirrorPath := filepath.Join(storage.MirrorsDir(), mirrorName(origin))
+				if got := gitCmd(t, mirrorPath, "rev-parse", "refs/platform/pr/7/head"); got != head {
+					t.Errorf("mirror has pull request 7 at %s, want %s", got, head)
+				}
+			}
+		})
+	}
+}
+
+func TestPullRequestRefspecs(t *testing.T) {
+	origin, head := newTestOrigin(t, "12")
+	gitCmd(t, origin, "update-ref", "refs/pull/120/head", "main")
+	repo := t.TempDir()
+	gitCmd(t, repo, "init", "--quiet", "--bare")
+	// refs of other pull requests and of other providers are left alone
+	gitCmd(t, repo, append([]string{"fetch", "--quiet", origin}, pullRequestRefspecs(12, "refs/remotes/origin/")...)...)
+
+	refs := gitCmd(t, repo, "for-each-ref", "--format=%(refname) %(objectname)")
+	if want := "refs/remotes/origin/pr/12/head " + head; refs != want {
+		t.Errorf("fetched refs %q, want %q", refs, want)
+	}
+}
+
+func TestGitServiceCloneTimeout(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	// a remote that accepts the connection and never answers
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			t.Cleanup(func() { conn.Close() })
+		}
+	}()
+	s := NewGitService(&GitServiceConfig{
+		GitAuthService: NewGitAuthService(&GitAuthServiceConfig{}),
+		TimeoutsConfig: &config.TimeoutsConfig{Clone: time.Minute},
+		MirrorConfig:   &config.GitMirrorConfig{},
+		StorageConfig:  &config.StorageConfig{DataDir: t.TempDir()},
+	})
+	repoURL := "git://" + listener.Addr().String() + "/app.git"
+	// the project's override wins over the default
+	project := &dto.Project{ID: 1, RepoUrl: repoURL, Timeouts: map[constants.BuildPhase]int{constants.BuildPhaseClone: 1}}
+
+	started := time.Now()
+	err = s.CloneRepository(context.Background(), project, &dto.Build{RepoUrl: repoURL}, t.TempDir())
+	if FailureCodeOf(err) != constants.FailureCodeTimeout {
+		t.Fatalf("CloneRepository() = %v, want a TIMEOUT failure", err)
+	}
+	if err.Error() != "clone phase timed out after 1s" {
+		t.Errorf("CloneRepository() = %q, want it to name the clone phase", err)
+	}
+	if elapsed := time.Since(started); elapsed > 10*time.Second {
+		t.Errorf("CloneRepository() took %s, want git killed at the deadline", elapsed)
+	}
+}
+
+func TestGitServiceCloneRepository(t *testing.T) {
+	origin, _ := newTestOrigin(t, "1")
+	first := gitCmd(t, origin, "rev-parse", "main")
+	// the branch moves on after the build was queued
+	writeFile(t, filepath.Join(origin, "later.go"), "package main\n")
+	gitCmd(t, origin, "add", "-A")
+	gitCmd(t, origin, "commit", "--quiet", "-m", "later\n\nwith a body")
+	tip := gitCmd(t, origin, "rev-parse", "main")
+
+	tests := []struct {
+		name     string
+		branch   string
+		commit   string
+		protocol string
+		want     string
+	}{
+		{name: "commit", branch: "main", commit: first, want: first},
+		{name: "tip of the branch", branch: "main", want: tip},
+		{name: "remote head", want: tip},
+		// servers speaking protocol v0 refuse commits that are not a ref's tip, a full fetch finds them
+		{name: "commit the server will not serve", branch: "main", commit: first, protocol: "0", want: first},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.protocol != "" {
+				t.Setenv("GIT_CONFIG_COUNT", "1")
+				t.Setenv("GIT_CONFIG_KEY_0", "protocol.version")
+				t.Setenv("GIT_CONFIG_VALUE_0", tt.protocol)
+			}
+			build := &dto.Build{RepoUrl: "file://" + origin}
+			if tt.branch != "" {
+				build.Branch = &tt.branch
+			}
+			if tt.commit != "" {
+				build.CommitHash = &tt.commit
+			}
+			workspace := t.TempDir()
+			s := newTestGitService(t, NewGitAuthService(&GitAuthServiceConfig{}))
+			if err := s.CloneRepository(context.Background(), &dto.Project{ID: 1, RepoUrl: build.RepoUrl}, build, workspace); err != nil {
+				t.Fatalf("CloneRepository() error = %v", err)
+			}
+			if got := gitCmd(t, workspace, "rev-parse", "HEAD"); got != tt.want {
+				t.Errorf("checked out %s, want %s", got, tt.want)
+			}
+			if build.CommitHash == nil || *build.CommitHash != tt.want || build.Commit == nil || build.Commit.SHA != tt.want {
+				t.Errorf("build records commit %v, want %s", build.Commit, tt.want)
+			}
+			// only the fallback fetches the whole history
+			if got, want := gitCmd(t, workspace, "rev-parse", "--is-shallow-repository"), strconv.FormatBool(tt.protocol == ""); got != want {
+				t.Errorf("shallow = %s, want %s", got, want)
+			}
+		})
+	}
+}
+
+func TestGitServiceCloneRecordsCommit(t *testing.T) {
+	origin, _ := newTestOrigin(t, "1")
+	build := &dto.Build{RepoUrl: origin}
+	s := newTestGitService(t, NewGitAuthService(&GitAuthServiceConfig{}))
+	if err := s.CloneRepository(context.Background(), &dto.Project{ID: 1, RepoUrl: origin}, build, t.TempDir()); err != nil {
+		t.Fatalf("CloneRepository() error = %v", err)
+	}
+	commit := build.Commit
+	if commit == nil {
+		t.Fatal("no commit recorded on the build")
+	}
+	if commit.SHA != gitCmd(t, origin, "rev-parse", "main") || commit.Author != "Dev" || commit.AuthorEmail != "dev@example.com" || commit.Message != "initial" {
+		t.Errorf("commit = %+v, want main's sha, author and message", commit)
+	}
+	if commit.CommittedAt.IsZero() {
+		t.Error("commit date was not recorded")
+	}
+}
+
+func TestGitServiceCloneSubmodules(t *testing.T) {
+	// submodules are cloned from local paths, which git refuses by default
+	t.Setenv("GIT_CONFIG_COUNT", "1")
+	t.Setenv("GIT_CONFIG_KEY_0", "protocol.file.allow")
+	t.Setenv("GIT_CONFIG_VALUE_0", "always")
+	library, _ := newTestOrigin(t, "1")
+	origin, _ := newTestOrigin(t, "1")
+	gitCmd(t, origin, "submodule", "add", "--quiet", library, "lib")
+	gitCmd(t, origin, "commit", "--quiet", "-m", "add lib")
+
+	for _, submodules := range []bool{false, true} {
+		t.Run(map[bool]string{false: "off", true: "on"}[submodules], func(t *testing.T) {
+			workspace := t.TempDir()
+			s := newTestGitService(t, NewGitAuthService(&GitAuthServiceConfig{}))
+			project := &dto.Project{ID: 1, RepoUrl: origin, Submodules: submodules}
+			if err := s.CloneRepository(context.Background(), project, &dto.Build{RepoUrl: origin}, workspace); err != nil {
+				t.Fatalf("CloneRepository() error = %v", err)
+			}
+			_, err := os.Stat(filepath.Join(workspace, "lib", "main.go"))
+			if (err == nil) != submodules {
+				t.Errorf("submodule checked out = %v, want %v", err == nil, submodules)
+			}
+		})
+	}
+}
+
+func TestGitServiceCloneLFSWithoutGitLFS(t *testing.T) {
+	if _, err := exec.LookPath("git-lfs"); err == nil {
+		t.Skip("git-lfs is installed")
+	}
+	origin, _ := newTestOrigin(t, "1")
+	s := newTestGitService(t, NewGitAuthService(&GitAuthServiceConfig{}))
+	project := &dto.Project{ID: 1, RepoUrl: origin, LFS: true}
+	err := s.CloneRepository(context.Background(), project, &dto.Build{RepoUrl: origin}, t.TempDir())
+	if err == nil || !strings.Contains(err.Error(), "git-lfs is not installed") {
+		t.Errorf("CloneRepository() = %v, want it to say git-lfs is missing", err)
+	}
+}
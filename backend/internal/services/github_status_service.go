@@ -0,0 +1,182 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	github_client "github.com/RajVerma97/golang-vercel/backend/internal/client/github"
+	"github.com/RajVerma97/golang-vercel/backend/internal/config"
+	"github.com/RajVerma97/golang-vercel/backend/internal/constants"
+	"github.com/RajVerma97/golang-vercel/backend/internal/dto"
+	"github.com/RajVerma97/golang-vercel/backend/internal/logger"
+	"go.uber.org/zap"
+)
+
+const (
+	githubStatusContext = "golang-vercel/deployment"
+	githubCheckRunName  = "Deployment"
+)
+
+// statusContext tells apart the statuses of the projects a monorepo push fans out to
+func statusContext(build *dto.Build) string {
+	if build.ProjectID == 0 {
+		return githubStatusContext
+	}
+	return fmt.Sprintf("%s/project-%d", githubStatusContext, build.ProjectID)
+}
+
+func checkRunName(build *dto.Build) string {
+	if build.ProjectID == 0 {
+		return githubCheckRunName
+	}
+	return fmt.Sprintf("%s (project-%d)", githubCheckRunName, build.ProjectID)
+}
+
+type GitHubStatusServiceConfig struct {
+	GitHubClient *github_client.GitHubClient
+	ServerConfig *config.ServerConfig
+}
+
+// GitHubStatusService reports the progress of webhook triggered builds back to GitHub.
+// Reporting is best effort: failures are logged and never fail the build.
+type GitHubStatusService struct {
+	GitHubClient *github_client.GitHubClient
+	serverConfig *config.ServerConfig
+}
+
+func NewGitHubStatusService(config *GitHubStatusServiceConfig) *GitHubStatusService {
+	return &GitHubStatusService{
+		GitHubClient: config.GitHubClient,
+		serverConfig: config.ServerConfig,
+	}
+}
+
+func (s *GitHubStatusService) shouldReport(build *dto.Build) bool {
+	return s.GitHubClient.Enabled() &&
+		build.Trigger == constants.BuildTriggerGitHub &&
+		build.GitHubRepository != nil &&
+		build.CommitHash != nil && *build.CommitHash != ""
+}
+
+func (s *GitHubStatusService) logsURL(build *dto.Build) string {
+	return fmt.Sprintf("%s/builds/%d/logs", s.serverConfig.PublicURL, build.ID)
+}
+
+func (s *GitHubStatusService) BuildStarted(ctx context.Context, build *dto.Build) {
+	if !s.shouldReport(build) {
+		return
+	}
+	repo, sha := *build.GitHubRepository, *build.CommitHash
+
+	s.createStatus(ctx, build, &github_client.CommitStatus{
+		State:       github_client.CommitStatePending,
+		TargetURL:   s.logsURL(build),
+		Description: "Building",
+		Context:     statusContext(build),
+	})
+
+	if !s.GitHubClient.UsesApp() {
+		return
+	}
+	run, err := s.GitHubClient.CreateCheckRun(ctx, repo, &github_client.CheckRun{
+		Name:       checkRunName(build),
+		HeadSHA:    sha,
+		Status:     github_client.CheckRunStatusInProgress,
+		DetailsURL: s.logsURL(build),
+		ExternalID: strconv.FormatUint(build.ID, 10),
+		Output: &github_client.CheckRunOutput{
+			Title:   "Building",
+			Summary: fmt.Sprintf("Build %d is in progress.", build.ID),
+		},
+	})
+	if err != nil {
+		logger.Error("failed to create check run", err, zap.Uint64("build_id", build.ID))
+		return
+	}
+	build.CheckRunID = &run.ID
+}
+
+func (s *GitHubStatusService) BuildFailed(ctx context.Context, build *dto.Build, reason string) {
+	if !s.shouldReport(build) {
+		return
+	}
+	s.createStatus(ctx, build, &github_client.CommitStatus{
+		State:       github_client.CommitStateFailure,
+		TargetURL:   s.logsURL(build),
+		Description: truncateDescription(reason),
+		Context:     statusContext(build),
+	})
+	s.completeCheckRun(ctx, build, github_client.CheckRunConclusionFailure, "Deployment failed",
+		fmt.Sprintf("Build %d failed: %s\n\n[View logs](%s)", build.ID, reason, s.logsURL(build)))
+}
+
+func (s *GitHubStatusService) DeploymentSucceeded(ctx context.Context, build *dto.Build, deployment *dto.Deployment, deploymentURL string) {
+	if !s.shouldReport(build) {
+		return
+	}
+	s.createStatus(ctx, build, &github_client.CommitStatus{
+		State:       github_client.CommitStateSuccess,
+		TargetURL:   deploymentURL,
+		Description: fmt.Sprintf("Deployed to %s", deployment.Environment),
+		Context:     statusContext(build),
+	})
+	s.completeCheckRun(ctx, build, github_client.CheckRunConclusionSuccess, "Deployment ready",
+		fmt.Sprintf("Deployed to %s: %s\n\n[View logs](%s)", deployment.Environment, deploymentURL, s.logsURL(build)))
+
+	if build.PullRequest == nil {
+		return
+	}
+	body := fmt.Sprintf("Preview for %s is ready: %s", shortCommit(*build.CommitHash), deploymentURL)
+	for _, alias := range deployment.Aliases {
+		if alias != deploymentURL {
+			body += fmt.Sprintf("\nLatest preview of this pull request: %s", alias)
+		}
+	}
+	if err := s.GitHubClient.CreateIssueComment(ctx, *build.GitHubRepository, *build.PullRequest, body); err != nil {
+		logger.Error("failed to comment on pull request", err, zap.Uint64("build_id", build.ID))
+	}
+}
+
+func (s *GitHubStatusService) createStatus(ctx context.Context, build *dto.Build, status *github_client.CommitStatus) {
+	if err := s.GitHubClient.CreateCommitStatus(ctx, *build.GitHubRepository, *build.CommitHash, status); err != nil {
+		logger.Error("failed to create commit status", err, zap.Uint64("build_id", build.ID), zap.String("state", status.State))
+	}
+}
+
+func (s *GitHubStatusService) completeCheckRun(ctx context.Context, build *dto.Build, conclusion, title, summary string) {
+	if build.CheckRunID == nil {
+		return
+	}
+	now := time.Now()
+	err := s.GitHubClient.UpdateCheckRun(ctx, *build.GitHubRepository, *build.CheckRunID, &github_client.CheckRun{
+		Status:      github_client.CheckRunStatusCompleted,
+		Conclusion:  conclusion,
+		CompletedAt: &now,
+		Output: &github_client.CheckRunOutput{
+			Title:   title,
+			Summary: summary,
+		},
+	})
+	if err != nil {
+		logger.Error("failed to update check run", err, zap.Uint64("build_id", build.ID))
+	}
+}
+
+// truncateDescription keeps commit status descriptions within GitHub's 140 character limit,
+// cutting between characters so multibyte ones are never split
+func truncateDescription(description string) string {
+	runes := []rune(description)
+	if len(runes) > 140 {
+		return string(runes[:137]) + "..."
+	}
+	return description
+}
+
+func shortCommit(commitHash string) string {
+	if len(commitHash) > 7 {
+		return commitHash[:7]
+	}
+	return commitHash
+}
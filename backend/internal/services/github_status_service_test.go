@@ -0,0 +1,204 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	github_client "github.com/RajVerma97/golang-vercel/backend/internal/client/github"
+	"github.com/RajVerma97/golang-vercel/backend/internal/config"
+	"github.com/RajVerma97/golang-vercel/backend/internal/constants"
+	"github.com/RajVerma97/golang-vercel/backend/internal/dto"
+)
+
+func TestStatusContext(t *testing.T) {
+	if got := statusContext(&dto.Build{}); got != githubStatusContext {
+		t.Errorf("statusContext() = %q without a project, want %q", got, githubStatusContext)
+	}
+	api, web := statusContext(&dto.Build{ProjectID: 1}), statusContext(&dto.Build{ProjectID: 2})
+	if api == web {
+		t.Errorf("statusContext() = %q for two projects, want them apart", api)
+	}
+	if checkRunName(&dto.Build{ProjectID: 1}) == checkRunName(&dto.Build{ProjectID: 2}) {
+		t.Error("checkRunName() is the same for two projects, want them apart")
+	}
+}
+
+func TestTruncateDescription(t *testing.T) {
+	tests := []struct {
+		name, description string
+		wantLen           int
+	}{
+		{name: "short", description: "Building", wantLen: 8},
+		{name: "exactly the limit", description: strings.Repeat("a", 140), wantLen: 140},
+		{name: "ascii", description: strings.Repeat("a", 200), wantLen: 140},
+		{name: "multibyte", description: strings.Repeat("é", 139) + "日本語", wantLen: 140},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := truncateDescription(tt.description)
+			if !utf8.ValidString(got) {
+				t.Errorf("truncateDescription() = %q, not valid utf-8", got)
+			}
+			if n := utf8.RuneCountInString(got); n != tt.wantLen {
+				t.Errorf("truncateDescription() has %d characters, want %d", n, tt.wantLen)
+			}
+		})
+	}
+}
+
+// githubRequest is a request received by the fake GitHub API
+type githubRequest struct {
+	method, path, authorization string
+	body                        map[string]any
+}
+
+// newTestGitHubClient runs a fake GitHub API for an app installed on every repository.
+// It returns the client and the API requests made through it, token exchanges left out.
+func newTestGitHubClient(t *testing.T) (*github_client.GitHubClient, func() []githubRequest) {
+	t.Helper()
+	var mu sync.Mutex
+	var received []githubRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/installation"):
+			json.NewEncoder(w).Encode(map[string]any{"id": 42})
+			return
+		case r.URL.Path == "/app/installations/42/access_tokens":
+			json.NewEncoder(w).Encode(map[string]any{"token": "installation-token", "expires_at": time.Now().Add(time.Hour)})
+			return
+		}
+		request := githubRequest{method: r.Method, path: r.URL.Path, authorization: r.Header.Get("Authorization")}
+		if err := json.NewDecoder(r.Body).Decode(&request.body); err != nil {
+			t.Errorf("%s %s: invalid body: %v", r.Method, r.URL.Path, err)
+		}
+		mu.Lock()
+		received = append(received, request)
+		mu.Unlock()
+		if r.Method == http.MethodPost && strings.HasSuffix(r.URL.Path, "/check-runs") {
+			json.NewEncoder(w).Encode(map[string]any{"id": 7})
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte("{}"))
+	}))
+	t.Cleanup(server.Close)
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyPath := filepath.Join(t.TempDir(), "app.pem")
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+	if err := os.WriteFile(keyPath, keyPEM, 0600); err != nil {
+		t.Fatal(err)
+	}
+	client, err := github_client.NewGitHubClient(&config.GitHubConfig{APIURL: server.URL, AppID: 1, AppPrivateKeyPath: keyPath})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client, func() []githubRequest {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]githubRequest(nil), received...)
+	}
+}
+
+func TestGitHubStatusServiceReporting(t *testing.T) {
+	client, received := newTestGitHubClient(t)
+	s := NewGitHubStatusService(&GitHubStatusServiceConfig{
+		GitHubClient: client,
+		ServerConfig: &config.ServerConfig{PublicURL: "https://api.example.com"},
+	})
+	ctx := context.Background()
+	repo, sha, number := "acme/app", "3f4d535aaaaaaaaa", 12
+	build := &dto.Build{
+		ID: 5, ProjectID: 1, Trigger: constants.BuildTriggerGitHub,
+		GitHubRepository: &repo, CommitHash: &sha, PullRequest: &number,
+	}
+
+	s.BuildStarted(ctx, build)
+	if build.CheckRunID == nil || *build.CheckRunID != 7 {
+		t.Fatalf("check run id = %v, want the created run's 7", build.CheckRunID)
+	}
+	s.DeploymentSucceeded(ctx, build, &dto.Deployment{
+		Environment: constants.DeploymentEnvironmentPreview,
+		Aliases:     []string{"http://app--git-feature.localhost:8080"},
+	}, "http://app--3f4d535.localhost:8080")
+
+	want := []struct {
+		method, path string
+		fields       map[string]any
+	}{
+		{http.MethodPost, "/repos/acme/app/statuses/" + sha, map[string]any{
+			"state": "pending", "context": "golang-vercel/deployment/project-1", "target_url": "https://api.example.com/builds/5/logs",
+		}},
+		{http.MethodPost, "/repos/acme/app/check-runs", map[string]any{
+			"name": "Deployment (project-1)", "head_sha": sha, "status": "in_progress", "external_id": "5",
+		}},
+		{http.MethodPost, "/repos/acme/app/statuses/" + sha, map[string]any{
+			"state": "success", "description": "Deployed to preview", "target_url": "http://app--3f4d535.localhost:8080",
+		}},
+		{http.MethodPatch, "/repos/acme/app/check-runs/7", map[string]any{"status": "completed", "conclusion": "success"}},
+		{http.MethodPost, "/repos/acme/app/issues/12/comments", map[string]any{
+			"body": "Preview for 3f4d535 is ready: http://app--3f4d535.localhost:8080\nLatest preview of this pull request: http://app--git-feature.localhost:8080",
+		}},
+	}
+	got := received()
+	if len(got) != len(want) {
+		t.Fatalf("got %d requests, want %d: %+v", len(got), len(want), got)
+	}
+	for i, request := range got {
+		if request.method != want[i].method || request.path != want[i].path {
+			t.Errorf("request %d = %s %s, want %s %s", i, request.method, request.path, want[i].method, want[i].path)
+		}
+		if request.authorization != "token installation-token" {
+			t.Errorf("request %d authorized with %q, want the installation token", i, request.authorization)
+		}
+		for field, value := range want[i].fields {
+			if request.body[field] != value {
+				t.Errorf("request %d: %s = %v, want %v", i, field, request.body[field], value)
+			}
+		}
+	}
+}
+
+func TestGitHubStatusServiceBuildFailed(t *testing.T) {
+	client, received := newTestGitHubClient(t)
+	s := NewGitHubStatusService(&GitHubStatusServiceConfig{
+		GitHubClient: client,
+		ServerConfig: &config.ServerConfig{PublicURL: "https://api.example.com"},
+	})
+	repo, sha := "acme/app", "3f4d535aaaaaaaaa"
+	build := &dto.Build{ID: 5, Trigger: constants.BuildTriggerGitHub, GitHubRepository: &repo, CommitHash: &sha}
+	reason := strings.Repeat("ü", 200)
+
+	s.BuildFailed(context.Background(), build, reason)
+	got := received()
+	// no check run was started, so there is none to complete
+	if len(got) != 1 {
+		t.Fatalf("got %d requests, want the commit status only: %+v", len(got), got)
+	}
+	description, _ := got[0].body["description"].(string)
+	if got[0].body["state"] != "failure" || description != truncateDescription(reason) {
+		t.Errorf("status = %v, want a failure with the truncated reason", got[0].body)
+	}
+
+	// builds that were not triggered by GitHub are not reported
+	s.BuildFailed(context.Background(), &dto.Build{ID: 6, GitHubRepository: &repo, CommitHash: &sha}, reason)
+	if n := len(received()); n != 1 {
+		t.Errorf("got %d requests after a build from another trigger, want 1", n)
+	}
+}
@@ -0,0 +1,112 @@
+package services
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/RajVerma97/golang-vercel/backend/internal/dto"
+)
+
+// moduleAuthMount is where the netrc for private module hosts is mounted in build containers
+const moduleAuthMount = "/run/module-auth"
+
+// vendored reports whether the module in rootDir vendors its dependencies
+func vendored(rootDir string) bool {
+	info, err := os.Stat(filepath.Join(rootDir, "vendor", "modules.txt"))
+	return err == nil && info.Mode().IsRegular()
+}
+
+// moduleEnv applies the project's module settings. The build never changes go.mod or go.sum,
+// missing checksums fail it instead.
+func moduleEnv(modules dto.GoModules, vendor bool) []string {
+	goflags := strings.Fields(modules.GOFLAGS)
+	setsMod := false
+	for _, flag := range goflags {
+		if strings.HasPrefix(flag, "-mod=") {
+			setsMod = true
+		}
+	}
+	if !setsMod {
+		mod := "-mod=readonly"
+		if vendor {
+			mod = "-mod=vendor"
+		}
+		goflags = append([]string{mod}, goflags...)
+	}
+
+	env := []string{"GOFLAGS=" + strings.Join(goflags, " ")}
+	for _, setting := range [][2]string{{"GOPROXY", modules.GOPROXY}, {"GONOSUMDB", modules.GONOSUMDB}, {"GOPRIVATE", modules.GOPRIVATE}} {
+		if setting[1] != "" {
+			env = append(env, setting[0]+"="+setting[1])
+		}
+	}
+	return env
+}
+
+// ModuleAuth hands the tokens for private module hosts to the build, through a netrc
+// for module proxies and a git credential helper for direct fetches. Close removes the netrc.
+type ModuleAuth struct {
+	Env   []string
+	Binds []string
+	dir   string
+}
+
+func (m *ModuleAuth) Close() error {
+	if m.dir == "" {
+		return nil
+	}
+	return os.RemoveAll(m.dir)
+}
+
+// ChownTo hands the netrc to the sandbox user
+func (m *ModuleAuth) ChownTo(uid, gid int) error {
+	if m.dir == "" {
+		return nil
+	}
+	if err := chownTree(m.dir, uid, gid); err != nil {
+		return fmt.Errorf("failed to hand netrc to sandbox user: %w", err)
+	}
+	return nil
+}
+
+// moduleAuth resolves the project's module credentials from the build env
+func moduleAuth(modules dto.GoModules, env *ResolvedEnv) (*ModuleAuth, error) {
+	auth := &ModuleAuth{}
+	if len(modules.Auth) == 0 {
+		return auth, nil
+	}
+
+	var netrc strings.Builder
+	for i, credential := range modules.Auth {
+		token, ok := env.BuildValue(credential.TokenEnv)
+		if !ok || token == "" {
+			return nil, fmt.Errorf("module auth for %s: env var %s is not set for builds", credential.Host, credential.TokenEnv)
+		}
+		username := credential.Username
+		if username == "" {
+			username = defaultTokenUsername
+		}
+		fmt.Fprintf(&netrc, "machine %s login %s password %s\n", credential.Host, username, token)
+		// the helper reads the token from the env var, so it never shows up in git's config or argv
+		auth.Env = append(auth.Env,
+			fmt.Sprintf("GIT_CONFIG_KEY_%d=credential.https://%s.helper", i, credential.Host),
+			fmt.Sprintf(`GIT_CONFIG_VALUE_%d=!f() { test "$1" = get && echo "username=%s" && echo "password=$%s"; }; f`, i, username, credential.TokenEnv),
+		)
+	}
+	auth.Env = append(auth.Env, fmt.Sprintf("GIT_CONFIG_COUNT=%d", len(modules.Auth)), "NETRC="+moduleAuthMount+"/netrc")
+
+	dir, err := os.MkdirTemp("", "module-auth-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create module auth dir: %w", err)
+	}
+	auth.dir = dir
+	path := filepath.Join(dir, "netrc")
+	if err := os.WriteFile(path, []byte(netrc.String()), 0600); err != nil {
+		auth.Close()
+		return nil, fmt.Errorf("failed to write netrc: %w", err)
+	}
+	auth.Binds = []string{dir + ":" + moduleAuthMount + ":ro"}
+	return auth, nil
+}
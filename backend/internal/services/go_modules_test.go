@@ -0,0 +1,221 @@
+package services
+
+import (
+	"archive/zip"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/RajVerma97/golang-vercel/backend/internal/constants"
+	"github.com/RajVerma97/golang-vercel/backend/internal/dto"
+)
+
+func TestModuleEnv(t *testing.T) {
+	tests := []struct {
+		name    string
+		modules dto.GoModules
+		vendor  bool
+		want    []string
+	}{
+		{name: "read-only by default", want: []string{"GOFLAGS=-mod=readonly"}},
+		{name: "vendor directory", vendor: true, want: []string{"GOFLAGS=-mod=vendor"}},
+		{name: "flags are kept", modules: dto.GoModules{GOFLAGS: "-tags=netgo  -buildvcs=false"}, want: []string{"GOFLAGS=-mod=readonly -tags=netgo -buildvcs=false"}},
+		// the project's own -mod wins, even over a vendor directory
+		{name: "project sets -mod", modules: dto.GoModules{GOFLAGS: "-mod=mod"}, vendor: true, want: []string{"GOFLAGS=-mod=mod"}},
+		{
+			name:    "module settings",
+			modules: dto.GoModules{GOPROXY: "https://proxy.example.com,direct", GONOSUMDB: "example.com", GOPRIVATE: "example.com/private"},
+			want:    []string{"GOFLAGS=-mod=readonly", "GOPROXY=https://proxy.example.com,direct", "GONOSUMDB=example.com", "GOPRIVATE=example.com/private"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := moduleEnv(tt.modules, tt.vendor); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("moduleEnv() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// newTestModuleProxy serves example.com/dep v1.0.0 from a directory, the way GOPROXY=file:// reads it
+func newTestModuleProxy(t *testing.T) string {
+	t.Helper()
+	proxy := t.TempDir()
+	dir := filepath.Join(proxy, "example.com", "dep", "@v")
+	writeFile(t, filepath.Join(dir, "list"), "v1.0.0\n")
+	writeFile(t, filepath.Join(dir, "v1.0.0.info"), `{"Version":"v1.0.0","Time":"2024-01-01T00:00:00Z"}`)
+	writeFile(t, filepath.Join(dir, "v1.0.0.mod"), "module example.com/dep\n\ngo 1.21\n")
+	archive, err := os.Create(filepath.Join(dir, "v1.0.0.zip"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := zip.NewWriter(archive)
+	for name, content := range map[string]string{
+		"go.mod": "module example.com/dep\n\ngo 1.21\n",
+		"dep.go": "package dep\n\nconst Name = \"dep\"\n",
+	} {
+		f, err := w.Create("example.com/dep@v1.0.0/" + name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.Write([]byte(content))
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	archive.Close()
+	return proxy
+}
+
+// goSum lists example.com/dep's checksums the way go mod tidy writes them
+func goSum(t *testing.T, proxy, modCache string) string {
+	t.Helper()
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "go.mod"), "module example.com/app\n\ngo 1.21\n\nrequire example.com/dep v1.0.0\n")
+	writeFile(t, filepath.Join(dir, "main.go"), "package main\n\nimport _ \"example.com/dep\"\n\nfunc main() {}\n")
+	cmd := exec.Command("go", "mod", "tidy")
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "GOPROXY=file://"+proxy, "GONOSUMDB=example.com", "GOFLAGS=", "GOMODCACHE="+modCache)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("go mod tidy: %v\n%s", err, out)
+	}
+	sum, err := os.ReadFile(filepath.Join(dir, "go.sum"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(sum)
+}
+
+func TestBuildStepsModules(t *testing.T) {
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go is not installed")
+	}
+	proxy := newTestModuleProxy(t)
+	modCache := t.TempDir()
+	// the module cache is read-only, go has to remove it
+	t.Cleanup(func() {
+		cmd := exec.Command("go", "clean", "-modcache")
+		cmd.Env = append(os.Environ(), "GOMODCACHE="+modCache)
+		cmd.Run()
+	})
+	sum := goSum(t, proxy, modCache)
+	goMod := "module example.com/app\n\ngo 1.21\n\nrequire example.com/dep v1.0.0\n"
+	modules := dto.GoModules{GOPROXY: "file://" + proxy, GONOSUMDB: "example.com"}
+
+	tests := []struct {
+		name    string
+		modules dto.GoModules
+		goSum   string
+		vendor  bool
+		wantErr string
+	}{
+		{name: "go.sum pins the modules", modules: modules, goSum: sum},
+		{name: "missing checksums fail the build", modules: modules, wantErr: "missing go.sum entry"},
+		// nothing is downloaded, so no proxy is needed
+		{name: "vendor directory", modules: dto.GoModules{GOPROXY: "off"}, goSum: sum, vendor: true},
+		{name: "project GOFLAGS win", modules: dto.GoModules{GOPROXY: "file://" + proxy, GONOSUMDB: "example.com", GOFLAGS: "-mod=mod"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeFile(t, filepath.Join(dir, "go.mod"), goMod)
+			writeFile(t, filepath.Join(dir, "main.go"), "package main\n\nimport \"example.com/dep\"\n\nfunc main() { println(dep.Name) }\n")
+			if tt.goSum != "" {
+				writeFile(t, filepath.Join(dir, "go.sum"), tt.goSum)
+			}
+			if tt.vendor {
+				writeFile(t, filepath.Join(dir, "vendor", "modules.txt"), "# example.com/dep v1.0.0\n## explicit\nexample.com/dep\n")
+				writeFile(t, filepath.Join(dir, "vendor", "example.com", "dep", "dep.go"), "package dep\n\nconst Name = \"dep\"\n")
+			}
+			vendor := vendored(dir)
+			if vendor != tt.vendor {
+				t.Fatalf("vendored() = %v, want %v", vendor, tt.vendor)
+			}
+			steps := buildSteps(&dto.Project{Modules: tt.modules}, &dto.Build{}, dto.BuildConfig{BuildCommand: "go build -o bin/app ."}, vendor)
+			if downloads := steps[0].phase == constants.BuildPhaseDependencies; downloads == vendor {
+				t.Errorf("dependencies step = %v, want it only without a vendor directory", downloads)
+			}
+
+			var output strings.Builder
+			var err error
+			var failed buildStep
+			for _, step := range steps {
+				failed = step
+				cmd := exec.Command("sh", "-c", step.cmd)
+				cmd.Dir = dir
+				cmd.Env = append(append(os.Environ(), "GOMODCACHE="+modCache, "GOFLAGS="), moduleEnv(tt.modules, vendor)...)
+				cmd.Stdout, cmd.Stderr = &output, &output
+				if err = cmd.Run(); err != nil {
+					break
+				}
+			}
+			if tt.wantErr == "" && err != nil {
+				t.Fatalf("build failed: %v\n%s", err, output.String())
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(output.String(), tt.wantErr)) {
+				t.Fatalf("build error = %v, want %q in\n%s", err, tt.wantErr, output.String())
+			}
+			if tt.wantErr != "" && failed.phase != constants.BuildPhaseDependencies {
+				t.Errorf("failed in the %s step, want the dependencies step", failed.phase)
+			}
+
+			// only -mod=mod, which the project asked for, lets go touch the dependency graph
+			if tt.modules.GOFLAGS != "" {
+				if got, _ := os.ReadFile(filepath.Join(dir, "go.sum")); !strings.Contains(string(got), "example.com/dep v1.0.0 h1:") {
+					t.Errorf("go.sum = %q, want -mod=mod to have added the checksums", got)
+				}
+			} else {
+				if got, _ := os.ReadFile(filepath.Join(dir, "go.mod")); string(got) != goMod {
+					t.Errorf("go.mod was rewritten to %q", got)
+				}
+				if got, _ := os.ReadFile(filepath.Join(dir, "go.sum")); string(got) != tt.goSum {
+					t.Errorf("go.sum was rewritten to %q", got)
+				}
+			}
+		})
+	}
+}
+
+func TestModuleAuth(t *testing.T) {
+	t.Setenv("TMPDIR", t.TempDir())
+	modules := dto.GoModules{Auth: []dto.ModuleAuth{
+		{Host: "git.example.com", TokenEnv: "GIT_TOKEN"},
+		{Host: "proxy.example.com", Username: "ci", TokenEnv: "PROXY_TOKEN"},
+	}}
+	env := &ResolvedEnv{Build: []string{"GIT_TOKEN=git-secret", "PROXY_TOKEN=proxy-secret"}}
+
+	auth, err := moduleAuth(modules, env)
+	if err != nil {
+		t.Fatal(err)
+	}
+	netrc, err := os.ReadFile(filepath.Join(auth.dir, "netrc"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "machine git.example.com login x-access-token password git-secret\nmachine proxy.example.com login ci password proxy-secret\n"
+	if string(netrc) != want {
+		t.Errorf("netrc = %q, want %q", netrc, want)
+	}
+	// git's credential helpers read the tokens from the env at run time
+	for _, pair := range auth.Env {
+		if strings.Contains(pair, "secret") {
+			t.Errorf("env %q holds a token", pair)
+		}
+	}
+	if want := []string{auth.dir + ":" + moduleAuthMount + ":ro"}; !reflect.DeepEqual(auth.Binds, want) {
+		t.Errorf("binds = %q, want %q", auth.Binds, want)
+	}
+	if err := auth.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(auth.dir); !os.IsNotExist(err) {
+		t.Error("Close() left the netrc behind")
+	}
+
+	if _, err := moduleAuth(modules, &ResolvedEnv{Build: []string{"GIT_TOKEN=git-secret"}}); err == nil || !strings.Contains(err.Error(), "PROXY_TOKEN") {
+		t.Errorf("moduleAuth() without the token = %v, want an error naming PROXY_TOKEN", err)
+	}
+}
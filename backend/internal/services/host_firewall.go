@@ -0,0 +1,78 @@
+package services
+
+import (
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// hostFirewall keeps containers on the platform's bridges from reaching the host, a bridge's
+// gateway address is the host's and so are the ports Redis and the API listen on
+type hostFirewall struct {
+	// iptables runs iptables with args, it is swapped out in tests
+	iptables func(args ...string) error
+	mu       sync.Mutex
+}
+
+func newHostFirewall() *hostFirewall {
+	return &hostFirewall{iptables: runIptables}
+}
+
+func runIptables(args ...string) error {
+	// -w waits for the xtables lock that docker holds while it edits its own rules
+	out, err := exec.Command("iptables", append([]string{"-w"}, args...)...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("iptables %s: %w: %s", strings.Join(args, " "), err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// isolationRules are the rules that cut bridge off, in the order they have to be evaluated.
+// Replies to connections the host opened, such as the reverse proxy's, still get through INPUT.
+// DOCKER-USER stops containers from reaching other networks' containers, the ones whose ports are
+// published on the host's addresses among them, while traffic within the bridge and out of the host is left alone.
+func isolationRules(bridge string) map[string][][]string {
+	return map[string][][]string{
+		"INPUT": {
+			{"-i", bridge, "-m", "conntrack", "--ctstate", "ESTABLISHED,RELATED", "-j", "ACCEPT"},
+			{"-i", bridge, "-j", "DROP"},
+		},
+		"DOCKER-USER": {
+			{"-i", bridge, "-o", bridge, "-j", "RETURN"},
+			{"-i", bridge, "-o", "br+", "-j", "DROP"},
+			{"-i", bridge, "-o", "docker0", "-j", "DROP"},
+		},
+	}
+}
+
+// isolate makes sure the rules for bridge are in place. A chain missing any of them has them all
+// inserted again at its top, since they only work in order.
+func (f *hostFirewall) isolate(bridge string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, chain := range []string{"INPUT", "DOCKER-USER"} {
+		rules := isolationRules(bridge)[chain]
+		complete := true
+		for _, rule := range rules {
+			if f.iptables(append([]string{"-C", chain}, rule...)...) != nil {
+				complete = false
+				break
+			}
+		}
+		if complete {
+			continue
+		}
+		for _, rule := range rules {
+			// deleting a rule that is not there fails, which is fine
+			f.iptables(append([]string{"-D", chain}, rule...)...)
+		}
+		for i, rule := range rules {
+			if err := f.iptables(append([]string{"-I", chain, strconv.Itoa(i + 1)}, rule...)...); err != nil {
+				return fmt.Errorf("failed to isolate %s from the host: %w", bridge, err)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,94 @@
+package services
+
+import (
+	"errors"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// fakeIptables keeps the chains' rules in memory and records every command
+type fakeIptables struct {
+	chains   map[string][]string
+	commands []string
+}
+
+func (f *fakeIptables) run(args ...string) error {
+	f.commands = append(f.commands, strings.Join(args, " "))
+	op, chain := args[0], args[1]
+	rules := f.chains[chain]
+	switch op {
+	case "-C":
+		if !slices.Contains(rules, strings.Join(args[2:], " ")) {
+			return errors.New("bad rule")
+		}
+	case "-D":
+		i := slices.Index(rules, strings.Join(args[2:], " "))
+		if i < 0 {
+			return errors.New("bad rule")
+		}
+		f.chains[chain] = slices.Delete(rules, i, i+1)
+	case "-I":
+		position, _ := strconv.Atoi(args[2])
+		f.chains[chain] = slices.Insert(rules, position-1, strings.Join(args[3:], " "))
+	}
+	return nil
+}
+
+func TestHostFirewallIsolate(t *testing.T) {
+	wantInput := []string{
+		"-i br-1 -m conntrack --ctstate ESTABLISHED,RELATED -j ACCEPT",
+		"-i br-1 -j DROP",
+		"-j ACCEPT-FROM-ELSEWHERE",
+	}
+	wantDockerUser := []string{
+		"-i br-1 -o br-1 -j RETURN",
+		"-i br-1 -o br+ -j DROP",
+		"-i br-1 -o docker0 -j DROP",
+		"-j RETURN",
+	}
+	tests := []struct {
+		name  string
+		input []string
+	}{
+		{name: "no rules", input: []string{"-j ACCEPT-FROM-ELSEWHERE"}},
+		{name: "drop without the accept", input: []string{"-j ACCEPT-FROM-ELSEWHERE", "-i br-1 -j DROP"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			iptables := &fakeIptables{chains: map[string][]string{"INPUT": tt.input, "DOCKER-USER": {"-j RETURN"}}}
+			f := &hostFirewall{iptables: iptables.run}
+			if err := f.isolate("br-1"); err != nil {
+				t.Fatal(err)
+			}
+			if got := iptables.chains["INPUT"]; !reflect.DeepEqual(got, wantInput) {
+				t.Errorf("INPUT = %q, want %q", got, wantInput)
+			}
+			if got := iptables.chains["DOCKER-USER"]; !reflect.DeepEqual(got, wantDockerUser) {
+				t.Errorf("DOCKER-USER = %q, want %q", got, wantDockerUser)
+			}
+
+			// once in place the rules are only checked
+			iptables.commands = nil
+			if err := f.isolate("br-1"); err != nil {
+				t.Fatal(err)
+			}
+			for _, command := range iptables.commands {
+				if !strings.HasPrefix(command, "-C ") {
+					t.Errorf("isolating again ran %q, want checks only", command)
+				}
+			}
+		})
+	}
+}
+
+func TestHostFirewallIsolateFails(t *testing.T) {
+	f := &hostFirewall{iptables: func(args ...string) error {
+		return errors.New("iptables: No chain/target/match by that name")
+	}}
+	if err := f.isolate("br-1"); err == nil {
+		t.Error("isolate() = nil when iptables fails, want an error so the network is not used unprotected")
+	}
+}
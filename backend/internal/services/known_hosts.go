@@ -0,0 +1,41 @@
+package services
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// pinnedKnownHosts are the published host keys of the hosted git providers, projects on other
+// servers add theirs through the git auth's known_hosts
+const pinnedKnownHosts = `github.com ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIOMqqnkVzrm0SdG6UOoqKLsabgH5C9okWi0dh2l9GKJl
+github.com ecdsa-sha2-nistp256 AAAAE2VjZHNhLXNoYTItbmlzdHAyNTYAAAAIbmlzdHAyNTYAAABBBEmKSENjQEezOmxkZMy7opKgwFB9nkt5YRrYMjNuG5N87uRgg6CLrbo5wAdT/y6v0mKV0U2w0WZ2YB/++Tpockg=
+gitlab.com ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIAfuCHKVTjquxvt6CM6tdG4SLp1Btn/nOeHHE5UOzRdf
+gitlab.com ecdsa-sha2-nistp256 AAAAE2VjZHNhLXNoYTItbmlzdHAyNTYAAAAIbmlzdHAyNTYAAABBBFSMqzJeV9rUzU4kWitGjeR4PWSa29SPqJ1fVkhtj3Hw9xjLVXVYrU9QlYWrOLXBpQ6KWjbjTDTdDkoohFzgbEY=
+bitbucket.org ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIIazEu89wgQZ4bqs3d63QSMzYVa0MuJ2e2gKTKqu+UUO
+bitbucket.org ecdsa-sha2-nistp256 AAAAE2VjZHNhLXNoYTItbmlzdHAyNTYAAAAIbmlzdHAyNTYAAABBBPIQmuzMBuKdWeF4+a2sjSSpBK0iqitSQ+5BM9KhpexuGt20JpTVM7u5BDZngncgrqDMbWdxMWWOGtZ9UgbqgZE=
+`
+
+var ErrInvalidKnownHosts = errors.New("invalid known_hosts")
+
+// validateKnownHosts checks that every line of a known_hosts file parses
+func validateKnownHosts(knownHosts string) error {
+	rest := []byte(knownHosts)
+	for len(bytes.TrimSpace(rest)) > 0 {
+		var err error
+		if _, _, _, _, rest, err = ssh.ParseKnownHosts(rest); err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidKnownHosts, err)
+		}
+	}
+	return nil
+}
+
+// knownHosts is the known_hosts file git checks host keys against, the pinned keys and the project's own
+func knownHosts(projectKnownHosts string) string {
+	if projectKnownHosts == "" {
+		return pinnedKnownHosts
+	}
+	return pinnedKnownHosts + projectKnownHosts + "\n"
+}
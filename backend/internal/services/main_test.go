@@ -0,0 +1,90 @@
+package services
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+
+	docker_client "github.com/RajVerma97/golang-vercel/backend/internal/client/docker"
+	redis_client "github.com/RajVerma97/golang-vercel/backend/internal/client/redis"
+	"github.com/RajVerma97/golang-vercel/backend/internal/config"
+	"github.com/RajVerma97/golang-vercel/backend/internal/dto"
+	"github.com/RajVerma97/golang-vercel/backend/internal/logger"
+	"github.com/RajVerma97/golang-vercel/backend/internal/proxy"
+	"github.com/alicebob/miniredis/v2"
+)
+
+func TestMain(m *testing.M) {
+	if err := logger.Init("test"); err != nil {
+		panic(err)
+	}
+	os.Exit(m.Run())
+}
+
+// newTestRedis connects to an in-memory redis that lives as long as the test
+func newTestRedis(t *testing.T) *redis_client.RedisClient {
+	t.Helper()
+	server := miniredis.RunT(t)
+	host, port, err := net.SplitHostPort(server.Addr())
+	if err != nil {
+		t.Fatal(err)
+	}
+	portNumber, _ := strconv.Atoi(port)
+	client, err := redis_client.NewRedisClient(context.Background(), &config.RedisConfig{Host: host, Port: portNumber})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { client.Close() })
+	return client
+}
+
+// newTestDockerClient points a docker client at a fake daemon serving handler
+func newTestDockerClient(t *testing.T, handler http.HandlerFunc) *docker_client.DockerClient {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	t.Setenv("DOCKER_HOST", "tcp://"+server.Listener.Addr().String())
+	client, err := docker_client.NewDockerClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { client.Close() })
+	return client
+}
+
+// newTestReleaseService wires a release service to an in-memory redis and a fresh proxy,
+// deployments in its tests have no containers so docker is never called
+func newTestReleaseService(t *testing.T) *ReleaseService {
+	t.Helper()
+	redisClient := newTestRedis(t)
+	return NewReleaseService(&ReleaseServiceConfig{
+		RedisClient:    redisClient,
+		RedisService:   NewRedisService(&RedisServiceConfig{RedisClient: redisClient}),
+		ProjectService: NewProjectService(&ProjectServiceConfig{RedisClient: redisClient}),
+		Proxy:          proxy.NewProxy(),
+		ProxyConfig:    &config.ProxyConfig{Domain: "localhost", Port: 8080},
+		DeployConfig:   &config.DeployConfig{DrainGracePeriod: time.Millisecond},
+	})
+}
+
+// saveTestDeployment stores a deployment of a build that retained its binary
+func saveTestDeployment(t *testing.T, s *ReleaseService, deployment *dto.Deployment) *dto.Deployment {
+	t.Helper()
+	ctx := context.Background()
+	binaryPath := "/artifacts/app"
+	if err := s.RedisService.SaveBuild(ctx, &dto.Build{ID: deployment.BuildID, ProjectID: deployment.ProjectID, BinaryPath: &binaryPath}); err != nil {
+		t.Fatal(err)
+	}
+	if deployment.URL == "" {
+		deployment.URL = "http://127.0.0.1:" + strconv.FormatUint(9000+deployment.ID, 10)
+	}
+	if err := s.RedisService.SaveDeployment(ctx, deployment); err != nil {
+		t.Fatal(err)
+	}
+	return deployment
+}
@@ -0,0 +1,213 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+
+	docker_client "github.com/RajVerma97/golang-vercel/backend/internal/client/docker"
+	"github.com/RajVerma97/golang-vercel/backend/internal/config"
+	"github.com/RajVerma97/golang-vercel/backend/internal/constants"
+	"github.com/RajVerma97/golang-vercel/backend/internal/dto"
+	"github.com/RajVerma97/golang-vercel/backend/internal/logger"
+	"go.uber.org/zap"
+)
+
+const (
+	egressProxyAlias = "egress-proxy"
+	egressProxyPort  = 3128
+)
+
+type NetworkServiceConfig struct {
+	DockerClient  *docker_client.DockerClient
+	NetworkConfig *config.NetworkConfig
+	StorageConfig *config.StorageConfig
+}
+type NetworkService struct {
+	DockerClient  *docker_client.DockerClient
+	networkConfig *config.NetworkConfig
+	storageConfig *config.StorageConfig
+	firewall      *hostFirewall
+}
+
+func NewNetworkService(config *NetworkServiceConfig) *NetworkService {
+	return &NetworkService{
+		DockerClient:  config.DockerClient,
+		networkConfig: config.NetworkConfig,
+		storageConfig: config.StorageConfig,
+		firewall:      newHostFirewall(),
+	}
+}
+
+// ProjectNetwork is where a project's deployments are attached
+type ProjectNetwork struct {
+	Attachment *docker_client.NetworkAttachment
+	// Env points the app at the egress proxy in allowlist mode
+	Env []string
+}
+
+// Prepare makes sure the project's network, and its egress proxy in allowlist mode, exist.
+// The proxy is removed in the other modes.
+// Every project gets its own bridge so deployments of different projects cannot reach each other.
+// Restricted modes use an internal network that has no route out of the host, and no bridge
+// can reach the host itself.
+func (s *NetworkService) Prepare(ctx context.Context, project *dto.Project) (*ProjectNetwork, error) {
+	mode := egressMode(project)
+	labels := map[string]string{
+		"project_id": fmt.Sprintf("%d", project.ID),
+		"egress":     mode.String(),
+	}
+
+	name := fmt.Sprintf("project-%d", project.ID)
+	internal := mode != constants.EgressModeOpen
+	switch mode {
+	case constants.EgressModeAllowlist:
+		// docker cannot flip an existing network to internal, so restricted projects use a separate one
+		name += "-isolated"
+	case constants.EgressModeDeny:
+		// the egress proxy is reachable from every container on the allowlist network
+		name += "-deny"
+	}
+	if err := s.ensureNetwork(ctx, name, internal, labels); err != nil {
+		return nil, fmt.Errorf("failed to create project network: %w", err)
+	}
+	network := &ProjectNetwork{
+		Attachment: &docker_client.NetworkAttachment{Name: name, Internal: internal},
+	}
+
+	if mode == constants.EgressModeAllowlist {
+		if err := s.ensureEgressProxy(ctx, project, name); err != nil {
+			return nil, err
+		}
+		proxyURL := fmt.Sprintf("http://%s:%d", egressProxyAlias, egressProxyPort)
+		network.Env = []string{
+			"HTTP_PROXY=" + proxyURL,
+			"HTTPS_PROXY=" + proxyURL,
+			"http_proxy=" + proxyURL,
+			"https_proxy=" + proxyURL,
+			"NO_PROXY=localhost,127.0.0.1",
+			"no_proxy=localhost,127.0.0.1",
+		}
+	} else {
+		// deployments still on the allowlist network lose their way out along with the proxy
+		if err := s.DockerClient.RemoveContainer(ctx, egressProxyName(project)); err != nil {
+			return nil, fmt.Errorf("failed to remove egress proxy: %w", err)
+		}
+	}
+	return network, nil
+}
+
+// PrepareBuildNetwork makes sure the network build containers run on exists and returns its name
+func (s *NetworkService) PrepareBuildNetwork(ctx context.Context) (string, error) {
+	name := s.networkConfig.BuildNetwork
+	if name == "" {
+		return "", nil
+	}
+	if err := s.ensureNetwork(ctx, name, false, map[string]string{"purpose": "builds"}); err != nil {
+		return "", fmt.Errorf("failed to create build network: %w", err)
+	}
+	return name, nil
+}
+
+// ensureNetwork creates the bridge network and firewalls it off the host
+func (s *NetworkService) ensureNetwork(ctx context.Context, name string, internal bool, labels map[string]string) error {
+	bridge, err := s.DockerClient.EnsureNetwork(ctx, name, internal, labels)
+	if err != nil {
+		return err
+	}
+	if !s.networkConfig.HostFirewall {
+		return nil
+	}
+	return s.firewall.isolate(bridge)
+}
+
+// egressMode is the project's egress mode, open when it has none
+func egressMode(project *dto.Project) constants.EgressMode {
+	if project.Egress.Mode == "" {
+		return constants.EgressModeOpen
+	}
+	return project.Egress.Mode
+}
+
+func egressProxyName(project *dto.Project) string {
+	return fmt.Sprintf("egress-project-%d", project.ID)
+}
+
+// ensureEgressProxy runs the project's egress proxy on networkName, recreating it when the allowlist changed
+func (s *NetworkService) ensureEgressProxy(ctx context.Context, project *dto.Project, networkName string) error {
+	dir := filepath.Join(s.storageConfig.EgressDir(), fmt.Sprintf("project-%d", project.ID))
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create egress config dir: %w", err)
+	}
+	configPath := filepath.Join(dir, "squid.conf")
+	squidConf := []byte(squidConfig(project.Egress.Allow))
+
+	containerName := egressProxyName(project)
+	current, err := os.ReadFile(configPath)
+	unchanged := err == nil && bytes.Equal(current, squidConf)
+	if unchanged && s.DockerClient.DoesContainerExist(ctx, containerName) {
+		inspect, err := s.DockerClient.InspectContainer(ctx, containerName)
+		if err != nil {
+			return fmt.Errorf("failed to inspect egress proxy: %w", err)
+		}
+		if !inspect.State.Running {
+			if err := s.DockerClient.StartContainer(ctx, containerName); err != nil {
+				return fmt.Errorf("failed to start egress proxy: %w", err)
+			}
+		}
+		return s.DockerClient.ConnectNetwork(ctx, networkName, containerName, []string{egressProxyAlias})
+	}
+
+	logger.Info("Starting egress proxy", zap.Uint64("project_id", project.ID), zap.Strings("allow", project.Egress.Allow))
+	if err := os.WriteFile(configPath, squidConf, 0644); err != nil {
+		return fmt.Errorf("failed to write egress proxy config: %w", err)
+	}
+	if err := s.DockerClient.RemoveContainer(ctx, containerName); err != nil {
+		return fmt.Errorf("failed to remove egress proxy: %w", err)
+	}
+	if err := s.DockerClient.PullImage(ctx, s.networkConfig.EgressProxyImage, ""); err != nil {
+		return fmt.Errorf("failed to pull egress proxy image: %w", err)
+	}
+	containerID, err := s.DockerClient.CreateEgressProxyContainer(ctx, s.networkConfig.EgressProxyImage, containerName, configPath)
+	if err != nil {
+		return fmt.Errorf("failed to create egress proxy: %w", err)
+	}
+	if err := s.DockerClient.ConnectNetwork(ctx, networkName, containerID, []string{egressProxyAlias}); err != nil {
+		return fmt.Errorf("failed to connect egress proxy: %w", err)
+	}
+	if err := s.DockerClient.StartContainer(ctx, containerID); err != nil {
+		return fmt.Errorf("failed to start egress proxy: %w", err)
+	}
+	return nil
+}
+
+// squidConfig only lets requests to allowed hosts and CIDRs through, *.example.com also matches example.com
+func squidConfig(allow []string) string {
+	var domains, nets []string
+	for _, entry := range allow {
+		if _, _, err := net.ParseCIDR(entry); err == nil || net.ParseIP(entry) != nil {
+			nets = append(nets, entry)
+			continue
+		}
+		domains = append(domains, strings.Replace(strings.ToLower(entry), "*.", ".", 1))
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "http_port %d\n", egressProxyPort)
+	b.WriteString("cache deny all\n")
+	b.WriteString("access_log stdio:/dev/stdout\n")
+	if len(domains) > 0 {
+		fmt.Fprintf(&b, "acl allowed_domains dstdomain %s\n", strings.Join(domains, " "))
+		b.WriteString("http_access allow allowed_domains\n")
+	}
+	if len(nets) > 0 {
+		fmt.Fprintf(&b, "acl allowed_nets dst %s\n", strings.Join(nets, " "))
+		b.WriteString("http_access allow allowed_nets\n")
+	}
+	b.WriteString("http_access deny all\n")
+	return b.String()
+}
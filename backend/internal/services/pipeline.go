@@ -0,0 +1,190 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/RajVerma97/golang-vercel/backend/internal/constants"
+	"github.com/RajVerma97/golang-vercel/backend/internal/dto"
+	"github.com/RajVerma97/golang-vercel/backend/internal/logger"
+	"go.uber.org/zap"
+)
+
+// PipelineStep is a node of a build's pipeline, Run records its logs and artifacts on result
+type PipelineStep struct {
+	Name  string
+	Needs []string
+	Run   func(ctx context.Context, result *dto.StepResult) error
+}
+
+// PipelineStepError is the failure of the step that stopped a pipeline
+type PipelineStepError struct {
+	Step string
+	Err  error
+}
+
+func (e *PipelineStepError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *PipelineStepError) Unwrap() error {
+	return e.Err
+}
+
+// Pipeline runs a build's steps one at a time, each once every step it needs has succeeded and
+// in the order they were added when several are ready. Steps may add more steps while the pipeline
+// runs, such as the ones the repository's build config defines. The results are kept on
+// build.Steps and saved on every change.
+type Pipeline struct {
+	build *dto.Build
+	steps []PipelineStep
+	save  func()
+}
+
+func NewPipeline(build *dto.Build, save func()) *Pipeline {
+	build.Steps = nil
+	return &Pipeline{build: build, save: save}
+}
+
+// Add appends steps, which may need the steps added before and each other. Needs cannot point
+// to steps added later, so only steps of the same call can form a cycle.
+func (p *Pipeline) Add(steps ...PipelineStep) error {
+	known := make(map[string]bool, len(p.steps)+len(steps))
+	for _, step := range p.steps {
+		known[step.Name] = true
+	}
+	for _, step := range steps {
+		if known[step.Name] {
+			return fmt.Errorf("pipeline already has a step named %s", step.Name)
+		}
+		known[step.Name] = true
+	}
+	for _, step := range steps {
+		for _, need := range step.Needs {
+			if !known[need] {
+				return fmt.Errorf("step %s needs unknown step %s", step.Name, need)
+			}
+		}
+	}
+	if cycle := findCycle(steps); cycle != nil {
+		return fmt.Errorf("steps depend on each other: %s", strings.Join(cycle, " -> "))
+	}
+
+	for _, step := range steps {
+		p.steps = append(p.steps, step)
+		p.build.Steps = append(p.build.Steps, dto.StepResult{Name: step.Name, Status: constants.StepStatusPending})
+	}
+	p.save()
+	return nil
+}
+
+// findCycle returns the names along a cycle among steps, nil when there is none
+func findCycle(steps []PipelineStep) []string {
+	needs := make(map[string][]string, len(steps))
+	for _, step := range steps {
+		needs[step.Name] = step.Needs
+	}
+	const (
+		visiting = 1
+		done     = 2
+	)
+	state := make(map[string]int, len(steps))
+	var path []string
+	var visit func(name string) []string
+	visit = func(name string) []string {
+		switch state[name] {
+		case visiting:
+			start := slices.Index(path, name)
+			return append(slices.Clone(path[start:]), name)
+		case done:
+			return nil
+		}
+		state[name] = visiting
+		path = append(path, name)
+		for _, need := range needs[name] {
+			if cycle := visit(need); cycle != nil {
+				return cycle
+			}
+		}
+		path = path[:len(path)-1]
+		state[name] = done
+		return nil
+	}
+	for _, step := range steps {
+		if cycle := visit(step.Name); cycle != nil {
+			return cycle
+		}
+	}
+	return nil
+}
+
+// Run runs the steps until all succeeded or one failed, which skips the ones still pending
+// and is returned as a *PipelineStepError
+func (p *Pipeline) Run(ctx context.Context) error {
+	for {
+		i := p.next()
+		if i < 0 {
+			return nil
+		}
+		step := p.steps[i]
+		startedAt := time.Now()
+		p.build.Steps[i].Status = constants.StepStatusRunning
+		p.build.Steps[i].StartedAt = &startedAt
+		p.save()
+		logger.Info("Starting pipeline step", zap.Uint64("build_id", p.build.ID), zap.String("step", step.Name))
+
+		// steps may add steps, so the result is copied back rather than pointed into build.Steps
+		result := p.build.Steps[i]
+		err := step.Run(ctx, &result)
+		completedAt := time.Now()
+		result.CompletedAt = &completedAt
+		result.Status = constants.StepStatusSuccess
+		if err != nil {
+			reason := err.Error()
+			result.Status = constants.StepStatusFailed
+			result.FailureReason = &reason
+			result.FailureCode = FailureCodeOf(err)
+		}
+		p.build.Steps[i] = result
+
+		if err != nil {
+			logger.Error("Pipeline step failed", err, zap.Uint64("build_id", p.build.ID), zap.String("step", step.Name))
+			p.skipPending()
+			p.save()
+			return &PipelineStepError{Step: step.Name, Err: err}
+		}
+		p.save()
+	}
+}
+
+// next returns the first pending step whose needs all succeeded, -1 when none is left
+func (p *Pipeline) next() int {
+	succeeded := make(map[string]bool, len(p.steps))
+	for _, result := range p.build.Steps {
+		succeeded[result.Name] = result.Status == constants.StepStatusSuccess
+	}
+	for i, step := range p.steps {
+		if p.build.Steps[i].Status != constants.StepStatusPending {
+			continue
+		}
+		ready := true
+		for _, need := range step.Needs {
+			ready = ready && succeeded[need]
+		}
+		if ready {
+			return i
+		}
+	}
+	return -1
+}
+
+func (p *Pipeline) skipPending() {
+	for i := range p.build.Steps {
+		if p.build.Steps[i].Status == constants.StepStatusPending {
+			p.build.Steps[i].Status = constants.StepStatusSkipped
+		}
+	}
+}
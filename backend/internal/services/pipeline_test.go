@@ -0,0 +1,171 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/RajVerma97/golang-vercel/backend/internal/constants"
+	"github.com/RajVerma97/golang-vercel/backend/internal/dto"
+)
+
+func TestPipelineAdd(t *testing.T) {
+	tests := []struct {
+		name    string
+		steps   []PipelineStep
+		wantErr string
+	}{
+		{
+			name:  "needs earlier and same call steps",
+			steps: []PipelineStep{{Name: "build", Needs: []string{"clone", "lint"}}, {Name: "lint", Needs: []string{"clone"}}},
+		},
+		{
+			name:    "name taken by an earlier step",
+			steps:   []PipelineStep{{Name: "clone"}},
+			wantErr: "pipeline already has a step named clone",
+		},
+		{
+			name:    "name taken in the same call",
+			steps:   []PipelineStep{{Name: "lint"}, {Name: "lint"}},
+			wantErr: "pipeline already has a step named lint",
+		},
+		{
+			name:    "unknown need",
+			steps:   []PipelineStep{{Name: "lint", Needs: []string{"generate"}}},
+			wantErr: "step lint needs unknown step generate",
+		},
+		{
+			name:    "cycle",
+			steps:   []PipelineStep{{Name: "a", Needs: []string{"b"}}, {Name: "b", Needs: []string{"a"}}},
+			wantErr: "steps depend on each other: a -> b -> a",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			build := &dto.Build{}
+			pipeline := NewPipeline(build, func() {})
+			if err := pipeline.Add(PipelineStep{Name: "clone"}); err != nil {
+				t.Fatal(err)
+			}
+			err := pipeline.Add(tt.steps...)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("Add() error = %v, want %q", err, tt.wantErr)
+				}
+				if len(build.Steps) != 1 {
+					t.Errorf("Add() kept %d steps after failing", len(build.Steps))
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Add() error = %v", err)
+			}
+			if len(build.Steps) != 1+len(tt.steps) {
+				t.Errorf("Add() recorded %d steps, want %d", len(build.Steps), 1+len(tt.steps))
+			}
+		})
+	}
+}
+
+func TestFindCycle(t *testing.T) {
+	tests := []struct {
+		name  string
+		steps []PipelineStep
+		want  []string
+	}{
+		{name: "none"},
+		{
+			name:  "chain",
+			steps: []PipelineStep{{Name: "a"}, {Name: "b", Needs: []string{"a"}}, {Name: "c", Needs: []string{"a", "b"}}},
+		},
+		{
+			name:  "self",
+			steps: []PipelineStep{{Name: "a", Needs: []string{"a"}}},
+			want:  []string{"a", "a"},
+		},
+		{
+			name:  "longer cycle behind a step",
+			steps: []PipelineStep{{Name: "a", Needs: []string{"b"}}, {Name: "b", Needs: []string{"c"}}, {Name: "c", Needs: []string{"d"}}, {Name: "d", Needs: []string{"b"}}},
+			want:  []string{"b", "c", "d", "b"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := findCycle(tt.steps); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("findCycle() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPipelineNext(t *testing.T) {
+	pending, running, success, failed := constants.StepStatusPending, constants.StepStatusRunning, constants.StepStatusSuccess, constants.StepStatusFailed
+	steps := []PipelineStep{{Name: "a"}, {Name: "b", Needs: []string{"a"}}, {Name: "c"}, {Name: "d", Needs: []string{"b", "c"}}}
+	tests := []struct {
+		name     string
+		statuses []constants.StepStatus
+		want     int
+	}{
+		{name: "first ready in order", statuses: []constants.StepStatus{pending, pending, pending, pending}, want: 0},
+		{name: "needs not done yet", statuses: []constants.StepStatus{running, pending, pending, pending}, want: 2},
+		{name: "needs succeeded", statuses: []constants.StepStatus{success, pending, success, pending}, want: 1},
+		{name: "waits for every need", statuses: []constants.StepStatus{success, success, pending, pending}, want: 2},
+		{name: "failed need", statuses: []constants.StepStatus{failed, pending, success, pending}, want: -1},
+		{name: "done", statuses: []constants.StepStatus{success, success, success, success}, want: -1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			build := &dto.Build{}
+			for i, step := range steps {
+				build.Steps = append(build.Steps, dto.StepResult{Name: step.Name, Status: tt.statuses[i]})
+			}
+			pipeline := &Pipeline{build: build, steps: steps}
+			if got := pipeline.next(); got != tt.want {
+				t.Errorf("next() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPipelineRun(t *testing.T) {
+	build := &dto.Build{}
+	pipeline := NewPipeline(build, func() {})
+	var ran []string
+	step := func(name string, err error) PipelineStep {
+		return PipelineStep{Name: name, Run: func(context.Context, *dto.StepResult) error {
+			ran = append(ran, name)
+			return err
+		}}
+	}
+	lint := step("lint", errors.New("lint failed"))
+	lint.Needs = []string{"clone"}
+	clone := step("clone", nil)
+	clone.Run = func(ctx context.Context, result *dto.StepResult) error {
+		ran = append(ran, "clone")
+		// steps may add steps while the pipeline runs
+		deploy := step("deploy", nil)
+		deploy.Needs = []string{"lint"}
+		return pipeline.Add(lint, deploy)
+	}
+	if err := pipeline.Add(clone); err != nil {
+		t.Fatal(err)
+	}
+
+	err := pipeline.Run(context.Background())
+	var stepErr *PipelineStepError
+	if !errors.As(err, &stepErr) || stepErr.Step != "lint" {
+		t.Fatalf("Run() error = %v, want lint's failure", err)
+	}
+	if !reflect.DeepEqual(ran, []string{"clone", "lint"}) {
+		t.Errorf("ran %q", ran)
+	}
+	var statuses []constants.StepStatus
+	for _, result := range build.Steps {
+		statuses = append(statuses, result.Status)
+	}
+	want := []constants.StepStatus{constants.StepStatusSuccess, constants.StepStatusFailed, constants.StepStatusSkipped}
+	if !reflect.DeepEqual(statuses, want) {
+		t.Errorf("statuses = %q, want %q", statuses, want)
+	}
+}
@@ -0,0 +1,265 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"path"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	redis_client "github.com/RajVerma97/golang-vercel/backend/internal/client/redis"
+	"github.com/RajVerma97/golang-vercel/backend/internal/constants"
+	"github.com/RajVerma97/golang-vercel/backend/internal/dto"
+	"github.com/RajVerma97/golang-vercel/backend/internal/logger"
+	"go.uber.org/zap"
+)
+
+const (
+	projectIDKey      = "projects:id"
+	projectsByNameKey = "projects:name"
+	// projectsByRepoKey maps a repo to its first project, from before repos could hold several
+	projectsByRepoKey = "projects:repo"
+
+	defaultProductionBranch = "main"
+)
+
+var invalidProjectNameChars = regexp.MustCompile(`[^a-z0-9-]+`)
+
+var ErrRepositoryMismatch = errors.New("repository does not belong to the project")
+
+func projectKey(id uint64) string {
+	return fmt.Sprintf("project:%d", id)
+}
+
+// repoProjectsKey holds the ids of every project built from repoURL
+func repoProjectsKey(repoURL string) string {
+	return "projects:repo:" + repoURL
+}
+
+type ProjectServiceConfig struct {
+	RedisClient *redis_client.RedisClient
+}
+type ProjectService struct {
+	RedisClient *redis_client.RedisClient
+}
+
+func NewProjectService(config *ProjectServiceConfig) *ProjectService {
+	return &ProjectService{
+		RedisClient: config.RedisClient,
+	}
+}
+
+func (s *ProjectService) Create(ctx context.Context, project *dto.Project) error {
+	id, err := s.RedisClient.NextID(ctx, projectIDKey)
+	if err != nil {
+		return fmt.Errorf("failed to assign project id: %w", err)
+	}
+	project.ID = id
+
+	name := project.Name
+	if name == "" {
+		name = strings.TrimSuffix(path.Base(project.RepoUrl), ".git")
+	}
+	name = invalidProjectNameChars.ReplaceAllString(strings.ToLower(name), "-")
+	name = strings.Trim(name, "-")
+	if name == "" {
+		name = "project"
+	}
+	// project names become alias hostnames, so they have to be unique
+	if _, taken, err := s.RedisClient.GetHashField(ctx, projectsByNameKey, name); err != nil {
+		return err
+	} else if taken {
+		name = fmt.Sprintf("%s-%d", name, id)
+	}
+	project.Name = name
+
+	if project.ProductionBranch == "" {
+		project.ProductionBranch = defaultProductionBranch
+	}
+	now := time.Now()
+	project.CreatedAt = now
+	project.UpdatedAt = now
+
+	if err := s.Save(ctx, project); err != nil {
+		return err
+	}
+	if err := s.RedisClient.SetHashField(ctx, projectsByNameKey, project.Name, strconv.FormatUint(id, 10)); err != nil {
+		return err
+	}
+	if err := s.RedisClient.AddToSet(ctx, repoProjectsKey(project.RepoUrl), strconv.FormatUint(id, 10)); err != nil {
+		return err
+	}
+	logger.Debug("Successfully created project", zap.Uint64("project_id", id), zap.String("name", project.Name))
+	return nil
+}
+
+func (s *ProjectService) Save(ctx context.Context, project *dto.Project) error {
+	project.UpdatedAt = time.Now()
+	return s.RedisClient.SetJSON(ctx, projectKey(project.ID), project, 0)
+}
+
+// Update applies update to the stored project and saves it, without overwriting fields changed
+// concurrently since the caller loaded its copy. It returns the updated project.
+func (s *ProjectService) Update(ctx context.Context, id uint64, update func(project *dto.Project)) (*dto.Project, error) {
+	var project dto.Project
+	found, err := s.RedisClient.UpdateJSON(ctx, projectKey(id), &project, func() error {
+		update(&project)
+		project.UpdatedAt = time.Now()
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, fmt.Errorf("project %d not found", id)
+	}
+	return &project, nil
+}
+
+// Get returns nil when the project does not exist
+func (s *ProjectService) Get(ctx context.Context, id uint64) (*dto.Project, error) {
+	var project dto.Project
+	found, err := s.RedisClient.GetJSON(ctx, projectKey(id), &project)
+	if err != nil || !found {
+		return nil, err
+	}
+	return &project, nil
+}
+
+// ListByRepoURL returns every project built from repoURL, ordered by id
+func (s *ProjectService) ListByRepoURL(ctx context.Context, repoURL string) ([]*dto.Project, error) {
+	ids, err := s.RedisClient.GetSet(ctx, repoProjectsKey(repoURL))
+	if err != nil {
+		return nil, err
+	}
+	legacy, found, err := s.RedisClient.GetHashField(ctx, projectsByRepoKey, repoURL)
+	if err != nil {
+		return nil, err
+	}
+	if found && !slices.Contains(ids, legacy) {
+		ids = append(ids, legacy)
+	}
+
+	projects := make([]*dto.Project, 0, len(ids))
+	for _, value := range ids {
+		id, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid project id %q for repo %s: %w", value, repoURL, err)
+		}
+		project, err := s.Get(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		if project != nil {
+			projects = append(projects, project)
+		}
+	}
+	sort.Slice(projects, func(i, j int) bool { return projects[i].ID < projects[j].ID })
+	return projects, nil
+}
+
+// Resolve returns the project for the build, creating one for unknown repositories.
+// Builds without a project go to the repository's first project.
+func (s *ProjectService) Resolve(ctx context.Context, build *dto.Build) (*dto.Project, error) {
+	if build.ProjectID != 0 {
+		project, err := s.Get(ctx, build.ProjectID)
+		if err != nil {
+			return nil, err
+		}
+		if project == nil {
+			return nil, fmt.Errorf("project %d not found", build.ProjectID)
+		}
+		// pull requests from forks are fetched from the project's repository too, never from the fork
+		if !SameRepository(project.RepoUrl, build.RepoUrl) {
+			return nil, fmt.Errorf("%w: project %d builds %s, not %s", ErrRepositoryMismatch, project.ID, project.RepoUrl, build.RepoUrl)
+		}
+		return project, nil
+	}
+
+	projects, err := s.ResolveRepo(ctx, build.RepoUrl)
+	if err != nil {
+		return nil, err
+	}
+	build.ProjectID = projects[0].ID
+	return projects[0], nil
+}
+
+// SameRepository reports whether two URLs point to the same repository, whatever their scheme,
+// credentials, .git suffix or trailing slash. Hosts are compared case-insensitively, paths are not.
+func SameRepository(a, b string) bool {
+	return repositoryIdentity(a) == repositoryIdentity(b)
+}
+
+func repositoryIdentity(repoURL string) string {
+	u, err := url.Parse(httpsURL(strings.TrimSpace(repoURL)))
+	if err != nil || u.Host == "" {
+		return strings.TrimSpace(repoURL)
+	}
+	repoPath := strings.TrimSuffix(strings.Trim(u.Path, "/"), ".git")
+	return strings.ToLower(u.Hostname()) + "/" + repoPath
+}
+
+// ResolveRepo returns the projects of repoURL, creating one if the repository is unknown
+func (s *ProjectService) ResolveRepo(ctx context.Context, repoURL string) ([]*dto.Project, error) {
+	projects, err := s.ListByRepoURL(ctx, repoURL)
+	if err != nil {
+		return nil, err
+	}
+	if len(projects) == 0 {
+		project := &dto.Project{RepoUrl: repoURL}
+		if err := s.Create(ctx, project); err != nil {
+			return nil, err
+		}
+		projects = append(projects, project)
+	}
+	return projects, nil
+}
+
+// Affected reports whether a push touching changedFiles needs a build of project.
+// A nil changedFiles means the provider did not say, which affects every project.
+func (s *ProjectService) Affected(project *dto.Project, changedFiles []string) bool {
+	if changedFiles == nil {
+		return true
+	}
+	paths := append([]string{project.RootDirectory}, project.WatchPaths...)
+	for _, dir := range paths {
+		dir = strings.Trim(path.Clean("/"+dir), "/")
+		if dir == "" {
+			return true
+		}
+		for _, file := range changedFiles {
+			if strings.HasPrefix(file, dir+"/") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// IsProductionBuild reports whether build targets the project's production branch.
+// Pull request builds never do, even when the head branch shares the production branch's name.
+func (s *ProjectService) IsProductionBuild(project *dto.Project, build *dto.Build) bool {
+	if build.PullRequest != nil {
+		return false
+	}
+	return build.Branch == nil || *build.Branch == "" || *build.Branch == project.ProductionBranch
+}
+
+// FromFork reports whether build is of a pull request from a fork, whose code the project's owners do not control
+func (s *ProjectService) FromFork(project *dto.Project, build *dto.Build) bool {
+	return build.PullRequest != nil && build.HeadRepoUrl != "" && !SameRepository(project.RepoUrl, build.HeadRepoUrl)
+}
+
+// Environment returns where a build of project gets deployed to
+func (s *ProjectService) Environment(project *dto.Project, build *dto.Build) constants.DeploymentEnvironment {
+	if s.IsProductionBuild(project, build) {
+		return constants.DeploymentEnvironmentProduction
+	}
+	return constants.DeploymentEnvironmentPreview
+}
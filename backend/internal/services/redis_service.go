@@ -2,11 +2,25 @@ package services
 
 import (
 	"context"
+	"fmt"
 
 	redis_client "github.com/RajVerma97/golang-vercel/backend/internal/client/redis"
 	"github.com/RajVerma97/golang-vercel/backend/internal/dto"
 )
 
+const (
+	buildIDKey      = "builds:id"
+	deploymentIDKey = "deployments:id"
+)
+
+func buildKey(id uint64) string {
+	return fmt.Sprintf("build:%d", id)
+}
+
+func deploymentKey(id uint64) string {
+	return fmt.Sprintf("deployment:%d", id)
+}
+
 type RedisServiceConfig struct {
 	RedisClient *redis_client.RedisClient
 }
@@ -21,10 +35,52 @@ func NewRedisService(config *RedisServiceConfig) *RedisService {
 	}
 }
 
-func (s *RedisService) EnqueueBuild(ctx context.Context,build *dto.Build) error {
-	return s.RedisClient.EnqueueBuild(ctx,build)
+func (s *RedisService) EnqueueBuild(ctx context.Context, build *dto.Build) error {
+	if build.ID == 0 {
+		id, err := s.RedisClient.NextID(ctx, buildIDKey)
+		if err != nil {
+			return fmt.Errorf("failed to assign build id: %w", err)
+		}
+		build.ID = id
+	}
+	if err := s.SaveBuild(ctx, build); err != nil {
+		return err
+	}
+	return s.RedisClient.EnqueueBuild(ctx, build)
 }
 
 func (s *RedisService) DequeueBuild(ctx context.Context) (*dto.Build, error) {
 	return s.RedisClient.DequeueBuild(ctx)
 }
+
+func (s *RedisService) SaveBuild(ctx context.Context, build *dto.Build) error {
+	return s.RedisClient.SetJSON(ctx, buildKey(build.ID), build, 0)
+}
+
+// GetBuild returns nil when the build does not exist
+func (s *RedisService) GetBuild(ctx context.Context, id uint64) (*dto.Build, error) {
+	var build dto.Build
+	found, err := s.RedisClient.GetJSON(ctx, buildKey(id), &build)
+	if err != nil || !found {
+		return nil, err
+	}
+	return &build, nil
+}
+
+func (s *RedisService) NextDeploymentID(ctx context.Context) (uint64, error) {
+	return s.RedisClient.NextID(ctx, deploymentIDKey)
+}
+
+func (s *RedisService) SaveDeployment(ctx context.Context, deployment *dto.Deployment) error {
+	return s.RedisClient.SetJSON(ctx, deploymentKey(deployment.ID), deployment, 0)
+}
+
+// GetDeployment returns nil when the deployment does not exist
+func (s *RedisService) GetDeployment(ctx context.Context, id uint64) (*dto.Deployment, error) {
+	var deployment dto.Deployment
+	found, err := s.RedisClient.GetJSON(ctx, deploymentKey(id), &deployment)
+	if err != nil || !found {
+		return nil, err
+	}
+	return &deployment, nil
+}
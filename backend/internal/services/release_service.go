@@ -0,0 +1,513 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	docker_client "github.com/RajVerma97/golang-vercel/backend/internal/client/docker"
+	redis_client "github.com/RajVerma97/golang-vercel/backend/internal/client/redis"
+	"github.com/RajVerma97/golang-vercel/backend/internal/config"
+	"github.com/RajVerma97/golang-vercel/backend/internal/constants"
+	"github.com/RajVerma97/golang-vercel/backend/internal/dto"
+	"github.com/RajVerma97/golang-vercel/backend/internal/logger"
+	"github.com/RajVerma97/golang-vercel/backend/internal/proxy"
+	"go.uber.org/zap"
+)
+
+const (
+	// aliasesKey maps alias hosts to the deployment they serve
+	aliasesKey = "aliases"
+
+	maxReleaseHistory = 50
+)
+
+var (
+	ErrDeploymentNotFound = errors.New("deployment not found")
+	ErrNoRollbackTarget   = errors.New("no previous deployment to roll back to")
+	ErrInvalidRollback    = errors.New("deployment cannot be rolled back to")
+)
+
+var invalidHostLabelChars = regexp.MustCompile(`[^a-z0-9-]+`)
+
+// releasesKey lists the project's production deployments, newest first
+func releasesKey(projectID uint64) string {
+	return fmt.Sprintf("project:%d:releases", projectID)
+}
+
+// previewsKey maps branches to their latest preview deployment
+func previewsKey(projectID uint64) string {
+	return fmt.Sprintf("project:%d:previews", projectID)
+}
+
+// branchDeploymentsKey lists every preview deployment of a branch, newest first
+func branchDeploymentsKey(projectID uint64, branch string) string {
+	return fmt.Sprintf("project:%d:branch:%s:deployments", projectID, branch)
+}
+
+// hostLabel turns s into a valid DNS label
+func hostLabel(s string) string {
+	label := invalidHostLabelChars.ReplaceAllString(strings.ToLower(s), "-")
+	label = strings.Trim(label, "-")
+	if len(label) > 63 {
+		label = strings.TrimRight(label[:63], "-")
+	}
+	return label
+}
+
+type ReleaseServiceConfig struct {
+	DockerClient   *docker_client.DockerClient
+	RedisClient    *redis_client.RedisClient
+	RedisService   *RedisService
+	ProjectService *ProjectService
+	DeployService  *DeployService
+	EnvService     *EnvService
+	Proxy          *proxy.Proxy
+	ProxyConfig    *config.ProxyConfig
+	DeployConfig   *config.DeployConfig
+}
+
+// ReleaseService moves project aliases between deployments and retires the ones left behind
+type ReleaseService struct {
+	DockerClient   *docker_client.DockerClient
+	RedisClient    *redis_client.RedisClient
+	RedisService   *RedisService
+	ProjectService *ProjectService
+	DeployService  *DeployService
+	EnvService     *EnvService
+	Proxy          *proxy.Proxy
+	proxyConfig    *config.ProxyConfig
+	deployConfig   *config.DeployConfig
+}
+
+func NewReleaseService(config *ReleaseServiceConfig) *ReleaseService {
+	return &ReleaseService{
+		DockerClient:   config.DockerClient,
+		RedisClient:    config.RedisClient,
+		RedisService:   config.RedisService,
+		ProjectService: config.ProjectService,
+		DeployService:  config.DeployService,
+		EnvService:     config.EnvService,
+		Proxy:          config.Proxy,
+		proxyConfig:    config.ProxyConfig,
+		deployConfig:   config.DeployConfig,
+	}
+}
+
+func (s *ReleaseService) ProductionHost(project *dto.Project) string {
+	return fmt.Sprintf("%s.%s", project.Name, s.proxyConfig.Domain)
+}
+
+// BranchHost is the stable preview host of a branch, e.g. myapp--git-feature-login.localhost
+func (s *ReleaseService) BranchHost(project *dto.Project, branch string) string {
+	return fmt.Sprintf("%s.%s", hostLabel(fmt.Sprintf("%s--git-%s", project.Name, branch)), s.proxyConfig.Domain)
+}
+
+// CommitHost is the immutable host of a single commit, e.g. myapp--3f4d535.localhost
+func (s *ReleaseService) CommitHost(project *dto.Project, commitHash string) string {
+	return fmt.Sprintf("%s.%s", hostLabel(fmt.Sprintf("%s--%s", project.Name, shortCommit(commitHash))), s.proxyConfig.Domain)
+}
+
+func (s *ReleaseService) aliasURL(host string) string {
+	return fmt.Sprintf("http://%s:%d", host, s.proxyConfig.Port)
+}
+
+// Promote switches the project's production alias to deployment, which must already be healthy.
+// The previous production deployment is drained and stopped in the background.
+func (s *ReleaseService) Promote(ctx context.Context, project *dto.Project, deployment *dto.Deployment) error {
+	host := s.ProductionHost(project)
+
+	if err := s.pointAlias(ctx, host, deployment); err != nil {
+		return err
+	}
+	dropped, err := s.RedisClient.PushListTrimmed(ctx, releasesKey(project.ID), strconv.FormatUint(deployment.ID, 10), maxReleaseHistory)
+	if err != nil {
+		logger.Error("failed to record release", err, zap.Uint64("deployment_id", deployment.ID))
+	}
+
+	// only the production fields are written, the project may have been changed since it was loaded
+	var previousID uint64
+	updated, err := s.ProjectService.Update(ctx, project.ID, func(stored *dto.Project) {
+		previousID = stored.ProductionDeploymentID
+		stored.ProductionDeploymentID = deployment.ID
+		stored.ProductionURL = s.aliasURL(host)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to save project: %w", err)
+	}
+	project.ProductionDeploymentID = updated.ProductionDeploymentID
+	project.ProductionURL = updated.ProductionURL
+	project.UpdatedAt = updated.UpdatedAt
+	logger.Info("Promoted deployment to production",
+		zap.String("alias", host),
+		zap.Uint64("deployment_id", deployment.ID),
+		zap.Uint64("previous_deployment_id", previousID))
+
+	if previousID != 0 && previousID != deployment.ID {
+		go s.retire(context.Background(), previousID)
+	}
+	if len(dropped) > 0 {
+		go s.forgetReleases(context.Background(), project.ID, dropped)
+	}
+	return nil
+}
+
+// forgetReleases frees the containers and images of deployments that fell out of the release history,
+// unless they are still in it further up after a rollback or serving
+func (s *ReleaseService) forgetReleases(ctx context.Context, projectID uint64, dropped []string) {
+	releases, err := s.RedisClient.GetList(ctx, releasesKey(projectID))
+	if err != nil {
+		logger.Error("failed to load release history", err, zap.Uint64("project_id", projectID))
+		return
+	}
+	for _, value := range dropped {
+		if slices.Contains(releases, value) {
+			continue
+		}
+		id, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			continue
+		}
+		deployment, err := s.RedisService.GetDeployment(ctx, id)
+		if err != nil || deployment == nil {
+			logger.Error("failed to load dropped release", err, zap.Uint64("deployment_id", id))
+			continue
+		}
+		if deployment.Status == constants.DeploymentStatusRunning || deployment.Status == constants.DeploymentStatusDraining {
+			continue
+		}
+		if deployment.Container != nil {
+			if err := s.DockerClient.RemoveContainer(ctx, deployment.Container.ID); err != nil {
+				logger.Error("failed to remove dropped release", err, zap.Uint64("deployment_id", id))
+				continue
+			}
+		}
+		s.removeBuildImage(ctx, deployment.BuildID)
+	}
+}
+
+// RemoveImage deletes the image of a Dockerfile build that no deployment is going to run. The build is
+// left without an artifact to roll back to, saving it is up to the caller.
+func (s *ReleaseService) RemoveImage(ctx context.Context, build *dto.Build) {
+	if build.Image == "" {
+		return
+	}
+	if err := s.DockerClient.RemoveImage(ctx, build.Image); err != nil {
+		logger.Warn("Failed to remove build image", zap.Uint64("build_id", build.ID), zap.String("image", build.Image), zap.Error(err))
+		return
+	}
+	logger.Info("Removed build image", zap.Uint64("build_id", build.ID), zap.String("image", build.Image))
+	build.Image = ""
+}
+
+// removeBuildImage is RemoveImage for a build that is only known by its ID
+func (s *ReleaseService) removeBuildImage(ctx context.Context, buildID uint64) {
+	build, err := s.RedisService.GetBuild(ctx, buildID)
+	if err != nil || build == nil || build.Image == "" {
+		return
+	}
+	s.RemoveImage(ctx, build)
+	if build.Image != "" {
+		return
+	}
+	build.UpdatedAt = time.Now()
+	if err := s.RedisService.SaveBuild(ctx, build); err != nil {
+		logger.Error("failed to save build", err, zap.Uint64("build_id", buildID))
+	}
+}
+
+// PreviewRef names the preview environment of a deployment: pr-<number> for pull requests, the branch otherwise
+func PreviewRef(deployment *dto.Deployment) string {
+	if deployment.PullRequest != nil {
+		return fmt.Sprintf("pr-%d", *deployment.PullRequest)
+	}
+	if deployment.Branch != nil {
+		return *deployment.Branch
+	}
+	return ""
+}
+
+// PublishPreview points the preview alias at a healthy preview deployment and gives it an immutable commit alias.
+// Earlier previews keep running so their commit URLs stay reachable.
+func (s *ReleaseService) PublishPreview(ctx context.Context, project *dto.Project, deployment *dto.Deployment) error {
+	branch := PreviewRef(deployment)
+	if branch == "" {
+		return fmt.Errorf("preview deployment %d has no branch", deployment.ID)
+	}
+	deploymentID := strconv.FormatUint(deployment.ID, 10)
+
+	var aliases []string
+	if deployment.CommitHash != nil && *deployment.CommitHash != "" {
+		commitHost := s.CommitHost(project, *deployment.CommitHash)
+		if err := s.pointAlias(ctx, commitHost, deployment); err != nil {
+			return err
+		}
+		aliases = append(aliases, s.aliasURL(commitHost))
+	}
+
+	// only move the branch alias once the immutable one is live
+	branchHost := s.BranchHost(project, branch)
+	if err := s.pointAlias(ctx, branchHost, deployment); err != nil {
+		return err
+	}
+	aliases = append(aliases, s.aliasURL(branchHost))
+
+	if err := s.RedisClient.SetHashField(ctx, previewsKey(project.ID), branch, deploymentID); err != nil {
+		return fmt.Errorf("failed to record preview: %w", err)
+	}
+	if err := s.RedisClient.PushList(ctx, branchDeploymentsKey(project.ID, branch), deploymentID, 0); err != nil {
+		return fmt.Errorf("failed to record preview: %w", err)
+	}
+
+	deployment.Aliases = aliases
+	deployment.UpdatedAt = time.Now()
+	if err := s.RedisService.SaveDeployment(ctx, deployment); err != nil {
+		return fmt.Errorf("failed to save deployment: %w", err)
+	}
+	logger.Info("Published preview deployment",
+		zap.String("branch", branch),
+		zap.Strings("aliases", aliases),
+		zap.Uint64("deployment_id", deployment.ID))
+	return nil
+}
+
+// TeardownPreview removes every alias, container and image of a preview environment
+func (s *ReleaseService) TeardownPreview(ctx context.Context, project *dto.Project, ref string) error {
+	values, err := s.RedisClient.GetList(ctx, branchDeploymentsKey(project.ID, ref))
+	if err != nil {
+		return err
+	}
+
+	for _, value := range values {
+		id, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			continue
+		}
+		deployment, err := s.RedisService.GetDeployment(ctx, id)
+		if err != nil {
+			return err
+		}
+		if deployment == nil {
+			continue
+		}
+		if err := s.removeDeployment(ctx, deployment); err != nil {
+			return err
+		}
+		s.removeBuildImage(ctx, deployment.BuildID)
+	}
+
+	if err := s.RedisClient.DeleteHashField(ctx, previewsKey(project.ID), ref); err != nil {
+		return err
+	}
+	if err := s.RedisClient.Delete(ctx, branchDeploymentsKey(project.ID, ref)); err != nil {
+		return err
+	}
+	logger.Info("Tore down preview", zap.Uint64("project_id", project.ID), zap.String("ref", ref), zap.Int("deployments", len(values)))
+	return nil
+}
+
+// removeDeployment drops the deployment's aliases and frees its container
+func (s *ReleaseService) removeDeployment(ctx context.Context, deployment *dto.Deployment) error {
+	deploymentID := strconv.FormatUint(deployment.ID, 10)
+	for _, alias := range deployment.Aliases {
+		aliasURL, err := url.Parse(alias)
+		if err != nil {
+			continue
+		}
+		host := aliasURL.Hostname()
+		// the alias may have moved on to a newer deployment since
+		current, found, err := s.RedisClient.GetHashField(ctx, aliasesKey, host)
+		if err != nil {
+			return err
+		}
+		if found && current == deploymentID {
+			s.Proxy.RemoveRoute(host)
+			if err := s.RedisClient.DeleteHashField(ctx, aliasesKey, host); err != nil {
+				return err
+			}
+		}
+	}
+
+	if deployment.Container != nil {
+		if err := s.DockerClient.RemoveContainer(ctx, deployment.Container.ID); err != nil {
+			return fmt.Errorf("failed to remove deployment container: %w", err)
+		}
+	}
+
+	now := time.Now()
+	deployment.Status = constants.DeploymentStatusStopped
+	deployment.StoppedAt = &now
+	deployment.UpdatedAt = now
+	return s.RedisService.SaveDeployment(ctx, deployment)
+}
+
+// Rollback points the production alias back at an earlier deployment of the project.
+// A zero deploymentID picks the most recent production deployment before the current one.
+// Stopped deployments are restarted from their retained artifact; nothing is rebuilt.
+func (s *ReleaseService) Rollback(ctx context.Context, project *dto.Project, deploymentID uint64) (*dto.Deployment, error) {
+	if deploymentID == 0 {
+		id, err := s.previousRelease(ctx, project)
+		if err != nil {
+			return nil, err
+		}
+		deploymentID = id
+	}
+
+	deployment, err := s.RedisService.GetDeployment(ctx, deploymentID)
+	if err != nil {
+		return nil, err
+	}
+	if deployment == nil || deployment.ProjectID != project.ID {
+		return nil, ErrDeploymentNotFound
+	}
+	if deployment.ID == project.ProductionDeploymentID {
+		return nil, fmt.Errorf("%w: deployment %d is already in production", ErrInvalidRollback, deployment.ID)
+	}
+	if deployment.Environment == constants.DeploymentEnvironmentPreview {
+		return nil, fmt.Errorf("%w: deployment %d is a preview", ErrInvalidRollback, deployment.ID)
+	}
+	if deployment.Status == constants.DeploymentStatusFailed || deployment.Status == constants.DeploymentStatusPending {
+		return nil, fmt.Errorf("%w: deployment %d never became healthy", ErrInvalidRollback, deployment.ID)
+	}
+
+	build, err := s.RedisService.GetBuild(ctx, deployment.BuildID)
+	if err != nil {
+		return nil, err
+	}
+	if build == nil || build.BinaryPath == nil && build.Image == "" {
+		return nil, fmt.Errorf("%w: deployment %d has no retained artifact", ErrInvalidRollback, deployment.ID)
+	}
+
+	logger.Info("Rolling back production", zap.Uint64("project_id", project.ID), zap.Uint64("deployment_id", deployment.ID))
+	if deployment.Status != constants.DeploymentStatusRunning {
+		// a recreated container gets today's runtime env vars
+		env, err := s.EnvService.Resolve(ctx, project.ID, constants.DeploymentEnvironmentProduction)
+		if err != nil {
+			return nil, err
+		}
+		if err := s.DeployService.RestartDeployment(ctx, project, build, deployment, env); err != nil {
+			return nil, fmt.Errorf("failed to restart deployment %d: %w", deployment.ID, err)
+		}
+		deployment.UpdatedAt = time.Now()
+		if err := s.RedisService.SaveDeployment(ctx, deployment); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := s.Promote(ctx, project, deployment); err != nil {
+		return nil, err
+	}
+	return deployment, nil
+}
+
+// previousRelease returns the newest production deployment other than the current one
+func (s *ReleaseService) previousRelease(ctx context.Context, project *dto.Project) (uint64, error) {
+	releases, err := s.RedisClient.GetList(ctx, releasesKey(project.ID))
+	if err != nil {
+		return 0, err
+	}
+	for _, value := range releases {
+		id, err := strconv.ParseUint(value, 10, 64)
+		if err != nil || id == project.ProductionDeploymentID {
+			continue
+		}
+		return id, nil
+	}
+	return 0, ErrNoRollbackTarget
+}
+
+// pointAlias atomically switches host to deployment in the proxy and records it
+func (s *ReleaseService) pointAlias(ctx context.Context, host string, deployment *dto.Deployment) error {
+	previousTarget, err := s.Proxy.SetRoute(host, deployment.URL)
+	if err != nil {
+		return fmt.Errorf("failed to update proxy route: %w", err)
+	}
+	if err := s.RedisClient.SetHashField(ctx, aliasesKey, host, strconv.FormatUint(deployment.ID, 10)); err != nil {
+		// keep the proxy consistent with what is persisted
+		if previousTarget != "" {
+			_, _ = s.Proxy.SetRoute(host, previousTarget)
+		} else {
+			s.Proxy.RemoveRoute(host)
+		}
+		return fmt.Errorf("failed to save alias: %w", err)
+	}
+	return nil
+}
+
+// retire drains the deployment's in-flight requests and stops its container
+func (s *ReleaseService) retire(ctx context.Context, deploymentID uint64) {
+	deployment, err := s.RedisService.GetDeployment(ctx, deploymentID)
+	if err != nil || deployment == nil {
+		logger.Error("failed to load deployment to retire", err, zap.Uint64("deployment_id", deploymentID))
+		return
+	}
+	if deployment.Status != constants.DeploymentStatusRunning {
+		return
+	}
+
+	deployment.Status = constants.DeploymentStatusDraining
+	deployment.UpdatedAt = time.Now()
+	if err := s.RedisService.SaveDeployment(ctx, deployment); err != nil {
+		logger.Error("failed to save deployment", err, zap.Uint64("deployment_id", deploymentID))
+	}
+
+	if !s.Proxy.Drain(ctx, deployment.URL, s.deployConfig.DrainGracePeriod) {
+		logger.Warn("Stopping deployment with requests still in flight", zap.Uint64("deployment_id", deploymentID))
+	}
+	// a rollback may have put it back behind an alias while it was draining
+	if s.Proxy.Serves(deployment.URL) {
+		logger.Info("Deployment is serving again, keeping it running", zap.Uint64("deployment_id", deploymentID))
+		return
+	}
+
+	if deployment.Container != nil {
+		if err := s.DockerClient.StopContainer(ctx, deployment.Container.ID); err != nil {
+			logger.Error("failed to stop retired deployment", err, zap.Uint64("deployment_id", deploymentID))
+			return
+		}
+	}
+
+	now := time.Now()
+	deployment.Status = constants.DeploymentStatusStopped
+	deployment.StoppedAt = &now
+	deployment.UpdatedAt = now
+	if err := s.RedisService.SaveDeployment(ctx, deployment); err != nil {
+		logger.Error("failed to save deployment", err, zap.Uint64("deployment_id", deploymentID))
+		return
+	}
+	logger.Info("Retired deployment", zap.Uint64("deployment_id", deploymentID))
+}
+
+// RestoreRoutes loads the persisted aliases into the proxy
+func (s *ReleaseService) RestoreRoutes(ctx context.Context) error {
+	aliases, err := s.RedisClient.GetHash(ctx, aliasesKey)
+	if err != nil {
+		return err
+	}
+	for host, value := range aliases {
+		id, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			logger.Warn("Skipping alias with invalid deployment id", zap.String("alias", host), zap.String("value", value))
+			continue
+		}
+		deployment, err := s.RedisService.GetDeployment(ctx, id)
+		if err != nil {
+			return err
+		}
+		if deployment == nil {
+			logger.Warn("Skipping alias for missing deployment", zap.String("alias", host), zap.Uint64("deployment_id", id))
+			continue
+		}
+		if _, err := s.Proxy.SetRoute(host, deployment.URL); err != nil {
+			return err
+		}
+	}
+	logger.Debug("Restored proxy routes", zap.Int("count", len(aliases)))
+	return nil
+}
@@ -0,0 +1,276 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/RajVerma97/golang-vercel/backend/internal/constants"
+	"github.com/RajVerma97/golang-vercel/backend/internal/dto"
+)
+
+func TestReleaseServiceRollback(t *testing.T) {
+	production, preview := constants.DeploymentEnvironmentProduction, constants.DeploymentEnvironmentPreview
+	running, failed := constants.DeploymentStatusRunning, constants.DeploymentStatusFailed
+	tests := []struct {
+		name         string
+		deployments  []*dto.Deployment
+		releases     []uint64
+		deploymentID uint64
+		want         uint64
+		wantErr      error
+	}{
+		{
+			name:        "previous release",
+			deployments: []*dto.Deployment{{ID: 1, Status: running}, {ID: 2, Status: running}, {ID: 3, Status: running}},
+			releases:    []uint64{1, 2, 3},
+			want:        2,
+		},
+		{
+			name:         "chosen release",
+			deployments:  []*dto.Deployment{{ID: 1, Status: running}, {ID: 2, Status: running}, {ID: 3, Status: running}},
+			releases:     []uint64{1, 2, 3},
+			deploymentID: 1,
+			want:         1,
+		},
+		{
+			name:        "no previous release",
+			deployments: []*dto.Deployment{{ID: 1, Status: running}},
+			releases:    []uint64{1},
+			wantErr:     ErrNoRollbackTarget,
+		},
+		{
+			name:         "already in production",
+			deployments:  []*dto.Deployment{{ID: 1, Status: running}, {ID: 2, Status: running}},
+			releases:     []uint64{1, 2},
+			deploymentID: 2,
+			wantErr:      ErrInvalidRollback,
+		},
+		{
+			name:         "preview",
+			deployments:  []*dto.Deployment{{ID: 1, Status: running}, {ID: 2, Status: running, Environment: preview}, {ID: 3, Status: running}},
+			releases:     []uint64{1, 3},
+			deploymentID: 2,
+			wantErr:      ErrInvalidRollback,
+		},
+		{
+			name:         "never healthy",
+			deployments:  []*dto.Deployment{{ID: 1, Status: failed}, {ID: 2, Status: running}},
+			releases:     []uint64{2},
+			deploymentID: 1,
+			wantErr:      ErrInvalidRollback,
+		},
+		{
+			name:         "other project",
+			deployments:  []*dto.Deployment{{ID: 1, ProjectID: 2, Status: running}, {ID: 2, Status: running}},
+			releases:     []uint64{2},
+			deploymentID: 1,
+			wantErr:      ErrDeploymentNotFound,
+		},
+		{
+			name:         "unknown deployment",
+			deployments:  []*dto.Deployment{{ID: 1, Status: running}},
+			releases:     []uint64{1},
+			deploymentID: 9,
+			wantErr:      ErrDeploymentNotFound,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			s := newTestReleaseService(t)
+			project := &dto.Project{Name: "app", RepoUrl: "https://github.com/acme/app"}
+			if err := s.ProjectService.Create(ctx, project); err != nil {
+				t.Fatal(err)
+			}
+			for _, deployment := range tt.deployments {
+				if deployment.ProjectID == 0 {
+					deployment.ProjectID = project.ID
+				}
+				if deployment.Environment == "" {
+					deployment.Environment = production
+				}
+				deployment.BuildID = deployment.ID
+				saveTestDeployment(t, s, deployment)
+			}
+			recordReleases(t, s, project, tt.releases...)
+			current := project.ProductionDeploymentID
+
+			got, err := s.Rollback(ctx, project, tt.deploymentID)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("Rollback() error = %v, want %v", err, tt.wantErr)
+				}
+				if project.ProductionDeploymentID != current {
+					t.Errorf("production moved to %d after a failed rollback", project.ProductionDeploymentID)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Rollback() error = %v", err)
+			}
+			if got.ID != tt.want {
+				t.Errorf("Rollback() = deployment %d, want %d", got.ID, tt.want)
+			}
+			stored, err := s.ProjectService.Get(ctx, project.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if stored.ProductionDeploymentID != tt.want {
+				t.Errorf("stored production deployment = %d, want %d", stored.ProductionDeploymentID, tt.want)
+			}
+			if target, ok := s.Proxy.Route(s.ProductionHost(project)); !ok || target != got.URL {
+				t.Errorf("production alias routes to %q, want %q", target, got.URL)
+			}
+		})
+	}
+}
+
+func TestReleaseServiceRollbackWithoutArtifact(t *testing.T) {
+	ctx := context.Background()
+	s := newTestReleaseService(t)
+	project := &dto.Project{Name: "app", RepoUrl: "https://github.com/acme/app"}
+	if err := s.ProjectService.Create(ctx, project); err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []uint64{1, 2} {
+		saveTestDeployment(t, s, &dto.Deployment{ID: id, BuildID: id, ProjectID: project.ID, Environment: constants.DeploymentEnvironmentProduction, Status: constants.DeploymentStatusRunning})
+	}
+	recordReleases(t, s, project, 1, 2)
+	// the build of the first deployment no longer has its binary
+	if err := s.RedisService.SaveBuild(ctx, &dto.Build{ID: 1, ProjectID: project.ID}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Rollback(ctx, project, 0); !errors.Is(err, ErrInvalidRollback) {
+		t.Errorf("Rollback() error = %v, want ErrInvalidRollback", err)
+	}
+}
+
+// recordReleases sets up the release history, the last release being in production. Promote is
+// not used as it retires the deployments it replaces, which a rollback would have to restart.
+func recordReleases(t *testing.T, s *ReleaseService, project *dto.Project, ids ...uint64) {
+	t.Helper()
+	ctx := context.Background()
+	for _, id := range ids {
+		if err := s.RedisClient.PushList(ctx, releasesKey(project.ID), strconv.FormatUint(id, 10), maxReleaseHistory); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(ids) == 0 {
+		return
+	}
+	current, err := s.RedisService.GetDeployment(ctx, ids[len(ids)-1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.pointAlias(ctx, s.ProductionHost(project), current); err != nil {
+		t.Fatal(err)
+	}
+	updated, err := s.ProjectService.Update(ctx, project.ID, func(stored *dto.Project) {
+		stored.ProductionDeploymentID = current.ID
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	*project = *updated
+}
+
+func TestReleaseServicePreviews(t *testing.T) {
+	ctx := context.Background()
+	s := newTestReleaseService(t)
+	project := &dto.Project{Name: "app", RepoUrl: "https://github.com/acme/app"}
+	if err := s.ProjectService.Create(ctx, project); err != nil {
+		t.Fatal(err)
+	}
+	branch, first, second := "feature/Login", "3f4d535aaaa", "9c1e2b7bbbb"
+	preview := func(id uint64, commit string) *dto.Deployment {
+		deployment := saveTestDeployment(t, s, &dto.Deployment{
+			ID: id, BuildID: id, ProjectID: project.ID, Environment: constants.DeploymentEnvironmentPreview,
+			Branch: &branch, CommitHash: &commit, Status: constants.DeploymentStatusRunning,
+		})
+		if err := s.PublishPreview(ctx, project, deployment); err != nil {
+			t.Fatalf("PublishPreview() error = %v", err)
+		}
+		return deployment
+	}
+	route := func(host string) string {
+		target, _ := s.Proxy.Route(host)
+		return target
+	}
+
+	older := preview(1, first)
+	newer := preview(2, second)
+	branchHost := "app--git-feature-login.localhost"
+	if s.BranchHost(project, branch) != branchHost {
+		t.Errorf("BranchHost() = %q, want %q", s.BranchHost(project, branch), branchHost)
+	}
+	wantAliases := []string{"http://app--9c1e2b7.localhost:8080", "http://app--git-feature-login.localhost:8080"}
+	if !reflect.DeepEqual(newer.Aliases, wantAliases) {
+		t.Errorf("aliases = %q, want %q", newer.Aliases, wantAliases)
+	}
+	if got := route(branchHost); got != newer.URL {
+		t.Errorf("branch alias routes to %q, want the newest preview %q", got, newer.URL)
+	}
+	if got := route("app--3f4d535.localhost"); got != older.URL {
+		t.Errorf("commit alias of the older preview routes to %q, want %q", got, older.URL)
+	}
+
+	if err := s.TeardownPreview(ctx, project, branch); err != nil {
+		t.Fatalf("TeardownPreview() error = %v", err)
+	}
+	for _, host := range []string{branchHost, "app--3f4d535.localhost", "app--9c1e2b7.localhost"} {
+		if got := route(host); got != "" {
+			t.Errorf("%s still routes to %q after the teardown", host, got)
+		}
+	}
+	for _, id := range []uint64{1, 2} {
+		deployment, err := s.RedisService.GetDeployment(ctx, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if deployment.Status != constants.DeploymentStatusStopped {
+			t.Errorf("deployment %d is %s after the teardown, want stopped", id, deployment.Status)
+		}
+	}
+	if deployments, _ := s.RedisClient.GetList(ctx, branchDeploymentsKey(project.ID, branch)); len(deployments) != 0 {
+		t.Errorf("the branch still lists deployments %q", deployments)
+	}
+}
+
+func TestPreviewRef(t *testing.T) {
+	branch, number := "main", 12
+	tests := []struct {
+		name       string
+		deployment dto.Deployment
+		want       string
+	}{
+		{name: "branch", deployment: dto.Deployment{Branch: &branch}, want: "main"},
+		{name: "pull request", deployment: dto.Deployment{Branch: &branch, PullRequest: &number}, want: "pr-12"},
+		{name: "neither"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := PreviewRef(&tt.deployment); got != tt.want {
+				t.Errorf("PreviewRef() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHostLabel(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"app--git-feature/Login", "app--git-feature-login"},
+		{"--app_1--", "app-1"},
+		{strings.Repeat("a", 62) + "-b", strings.Repeat("a", 62)},
+	}
+	for _, tt := range tests {
+		if got := hostLabel(tt.in); got != tt.want {
+			t.Errorf("hostLabel(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/RajVerma97/golang-vercel/backend/internal/constants"
+	"github.com/RajVerma97/golang-vercel/backend/internal/dto"
+)
+
+// RunRepoStep runs a step of the repository's build config in the project's root directory, with the
+// build env and module settings of the builder, extraEnv describing the build and the step's own env
+// on top. The logs go to result.
+func (a *BuildService) RunRepoStep(ctx context.Context, project *dto.Project, build *dto.Build, tempDirPath string, env *ResolvedEnv, step dto.StepConfig, extraEnv []string, result *dto.StepResult) error {
+	var cache *ProjectCache
+	if step.Cache {
+		var err error
+		if cache, err = a.CacheService.Acquire(project, build); err != nil {
+			return err
+		}
+		defer cache.Unlock()
+	}
+	rootDir := filepath.Join(tempDirPath, project.RootDirectory)
+	builder, err := a.newBuilder(ctx, project, build, tempDirPath, env, cache, project.Build, vendored(rootDir))
+	if err != nil {
+		return err
+	}
+	defer builder.Close()
+	builder.env = overrideEnv(append(builder.env, extraEnv...), step.Env)
+
+	image, platform := step.Image, ""
+	if image == "" {
+		if image, err = a.Toolchains.Image(build.GoVersion, build.CGO); err != nil {
+			return err
+		}
+		platform = builderPlatform(build)
+	}
+	if err := a.DockerClient.PullImage(ctx, image, platform); err != nil {
+		return fmt.Errorf("failed to pull step image %s: %w", image, err)
+	}
+
+	timeout := a.timeoutsConfig.For(constants.BuildPhaseStep, project.Timeouts)
+	if step.Timeout > 0 {
+		timeout = time.Duration(step.Timeout) * time.Second
+	}
+	containerStep := buildStep{phase: constants.BuildPhaseStep, name: step.Name, cmd: step.Run}
+	logs, err := a.runStep(ctx, build, containerStep, image, platform, path.Join("/app", project.RootDirectory), builder, timeout, env)
+	result.Logs = logs
+	return err
+}
+
+// overrideEnv replaces the keys of env that overrides sets
+func overrideEnv(env []string, overrides map[string]string) []string {
+	if len(overrides) == 0 {
+		return env
+	}
+	merged := make([]string, 0, len(env)+len(overrides))
+	for _, pair := range env {
+		key, _, _ := strings.Cut(pair, "=")
+		if _, ok := overrides[key]; !ok {
+			merged = append(merged, pair)
+		}
+	}
+	keys := make([]string, 0, len(overrides))
+	for key := range overrides {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		merged = append(merged, key+"="+overrides[key])
+	}
+	return merged
+}
@@ -0,0 +1,165 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/RajVerma97/golang-vercel/backend/internal/constants"
+	"github.com/RajVerma97/golang-vercel/backend/internal/dto"
+	"github.com/RajVerma97/golang-vercel/backend/internal/logger"
+	"go.uber.org/zap"
+)
+
+// testEvent is a line of go test -json output
+type testEvent struct {
+	Action  string
+	Package string
+	Test    string
+	Elapsed float64
+	Output  string
+}
+
+// TestApplication runs go vet and go test ./... in the built workspace when the project enables
+// the test stage, with the builder image, env and caches of the build. Either failing fails the
+// build with TESTS_FAILED and the summary is kept on build.Tests.
+func (a *BuildService) TestApplication(ctx context.Context, project *dto.Project, build *dto.Build, tempDirPath string, env *ResolvedEnv) error {
+	options := project.Test
+	if !options.Enabled {
+		return nil
+	}
+	logger.Info("Starting Test Phase", zap.Uint64("build_id", build.ID), zap.Bool("race", options.Race))
+	rootDir := filepath.Join(tempDirPath, project.RootDirectory)
+
+	cache, err := a.CacheService.Acquire(project, build)
+	if err != nil {
+		return err
+	}
+	defer cache.Unlock()
+	platformOptions := project.Build
+	// the race detector is built on cgo
+	platformOptions.CGO = platformOptions.CGO || options.Race
+	builder, err := a.newBuilder(ctx, project, build, tempDirPath, env, cache, platformOptions, vendored(rootDir))
+	if err != nil {
+		return err
+	}
+	defer builder.Close()
+
+	image, err := a.Toolchains.Image(build.GoVersion, platformOptions.CGO)
+	if err != nil {
+		return fmt.Errorf("test stage requires %w", err)
+	}
+	// test binaries are run, so the container has the target platform, emulated when the host's differs
+	platform := "linux/" + build.GOARCH
+	if err := a.DockerClient.PullImage(ctx, image, platform); err != nil {
+		return fmt.Errorf("failed to pull test image %s: %w", image, err)
+	}
+
+	report := &dto.TestReport{Race: options.Race}
+	build.Tests = report
+	workDir := path.Join("/app", project.RootDirectory)
+	timeout := a.timeoutsConfig.For(constants.BuildPhaseTest, project.Timeouts)
+
+	vet := buildStep{phase: constants.BuildPhaseTest, name: "vet", cmd: "go vet ./..."}
+	logs, err := a.runStep(ctx, build, vet, image, platform, workDir, builder, timeout, env)
+	build.Logs += logs
+	if err != nil {
+		return testsFailed(err, "go vet found problems:\n"+logs)
+	}
+	report.VetPassed = true
+
+	test := buildStep{phase: constants.BuildPhaseTest, cmd: testCommand(options)}
+	logs, err = a.runStep(ctx, build, test, image, platform, workDir, builder, timeout, env)
+	output, packages := parseTestOutput(logs)
+	build.Logs += output
+	report.Packages = packages
+	if err != nil {
+		return testsFailed(err, testFailureSummary(packages, output))
+	}
+	report.Passed = true
+	logger.Info("Tests passed", zap.Uint64("build_id", build.ID), zap.Int("packages", len(packages)))
+	return nil
+}
+
+func testCommand(options dto.TestOptions) string {
+	cmd := "go test -json"
+	if options.Race {
+		cmd += " -race"
+	}
+	return cmd + " ./..."
+}
+
+// testsFailed reports a vet or test step that exited non-zero as TESTS_FAILED with message,
+// timeouts and OOM kills keep their own code
+func testsFailed(err error, message string) error {
+	var stepErr *stepFailedError
+	if !errors.As(err, &stepErr) {
+		return err
+	}
+	return &FailureError{Code: constants.FailureCodeTestsFailed, Err: errors.New(message)}
+}
+
+// testFailureSummary names the failed packages and tests, or falls back to the output when
+// go test failed before running any, such as on a module that does not load
+func testFailureSummary(packages []dto.PackageResult, output string) string {
+	var failed []string
+	for _, result := range packages {
+		if result.Status != "fail" {
+			continue
+		}
+		if len(result.FailedTests) == 0 {
+			failed = append(failed, result.Package)
+			continue
+		}
+		failed = append(failed, fmt.Sprintf("%s (%s)", result.Package, strings.Join(result.FailedTests, ", ")))
+	}
+	if len(failed) == 0 {
+		return "go test failed:\n" + output
+	}
+	return "tests failed in " + strings.Join(failed, "; ")
+}
+
+// parseTestOutput turns go test -json output back into the plain text go test prints and sums it
+// up per package. Lines that are not events, such as build errors, are kept as they are.
+// Packages that did not finish are left out.
+func parseTestOutput(logs string) (string, []dto.PackageResult) {
+	var output strings.Builder
+	results := map[string]*dto.PackageResult{}
+	for _, line := range strings.SplitAfter(logs, "\n") {
+		var event testEvent
+		if !strings.HasPrefix(line, "{") || json.Unmarshal([]byte(line), &event) != nil {
+			output.WriteString(line)
+			continue
+		}
+		output.WriteString(event.Output)
+		if event.Package == "" {
+			continue
+		}
+		result, ok := results[event.Package]
+		if !ok {
+			result = &dto.PackageResult{Package: event.Package}
+			results[event.Package] = result
+		}
+		switch {
+		case event.Test != "" && event.Action == "fail":
+			result.FailedTests = append(result.FailedTests, event.Test)
+		case event.Test == "" && (event.Action == "pass" || event.Action == "fail" || event.Action == "skip"):
+			result.Status = event.Action
+			result.Elapsed = event.Elapsed
+		}
+	}
+
+	packages := make([]dto.PackageResult, 0, len(results))
+	for _, result := range results {
+		if result.Status != "" {
+			packages = append(packages, *result)
+		}
+	}
+	sort.Slice(packages, func(i, j int) bool { return packages[i].Package < packages[j].Package })
+	return output.String(), packages
+}
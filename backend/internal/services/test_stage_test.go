@@ -0,0 +1,114 @@
+package services
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/RajVerma97/golang-vercel/backend/internal/dto"
+)
+
+func TestParseTestOutput(t *testing.T) {
+	tests := []struct {
+		name         string
+		logs         string
+		wantOutput   string
+		wantPackages []dto.PackageResult
+	}{
+		{
+			name:         "empty",
+			wantPackages: []dto.PackageResult{},
+		},
+		{
+			name: "passing and failing packages",
+			logs: `{"Action":"start","Package":"example.com/app/b"}
+{"Action":"run","Package":"example.com/app/b","Test":"TestB"}
+{"Action":"output","Package":"example.com/app/b","Test":"TestB","Output":"--- FAIL: TestB (0.00s)\n"}
+{"Action":"fail","Package":"example.com/app/b","Test":"TestB","Elapsed":0}
+{"Action":"output","Package":"example.com/app/b","Output":"FAIL\texample.com/app/b\t0.012s\n"}
+{"Action":"fail","Package":"example.com/app/b","Elapsed":0.012}
+{"Action":"output","Package":"example.com/app/a","Output":"ok  \texample.com/app/a\t0.003s\n"}
+{"Action":"pass","Package":"example.com/app/a","Elapsed":0.003}
+`,
+			wantOutput: "--- FAIL: TestB (0.00s)\nFAIL\texample.com/app/b\t0.012s\nok  \texample.com/app/a\t0.003s\n",
+			wantPackages: []dto.PackageResult{
+				{Package: "example.com/app/a", Status: "pass", Elapsed: 0.003},
+				{Package: "example.com/app/b", Status: "fail", Elapsed: 0.012, FailedTests: []string{"TestB"}},
+			},
+		},
+		{
+			name: "package without tests",
+			logs: `{"Action":"output","Package":"example.com/app/cmd","Output":"?   \texample.com/app/cmd\t[no test files]\n"}
+{"Action":"skip","Package":"example.com/app/cmd","Elapsed":0}
+`,
+			wantOutput:   "?   \texample.com/app/cmd\t[no test files]\n",
+			wantPackages: []dto.PackageResult{{Package: "example.com/app/cmd", Status: "skip"}},
+		},
+		{
+			name: "build errors are kept",
+			logs: `# example.com/app/c
+c/c.go:3:1: syntax error: unexpected }
+{"Action":"output","Package":"example.com/app/c","Output":"FAIL\texample.com/app/c [build failed]\n"}
+{"Action":"fail","Package":"example.com/app/c","Elapsed":0}
+`,
+			wantOutput:   "# example.com/app/c\nc/c.go:3:1: syntax error: unexpected }\nFAIL\texample.com/app/c [build failed]\n",
+			wantPackages: []dto.PackageResult{{Package: "example.com/app/c", Status: "fail"}},
+		},
+		{
+			name: "unfinished package",
+			logs: `{"Action":"run","Package":"example.com/app/d","Test":"TestD"}
+{"Action":"output","Package":"example.com/app/d","Test":"TestD","Output":"=== RUN   TestD\n"}
+`,
+			wantOutput:   "=== RUN   TestD\n",
+			wantPackages: []dto.PackageResult{},
+		},
+		{
+			name:         "broken event",
+			logs:         "{\"Action\":\n",
+			wantOutput:   "{\"Action\":\n",
+			wantPackages: []dto.PackageResult{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			output, packages := parseTestOutput(tt.logs)
+			if output != tt.wantOutput {
+				t.Errorf("parseTestOutput() output = %q, want %q", output, tt.wantOutput)
+			}
+			if !reflect.DeepEqual(packages, tt.wantPackages) {
+				t.Errorf("parseTestOutput() packages = %+v, want %+v", packages, tt.wantPackages)
+			}
+		})
+	}
+}
+
+func TestTestFailureSummary(t *testing.T) {
+	tests := []struct {
+		name     string
+		packages []dto.PackageResult
+		output   string
+		want     string
+	}{
+		{
+			name: "failed tests",
+			packages: []dto.PackageResult{
+				{Package: "example.com/app/a", Status: "pass"},
+				{Package: "example.com/app/b", Status: "fail", FailedTests: []string{"TestB", "TestB/sub"}},
+				{Package: "example.com/app/c", Status: "fail"},
+			},
+			want: "tests failed in example.com/app/b (TestB, TestB/sub); example.com/app/c",
+		},
+		{
+			name:     "no failed package",
+			packages: []dto.PackageResult{{Package: "example.com/app/a", Status: "pass"}},
+			output:   "go: updates to go.mod needed\n",
+			want:     "go test failed:\ngo: updates to go.mod needed\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := testFailureSummary(tt.packages, tt.output); got != tt.want {
+				t.Errorf("testFailureSummary() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
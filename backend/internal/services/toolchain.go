@@ -0,0 +1,191 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go/version"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/RajVerma97/golang-vercel/backend/internal/config"
+	"github.com/RajVerma97/golang-vercel/backend/internal/logger"
+	"go.uber.org/zap"
+	"golang.org/x/mod/modfile"
+)
+
+var ErrUnknownGoVersion = errors.New("go version is not available")
+
+// defaultBuilderImages and defaultCgoBuilderImages are the catalogs without GO_BUILDER_CATALOG
+// and GO_BUILDER_CATALOG_CGO. They name patch releases, which Pin resolves to digests at startup.
+var (
+	defaultBuilderImages = builderImages{
+		"1.21.13": "golang:1.21.13-alpine",
+		"1.22.12": "golang:1.22.12-alpine",
+		"1.23.12": "golang:1.23.12-alpine",
+		"1.24.7":  "golang:1.24.7-alpine",
+		"1.25.1":  "golang:1.25.1-alpine",
+	}
+	// cgo builds link against glibc, alpine's musl binaries would not run on the glibc runtime image
+	defaultCgoBuilderImages = builderImages{
+		"1.21.13": "golang:1.21.13-bookworm",
+		"1.22.12": "golang:1.22.12-bookworm",
+		"1.23.12": "golang:1.23.12-bookworm",
+		"1.24.7":  "golang:1.24.7-bookworm",
+		"1.25.1":  "golang:1.25.1-bookworm",
+	}
+)
+
+// builderImages maps Go versions, either a release such as 1.22.3 or a language version such as 1.22,
+// to the builder image that provides them. A language version stands for its first release, 1.22.0.
+type builderImages map[string]string
+
+// imagePinner resolves an image to the digest the registry serves for it
+type imagePinner interface {
+	PinImage(ctx context.Context, imageName string) (string, error)
+}
+
+// ToolchainCatalog holds the builder images of static and of cgo builds
+type ToolchainCatalog struct {
+	images         builderImages
+	cgoImages      builderImages
+	defaultVersion string
+}
+
+// LoadToolchainCatalog reads the catalog files, falling back to the built-in catalogs
+func LoadToolchainCatalog(config *config.ToolchainConfig) (*ToolchainCatalog, error) {
+	images, err := loadBuilderImages(config.CatalogPath, defaultBuilderImages)
+	if err != nil {
+		return nil, err
+	}
+	cgoImages, err := loadBuilderImages(config.CgoCatalogPath, defaultCgoBuilderImages)
+	if err != nil {
+		return nil, err
+	}
+
+	catalog := &ToolchainCatalog{images: images, cgoImages: cgoImages, defaultVersion: config.DefaultVersion}
+	if _, err := catalog.Image(config.DefaultVersion, false); err != nil {
+		return nil, fmt.Errorf("default go version: %w", err)
+	}
+	return catalog, nil
+}
+
+func loadBuilderImages(path string, defaults builderImages) (builderImages, error) {
+	images := defaults
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read builder catalog: %w", err)
+		}
+		images = nil
+		if err := json.Unmarshal(data, &images); err != nil {
+			return nil, fmt.Errorf("invalid builder catalog %s: %w", path, err)
+		}
+	}
+	pinned := make(builderImages, len(images))
+	for goVersion, image := range images {
+		if !goVersionPattern.MatchString(goVersion) {
+			return nil, fmt.Errorf("invalid builder catalog: %q is not a Go version", goVersion)
+		}
+		pinned[goVersion] = image
+	}
+	return pinned, nil
+}
+
+// Pin resolves the images that are not pinned by digest yet, so that a tag moving in the registry
+// does not change what builds run on. Images that cannot be resolved keep their tag.
+func (c *ToolchainCatalog) Pin(ctx context.Context, pinner imagePinner) {
+	for _, images := range []builderImages{c.images, c.cgoImages} {
+		for goVersion, image := range images {
+			if strings.Contains(image, "@sha256:") {
+				continue
+			}
+			pinned, err := pinner.PinImage(ctx, image)
+			if err != nil {
+				logger.Warn("Builder image is not pinned by digest", zap.String("go_version", goVersion), zap.String("image", image), zap.Error(err))
+				continue
+			}
+			images[goVersion] = pinned
+		}
+	}
+}
+
+// Versions lists the versions in the catalog, oldest first
+func (c builderImages) Versions() []string {
+	versions := make([]string, 0, len(c))
+	for goVersion := range c {
+		versions = append(versions, goVersion)
+	}
+	sort.Slice(versions, func(i, j int) bool { return version.Compare("go"+versions[i], "go"+versions[j]) < 0 })
+	return versions
+}
+
+// Image returns the builder image for goVersion, with glibc and a C toolchain when cgo is set.
+// The exact release is preferred, otherwise the newest release of the same language version that
+// is not older than goVersion, since GOTOOLCHAIN=local refuses to build with an older one.
+func (c *ToolchainCatalog) Image(goVersion string, cgo bool) (string, error) {
+	images, kind := c.images, ""
+	if cgo {
+		images, kind = c.cgoImages, " with cgo"
+	}
+	if image, ok := images[goVersion]; ok {
+		return image, nil
+	}
+	if lang := version.Lang("go" + goVersion); lang != "" {
+		best := ""
+		for _, candidate := range images.Versions() {
+			if version.Lang("go"+candidate) == lang && version.Compare("go"+candidate, "go"+goVersion) >= 0 {
+				best = candidate
+			}
+		}
+		if best != "" {
+			return images[best], nil
+		}
+	}
+	return "", fmt.Errorf("%w: go %s%s, available versions are %s", ErrUnknownGoVersion, goVersion, kind, strings.Join(images.Versions(), ", "))
+}
+
+// goModVersion returns the Go version go.mod in dir asks for, the newer of its go and toolchain
+// directives, and "" when there is no go.mod or it does not say
+func goModVersion(dir string) (string, error) {
+	path := filepath.Join(dir, "go.mod")
+	info, err := os.Lstat(path)
+	if os.IsNotExist(err) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to stat go.mod: %w", err)
+	}
+	// parse errors quote the file, which must not be a symlink to somewhere on the host
+	if !info.Mode().IsRegular() {
+		return "", fmt.Errorf("go.mod must be a regular file")
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read go.mod: %w", err)
+	}
+	// lax parsing keeps working with directives newer than x/mod, but skips toolchain
+	file, err := modfile.ParseLax(path, data, nil)
+	if err != nil {
+		return "", fmt.Errorf("invalid go.mod: %w", err)
+	}
+
+	goVersion := ""
+	if file.Go != nil {
+		goVersion = "go" + file.Go.Version
+	}
+	for _, stmt := range file.Syntax.Stmt {
+		line, ok := stmt.(*modfile.Line)
+		if !ok || len(line.Token) != 2 || line.Token[0] != "toolchain" {
+			continue
+		}
+		// toolchain default means the go directive decides
+		if toolchain := line.Token[1]; version.IsValid(toolchain) && version.Compare(toolchain, goVersion) > 0 {
+			goVersion = toolchain
+		}
+	}
+	return strings.TrimPrefix(goVersion, "go"), nil
+}
@@ -0,0 +1,139 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestGoModVersion(t *testing.T) {
+	tests := []struct {
+		name    string
+		goMod   string
+		want    string
+		wantErr bool
+	}{
+		{name: "no go.mod"},
+		{name: "no go directive", goMod: "module example.com/app\n"},
+		{name: "language version", goMod: "module example.com/app\n\ngo 1.22\n", want: "1.22"},
+		{name: "release", goMod: "module example.com/app\n\ngo 1.23.4\n", want: "1.23.4"},
+		{name: "newer toolchain", goMod: "module example.com/app\n\ngo 1.23.0\n\ntoolchain go1.24.2\n", want: "1.24.2"},
+		{name: "older toolchain", goMod: "module example.com/app\n\ngo 1.24.1\n\ntoolchain go1.23.8\n", want: "1.24.1"},
+		{name: "toolchain default", goMod: "module example.com/app\n\ngo 1.24.1\n\ntoolchain default\n", want: "1.24.1"},
+		{name: "invalid", goMod: "module example.com/app\n\ngo one\n", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			if tt.goMod != "" {
+				writeFile(t, filepath.Join(dir, "go.mod"), tt.goMod)
+			}
+			got, err := goModVersion(dir)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("goModVersion() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("goModVersion() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGoModVersionSymlink(t *testing.T) {
+	dir := t.TempDir()
+	outside := filepath.Join(t.TempDir(), "go.mod")
+	writeFile(t, outside, "module example.com/app\n\ngo 1.24\n")
+	if err := os.Symlink(outside, filepath.Join(dir, "go.mod")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := goModVersion(dir); err == nil {
+		t.Error("goModVersion() followed a symlinked go.mod")
+	}
+}
+
+func TestToolchainCatalogImage(t *testing.T) {
+	catalog := &ToolchainCatalog{
+		images: builderImages{
+			"1.22":    "golang:1.22",
+			"1.23.2":  "golang:1.23.2",
+			"1.23.12": "golang:1.23.12",
+			"1.24.7":  "golang:1.24.7",
+		},
+		cgoImages: builderImages{"1.24.7": "golang:1.24.7-bookworm"},
+	}
+	tests := []struct {
+		name      string
+		goVersion string
+		cgo       bool
+		want      string
+		wantErr   bool
+	}{
+		{name: "exact release", goVersion: "1.23.2", want: "golang:1.23.2"},
+		{name: "exact language version", goVersion: "1.22", want: "golang:1.22"},
+		{name: "newer patch release", goVersion: "1.23.4", want: "golang:1.23.12"},
+		{name: "newest release of language version", goVersion: "1.23", want: "golang:1.23.12"},
+		{name: "older patch release only", goVersion: "1.24.9", wantErr: true},
+		{name: "language version image is older than release", goVersion: "1.22.5", wantErr: true},
+		{name: "unknown language version", goVersion: "1.21.3", wantErr: true},
+		{name: "cgo", goVersion: "1.24", cgo: true, want: "golang:1.24.7-bookworm"},
+		{name: "cgo unknown", goVersion: "1.23.2", cgo: true, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := catalog.Image(tt.goVersion, tt.cgo)
+			if tt.wantErr {
+				if !errors.Is(err, ErrUnknownGoVersion) {
+					t.Fatalf("Image() error = %v, want ErrUnknownGoVersion", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Image() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Image() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+type fakePinner map[string]string
+
+func (p fakePinner) PinImage(_ context.Context, imageName string) (string, error) {
+	digest, ok := p[imageName]
+	if !ok {
+		return "", errors.New("not found")
+	}
+	return imageName + "@" + digest, nil
+}
+
+func TestToolchainCatalogPin(t *testing.T) {
+	catalog := &ToolchainCatalog{
+		images: builderImages{
+			"1.23.12": "golang:1.23.12-alpine",
+			"1.24.7":  "golang:1.24.7-alpine@sha256:pinned",
+			"1.25.1":  "golang:1.25.1-alpine",
+		},
+		cgoImages: builderImages{"1.24.7": "golang:1.24.7-bookworm"},
+	}
+	catalog.Pin(context.Background(), fakePinner{
+		"golang:1.23.12-alpine":  "sha256:alpine",
+		"golang:1.24.7-bookworm": "sha256:bookworm",
+	})
+
+	want := map[string]string{
+		"1.23.12": "golang:1.23.12-alpine@sha256:alpine",
+		"1.24.7":  "golang:1.24.7-alpine@sha256:pinned",
+		"1.25.1":  "golang:1.25.1-alpine",
+	}
+	for goVersion, image := range want {
+		if got := catalog.images[goVersion]; got != image {
+			t.Errorf("images[%s] = %q, want %q", goVersion, got, image)
+		}
+	}
+	if got := catalog.cgoImages["1.24.7"]; got != "golang:1.24.7-bookworm@sha256:bookworm" {
+		t.Errorf("cgoImages[1.24.7] = %q", got)
+	}
+}
@@ -0,0 +1,132 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	redis_client "github.com/RajVerma97/golang-vercel/backend/internal/client/redis"
+	"github.com/RajVerma97/golang-vercel/backend/internal/config"
+	"github.com/RajVerma97/golang-vercel/backend/internal/dto"
+	"github.com/RajVerma97/golang-vercel/backend/internal/logger"
+	"go.uber.org/zap"
+)
+
+// webhookDeliveriesKey lists recent deliveries, newest first
+const webhookDeliveriesKey = "webhook:deliveries"
+
+func webhookDeliveryKey(provider, deliveryID string) string {
+	return fmt.Sprintf("webhook:delivery:%s:%s", provider, deliveryID)
+}
+
+// webhookDeliveryBuildsKey maps the projects a delivery queued builds for to the builds' ids
+func webhookDeliveryBuildsKey(provider, deliveryID string) string {
+	return webhookDeliveryKey(provider, deliveryID) + ":builds"
+}
+
+type WebhookDeliveryServiceConfig struct {
+	RedisClient   *redis_client.RedisClient
+	WebhookConfig *config.WebhookConfig
+}
+
+// WebhookDeliveryService drops redelivered webhooks and keeps a history of what each delivery did
+type WebhookDeliveryService struct {
+	RedisClient   *redis_client.RedisClient
+	webhookConfig *config.WebhookConfig
+}
+
+func NewWebhookDeliveryService(config *WebhookDeliveryServiceConfig) *WebhookDeliveryService {
+	return &WebhookDeliveryService{
+		RedisClient:   config.RedisClient,
+		webhookConfig: config.WebhookConfig,
+	}
+}
+
+// Claim reports whether the delivery is seen for the first time.
+// Deliveries without an id cannot be deduplicated and are always claimed.
+func (s *WebhookDeliveryService) Claim(ctx context.Context, provider, deliveryID string) (bool, error) {
+	if deliveryID == "" {
+		return true, nil
+	}
+	return s.RedisClient.SetIfAbsent(ctx, webhookDeliveryKey(provider, deliveryID), "1", s.webhookConfig.DeliveryTTL)
+}
+
+// Release forgets a claimed delivery so that a redelivery is processed again
+func (s *WebhookDeliveryService) Release(ctx context.Context, provider, deliveryID string) error {
+	if deliveryID == "" {
+		return nil
+	}
+	return s.RedisClient.Delete(ctx, webhookDeliveryKey(provider, deliveryID))
+}
+
+// RecordQueued remembers that the delivery queued buildID for projectID. It outlives Release,
+// so the redelivery of a delivery that failed half way through only queues the projects that are missing.
+func (s *WebhookDeliveryService) RecordQueued(ctx context.Context, provider, deliveryID string, projectID, buildID uint64) error {
+	if deliveryID == "" {
+		return nil
+	}
+	key := webhookDeliveryBuildsKey(provider, deliveryID)
+	return s.RedisClient.SetHashFieldWithTTL(ctx, key, strconv.FormatUint(projectID, 10), strconv.FormatUint(buildID, 10), s.webhookConfig.DeliveryTTL)
+}
+
+// Queued returns the builds earlier attempts at the delivery queued, by project id
+func (s *WebhookDeliveryService) Queued(ctx context.Context, provider, deliveryID string) (map[uint64]uint64, error) {
+	queued := make(map[uint64]uint64)
+	if deliveryID == "" {
+		return queued, nil
+	}
+	values, err := s.RedisClient.GetHash(ctx, webhookDeliveryBuildsKey(provider, deliveryID))
+	if err != nil {
+		return nil, err
+	}
+	for project, build := range values {
+		projectID, err := strconv.ParseUint(project, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid project id %q queued by delivery %s: %w", project, deliveryID, err)
+		}
+		buildID, err := strconv.ParseUint(build, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid build id %q queued by delivery %s: %w", build, deliveryID, err)
+		}
+		queued[projectID] = buildID
+	}
+	return queued, nil
+}
+
+// Record adds the delivery to the history. Failures are logged since the history is only for debugging.
+func (s *WebhookDeliveryService) Record(ctx context.Context, delivery *dto.WebhookDelivery) {
+	data, err := json.Marshal(delivery)
+	if err != nil {
+		logger.Error("failed to marshal webhook delivery", err)
+		return
+	}
+	if err := s.RedisClient.PushList(ctx, webhookDeliveriesKey, string(data), int64(s.webhookConfig.HistorySize)); err != nil {
+		logger.Error("failed to record webhook delivery", err, zap.String("delivery_id", delivery.DeliveryID))
+	}
+}
+
+// List returns recent deliveries, newest first, optionally only those of provider
+func (s *WebhookDeliveryService) List(ctx context.Context, provider string, limit int) ([]*dto.WebhookDelivery, error) {
+	values, err := s.RedisClient.GetList(ctx, webhookDeliveriesKey)
+	if err != nil {
+		return nil, err
+	}
+
+	deliveries := make([]*dto.WebhookDelivery, 0, len(values))
+	for _, value := range values {
+		var delivery dto.WebhookDelivery
+		if err := json.Unmarshal([]byte(value), &delivery); err != nil {
+			logger.Warn("Skipping malformed webhook delivery", zap.Error(err))
+			continue
+		}
+		if provider != "" && delivery.Provider != provider {
+			continue
+		}
+		deliveries = append(deliveries, &delivery)
+		if limit > 0 && len(deliveries) == limit {
+			break
+		}
+	}
+	return deliveries, nil
+}
@@ -0,0 +1,68 @@
+package services
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/RajVerma97/golang-vercel/backend/internal/config"
+)
+
+func TestWebhookDeliveryServiceRedelivery(t *testing.T) {
+	ctx := context.Background()
+	s := NewWebhookDeliveryService(&WebhookDeliveryServiceConfig{
+		RedisClient:   newTestRedis(t),
+		WebhookConfig: &config.WebhookConfig{DeliveryTTL: time.Hour, HistorySize: 10},
+	})
+	claim := func(deliveryID string) bool {
+		t.Helper()
+		first, err := s.Claim(ctx, "github", deliveryID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return first
+	}
+
+	if !claim("d1") {
+		t.Fatal("Claim() = false for a new delivery")
+	}
+	if claim("d1") {
+		t.Error("Claim() = true for a redelivery")
+	}
+	if !claim("d1-other") {
+		t.Error("Claim() = false for another delivery")
+	}
+	if first, _ := s.Claim(ctx, "gitlab", "d1"); !first {
+		t.Error("Claim() = false for the same id from another provider")
+	}
+
+	// a delivery that failed half way through is released with the builds it queued still recorded
+	if err := s.RecordQueued(ctx, "github", "d1", 1, 10); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Release(ctx, "github", "d1"); err != nil {
+		t.Fatal(err)
+	}
+	if !claim("d1") {
+		t.Error("Claim() = false for a released delivery")
+	}
+	queued, err := s.Queued(ctx, "github", "d1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := map[uint64]uint64{1: 10}; !reflect.DeepEqual(queued, want) {
+		t.Errorf("Queued() = %v after the release, want %v", queued, want)
+	}
+
+	// deliveries without an id are neither deduplicated nor recorded
+	if !claim("") || !claim("") {
+		t.Error("Claim() = false for a delivery without an id")
+	}
+	if err := s.RecordQueued(ctx, "github", "", 1, 10); err != nil {
+		t.Fatal(err)
+	}
+	if queued, _ := s.Queued(ctx, "github", ""); len(queued) != 0 {
+		t.Errorf("Queued() = %v for a delivery without an id, want none", queued)
+	}
+}
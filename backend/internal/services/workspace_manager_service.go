@@ -3,20 +3,29 @@ package services
 import (
 	"context"
 	"fmt"
+	"io"
+	"io/fs"
 	"os"
+	"path"
+	"path/filepath"
 
+	"github.com/RajVerma97/golang-vercel/backend/internal/config"
 	"github.com/RajVerma97/golang-vercel/backend/internal/dto"
 	"github.com/RajVerma97/golang-vercel/backend/internal/logger"
 	"go.uber.org/zap"
 )
 
 type WorkspaceManagerServiceConfig struct {
+	StorageConfig *config.StorageConfig
 }
 type WorkspaceManagerService struct {
+	storageConfig *config.StorageConfig
 }
 
 func NewWorkspaceManagerService(config *WorkspaceManagerServiceConfig) *WorkspaceManagerService {
-	return &WorkspaceManagerService{}
+	return &WorkspaceManagerService{
+		storageConfig: config.StorageConfig,
+	}
 }
 
 func (s *WorkspaceManagerService) InitializeEnvironment(ctx context.Context, build *dto.Build, tempDirPath string) error {
@@ -50,3 +59,120 @@ func (s *WorkspaceManagerService) Cleanup(ctx context.Context, tempDirPath strin
 	}
 	return nil
 }
+
+// RetainArtifact copies the build's binary out of the workspace so deployments outlive the workspace
+// and can be restarted later without a rebuild. The binary is opened inside rootDir, a symlink cannot
+// point it at a file elsewhere on the host.
+func (s *WorkspaceManagerService) RetainArtifact(ctx context.Context, build *dto.Build, rootDir string) error {
+	if build.BinaryPath == nil {
+		return fmt.Errorf("build %d has no binary to retain", build.ID)
+	}
+	rel, err := filepath.Rel(rootDir, *build.BinaryPath)
+	if err != nil {
+		return fmt.Errorf("binary %s is outside the root directory", *build.BinaryPath)
+	}
+	root, err := os.OpenRoot(rootDir)
+	if err != nil {
+		return fmt.Errorf("failed to open root directory: %w", err)
+	}
+	defer root.Close()
+	info, err := root.Stat(rel)
+	if err != nil {
+		return fmt.Errorf("failed to stat binary: %w", err)
+	}
+	if !info.Mode().IsRegular() {
+		return fmt.Errorf("binary %s must be a regular file", filepath.ToSlash(rel))
+	}
+
+	artifactDir := filepath.Join(s.storageConfig.ArtifactsDir(), fmt.Sprintf("build-%d", build.ID))
+	if err := os.MkdirAll(artifactDir, 0755); err != nil {
+		return fmt.Errorf("failed to create artifact directory:%w", err)
+	}
+	artifactPath := filepath.Join(artifactDir, "app")
+	if err := copyFile(root.FS(), filepath.ToSlash(rel), artifactPath, 0755); err != nil {
+		logger.Error("Failed to retain artifact", err, zap.String("path", artifactPath))
+		return fmt.Errorf("failed to retain artifact:%w", err)
+	}
+	build.BinaryPath = &artifactPath
+	logger.Debug("Retained build artifact", zap.String("path", artifactPath))
+	return nil
+}
+
+// RetainStepArtifacts copies the paths a pipeline step lists, relative to rootDir, out of the workspace.
+// They are kept apart from the binary, whose directory deployments mount. The workspace is untrusted,
+// so paths are resolved inside rootDir, where symlinks cannot lead out of it, symlinks among the
+// artifacts are not copied and only regular files and directories are.
+func (s *WorkspaceManagerService) RetainStepArtifacts(ctx context.Context, build *dto.Build, step, rootDir string, paths []string) ([]string, error) {
+	if len(paths) == 0 {
+		return nil, nil
+	}
+	root, err := os.OpenRoot(rootDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open root directory: %w", err)
+	}
+	defer root.Close()
+
+	stepDir := filepath.Join(s.storageConfig.ArtifactsDir(), fmt.Sprintf("build-%d-steps", build.ID), step)
+	var retained []string
+	for _, artifact := range paths {
+		info, err := root.Lstat(filepath.FromSlash(artifact))
+		if os.IsNotExist(err) {
+			return retained, fmt.Errorf("artifact %s was not created", artifact)
+		}
+		if err != nil {
+			return retained, fmt.Errorf("failed to stat artifact %s: %w", artifact, err)
+		}
+		if !info.Mode().IsRegular() && !info.IsDir() {
+			return retained, fmt.Errorf("artifact %s must be a regular file or a directory", artifact)
+		}
+		if err := copyTree(root.FS(), path.Clean(artifact), filepath.Join(stepDir, filepath.FromSlash(artifact))); err != nil {
+			logger.Error("Failed to retain step artifact", err, zap.String("step", step), zap.String("artifact", artifact))
+			return retained, fmt.Errorf("failed to retain artifact %s: %w", artifact, err)
+		}
+		retained = append(retained, artifact)
+	}
+	logger.Debug("Retained step artifacts", zap.String("step", step), zap.Strings("artifacts", retained))
+	return retained, nil
+}
+
+// copyTree copies the regular files and directories below src in fsys to dst, skipping everything else
+func copyTree(fsys fs.FS, src, dst string) error {
+	return fs.WalkDir(fsys, src, func(p string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, p)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+		switch {
+		case entry.IsDir():
+			return os.MkdirAll(target, 0755)
+		case entry.Type().IsRegular():
+			if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+				return err
+			}
+			return copyFile(fsys, p, target, 0644)
+		}
+		return nil
+	})
+}
+
+func copyFile(fsys fs.FS, src, dst string, perm os.FileMode) error {
+	in, err := fsys.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
@@ -0,0 +1,189 @@
+package services
+
+import (
+	"context"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/RajVerma97/golang-vercel/backend/internal/config"
+	"github.com/RajVerma97/golang-vercel/backend/internal/dto"
+)
+
+func TestRetainArtifact(t *testing.T) {
+	outside := filepath.Join(t.TempDir(), "host-file")
+	if err := os.WriteFile(outside, []byte("host"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name    string
+		setup   func(t *testing.T, rootDir string)
+		wantErr bool
+	}{
+		{
+			name: "binary",
+			setup: func(t *testing.T, rootDir string) {
+				writeFile(t, filepath.Join(rootDir, "bin", "app"), "binary")
+			},
+		},
+		{
+			name: "symlink to a file in the workspace",
+			setup: func(t *testing.T, rootDir string) {
+				writeFile(t, filepath.Join(rootDir, "build", "server"), "binary")
+				symlink(t, "../build/server", filepath.Join(rootDir, "bin", "app"))
+			},
+		},
+		{
+			name: "symlink out of the workspace",
+			setup: func(t *testing.T, rootDir string) {
+				symlink(t, outside, filepath.Join(rootDir, "bin", "app"))
+			},
+			wantErr: true,
+		},
+		{
+			name: "directory symlinked out of the workspace",
+			setup: func(t *testing.T, rootDir string) {
+				symlink(t, filepath.Dir(outside), filepath.Join(rootDir, "bin"))
+				if err := os.Rename(outside, filepath.Join(filepath.Dir(outside), "app")); err != nil {
+					t.Fatal(err)
+				}
+				t.Cleanup(func() { os.Rename(filepath.Join(filepath.Dir(outside), "app"), outside) })
+			},
+			wantErr: true,
+		},
+		{
+			name: "directory",
+			setup: func(t *testing.T, rootDir string) {
+				if err := os.MkdirAll(filepath.Join(rootDir, "bin", "app"), 0755); err != nil {
+					t.Fatal(err)
+				}
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rootDir := t.TempDir()
+			tt.setup(t, rootDir)
+			s := NewWorkspaceManagerService(&WorkspaceManagerServiceConfig{StorageConfig: &config.StorageConfig{DataDir: t.TempDir()}})
+			binaryPath := filepath.Join(rootDir, "bin", "app")
+			build := &dto.Build{ID: 1, BinaryPath: &binaryPath}
+
+			err := s.RetainArtifact(context.Background(), build, rootDir)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("RetainArtifact() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			data, err := os.ReadFile(*build.BinaryPath)
+			if err != nil || string(data) != "binary" {
+				t.Errorf("retained %q, %v, want the binary", data, err)
+			}
+		})
+	}
+}
+
+func writeFile(t *testing.T, name, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(name, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func symlink(t *testing.T, target, name string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(target, name); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRetainStepArtifacts(t *testing.T) {
+	outsideDir := t.TempDir()
+	writeFile(t, filepath.Join(outsideDir, "secret"), "host")
+	tests := []struct {
+		name    string
+		setup   func(t *testing.T, rootDir string)
+		paths   []string
+		want    []string
+		wantErr bool
+	}{
+		{
+			name: "files and directories",
+			setup: func(t *testing.T, rootDir string) {
+				writeFile(t, filepath.Join(rootDir, "report.xml"), "report")
+				writeFile(t, filepath.Join(rootDir, "coverage", "index.html"), "coverage")
+			},
+			paths: []string{"report.xml", "coverage"},
+			want:  []string{"report.xml", "coverage/index.html"},
+		},
+		{
+			name: "symlinks inside a directory are skipped",
+			setup: func(t *testing.T, rootDir string) {
+				writeFile(t, filepath.Join(rootDir, "coverage", "index.html"), "coverage")
+				symlink(t, filepath.Join(outsideDir, "secret"), filepath.Join(rootDir, "coverage", "secret"))
+				symlink(t, outsideDir, filepath.Join(rootDir, "coverage", "host"))
+			},
+			paths: []string{"coverage"},
+			want:  []string{"coverage/index.html"},
+		},
+		{
+			name: "symlinked artifact",
+			setup: func(t *testing.T, rootDir string) {
+				symlink(t, filepath.Join(outsideDir, "secret"), filepath.Join(rootDir, "report.xml"))
+			},
+			paths:   []string{"report.xml"},
+			wantErr: true,
+		},
+		{
+			name: "artifact below a symlinked directory",
+			setup: func(t *testing.T, rootDir string) {
+				symlink(t, outsideDir, filepath.Join(rootDir, "out"))
+			},
+			paths:   []string{"out/secret"},
+			wantErr: true,
+		},
+		{
+			name:    "missing artifact",
+			setup:   func(t *testing.T, rootDir string) {},
+			paths:   []string{"report.xml"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rootDir := t.TempDir()
+			tt.setup(t, rootDir)
+			storage := &config.StorageConfig{DataDir: t.TempDir()}
+			s := NewWorkspaceManagerService(&WorkspaceManagerServiceConfig{StorageConfig: storage})
+
+			_, err := s.RetainStepArtifacts(context.Background(), &dto.Build{ID: 1}, "lint", rootDir, tt.paths)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("RetainStepArtifacts() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			stepDir := filepath.Join(storage.ArtifactsDir(), "build-1-steps", "lint")
+			var got []string
+			filepath.WalkDir(stepDir, func(path string, entry fs.DirEntry, err error) error {
+				if err == nil && !entry.IsDir() {
+					rel, _ := filepath.Rel(stepDir, path)
+					got = append(got, filepath.ToSlash(rel))
+				}
+				return nil
+			})
+			sort.Strings(got)
+			sort.Strings(tt.want)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("retained %q, want %q", got, tt.want)
+			}
+		})
+	}
+}